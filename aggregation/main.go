@@ -1,88 +1,12 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"os"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/aggregation"
 )
 
-// Podcast represents the schema for the "Podcasts" collection
-type Podcast struct {
-	ID     primitive.ObjectID `bson:"_id,omitempty"`
-	Title  string             `bson:"title,omitempty"`
-	Author string             `bson:"author,omitempty"`
-	Tags   []string           `bson:"tags,omitempty"`
-}
-
-// Episode represents the schema for the "Episodes" collection
-type Episode struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
-	Title       string             `bson:"title,omitempty"`
-	Description string             `bson:"description,omitempty"`
-	Duration    int32              `bson:"duration,omitempty"`
-}
-
-// PodcastEpisode represents an aggregation result-set for two collections
-type PodcastEpisode struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	Podcast     Podcast            `bson:"podcast,omitempty"`
-	Title       string             `bson:"title,omitempty"`
-	Description string             `bson:"description,omitempty"`
-	Duration    int32              `bson:"duration,omitempty"`
-}
-
 func main() {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("ATLAS_URI")))
-	if err != nil {
-		panic(err)
-	}
-	defer client.Disconnect(ctx)
-
-	database := client.Database("quickstart")
-	episodesCollection := database.Collection("episodes")
-
-	id, _ := primitive.ObjectIDFromHex("5e3b37e51c9d4400004117e6")
-
-	matchStage := bson.D{{"$match", bson.D{{"podcast", id}}}}
-	groupStage := bson.D{{"$group", bson.D{{"_id", "$podcast"}, {"total", bson.D{{"$sum", "$duration"}}}}}}
-	showInfoCursor, err := episodesCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage})
-	if err != nil {
-		panic(err)
-	}
-	var showsWithInfo []bson.M
-	if err = showInfoCursor.All(ctx, &showsWithInfo); err != nil {
-		panic(err)
-	}
-	fmt.Println(showsWithInfo)
-
-	lookupStage := bson.D{{"$lookup", bson.D{{"from", "podcasts"}, {"localField", "podcast"}, {"foreignField", "_id"}, {"as", "podcast"}}}}
-	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$podcast"}, {"preserveNullAndEmptyArrays", false}}}}
-
-	showLoadedCursor, err := episodesCollection.Aggregate(ctx, mongo.Pipeline{lookupStage, unwindStage})
-	if err != nil {
-		panic(err)
-	}
-	var showsLoaded []bson.M
-	if err = showLoadedCursor.All(ctx, &showsLoaded); err != nil {
-		panic(err)
-	}
-	fmt.Println(showsLoaded)
-
-	showLoadedStructCursor, err := episodesCollection.Aggregate(ctx, mongo.Pipeline{lookupStage, unwindStage})
-	if err != nil {
-		panic(err)
-	}
-	var showsLoadedStruct []PodcastEpisode
-	if err = showLoadedStructCursor.All(ctx, &showsLoadedStruct); err != nil {
-		panic(err)
-	}
-	fmt.Println(showsLoadedStruct)
+	examples.Main(aggregation.Run, 10*time.Second)
 }
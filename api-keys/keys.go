@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidKey is returned for keys that are malformed, unknown or revoked.
+// Callers get the same error for each, so responses reveal nothing about
+// which keys exist.
+var ErrInvalidKey = errors.New("invalid API key")
+
+// keyPrefix starts every key, so leaked keys are easy to find in code scans
+const keyPrefix = "qs"
+
+// Key represents the schema for the "api_keys" collection. Only a hash of
+// the secret is stored; the full key is shown once, when it is issued.
+type Key struct {
+	ID         string     `bson:"_id"`
+	Hash       []byte     `bson:"hash"`
+	Name       string     `bson:"name"`
+	Scopes     []string   `bson:"scopes"`
+	RateLimit  int64      `bson:"rate_limit"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the key grants scope; "*" grants every scope
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys issues, verifies and rate limits API keys
+type Keys struct {
+	Keys  *mongo.Collection
+	Usage *mongo.Collection
+	// Window is the rate limit period, RateLimit requests are allowed per window
+	Window time.Duration
+}
+
+// EnsureIndexes expires usage counters once their window is over
+func (k *Keys) EnsureIndexes(ctx context.Context) error {
+	_, err := k.Usage.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"expires_at", 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// hashSecret hashes the random part of a key. A fast hash is fine here,
+// unlike for passwords: the secret has 256 bits of entropy, so there is
+// nothing to brute force.
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// Issue creates a key and returns it in full, formatted qs_<id>_<secret>.
+// The id is public and used for lookups; the secret is only stored hashed.
+func (k *Keys) Issue(ctx context.Context, name string, scopes []string, rateLimit int64) (string, Key, error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", Key{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", Key{}, err
+	}
+	key := Key{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now().UTC(),
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	key.Hash = hashSecret(encoded)
+	if _, err := k.Keys.InsertOne(ctx, key); err != nil {
+		return "", Key{}, err
+	}
+	return keyPrefix + "_" + key.ID + "_" + encoded, key, nil
+}
+
+// dummyHash is compared against when the id is unknown, so a miss costs the
+// same as a wrong secret
+var dummyHash = hashSecret("")
+
+// Verify returns the key matching token unless it is revoked. The lookup is
+// by the public id only, and the secret's hash is compared in constant time,
+// so response times do not reveal how much of a guessed secret was right.
+func (k *Keys) Verify(ctx context.Context, token string) (Key, error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return Key{}, ErrInvalidKey
+	}
+	var key Key
+	err := k.Keys.FindOne(ctx, bson.D{{"_id", parts[1]}}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		subtle.ConstantTimeCompare(dummyHash, hashSecret(parts[2]))
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if subtle.ConstantTimeCompare(key.Hash, hashSecret(parts[2])) != 1 || key.RevokedAt != nil {
+		return Key{}, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// Revoke disables a key for good. The document is kept for auditing.
+func (k *Keys) Revoke(ctx context.Context, id string) error {
+	result, err := k.Keys.UpdateOne(ctx,
+		bson.D{{"_id", id}, {"revoked_at", bson.D{{"$exists", false}}}},
+		bson.D{{"$set", bson.D{{"revoked_at", time.Now().UTC()}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// Usage represents the schema for the "api_key_usage" collection: one
+// counter per key and window
+type Usage struct {
+	Count     int64     `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Allow counts a request against the key's current window and reports
+// whether it is within the limit, how many requests remain and when the
+// window resets. The $inc upsert is atomic, so concurrent requests served
+// by several processes share one count.
+func (k *Keys) Allow(ctx context.Context, key Key, now time.Time) (bool, int64, time.Time, error) {
+	start := now.Truncate(k.Window)
+	reset := start.Add(k.Window)
+	var usage Usage
+	err := k.Usage.FindOneAndUpdate(ctx,
+		bson.D{{"_id", bson.D{{"key", key.ID}, {"window", start}}}},
+		bson.D{
+			{"$inc", bson.D{{"count", 1}}},
+			{"$setOnInsert", bson.D{{"expires_at", reset}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&usage)
+	if err != nil {
+		return false, 0, reset, err
+	}
+	remaining := key.RateLimit - usage.Count
+	if remaining < 0 {
+		return false, 0, reset, nil
+	}
+	return true, remaining, reset, nil
+}
+
+// Touch records when the key was last used
+func (k *Keys) Touch(ctx context.Context, key Key, now time.Time) error {
+	_, err := k.Keys.UpdateOne(ctx, bson.D{{"_id", key.ID}}, bson.D{{"$max", bson.D{{"last_used_at", now}}}})
+	return err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/service"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Identity is what /whoami returns about the calling key
+type Identity struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// routes serves the podcasts API behind API key checks
+func routes(keys *Keys, s *service.Service) http.Handler {
+	routes := openapi.New("Quickstart API With API Keys", "1.0.0")
+	unauthorized := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/whoami", Summary: "Describe the calling key", Tags: []string{"keys"},
+		Response: Identity{}, Errors: unauthorized,
+	}, keys.Require("", func(w http.ResponseWriter, r *http.Request) {
+		key, _ := KeyFromContext(r.Context())
+		writeJSON(w, http.StatusOK, Identity{ID: key.ID, Name: key.Name, Scopes: key.Scopes})
+	}))
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/podcasts", Summary: "List podcasts", Tags: []string{"podcasts"},
+		Response: []dto.Podcast{}, Errors: unauthorized,
+		Description: "Requires the podcasts:read scope.",
+	}, keys.Require("podcasts:read", func(w http.ResponseWriter, r *http.Request) {
+		podcasts, err := s.Podcasts(r.Context())
+		if err != nil {
+			log.Printf("list podcasts: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		writeJSON(w, http.StatusOK, podcasts)
+	}))
+	return routes
+}
+
+var (
+	addr      = flag.String("addr", ":8080", "address serve listens on")
+	name      = flag.String("name", "", "name of the key to issue")
+	scopes    = flag.String("scopes", "podcasts:read", "comma separated scopes of the key to issue, * for all")
+	rateLimit = flag.Int64("rate-limit", 60, "requests per window allowed for the key to issue")
+	window    = flag.Duration("window", time.Minute, "rate limit window")
+	id        = flag.String("id", "", "id of the key to revoke")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] issue|revoke|list|serve\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	// Truncate does nothing with a zero window, so every request would get
+	// a counter of its own and never reach the limit
+	if *window <= 0 {
+		return fmt.Errorf("%w: -window must be positive", shutdown.ErrUsage)
+	}
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	keys := &Keys{
+		Keys:   database.Collection("api_keys"),
+		Usage:  database.Collection("api_key_usage"),
+		Window: *window,
+	}
+	if err = keys.EnsureIndexes(connectCtx); err != nil {
+		return err
+	}
+
+	switch flag.Arg(0) {
+	case "issue":
+		// Issue A Key, Shown Only Once
+		if *name == "" {
+			return fmt.Errorf("%w: issue requires -name", shutdown.ErrUsage)
+		}
+		plaintext, key, err := keys.Issue(connectCtx, *name, strings.Split(*scopes, ","), *rateLimit)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Issued key %s (%s) with scopes %v\n", key.ID, key.Name, key.Scopes)
+		fmt.Println("Store it now, it cannot be shown again:")
+		fmt.Println(plaintext)
+	case "revoke":
+		if err = keys.Revoke(connectCtx, *id); err != nil {
+			return err
+		}
+		fmt.Println("Revoked key", *id)
+	case "list":
+		cursor, err := keys.Keys.Find(connectCtx, bson.D{}, options.Find().SetSort(bson.D{{"created_at", 1}}))
+		if err != nil {
+			return err
+		}
+		var all []Key
+		if err = cursor.All(connectCtx, &all); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSCOPES\tLIMIT\tLAST USED\tREVOKED")
+		for _, key := range all {
+			lastUsed, revoked := "never", ""
+			if key.LastUsedAt != nil {
+				lastUsed = key.LastUsedAt.Format(time.RFC3339)
+			}
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d/%v\t%s\t%s\n", key.ID, key.Name, strings.Join(key.Scopes, ","),
+				key.RateLimit, *window, lastUsed, revoked)
+		}
+		w.Flush()
+	case "serve":
+		log.Printf("serving on %s, call with \"Authorization: Bearer <key>\"", *addr)
+		if err = shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: routes(keys, service.New(database, database))}); err != nil {
+			return err
+		}
+	default:
+		return shutdown.ErrUsage
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type keyContextKey struct{}
+
+// KeyFromContext returns the key that authenticated the request
+func KeyFromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(Key)
+	return key, ok
+}
+
+// token reads the key from "Authorization: Bearer <key>" or "X-API-Key"
+func token(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// Require wraps next so it only runs for a valid key within its rate limit
+// that grants scope; an empty scope accepts any valid key. The key is
+// available to next through KeyFromContext.
+func (k *Keys) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := k.Verify(r.Context(), token(r))
+		if errors.Is(err, ErrInvalidKey) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="quickstart"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("verify key: %v", err)
+			writeError(w, http.StatusServiceUnavailable, "cannot verify API key")
+			return
+		}
+		if scope != "" && !key.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "this key lacks the "+scope+" scope")
+			return
+		}
+
+		now := time.Now().UTC()
+		allowed, remaining, reset, err := k.Allow(r.Context(), key, now)
+		if err != nil {
+			log.Printf("rate limit: %v", err)
+			writeError(w, http.StatusServiceUnavailable, "cannot check rate limit")
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(key.RateLimit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		if err = k.Touch(r.Context(), key, now); err != nil {
+			log.Printf("touch key: %v", err)
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), keyContextKey{}, key)))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
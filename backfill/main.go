@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/repair"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Episode represents the schema for the "repair_episodes" collection as the
+// job sees it. Duration is an interface{} so the strings it repairs decode
+// as well as the numbers it writes.
+type Episode struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Title    string             `bson:"title"`
+	Duration interface{}        `bson:"duration"`
+}
+
+// episodes are written when "repair_episodes" is empty, with durations
+// typed in by hand the way they drift in an application without validation
+var episodes = []interface{}{
+	bson.D{{"title", "GraphQL for API Development"}, {"duration", "25"}},
+	bson.D{{"title", "Progressive Web Application Development"}, {"duration", "32 min"}},
+	bson.D{{"title", "MongoDB Transactions"}, {"duration", "1:05:00"}},
+	bson.D{{"title", "Time Series Collections"}, {"duration", "42:30"}},
+	bson.D{{"title", "Atlas Search"}, {"duration", "about an hour"}},
+	bson.D{{"title", "Change Streams"}, {"duration", 40}},
+}
+
+var (
+	job       = flag.String("job", "duration-to-int", "job name used to store and resume progress")
+	apply     = flag.Bool("apply", false, "write the repairs; without it the job is a dry run printing them")
+	batchSize = flag.Int64("batch", 2, "documents repaired per BulkWrite")
+	pause     = flag.Duration("pause", 100*time.Millisecond, "sleep between batches")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	episodesCollection := database.Collection("repair_episodes")
+	count, err := episodesCollection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("count repair_episodes: %w", err)
+	}
+	if count == 0 {
+		if _, err = episodesCollection.InsertMany(ctx, episodes); err != nil {
+			return fmt.Errorf("insert into repair_episodes: %w", err)
+		}
+		fmt.Printf("Inserted %d episodes with hand typed durations\n", len(episodes))
+	}
+
+	// Repair Durations Stored As Strings
+	durationJob := &repair.Job[Episode]{
+		Name:        *job,
+		Collection:  episodesCollection,
+		Checkpoints: database.Collection("repair_jobs"),
+		Filter:      bson.D{{"duration", bson.D{{"$type", "string"}}}},
+		Transform: func(ctx context.Context, episode Episode) (Episode, error) {
+			minutes, err := parseMinutes(episode.Duration.(string))
+			if err != nil {
+				return episode, err
+			}
+			episode.Duration = minutes
+			return episode, nil
+		},
+		Verify: func(episode Episode) error {
+			minutes, ok := episode.Duration.(int32)
+			if !ok || minutes <= 0 || minutes > 24*60 {
+				return fmt.Errorf("duration %v is not a number of minutes in a day", episode.Duration)
+			}
+			return nil
+		},
+		BatchSize: *batchSize,
+		Pause:     *pause,
+		DryRun:    !*apply,
+	}
+	if durationJob.DryRun {
+		fmt.Println("Dry run, the updates the job would make:")
+	}
+	checkpoint, err := durationJob.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("Interrupted, run again with -job %s to resume\n", *job)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scanned %d, changed %d, unchanged %d, failed %d\n",
+		checkpoint.Scanned, checkpoint.Changed, checkpoint.Unchanged, checkpoint.Failed)
+	if durationJob.DryRun {
+		fmt.Println("Run with -apply to write them")
+		return nil
+	}
+
+	cursor, err := episodesCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("find in repair_episodes: %w", err)
+	}
+	var repaired []Episode
+	if err = cursor.All(ctx, &repaired); err != nil {
+		return fmt.Errorf("decode repair_episodes: %w", err)
+	}
+	fmt.Println("After the repair:")
+	for _, episode := range repaired {
+		fmt.Printf("  %s: %#v\n", episode.Title, episode.Duration)
+	}
+	return nil
+}
+
+// clock matches h:mm:ss and mm:ss
+var clock = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{2})$`)
+
+// parseMinutes reads a duration typed as "25", "32 min", "42:30" or
+// "1:05:00", rounding seconds to the nearest minute
+func parseMinutes(s string) (int32, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "min"))
+	if n, err := strconv.Atoi(s); err == nil {
+		return int32(n), nil
+	}
+	parts := clock.FindStringSubmatch(s)
+	if parts == nil {
+		return 0, fmt.Errorf("cannot read %q as a duration", s)
+	}
+	hours, _ := strconv.Atoi(parts[1])
+	minutes, _ := strconv.Atoi(parts[2])
+	seconds, _ := strconv.Atoi(parts[3])
+	total := float64(hours*60+minutes) + float64(seconds)/60
+	return int32(math.Round(total)), nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Checkpoint represents the schema for the "batch_delete_jobs" collection.
+// LastID is the highest _id already processed, so a restarted job continues
+// after it instead of rescanning deleted ranges.
+type Checkpoint struct {
+	Job        string             `bson:"_id"`
+	Collection string             `bson:"collection"`
+	Filter     string             `bson:"filter"`
+	LastID     primitive.ObjectID `bson:"last_id"`
+	Deleted    int64              `bson:"deleted"`
+	StartedAt  time.Time          `bson:"started_at"`
+	UpdatedAt  time.Time          `bson:"updated_at"`
+	Finished   bool               `bson:"finished"`
+}
+
+// BatchDeleter removes matching documents one bounded _id range at a time
+type BatchDeleter struct {
+	Collection *mongo.Collection
+	Jobs       *mongo.Collection
+	Filter     bson.D
+	BatchSize  int64
+	Pause      time.Duration
+}
+
+// nextRange returns the first and last _id of the next batch of matching
+// documents after the checkpoint, using the _id index to stay cheap
+func (d *BatchDeleter) nextRange(ctx context.Context, after primitive.ObjectID) (primitive.ObjectID, primitive.ObjectID, int, error) {
+	filter := bson.D{{"$and", bson.A{bson.D{{"_id", bson.D{{"$gt", after}}}}, d.Filter}}}
+	opts := options.Find().
+		SetSort(bson.D{{"_id", 1}}).
+		SetLimit(d.BatchSize).
+		SetProjection(bson.D{{"_id", 1}})
+	cursor, err := d.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return after, after, 0, err
+	}
+	var ids []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &ids); err != nil || len(ids) == 0 {
+		return after, after, 0, err
+	}
+	return ids[0].ID, ids[len(ids)-1].ID, len(ids), nil
+}
+
+// Run deletes batches until nothing matches or the context is cancelled,
+// saving a checkpoint after each batch. DeleteMany has no limit option, so
+// each batch is a DeleteMany over a closed _id range that holds at most
+// BatchSize matching documents.
+func (d *BatchDeleter) Run(ctx context.Context, checkpoint *Checkpoint, total int64) error {
+	started := time.Now()
+	startDeleted := checkpoint.Deleted
+	for {
+		first, last, n, err := d.nextRange(ctx, checkpoint.LastID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			checkpoint.Finished = true
+			return d.save(ctx, checkpoint)
+		}
+		filter := bson.D{{"$and", bson.A{bson.D{{"_id", bson.D{{"$gte", first}, {"$lte", last}}}}, d.Filter}}}
+		result, err := d.Collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		checkpoint.LastID = last
+		checkpoint.Deleted += result.DeletedCount
+		if err = d.save(ctx, checkpoint); err != nil {
+			return err
+		}
+
+		rate := float64(checkpoint.Deleted-startDeleted) / time.Since(started).Seconds()
+		remaining := total - checkpoint.Deleted
+		eta := time.Duration(0)
+		if rate > 0 && remaining > 0 {
+			eta = time.Duration(float64(remaining)/rate) * time.Second
+		}
+		fmt.Printf("Deleted %v/%v (%.0f docs/s, ETA %v) up to %s\n",
+			checkpoint.Deleted, total, rate, eta.Round(time.Second), last.Hex())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.Pause):
+		}
+	}
+}
+
+func (d *BatchDeleter) save(ctx context.Context, checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	_, err := d.Jobs.ReplaceOne(ctx, bson.D{{"_id", checkpoint.Job}}, checkpoint, options.Replace().SetUpsert(true))
+	return err
+}
+
+var (
+	job            = flag.String("job", "", "job name used to store and resume progress (default: collection name)")
+	collectionName = flag.String("collection", "episodes", "collection to delete from")
+	filterJSON     = flag.String("filter", "{}", "Extended JSON filter of documents to delete")
+	olderThan      = flag.Duration("older-than", 0, "only delete documents whose ObjectID is older than this")
+	batchSize      = flag.Int64("batch", 1000, "maximum documents removed per DeleteMany")
+	pause          = flag.Duration("pause", 250*time.Millisecond, "sleep between batches to let secondaries keep up")
+	dryRun         = flag.Bool("dry-run", false, "only count the documents that would be deleted")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *job == "" {
+		*job = *collectionName
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	var filter bson.D
+	if err = bson.UnmarshalExtJSON([]byte(*filterJSON), false, &filter); err != nil {
+		return fmt.Errorf("invalid -filter: %v", err)
+	}
+	if *olderThan > 0 {
+		cutoff := primitive.NewObjectIDFromTimestamp(time.Now().Add(-*olderThan))
+		filter = bson.D{{"$and", bson.A{filter, bson.D{{"_id", bson.D{{"$lt", cutoff}}}}}}}
+	}
+
+	database := client.Database("quickstart")
+	// Majority write concern makes every batch wait for replication, which
+	// keeps secondaries from falling behind while millions of deletes go through
+	majority := options.Collection().SetWriteConcern(writeconcern.Majority())
+	deleter := &BatchDeleter{
+		Collection: database.Collection(*collectionName, majority),
+		Jobs:       database.Collection("batch_delete_jobs"),
+		Filter:     filter,
+		BatchSize:  *batchSize,
+		Pause:      *pause,
+	}
+
+	checkpoint := &Checkpoint{Job: *job, Collection: *collectionName, Filter: *filterJSON, StartedAt: time.Now().UTC()}
+	err = deleter.Jobs.FindOne(ctx, bson.D{{"_id", *job}}).Decode(checkpoint)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+	case err != nil:
+		return err
+	case checkpoint.Collection != *collectionName || checkpoint.Filter != *filterJSON:
+		return fmt.Errorf("job %q was started with a different collection or filter, pick another -job name", *job)
+	case checkpoint.Finished:
+		fmt.Printf("Job %q already finished after deleting %v documents\n", *job, checkpoint.Deleted)
+		return nil
+	default:
+		fmt.Printf("Resuming job %q after %s (%v deleted so far)\n", *job, checkpoint.LastID.Hex(), checkpoint.Deleted)
+	}
+
+	remaining, err := deleter.Collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v documents in %s match the filter\n", remaining, *collectionName)
+	if *dryRun || remaining == 0 {
+		return nil
+	}
+
+	if err = deleter.Run(ctx, checkpoint, checkpoint.Deleted+remaining); err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("Interrupted, run again with -job %s to resume\n", *job)
+			return nil
+		}
+		return err
+	}
+	fmt.Printf("Finished: deleted %v documents\n", checkpoint.Deleted)
+	return nil
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/bulk"
+)
+
+func main() {
+	examples.Main(bulk.Run, 30*time.Second)
+}
@@ -0,0 +1,151 @@
+// Package cascade removes a podcast together with everything that references
+// it: episodes, reviews and the GridFS files stored for it.
+package cascade
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when the podcast does not exist
+var ErrNotFound = errors.New("podcast not found")
+
+// Result counts the documents removed from each collection
+type Result struct {
+	Podcasts      int64
+	Episodes      int64
+	Reviews       int64
+	Files         int64
+	Chunks        int64
+	Transactional bool
+}
+
+// Deleter performs cascading deletes against one database. GridFS files are
+// linked to a podcast through metadata.podcast in the Bucket's files collection.
+type Deleter struct {
+	Database *mongo.Database
+	Bucket   string
+	// Podcasts, Episodes and Reviews name the collections
+	Podcasts string
+	Episodes string
+	Reviews  string
+
+	mu         sync.Mutex
+	checked    bool
+	replicaSet bool
+}
+
+// New returns a Deleter using the default "fs" GridFS bucket and the
+// "podcasts", "episodes" and "reviews" collections
+func New(database *mongo.Database) *Deleter {
+	return &Deleter{Database: database, Bucket: "fs", Podcasts: "podcasts", Episodes: "episodes", Reviews: "reviews"}
+}
+
+// supportsTransactions reports whether the deployment is a replica set or a
+// sharded cluster. Standalone servers reject transactions.
+func (d *Deleter) supportsTransactions(ctx context.Context) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.checked {
+		return d.replicaSet, nil
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := d.Database.RunCommand(ctx, bson.D{{"hello", 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	d.checked = true
+	d.replicaSet = hello.SetName != "" || hello.Msg == "isdbgrid"
+	return d.replicaSet, nil
+}
+
+// DeletePodcastCascade removes the podcast and everything referencing it. On
+// a replica set all deletes happen in one transaction. On a standalone server
+// it falls back to a saga that deletes children first and the podcast last,
+// so a failure part way leaves the podcast in place and calling it again
+// finishes the job.
+func (d *Deleter) DeletePodcastCascade(ctx context.Context, id primitive.ObjectID) (Result, error) {
+	transactional, err := d.supportsTransactions(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	if !transactional {
+		return d.deleteAll(ctx, id)
+	}
+
+	session, err := d.Database.Client().StartSession()
+	if err != nil {
+		return Result{}, err
+	}
+	defer session.EndSession(context.Background())
+	var result Result
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		// WithTransaction may retry the callback, so start from zero each time
+		var err error
+		result, err = d.deleteAll(sessionContext, id)
+		return nil, err
+	})
+	result.Transactional = true
+	return result, err
+}
+
+func (d *Deleter) deleteAll(ctx context.Context, id primitive.ObjectID) (Result, error) {
+	var result Result
+	if err := d.Database.Collection(d.Podcasts).FindOne(ctx, bson.D{{"_id", id}}).Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return result, ErrNotFound
+		}
+		return result, err
+	}
+
+	files := d.Database.Collection(d.Bucket + ".files")
+	cursor, err := files.Find(ctx, bson.D{{"metadata.podcast", id}})
+	if err != nil {
+		return result, err
+	}
+	var fileDocuments []struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &fileDocuments); err != nil {
+		return result, err
+	}
+	if len(fileDocuments) > 0 {
+		fileIDs := make(bson.A, len(fileDocuments))
+		for i, file := range fileDocuments {
+			fileIDs[i] = file.ID
+		}
+		// chunks first: a file document without chunks is still listed,
+		// chunks without a file document would be unreachable garbage
+		deleted, err := d.Database.Collection(d.Bucket+".chunks").DeleteMany(ctx, bson.D{{"files_id", bson.D{{"$in", fileIDs}}}})
+		if err != nil {
+			return result, err
+		}
+		result.Chunks = deleted.DeletedCount
+		if deleted, err = files.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", fileIDs}}}}); err != nil {
+			return result, err
+		}
+		result.Files = deleted.DeletedCount
+	}
+
+	deleted, err := d.Database.Collection(d.Reviews).DeleteMany(ctx, bson.D{{"podcast", id}})
+	if err != nil {
+		return result, err
+	}
+	result.Reviews = deleted.DeletedCount
+	if deleted, err = d.Database.Collection(d.Episodes).DeleteMany(ctx, bson.D{{"podcast", id}}); err != nil {
+		return result, err
+	}
+	result.Episodes = deleted.DeletedCount
+	if deleted, err = d.Database.Collection(d.Podcasts).DeleteOne(ctx, bson.D{{"_id", id}}); err != nil {
+		return result, err
+	}
+	result.Podcasts = deleted.DeletedCount
+	return result, nil
+}
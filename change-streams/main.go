@@ -2,60 +2,29 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"sync"
+	"flag"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/changestreams"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
 )
 
-func iterateChangeStream(routineCtx context.Context, waitGroup sync.WaitGroup, stream *mongo.ChangeStream) {
-	defer stream.Close(routineCtx)
-	defer waitGroup.Done()
-	for stream.Next(routineCtx) {
-		var data bson.M
-		if err := stream.Decode(&data); err != nil {
-			panic(err)
-		}
-		fmt.Printf("%v\n", data)
-	}
-	if stream.Err() != nil {
-		panic(stream.Err())
-	}
-}
+var (
+	name       = flag.String("stream", "long-episodes", "name the resume token is saved under")
+	crashAfter = flag.Int("crash-after", 0, "exit abruptly after handling this many events, to demonstrate recovery")
+	reset      = flag.Bool("reset", false, "forget the saved resume token and only watch new events")
+)
 
 func main() {
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(os.Getenv("ATLAS_URI")))
-	if err != nil {
-		panic(err)
-	}
-	defer client.Disconnect(context.TODO())
-
-	database := client.Database("quickstart")
-	episodesCollection := database.Collection("episodes")
-
-	var waitGroup sync.WaitGroup
-
-	matchPipeline := bson.D{
-		{
-			"$match", bson.D{
-				{"operationType", "insert"},
-				{"fullDocument.duration", bson.D{
-					{"$gt", 30},
-				}},
-			},
-		},
-	}
-
-	episodesStream, err := episodesCollection.Watch(context.TODO(), mongo.Pipeline{matchPipeline})
-	if err != nil {
-		panic(err)
-	}
-	waitGroup.Add(1)
-	routineCtx, cancelFn := context.WithCancel(context.Background())
-	go iterateChangeStream(routineCtx, waitGroup, episodesStream)
-
-	waitGroup.Wait()
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	// Ctrl+C cancels ctx, which ends the watch
+	shutdown.Main(func(ctx context.Context, down *shutdown.Shutdown) error {
+		deps, err := examples.Connect(ctx, down)
+		if err != nil {
+			return err
+		}
+		return changestreams.Watch(ctx, deps, changestreams.Options{Stream: *name, CrashAfter: *crashAfter, Reset: *reset})
+	})
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/filter"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// episodesCommand returns "episodes" and its add, list, update and delete
+// subcommands
+func (c *cli) episodesCommand() *cobra.Command {
+	var collection string
+	episodes := func(ctx context.Context) (*repository.Repository[dto.Episode], error) {
+		coll, err := c.collection(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		return repository.New[dto.Episode](coll), nil
+	}
+	cmd := &cobra.Command{Use: "episodes", Short: "Add, list, update and delete episodes"}
+	cmd.PersistentFlags().StringVar(&collection, "collection", "episodes", "collection holding the episodes")
+
+	var episode dto.Episode
+	var podcast string
+	add := &cobra.Command{
+		Use:   "add",
+		Short: "Add an episode to a podcast",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if podcast != "" {
+				id, err := dto.ParseID(podcast)
+				if err != nil {
+					return fmt.Errorf("%w: --podcast: %v", shutdown.ErrUsage, err)
+				}
+				episode.Podcast = id
+			}
+			if err := episode.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			repo, err := episodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+			id, err := repo.Insert(cmd.Context(), episode)
+			if err != nil {
+				return fmt.Errorf("insert into %s: %w", collection, err)
+			}
+			if oid, ok := id.(primitive.ObjectID); ok {
+				episode.ID = dto.ID(oid)
+			}
+			return c.printEpisodes(episode)
+		},
+	}
+	add.Flags().StringVar(&podcast, "podcast", "", "ID of the podcast the episode belongs to")
+	episodeFlags(add, &episode)
+
+	var of string
+	var longerThan int32
+	var limit int64
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List episodes by podcast and title",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conditions := []bson.D{{}}
+			if of != "" {
+				id, err := dto.ParseID(of)
+				if err != nil {
+					return fmt.Errorf("%w: --podcast: %v", shutdown.ErrUsage, err)
+				}
+				conditions = append(conditions, filter.Eq("podcast", id))
+			}
+			if longerThan > 0 {
+				conditions = append(conditions, filter.Gt("duration", longerThan))
+			}
+			repo, err := episodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+			found, err := repo.Find(cmd.Context(), filter.And(conditions...),
+				options.Find().SetSort(bson.D{{"podcast", 1}, {"title", 1}}).SetLimit(limit))
+			if err != nil {
+				return fmt.Errorf("find in %s: %w", collection, err)
+			}
+			return c.printEpisodes(found...)
+		},
+	}
+	list.Flags().StringVar(&of, "podcast", "", "only episodes of the podcast with this ID")
+	list.Flags().Int32Var(&longerThan, "longer-than", 0, "only episodes longer than this many minutes")
+	list.Flags().Int64Var(&limit, "limit", 0, "list at most this many episodes, 0 for all")
+
+	var changes dto.Episode
+	update := &cobra.Command{
+		Use:   "update ID",
+		Short: "Change the fields of an episode given as flags",
+		Args:  exactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := dto.ParseID(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			flags := cmd.Flags()
+			if !flags.Changed("title") && !flags.Changed("description") && !flags.Changed("duration") {
+				return errNothingToUpdate
+			}
+			repo, err := episodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+			// the episode as it will be must pass the same validation as add
+			edited, err := repo.FindByID(cmd.Context(), id)
+			if err != nil {
+				return fmt.Errorf("find episode %s: %w", id, err)
+			}
+			set := bson.D{}
+			if flags.Changed("title") {
+				edited.Title = changes.Title
+				set = append(set, bson.E{Key: "title", Value: changes.Title})
+			}
+			if flags.Changed("description") {
+				edited.Description = changes.Description
+				set = append(set, bson.E{Key: "description", Value: changes.Description})
+			}
+			if flags.Changed("duration") {
+				edited.Duration = changes.Duration
+				set = append(set, bson.E{Key: "duration", Value: changes.Duration})
+			}
+			if err = edited.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			if err = repo.UpdateByID(cmd.Context(), id, bson.D{{"$set", set}}); err != nil {
+				return fmt.Errorf("update episode %s: %w", id, err)
+			}
+			updated, err := repo.FindByID(cmd.Context(), id)
+			if err != nil {
+				return fmt.Errorf("find episode %s: %w", id, err)
+			}
+			return c.printEpisodes(updated)
+		},
+	}
+	episodeFlags(update, &changes)
+
+	var allOf string
+	remove := &cobra.Command{
+		Use:   "delete [ID]",
+		Short: "Delete an episode, or with --podcast every episode of a podcast",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if allOf != "" {
+				return exactArgs(0)(cmd, args)
+			}
+			return exactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := episodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if allOf != "" {
+				podcastID, err := dto.ParseID(allOf)
+				if err != nil {
+					return fmt.Errorf("%w: --podcast: %v", shutdown.ErrUsage, err)
+				}
+				result, err := repo.Collection.DeleteMany(cmd.Context(), filter.Eq("podcast", podcastID))
+				if err != nil {
+					return fmt.Errorf("delete episodes of podcast %s: %w", podcastID, err)
+				}
+				fmt.Fprintf(c.out, "Deleted %d episode(s) of podcast %s\n", result.DeletedCount, podcastID)
+				return nil
+			}
+			id, err := dto.ParseID(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			if err = repo.DeleteByID(cmd.Context(), id); err != nil {
+				return fmt.Errorf("delete episode %s: %w", id, err)
+			}
+			fmt.Fprintf(c.out, "Deleted episode %s\n", id)
+			return nil
+		},
+	}
+	remove.Flags().StringVar(&allOf, "podcast", "", "delete every episode of the podcast with this ID")
+
+	cmd.AddCommand(add, list, update, remove)
+	return cmd
+}
+
+// episodeFlags binds the field flags of add and update to episode
+func episodeFlags(cmd *cobra.Command, episode *dto.Episode) {
+	cmd.Flags().StringVar(&episode.Title, "title", "", "title of the episode")
+	cmd.Flags().StringVar(&episode.Description, "description", "", "description of the episode")
+	cmd.Flags().Int32Var(&episode.Duration, "duration", 0, "length of the episode in minutes")
+}
+
+// printEpisodes prints episodes in the output format
+func (c *cli) printEpisodes(episodes ...dto.Episode) error {
+	rows := make([][]string, 0, len(episodes))
+	for _, episode := range episodes {
+		rows = append(rows, []string{episode.ID.String(), episode.Podcast.String(), episode.Title, strconv.Itoa(int(episode.Duration))})
+	}
+	return c.print(episodes, []string{"ID", "PODCAST", "TITLE", "DURATION"}, rows)
+}
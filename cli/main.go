@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cli holds the global flags and the client every subcommand shares
+type cli struct {
+	uri      string
+	database string
+	output   string
+
+	down   *shutdown.Shutdown
+	client *mongo.Client
+	out    io.Writer
+}
+
+func main() {
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	c := &cli{down: down, out: os.Stdout}
+	root := &cobra.Command{
+		Use:   "cli",
+		Short: "Manage the podcasts and episodes of the quickstart database",
+		// errors are printed once, with a hint, by shutdown.Main
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if c.output != "table" && c.output != "json" {
+				return fmt.Errorf("%w: --output must be table or json, not %q", shutdown.ErrUsage, c.output)
+			}
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&c.uri, "uri", "", "MongoDB connection string (default: $ATLAS_URI)")
+	root.PersistentFlags().StringVar(&c.database, "db", "quickstart", "database holding the collections")
+	root.PersistentFlags().StringVarP(&c.output, "output", "o", "table", "output format: table or json")
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+	})
+	root.AddCommand(c.podcastsCommand(), c.episodesCommand())
+
+	// shutdown.Main prints the error and then the usage of the standard flag
+	// package, which knows nothing about the subcommands
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Run 'cli --help' for usage.")
+	}
+	return root.ExecuteContext(ctx)
+}
+
+// collection connects on first use and returns the named collection
+func (c *cli) collection(ctx context.Context, name string) (*mongo.Collection, error) {
+	if c.client == nil {
+		// --uri replaces ATLAS_URI, which db.Connect reads otherwise
+		opts := options.Client()
+		if c.uri != "" {
+			opts.ApplyURI(c.uri)
+		}
+		client, err := db.Connect(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		c.down.Client(client)
+		c.client = client
+	}
+	return c.client.Database(c.database).Collection(name), nil
+}
+
+// print writes v as indented JSON, or as a table of header and rows
+func (c *cli) print(v interface{}, header []string, rows [][]string) error {
+	if c.output == "json" {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// exactArgs is cobra.ExactArgs reporting a usage error
+func exactArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(n)(cmd, args); err != nil {
+			return fmt.Errorf("%w: %s %v", shutdown.ErrUsage, cmd.CommandPath(), err)
+		}
+		return nil
+	}
+}
+
+// errNothingToUpdate is returned by update commands given no field flags
+var errNothingToUpdate = fmt.Errorf("%w: nothing to update, pass at least one field flag", shutdown.ErrUsage)
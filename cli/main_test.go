@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// execute runs one of the commands of c, built afresh so no flag keeps its
+// value from an earlier run
+func execute(c *cli, command func() *cobra.Command, args ...string) error {
+	cmd := command()
+	cmd.SetArgs(args)
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	return cmd.ExecuteContext(context.Background())
+}
+
+func TestUpdateWithoutFields(t *testing.T) {
+	c := &cli{output: "table", out: &bytes.Buffer{}}
+	id := primitive.NewObjectID().Hex()
+	if err := execute(c, c.podcastsCommand, "update", id); !errors.Is(err, errNothingToUpdate) {
+		t.Errorf("podcasts update = %v, want errNothingToUpdate", err)
+	}
+	if err := execute(c, c.episodesCommand, "update", id); !errors.Is(err, errNothingToUpdate) {
+		t.Errorf("episodes update = %v, want errNothingToUpdate", err)
+	}
+}
+
+func TestUpdateValidates(t *testing.T) {
+	ctx := context.Background()
+	database := mongotest.Database(t)
+	c := &cli{database: database.Name(), output: "table", out: &bytes.Buffer{}, client: database.Client()}
+	podcast := primitive.NewObjectID()
+	episode := primitive.NewObjectID()
+	if _, err := database.Collection("podcasts").InsertOne(ctx, bson.D{{"_id", podcast}, {"title", "Go Time"}, {"author", "Changelog"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Collection("episodes").InsertOne(ctx, bson.D{{"_id", episode}, {"podcast", podcast}, {"title", "Generics"}, {"duration", 60}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command func() *cobra.Command
+		args    []string
+		invalid bool
+	}{
+		{"podcast without a title", c.podcastsCommand, []string{"update", podcast.Hex(), "--title", " "}, true},
+		{"podcast without an author", c.podcastsCommand, []string{"update", podcast.Hex(), "--author", ""}, true},
+		{"podcast retitled", c.podcastsCommand, []string{"update", podcast.Hex(), "--title", "Go Time FM"}, false},
+		{"episode without a title", c.episodesCommand, []string{"update", episode.Hex(), "--title", ""}, true},
+		{"episode of negative duration", c.episodesCommand, []string{"update", episode.Hex(), "--duration", "-5"}, true},
+		{"episode shortened", c.episodesCommand, []string{"update", episode.Hex(), "--duration", "45"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := execute(c, test.command, test.args...)
+			if test.invalid != errors.Is(err, shutdown.ErrUsage) || (!test.invalid && err != nil) {
+				t.Errorf("update = %v, want a usage error %v", err, test.invalid)
+			}
+		})
+	}
+
+	var stored dto.Podcast
+	if err := database.Collection("podcasts").FindOne(ctx, bson.D{{"_id", podcast}}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "Go Time FM" || stored.Author != "Changelog" {
+		t.Errorf("podcast = %+v, want only the valid update applied", stored)
+	}
+	var edited dto.Episode
+	if err := database.Collection("episodes").FindOne(ctx, bson.D{{"_id", episode}}).Decode(&edited); err != nil {
+		t.Fatal(err)
+	}
+	if edited.Title != "Generics" || edited.Duration != 45 {
+		t.Errorf("episode = %+v, want only the valid update applied", edited)
+	}
+
+	missing := primitive.NewObjectID().Hex()
+	if err := execute(c, c.podcastsCommand, "update", missing, "--title", "x"); err == nil {
+		t.Errorf("update of a missing podcast succeeded")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mongodb-developer/golang-quickstart/cascade"
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/filter"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// podcastsCommand returns "podcasts" and its add, list, update and delete
+// subcommands
+func (c *cli) podcastsCommand() *cobra.Command {
+	var collection string
+	podcasts := func(ctx context.Context) (*repository.Repository[dto.Podcast], error) {
+		coll, err := c.collection(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		return repository.New[dto.Podcast](coll), nil
+	}
+	cmd := &cobra.Command{Use: "podcasts", Short: "Add, list, update and delete podcasts"}
+	cmd.PersistentFlags().StringVar(&collection, "collection", "podcasts", "collection holding the podcasts")
+
+	var podcast dto.Podcast
+	add := &cobra.Command{
+		Use:   "add",
+		Short: "Add a podcast",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := podcast.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			repo, err := podcasts(cmd.Context())
+			if err != nil {
+				return err
+			}
+			id, err := repo.Insert(cmd.Context(), podcast)
+			if err != nil {
+				return fmt.Errorf("insert into %s: %w", collection, err)
+			}
+			if oid, ok := id.(primitive.ObjectID); ok {
+				podcast.ID = dto.ID(oid)
+			}
+			return c.printPodcasts(podcast)
+		},
+	}
+	podcastFlags(add, &podcast)
+
+	var tag, author string
+	var limit int64
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List podcasts by title",
+		Args:  exactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repo, err := podcasts(cmd.Context())
+			if err != nil {
+				return err
+			}
+			conditions := []bson.D{{}}
+			if tag != "" {
+				conditions = append(conditions, filter.Eq("tags", tag))
+			}
+			if author != "" {
+				conditions = append(conditions, filter.Eq("author", author))
+			}
+			found, err := repo.Find(cmd.Context(), filter.And(conditions...),
+				options.Find().SetSort(bson.D{{"title", 1}}).SetLimit(limit))
+			if err != nil {
+				return fmt.Errorf("find in %s: %w", collection, err)
+			}
+			return c.printPodcasts(found...)
+		},
+	}
+	list.Flags().StringVar(&tag, "tag", "", "only podcasts with this tag")
+	list.Flags().StringVar(&author, "author", "", "only podcasts by this author")
+	list.Flags().Int64Var(&limit, "limit", 0, "list at most this many podcasts, 0 for all")
+
+	var changes dto.Podcast
+	update := &cobra.Command{
+		Use:   "update ID",
+		Short: "Change the fields of a podcast given as flags",
+		Args:  exactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := dto.ParseID(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			// only the flags given are set, so the other fields keep their values
+			flags := cmd.Flags()
+			if !flags.Changed("title") && !flags.Changed("author") && !flags.Changed("tag") {
+				return errNothingToUpdate
+			}
+			repo, err := podcasts(cmd.Context())
+			if err != nil {
+				return err
+			}
+			// the podcast as it will be must pass the same validation as add
+			edited, err := repo.FindByID(cmd.Context(), id)
+			if err != nil {
+				return fmt.Errorf("find podcast %s: %w", id, err)
+			}
+			set := bson.D{}
+			if flags.Changed("title") {
+				edited.Title = changes.Title
+				set = append(set, bson.E{Key: "title", Value: changes.Title})
+			}
+			if flags.Changed("author") {
+				edited.Author = changes.Author
+				set = append(set, bson.E{Key: "author", Value: changes.Author})
+			}
+			if flags.Changed("tag") {
+				edited.Tags = changes.Tags
+				set = append(set, bson.E{Key: "tags", Value: changes.Tags})
+			}
+			if err = edited.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			if err = repo.UpdateByID(cmd.Context(), id, bson.D{{"$set", set}}); err != nil {
+				return fmt.Errorf("update podcast %s: %w", id, err)
+			}
+			updated, err := repo.FindByID(cmd.Context(), id)
+			if err != nil {
+				return fmt.Errorf("find podcast %s: %w", id, err)
+			}
+			return c.printPodcasts(updated)
+		},
+	}
+	podcastFlags(update, &changes)
+
+	remove := &cobra.Command{
+		Use:   "delete ID",
+		Short: "Delete a podcast with its episodes, reviews and files",
+		Args:  exactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := dto.ParseID(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: %v", shutdown.ErrUsage, err)
+			}
+			coll, err := c.collection(cmd.Context(), collection)
+			if err != nil {
+				return err
+			}
+			// the same cascade as the deleting example and the REST API, so
+			// no episode or review is left pointing at a missing podcast
+			deleter := cascade.New(coll.Database())
+			deleter.Podcasts = coll.Name()
+			deleted, err := deleter.DeletePodcastCascade(cmd.Context(), id.ObjectID())
+			if err != nil {
+				return fmt.Errorf("delete podcast %s: %w", id, err)
+			}
+			fmt.Fprintf(c.out, "Deleted podcast %s with %d episode(s), %d review(s) and %d file(s)\n",
+				id, deleted.Episodes, deleted.Reviews, deleted.Files)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(add, list, update, remove)
+	return cmd
+}
+
+// podcastFlags binds the field flags of add and update to podcast
+func podcastFlags(cmd *cobra.Command, podcast *dto.Podcast) {
+	cmd.Flags().StringVar(&podcast.Title, "title", "", "title of the podcast")
+	cmd.Flags().StringVar(&podcast.Author, "author", "", "author of the podcast")
+	cmd.Flags().StringSliceVar(&podcast.Tags, "tag", nil, "a tag; repeat the flag or separate tags with commas")
+}
+
+// printPodcasts prints podcasts in the output format
+func (c *cli) printPodcasts(podcasts ...dto.Podcast) error {
+	rows := make([][]string, 0, len(podcasts))
+	for _, podcast := range podcasts {
+		rows = append(rows, []string{podcast.ID.String(), podcast.Title, podcast.Author, strings.Join(podcast.Tags, ",")})
+	}
+	return c.print(podcasts, []string{"ID", "TITLE", "AUTHOR", "TAGS"}, rows)
+}
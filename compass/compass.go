@@ -0,0 +1,165 @@
+// Package compass turns queries copied out of MongoDB Compass into driver
+// values. Compass exports filters, projections, sorts and pipelines as
+// relaxed Extended JSON, with {"$oid": ...} and {"$date": ...} standing in
+// for ObjectIds and dates; Parse keeps those types and the field order, so
+// the result can be passed straight to Find or Aggregate.
+package compass
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrShellSyntax is returned for input written in mongosh syntax, such as
+// ObjectId("...") or ISODate("..."), which is not JSON
+var ErrShellSyntax = errors.New(`mongosh syntax is not JSON, use {"$oid": ...} and {"$date": ...} or copy the query from Compass as JSON`)
+
+// Query is a query exported from the Compass query bar
+type Query struct {
+	Filter    bson.D
+	Project   bson.D
+	Sort      bson.D
+	Collation bson.D
+	Skip      int64
+	Limit     int64
+}
+
+// Parse parses one document, for example the contents of the Compass filter
+// field. An empty string is an empty document, like an empty filter field.
+func Parse(s string) (bson.D, error) {
+	document := bson.D{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return document, nil
+	}
+	if err := bson.UnmarshalExtJSON([]byte(s), false, &document); err != nil {
+		return nil, explain(s, err)
+	}
+	return document, nil
+}
+
+// ParseQuery parses a whole exported query: a document with any of the
+// filter, project, sort, collation, skip and limit fields. An empty string
+// is a query without any, like Parse.
+func ParseQuery(s string) (Query, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		s = "{}"
+	}
+	var raw struct {
+		Filter    bson.Raw `bson:"filter"`
+		Project   bson.Raw `bson:"project"`
+		Sort      bson.Raw `bson:"sort"`
+		Collation bson.Raw `bson:"collation"`
+		Skip      int64    `bson:"skip"`
+		Limit     int64    `bson:"limit"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(s), false, &raw); err != nil {
+		return Query{}, explain(s, err)
+	}
+	query := Query{Skip: raw.Skip, Limit: raw.Limit}
+	for _, field := range []struct {
+		name string
+		raw  bson.Raw
+		into *bson.D
+	}{
+		{"filter", raw.Filter, &query.Filter},
+		{"project", raw.Project, &query.Project},
+		{"sort", raw.Sort, &query.Sort},
+		{"collation", raw.Collation, &query.Collation},
+	} {
+		*field.into = bson.D{}
+		if field.raw == nil {
+			continue
+		}
+		if err := bson.Unmarshal(field.raw, field.into); err != nil {
+			return Query{}, fmt.Errorf("%s: %w", field.name, err)
+		}
+	}
+	return query, nil
+}
+
+// ParsePipeline parses an aggregation pipeline exported from the Compass
+// aggregation builder, a JSON array of stages. An empty string is an empty
+// pipeline, like Parse.
+func ParsePipeline(s string) (mongo.Pipeline, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return mongo.Pipeline{}, nil
+	}
+	// ExtJSON only decodes documents, so wrap the array in one
+	var wrapper struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	err := bson.UnmarshalExtJSON([]byte(`{"pipeline": `+s+`}`), false, &wrapper)
+	if err != nil {
+		return nil, explain(s, err)
+	}
+	return mongo.Pipeline(wrapper.Pipeline), nil
+}
+
+// FindOptions returns the options for the non-filter parts of the query, or
+// an error for a collation that cannot be decoded
+func (q Query) FindOptions() (*options.FindOptions, error) {
+	opts := options.Find()
+	if len(q.Project) > 0 {
+		opts.SetProjection(q.Project)
+	}
+	if len(q.Sort) > 0 {
+		opts.SetSort(q.Sort)
+	}
+	if q.Skip > 0 {
+		opts.SetSkip(q.Skip)
+	}
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit)
+	}
+	if len(q.Collation) > 0 {
+		collation, err := toCollation(q.Collation)
+		if err != nil {
+			return nil, fmt.Errorf("collation: %w", err)
+		}
+		opts.SetCollation(collation)
+	}
+	return opts, nil
+}
+
+// toCollation decodes a collation document; options.Collation has no field
+// tags for the camel-cased names the server uses, so they are listed here
+func toCollation(document bson.D) (*options.Collation, error) {
+	var fields struct {
+		Locale          string `bson:"locale"`
+		CaseLevel       bool   `bson:"caseLevel"`
+		CaseFirst       string `bson:"caseFirst"`
+		Strength        int    `bson:"strength"`
+		NumericOrdering bool   `bson:"numericOrdering"`
+		Alternate       string `bson:"alternate"`
+		MaxVariable     string `bson:"maxVariable"`
+		Normalization   bool   `bson:"normalization"`
+		Backwards       bool   `bson:"backwards"`
+	}
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	if err = bson.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	collation := options.Collation(fields)
+	return &collation, nil
+}
+
+// explain points out the usual mistake of pasting mongosh syntax
+func explain(s string, err error) error {
+	for _, helper := range []string{"ObjectId(", "ISODate(", "NumberLong(", "NumberDecimal(", "new Date("} {
+		if strings.Contains(s, helper) {
+			return fmt.Errorf("%w: %v", ErrShellSyntax, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package compass
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParse(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5dd890a61c9d4400003f3a31")
+	tests := []struct {
+		name    string
+		input   string
+		want    bson.D
+		wantErr error
+	}{
+		{"empty", "  ", bson.D{}, nil},
+		{"empty document", "{}", bson.D{}, nil},
+		{"field order kept", `{"b": 1, "a": 2}`, bson.D{{"b", int32(1)}, {"a", int32(2)}}, nil},
+		{"ObjectId", `{"podcast": {"$oid": "5dd890a61c9d4400003f3a31"}}`, bson.D{{"podcast", id}}, nil},
+		{"date", `{"at": {"$gte": {"$date": "2020-02-05T00:00:00Z"}}}`,
+			bson.D{{"at", bson.D{{"$gte", primitive.NewDateTimeFromTime(time.Date(2020, 2, 5, 0, 0, 0, 0, time.UTC))}}}}, nil},
+		{"mongosh syntax", `{"podcast": ObjectId("5dd890a61c9d4400003f3a31")}`, nil, ErrShellSyntax},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.input)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Parse = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Parse = %v, want %v", got, test.want)
+			}
+		})
+	}
+	if _, err := Parse(`{"a": `); err == nil || errors.Is(err, ErrShellSyntax) {
+		t.Errorf("Parse of broken JSON = %v, want a plain syntax error", err)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, input := range []string{"", " ", "{}"} {
+		query, err := ParseQuery(input)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", input, err)
+		}
+		want := Query{Filter: bson.D{}, Project: bson.D{}, Sort: bson.D{}, Collation: bson.D{}}
+		if !reflect.DeepEqual(query, want) {
+			t.Errorf("ParseQuery(%q) = %+v, want an empty query", input, query)
+		}
+	}
+
+	query, err := ParseQuery(`{
+		"filter": {"duration": {"$gt": 20}},
+		"project": {"title": 1},
+		"sort": {"duration": -1, "title": 1},
+		"collation": {"locale": "en", "strength": 2},
+		"skip": 5,
+		"limit": 2
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{"duration", int32(-1)}, {"title", int32(1)}}); !reflect.DeepEqual(query.Sort, want) {
+		t.Errorf("Sort = %v, want %v", query.Sort, want)
+	}
+	opts, err := query.FindOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *opts.Skip != 5 || *opts.Limit != 2 {
+		t.Errorf("skip, limit = %d, %d, want 5, 2", *opts.Skip, *opts.Limit)
+	}
+	if opts.Collation == nil || opts.Collation.Locale != "en" || opts.Collation.Strength != 2 {
+		t.Errorf("Collation = %+v, want en with strength 2", opts.Collation)
+	}
+
+	query, err = ParseQuery(`{"collation": {"locale": 5}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = query.FindOptions(); err == nil {
+		t.Error("FindOptions accepted a collation with a numeric locale")
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	pipeline, err := ParsePipeline("")
+	if err != nil || len(pipeline) != 0 {
+		t.Errorf("ParsePipeline(\"\") = %v, %v, want an empty pipeline", pipeline, err)
+	}
+	pipeline, err = ParsePipeline(`[{"$match": {"duration": {"$gt": 20}}}, {"$limit": 5}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mongo.Pipeline{
+		{{"$match", bson.D{{"duration", bson.D{{"$gt", int32(20)}}}}}},
+		{{"$limit", int32(5)}},
+	}
+	if !reflect.DeepEqual(pipeline, want) {
+		t.Errorf("ParsePipeline = %v, want %v", pipeline, want)
+	}
+	if _, err = ParsePipeline(`[{"$match": {"at": ISODate("2020-01-01")}}]`); !errors.Is(err, ErrShellSyntax) {
+		t.Errorf("ParsePipeline of mongosh syntax = %v, want ErrShellSyntax", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/internal/warmup"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func printStats(label string, stats warmup.Stats) {
+	fmt.Printf("%-12s open=%d created=%d ready=%d closed=%d checkedOut=%d checkedIn=%d checkOutFailed=%d cleared=%d\n",
+		label, stats.Open, stats.Created, stats.Ready, stats.Closed, stats.CheckedOut, stats.CheckedIn, stats.CheckFailed, stats.Cleared)
+}
+
+var (
+	minPoolSize = flag.Uint64("min-pool-size", 10, "connections opened before serving traffic")
+	maxJitter   = flag.Duration("max-jitter", 5*time.Second, "upper bound of the random delay before re-warming after a failover")
+	duration    = flag.Duration("duration", time.Minute, "how long to run the simulated workload")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *maxJitter < 0 {
+		return fmt.Errorf("%w: -max-jitter must not be negative", shutdown.ErrUsage)
+	}
+	monitor := warmup.NewMonitor()
+	clientOptions := options.Client().
+		SetMinPoolSize(*minPoolSize).
+		SetMaxConnecting(2).
+		SetPoolMonitor(monitor.PoolMonitor()).
+		SetServerMonitor(monitor.ServerMonitor())
+
+	connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx, clientOptions)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	printStats("connected", monitor.Stats())
+	started := time.Now()
+	if err = warmup.WarmUp(connectCtx, client, monitor, int(*minPoolSize)); err != nil {
+		return err
+	}
+	fmt.Printf("Warm-up finished in %v\n", time.Since(started).Round(time.Millisecond))
+	printStats("warmed up", monitor.Stats())
+
+	runCtx, stop := context.WithTimeout(ctx, *duration)
+	defer stop()
+
+	reconnector := &warmup.Reconnector{
+		Client:    client,
+		Monitor:   monitor,
+		Size:      int(*minPoolSize),
+		MaxJitter: *maxJitter,
+		OnReconnect: func(address string, delay time.Duration, err error) {
+			if err != nil {
+				log.Printf("re-warm after %s was cleared failed: %v", address, err)
+				return
+			}
+			log.Printf("pool for %s re-warmed after %v jitter", address, delay.Round(time.Millisecond))
+		},
+	}
+	go reconnector.Run(runCtx)
+
+	// Simulated traffic: trigger a failover from the Atlas UI (Test Failover)
+	// while this runs to see the pool cleared and re-warmed with jitter.
+	episodesCollection := client.Database("quickstart").Collection("episodes")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				opCtx, cancel := context.WithTimeout(runCtx, 2*time.Second)
+				episodesCollection.FindOne(opCtx, bson.M{"duration": bson.M{"$gt": 10}})
+				cancel()
+				time.Sleep(50 * time.Millisecond)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-runCtx.Done():
+			wg.Wait()
+			printStats("finished", monitor.Stats())
+			return nil
+		case <-ticker.C:
+			printStats("running", monitor.Stats())
+		}
+	}
+}
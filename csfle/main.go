@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/localkey"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The key vault holds the data keys, encrypted with the master key. It lives
+// outside the application database so access to it can be granted separately.
+const (
+	keyVaultNamespace = "encryption.__keyVault"
+	keyAltName        = "quickstart-csfle"
+	listenersNS       = "quickstart.csfle_listeners"
+)
+
+// Listener is stored with email encrypted deterministically, so it can still
+// be matched by equality, and card encrypted randomly, so it cannot be
+// queried at all and equal cards do not produce equal ciphertext
+type Listener struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Name  string             `bson:"name"`
+	Email string             `bson:"email"`
+	Card  string             `bson:"card"`
+}
+
+// dataKey returns the id of the data key named keyAltName, creating it with
+// the master key when the key vault has none
+func dataKey(ctx context.Context, encryption *mongo.ClientEncryption) (primitive.Binary, error) {
+	var existing struct {
+		ID primitive.Binary `bson:"_id"`
+	}
+	err := encryption.GetKeyByAltName(ctx, keyAltName).Decode(&existing)
+	if err == nil {
+		return existing.ID, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.Binary{}, fmt.Errorf("find data key %s: %w", keyAltName, err)
+	}
+	id, err := encryption.CreateDataKey(ctx, "local", options.DataKey().SetKeyAltNames([]string{keyAltName}))
+	if err != nil {
+		return primitive.Binary{}, fmt.Errorf("create data key: %w", err)
+	}
+	fmt.Printf("Created data key %x\n", id.Data)
+	return id, nil
+}
+
+// schema is the $jsonSchema telling the driver which fields to encrypt. It is
+// given to the client rather than read from the server, so a server whose
+// validator was tampered with cannot make the client send plaintext.
+func schema(keyID primitive.Binary) bson.D {
+	encrypted := func(algorithm string) bson.D {
+		return bson.D{{"encrypt", bson.D{
+			{"bsonType", "string"},
+			{"algorithm", algorithm},
+		}}}
+	}
+	return bson.D{
+		{"bsonType", "object"},
+		{"encryptMetadata", bson.D{{"keyId", bson.A{keyID}}}},
+		{"properties", bson.D{
+			{"email", encrypted("AEAD_AES_256_CBC_HMAC_SHA_512-Deterministic")},
+			{"card", encrypted("AEAD_AES_256_CBC_HMAC_SHA_512-Random")},
+		}},
+	}
+}
+
+var (
+	keyFile     = flag.String("key-file", "csfle-master-key.txt", "local master key, created when missing")
+	cryptShared = flag.String("crypt-shared", os.Getenv("CRYPT_SHARED_LIB_PATH"), "path to the crypt_shared library (default: $CRYPT_SHARED_LIB_PATH); mongocryptd is spawned without it")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	key, err := localkey.Load(*keyFile)
+	if err != nil {
+		return err
+	}
+	kmsProviders := localkey.Providers(key)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// A Unique Index On keyAltNames Keeps Two Keys From Sharing A Name
+	keyVault := client.Database("encryption").Collection("__keyVault")
+	_, err = keyVault.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"keyAltNames", 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.D{{"keyAltNames", bson.D{{"$exists", true}}}}),
+	})
+	if err != nil {
+		return fmt.Errorf("create index on key vault: %w", err)
+	}
+
+	encryption, err := mongo.NewClientEncryption(client, options.ClientEncryption().
+		SetKeyVaultNamespace(keyVaultNamespace).
+		SetKmsProviders(kmsProviders))
+	if err != nil {
+		return fmt.Errorf("client encryption needs libmongocrypt and a build with -tags cse: %w", err)
+	}
+	defer encryption.Close(context.Background())
+	keyID, err := dataKey(ctx, encryption)
+	if err != nil {
+		return err
+	}
+
+	// The Encrypted Client Encrypts And Decrypts The Schema's Fields Itself
+	autoEncryption := options.AutoEncryption().
+		SetKeyVaultNamespace(keyVaultNamespace).
+		SetKmsProviders(kmsProviders).
+		SetSchemaMap(map[string]interface{}{listenersNS: schema(keyID)})
+	if *cryptShared != "" {
+		autoEncryption.SetExtraOptions(map[string]interface{}{
+			"cryptSharedLibPath":     *cryptShared,
+			"cryptSharedLibRequired": true,
+		})
+	}
+	encryptedClient, err := db.Connect(ctx, options.Client().SetAutoEncryptionOptions(autoEncryption))
+	if err != nil {
+		return fmt.Errorf("connect with automatic encryption: %w", err)
+	}
+	down.Client(encryptedClient)
+
+	listenersCollection := encryptedClient.Database("quickstart").Collection("csfle_listeners")
+	if err = listenersCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop csfle_listeners: %w", err)
+	}
+	_, err = listenersCollection.InsertMany(ctx, []interface{}{
+		Listener{Name: "Ada", Email: "ada@example.com", Card: "4242 4242 4242 4242"},
+		Listener{Name: "Grace", Email: "grace@example.com", Card: "4242 4242 4242 4242"},
+	})
+	if err != nil {
+		return fmt.Errorf("insert into csfle_listeners: %w", err)
+	}
+
+	// Deterministic Encryption Allows Equality Matches On Encrypted Fields
+	var listener Listener
+	if err = listenersCollection.FindOne(ctx, bson.D{{"email", "grace@example.com"}}).Decode(&listener); err != nil {
+		return fmt.Errorf("find listener by email: %w", err)
+	}
+	fmt.Printf("Encrypted client: %s <%s> card %s\n", listener.Name, listener.Email, listener.Card)
+
+	// Without The Keys The Same Documents Only Hold Ciphertext
+	cursor, err := client.Database("quickstart").Collection("csfle_listeners").Find(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("find in csfle_listeners: %w", err)
+	}
+	var raw []bson.M
+	if err = cursor.All(ctx, &raw); err != nil {
+		return fmt.Errorf("decode csfle_listeners: %w", err)
+	}
+	for _, document := range raw {
+		email := document["email"].(primitive.Binary)
+		card := document["card"].(primitive.Binary)
+		fmt.Printf("Plain client: %s email=Binary(subtype %d, %d bytes) card=%s...\n",
+			document["name"], email.Subtype, len(email.Data), base64.StdEncoding.EncodeToString(card.Data)[:16])
+	}
+	return nil
+}
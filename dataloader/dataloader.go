@@ -0,0 +1,150 @@
+// Package dataloader coalesces individual loads by key made within a short
+// window into a single batched query, then hands each caller its own result.
+// Resolvers that load one related document per item (the N+1 pattern) end up
+// issuing one $in query per window instead of one query per item.
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Load when the batch function has no value for the key
+var ErrNotFound = errors.New("dataloader: not found")
+
+// BatchFunc loads the values for a set of distinct keys. Keys without a value
+// are left out of the returned map.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader batches calls to Load. It is safe for concurrent use; create one per
+// request so that results are never cached across users.
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	seen    map[K]struct{}
+	done    chan struct{}
+	values  map[K]V
+	err     error
+	started bool
+}
+
+// Options configures a Loader
+type Options struct {
+	// Wait is how long the first Load of a batch waits for others to join
+	Wait time.Duration
+	// MaxBatch dispatches a batch early once it holds this many keys
+	MaxBatch int
+	// Timeout bounds each batch query, independently of the callers' contexts
+	Timeout time.Duration
+}
+
+// New returns a Loader calling fetch for every batch. Zero options default to
+// a 2ms window, batches of 100 keys and a 5 second query timeout.
+func New[K comparable, V any](fetch BatchFunc[K, V], opts Options) *Loader[K, V] {
+	if opts.Wait <= 0 {
+		opts.Wait = 2 * time.Millisecond
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 100
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Loader[K, V]{fetch: fetch, wait: opts.Wait, maxBatch: opts.MaxBatch, timeout: opts.Timeout}
+}
+
+// Load returns the value for key, waiting for the batch it joined to be
+// fetched. Cancelling ctx stops this caller waiting but not the shared query.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	b := l.join(ctx, key)
+	var zero V
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-b.done:
+	}
+	if b.err != nil {
+		return zero, b.err
+	}
+	value, ok := b.values[key]
+	if !ok {
+		return zero, ErrNotFound
+	}
+	return value, nil
+}
+
+// LoadMany loads several keys through the same batching, returning values
+// and errors at the same positions as keys
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}()
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// join adds key to the pending batch, starting a new one if needed
+func (l *Loader[K, V]) join(ctx context.Context, key K) *batch[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.pending
+	if b == nil {
+		b = &batch[K, V]{
+			// the query outlives any single caller, but keeps its values
+			ctx:  context.WithoutCancel(ctx),
+			seen: map[K]struct{}{},
+			done: make(chan struct{}),
+		}
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+	if _, ok := b.seen[key]; !ok {
+		b.seen[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+	if len(b.keys) >= l.maxBatch {
+		// a full batch takes no more keys, even before dispatch starts
+		l.pending = nil
+		go l.dispatch(b)
+	}
+	return b
+}
+
+// dispatch runs the batch once, whichever of the timer or the size limit
+// triggers it first
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if b.started {
+		l.mu.Unlock()
+		return
+	}
+	b.started = true
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(b.ctx, l.timeout)
+	defer cancel()
+	b.values, b.err = l.fetch(ctx, b.keys)
+	close(b.done)
+}
@@ -0,0 +1,198 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a BatchFunc returning the string form of every key except
+// the missing ones, and keeping the keys of each batch it was called with
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+	missing map[int]bool
+	err     error
+	// release, when set, holds every fetch until it is closed
+	release chan struct{}
+}
+
+func (r *recorder) fetch(ctx context.Context, keys []int) (map[int]string, error) {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]int(nil), keys...))
+	r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	values := map[int]string{}
+	for _, key := range keys {
+		if !r.missing[key] {
+			values[key] = strconv.Itoa(key)
+		}
+	}
+	return values, nil
+}
+
+// sorted returns the recorded batches with their keys in ascending order
+func (r *recorder) sorted() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batches := [][]int{}
+	for _, batch := range r.batches {
+		keys := append([]int(nil), batch...)
+		sort.Ints(keys)
+		batches = append(batches, keys)
+	}
+	return batches
+}
+
+func TestBatchingWindow(t *testing.T) {
+	r := &recorder{}
+	loader := New(r.fetch, Options{Wait: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	values, errs := loader.LoadMany(ctx, []int{1, 2, 3})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	// a load after the window closed starts a batch of its own
+	if _, err := loader.Load(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{1, 2, 3}, {4}}; !reflect.DeepEqual(r.sorted(), want) {
+		t.Errorf("batches = %v, want %v", r.sorted(), want)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	r := &recorder{}
+	loader := New(r.fetch, Options{Wait: 20 * time.Millisecond})
+
+	values, errs := loader.LoadMany(context.Background(), []int{1, 2, 1, 3, 2})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+	}
+	if want := []string{"1", "2", "1", "3", "2"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	if want := [][]int{{1, 2, 3}}; !reflect.DeepEqual(r.sorted(), want) {
+		t.Errorf("batches = %v, want each key once in %v", r.sorted(), want)
+	}
+}
+
+func TestMaxBatch(t *testing.T) {
+	r := &recorder{}
+	loader := New(r.fetch, Options{Wait: 50 * time.Millisecond, MaxBatch: 3})
+
+	keys := []int{1, 2, 3, 4, 5, 6, 7}
+	if _, errs := loader.LoadMany(context.Background(), keys); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	var loaded []int
+	for _, batch := range r.sorted() {
+		if len(batch) > 3 {
+			t.Errorf("batch %v holds more than 3 keys", batch)
+		}
+		loaded = append(loaded, batch...)
+	}
+	sort.Ints(loaded)
+	if !reflect.DeepEqual(loaded, keys) {
+		t.Errorf("loaded keys = %v, want %v each once", loaded, keys)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing key", func(t *testing.T) {
+		r := &recorder{missing: map[int]bool{2: true}}
+		loader := New(r.fetch, Options{})
+		_, errs := loader.LoadMany(ctx, []int{1, 2})
+		if errs[0] != nil {
+			t.Errorf("key 1: %v", errs[0])
+		}
+		if !errors.Is(errs[1], ErrNotFound) {
+			t.Errorf("key 2: %v, want ErrNotFound", errs[1])
+		}
+	})
+
+	t.Run("failed batch", func(t *testing.T) {
+		failure := errors.New("server down")
+		r := &recorder{err: failure}
+		loader := New(r.fetch, Options{})
+		_, errs := loader.LoadMany(ctx, []int{1, 2})
+		for i, err := range errs {
+			if !errors.Is(err, failure) {
+				t.Errorf("key %d: %v, want the batch error", i, err)
+			}
+		}
+	})
+
+	t.Run("canceled caller", func(t *testing.T) {
+		r := &recorder{release: make(chan struct{})}
+		loader := New(r.fetch, Options{})
+		canceled, cancel := context.WithCancel(ctx)
+		result := make(chan error, 1)
+		go func() {
+			_, err := loader.Load(canceled, 1)
+			result <- err
+		}()
+		other := make(chan error, 1)
+		go func() {
+			_, err := loader.Load(ctx, 1)
+			other <- err
+		}()
+		cancel()
+		if err := <-result; !errors.Is(err, context.Canceled) {
+			t.Errorf("canceled caller: %v, want context.Canceled", err)
+		}
+		// the shared query carries on for the caller still waiting
+		close(r.release)
+		if err := <-other; err != nil {
+			t.Errorf("other caller: %v", err)
+		}
+	})
+}
+
+func TestConcurrentLoads(t *testing.T) {
+	r := &recorder{}
+	loader := New(r.fetch, Options{Wait: 5 * time.Millisecond, MaxBatch: 10})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			value, err := loader.Load(ctx, key)
+			if err != nil {
+				t.Errorf("key %d: %v", key, err)
+				return
+			}
+			if value != strconv.Itoa(key) {
+				t.Errorf("key %d: got the value %q of another key", key, value)
+			}
+		}(i % 50)
+	}
+	wg.Wait()
+	for _, batch := range r.sorted() {
+		if len(batch) > 10 {
+			t.Errorf("batch %v holds more than 10 keys", batch)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package dataloader
+
+import (
+	"context"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Podcasts returns a loader fetching documents from the "podcasts"
+// collection by _id, one $in query per batch
+func Podcasts(collection *mongo.Collection, opts Options) *Loader[dto.ID, dto.Podcast] {
+	return New(func(ctx context.Context, ids []dto.ID) (map[dto.ID]dto.Podcast, error) {
+		cursor, err := collection.Find(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}})
+		if err != nil {
+			return nil, err
+		}
+		var podcasts []dto.Podcast
+		if err = cursor.All(ctx, &podcasts); err != nil {
+			return nil, err
+		}
+		byID := make(map[dto.ID]dto.Podcast, len(podcasts))
+		for _, podcast := range podcasts {
+			byID[podcast.ID] = podcast
+		}
+		return byID, nil
+	}, opts)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DatabaseStats holds the fields of the dbStats command we track
+type DatabaseStats struct {
+	Collections int64 `bson:"collections" json:"collections"`
+	Objects     int64 `bson:"objects" json:"objects"`
+	DataSize    int64 `bson:"dataSize" json:"data_size"`
+	StorageSize int64 `bson:"storageSize" json:"storage_size"`
+	IndexSize   int64 `bson:"indexSize" json:"index_size"`
+}
+
+// CollectionStats holds the storageStats of a $collStats stage
+type CollectionStats struct {
+	Name           string           `bson:"name" json:"name"`
+	Count          int64            `bson:"count" json:"count"`
+	Size           int64            `bson:"size" json:"size"`
+	StorageSize    int64            `bson:"storageSize" json:"storage_size"`
+	TotalIndexSize int64            `bson:"totalIndexSize" json:"total_index_size"`
+	IndexSizes     map[string]int64 `bson:"indexSizes" json:"index_sizes"`
+}
+
+// Snapshot represents the schema for the "storage_snapshots" metrics collection
+type Snapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Database    string             `bson:"database" json:"database"`
+	TakenAt     time.Time          `bson:"taken_at" json:"taken_at"`
+	Stats       DatabaseStats      `bson:"stats" json:"stats"`
+	Collections []CollectionStats  `bson:"collections" json:"collections"`
+}
+
+func takeSnapshot(ctx context.Context, database *mongo.Database, skip string) (Snapshot, error) {
+	snapshot := Snapshot{Database: database.Name(), TakenAt: time.Now().UTC()}
+	if err := database.RunCommand(ctx, bson.D{{"dbStats", 1}}).Decode(&snapshot.Stats); err != nil {
+		return snapshot, fmt.Errorf("dbStats: %w", err)
+	}
+
+	names, err := database.ListCollectionNames(ctx, bson.D{{"type", "collection"}})
+	if err != nil {
+		return snapshot, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == skip {
+			continue
+		}
+		collStatsStage := bson.D{{"$collStats", bson.D{{"storageStats", bson.D{}}}}}
+		cursor, err := database.Collection(name).Aggregate(ctx, mongo.Pipeline{collStatsStage})
+		if err != nil {
+			return snapshot, fmt.Errorf("$collStats %s: %w", name, err)
+		}
+		var results []struct {
+			StorageStats CollectionStats `bson:"storageStats"`
+		}
+		if err = cursor.All(ctx, &results); err != nil {
+			return snapshot, err
+		}
+		// sharded collections report one document per shard
+		stats := CollectionStats{Name: name, IndexSizes: map[string]int64{}}
+		for _, result := range results {
+			stats.Count += result.StorageStats.Count
+			stats.Size += result.StorageStats.Size
+			stats.StorageSize += result.StorageStats.StorageSize
+			stats.TotalIndexSize += result.StorageStats.TotalIndexSize
+			for index, size := range result.StorageStats.IndexSizes {
+				stats.IndexSizes[index] += size
+			}
+		}
+		snapshot.Collections = append(snapshot.Collections, stats)
+	}
+	return snapshot, nil
+}
+
+func previousSnapshot(ctx context.Context, metrics *mongo.Collection, database string) (*Snapshot, error) {
+	var previous Snapshot
+	opts := options.FindOne().SetSort(bson.D{{"taken_at", -1}})
+	err := metrics.FindOne(ctx, bson.D{{"database", database}}, opts).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n), 0
+	for value >= unit*unit || value <= -unit*unit {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value/unit, "KMGTPE"[exp])
+}
+
+func growth(current, previous int64, known bool) string {
+	if !known {
+		return "-"
+	}
+	delta := current - previous
+	if delta >= 0 {
+		return "+" + humanBytes(delta)
+	}
+	return humanBytes(delta)
+}
+
+func printTable(snapshot Snapshot, previous *Snapshot) {
+	before := map[string]CollectionStats{}
+	if previous != nil {
+		for _, stats := range previous.Collections {
+			before[stats.Name] = stats
+		}
+		fmt.Printf("Compared with snapshot from %s\n\n", previous.TakenAt.Format(time.RFC3339))
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "COLLECTION\tDOCS\tDATA\tSTORAGE\tINDEXES\tDATA GROWTH\tINDEX GROWTH\t")
+	for _, stats := range snapshot.Collections {
+		old, known := before[stats.Name]
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", stats.Name, stats.Count,
+			humanBytes(stats.Size), humanBytes(stats.StorageSize), humanBytes(stats.TotalIndexSize),
+			growth(stats.Size, old.Size, known), growth(stats.TotalIndexSize, old.TotalIndexSize, known))
+	}
+	var old DatabaseStats
+	if previous != nil {
+		old = previous.Stats
+	}
+	fmt.Fprintf(table, "TOTAL\t%d\t%s\t%s\t%s\t%s\t%s\t\n", snapshot.Stats.Objects,
+		humanBytes(snapshot.Stats.DataSize), humanBytes(snapshot.Stats.StorageSize), humanBytes(snapshot.Stats.IndexSize),
+		growth(snapshot.Stats.DataSize, old.DataSize, previous != nil), growth(snapshot.Stats.IndexSize, old.IndexSize, previous != nil))
+	table.Flush()
+
+	fmt.Println()
+	for _, stats := range snapshot.Collections {
+		names := make([]string, 0, len(stats.IndexSizes))
+		for name := range stats.IndexSizes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s.%s: %s\n", stats.Name, name, humanBytes(stats.IndexSizes[name]))
+		}
+	}
+}
+
+var (
+	databaseName = flag.String("db", "quickstart", "database to report on")
+	asJSON       = flag.Bool("json", false, "print the snapshot as JSON instead of a table")
+	save         = flag.Bool("save", true, "persist the snapshot for growth tracking")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database(*databaseName)
+	metrics := database.Collection("storage_snapshots")
+
+	snapshot, err := takeSnapshot(ctx, database, metrics.Name())
+	if err != nil {
+		return err
+	}
+	previous, err := previousSnapshot(ctx, metrics, database.Name())
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(map[string]interface{}{"current": snapshot, "previous": previous}); err != nil {
+			return err
+		}
+	} else {
+		printTable(snapshot, previous)
+	}
+
+	if *save {
+		if _, err = metrics.InsertOne(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
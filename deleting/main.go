@@ -1,49 +1,12 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"log"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/deleting"
 )
 
 func main() {
-	client, err := mongo.NewClient(options.Client().ApplyURI("<ATLAS_URI_HERE>"))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer client.Disconnect(ctx)
-
-	database := client.Database("quickstart")
-	podcastsCollection := database.Collection("podcasts")
-	episodesCollection := database.Collection("episodes")
-
-	result, err := podcastsCollection.DeleteOne(ctx, bson.M{"title": "The Polyglot Developer Podcast"})
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("DeleteOne removed %v document(s)\n", result.DeletedCount)
-
-	result, err = episodesCollection.DeleteMany(ctx, bson.M{"duration": 25})
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("DeleteMany removed %v document(s)\n", result.DeletedCount)
-
-	if err = podcastsCollection.Drop(ctx); err != nil {
-		log.Fatal(err)
-	}
-
-	if err = episodesCollection.Drop(ctx); err != nil {
-		log.Fatal(err)
-	}
+	examples.Main(deleting.Run, 10*time.Second)
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/fixtures"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed script.yaml
+var defaultScript []byte
+
+// Step is one entry of a demo script. Strings in documents, filters and
+// updates may use the fixtures placeholders, such as {{id "episode"}}.
+type Step struct {
+	Op         string                 `yaml:"op"`
+	Collection string                 `yaml:"collection"`
+	Document   map[string]interface{} `yaml:"document"`
+	Filter     map[string]interface{} `yaml:"filter"`
+	Update     map[string]interface{} `yaml:"update"`
+	Many       bool                   `yaml:"many"`
+	Text       string                 `yaml:"text"`
+	Duration   string                 `yaml:"duration"`
+}
+
+// Player runs a script against a database
+type Player struct {
+	Database *mongo.Database
+	Pace     time.Duration
+	Manual   bool
+
+	placeholders *fixtures.Set
+	input        *bufio.Scanner
+}
+
+var (
+	scriptPath = flag.String("script", "", "YAML script to replay (default: the built-in episodes script)")
+	pace       = flag.Duration("pace", 2*time.Second, "delay between write steps")
+	manual     = flag.Bool("manual", false, "wait for enter before every write step instead of pacing")
+	loop       = flag.Bool("loop", false, "replay the script until interrupted")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	data := defaultScript
+	if *scriptPath != "" {
+		var err error
+		if data, err = os.ReadFile(*scriptPath); err != nil {
+			return err
+		}
+	}
+	var script []Step
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return fmt.Errorf("script: %v", err)
+	}
+
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	player := &Player{
+		Database: client.Database("quickstart"),
+		Pace:     *pace,
+		Manual:   *manual,
+		input:    bufio.NewScanner(os.Stdin),
+	}
+	for {
+		err = player.Play(ctx, script)
+		if err != nil || !*loop {
+			break
+		}
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// Play runs every step in order. Each run gets fresh placeholders, so named
+// ids are stable within a run and new in the next one.
+func (p *Player) Play(ctx context.Context, script []Step) error {
+	p.placeholders = fixtures.New(p.Database)
+	for i, step := range script {
+		if err := p.run(ctx, step); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+	}
+	return nil
+}
+
+func (p *Player) run(ctx context.Context, step Step) error {
+	switch step.Op {
+	case "say":
+		fmt.Printf("\n# %s\n", step.Text)
+		return nil
+	case "pause":
+		return p.waitForEnter(step.Text)
+	case "sleep":
+		duration, err := time.ParseDuration(step.Duration)
+		if err != nil {
+			return err
+		}
+		return sleep(ctx, duration)
+	case "insert", "update", "replace", "delete":
+		if p.Manual {
+			if err := p.waitForEnter("Press enter to " + step.Op); err != nil {
+				return err
+			}
+		}
+		if err := p.write(ctx, step); err != nil {
+			return err
+		}
+		if p.Manual {
+			return nil
+		}
+		return sleep(ctx, p.Pace)
+	}
+	return fmt.Errorf("unknown op %q", step.Op)
+}
+
+// write performs an insert, update, replace or delete step
+func (p *Player) write(ctx context.Context, step Step) error {
+	if step.Collection == "" {
+		return errors.New("collection is required")
+	}
+	collection := p.Database.Collection(step.Collection)
+	filter, err := p.expand(step.Filter)
+	if err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+	document, err := p.expand(step.Document)
+	if err != nil {
+		return fmt.Errorf("document: %w", err)
+	}
+	update, err := p.expand(step.Update)
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	switch step.Op {
+	case "insert":
+		result, err := collection.InsertOne(ctx, document)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("inserted %v into %s\n", result.InsertedID, step.Collection)
+	case "update":
+		var result *mongo.UpdateResult
+		if step.Many {
+			result, err = collection.UpdateMany(ctx, filter, update)
+		} else {
+			result, err = collection.UpdateOne(ctx, filter, update)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("updated %d document(s) in %s\n", result.ModifiedCount, step.Collection)
+	case "replace":
+		result, err := collection.ReplaceOne(ctx, filter, document)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("replaced %d document(s) in %s\n", result.ModifiedCount, step.Collection)
+	case "delete":
+		var result *mongo.DeleteResult
+		if step.Many {
+			result, err = collection.DeleteMany(ctx, filter)
+		} else {
+			result, err = collection.DeleteOne(ctx, filter)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deleted %d document(s) from %s\n", result.DeletedCount, step.Collection)
+	}
+	return nil
+}
+
+// expand resolves placeholders, treating a missing document as empty
+func (p *Player) expand(document map[string]interface{}) (bson.M, error) {
+	if document == nil {
+		return bson.M{}, nil
+	}
+	value, err := p.placeholders.Expand(document)
+	if err != nil {
+		return nil, err
+	}
+	return value.(bson.M), nil
+}
+
+func (p *Player) waitForEnter(message string) error {
+	if message == "" {
+		message = "Press enter to continue"
+	}
+	fmt.Printf("%s ", message)
+	if !p.input.Scan() {
+		if err := p.input.Err(); err != nil {
+			return err
+		}
+		return errors.New("standard input closed")
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
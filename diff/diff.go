@@ -0,0 +1,205 @@
+// Package diff compares two versions of a document and builds the smallest
+// update turning the first into the second: changed fields are $set by their
+// dotted path, removed fields are $unset, and arrays are handled by a
+// configurable strategy. Sending only what changed keeps concurrent edits of
+// different fields from overwriting each other, which a ReplaceOne would do.
+package diff
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ArrayStrategy decides how a changed array is written
+type ArrayStrategy int
+
+const (
+	// Replace sets the whole array
+	Replace ArrayStrategy = iota
+	// ByIndex sets the changed elements by index when the array did not
+	// shrink, and replaces it otherwise
+	ByIndex
+	// Append pushes the new elements when the original array is a prefix of
+	// the edited one, and replaces it otherwise
+	Append
+)
+
+// Options tune how updates are built
+type Options struct {
+	Arrays ArrayStrategy
+	// Ignore lists dotted paths left out of the comparison, such as fields
+	// the server maintains
+	Ignore []string
+}
+
+// Update returns the update document turning original into edited. Both may
+// be anything bson.Marshal accepts, typically the same struct type or bson.M.
+// Fields dropped by omitempty count as removed. The update is empty when the
+// documents are equal; _id is never part of it.
+func Update(original, edited interface{}, opts ...Options) (bson.D, error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	before, err := toRaw(original)
+	if err != nil {
+		return nil, fmt.Errorf("original: %w", err)
+	}
+	after, err := toRaw(edited)
+	if err != nil {
+		return nil, fmt.Errorf("edited: %w", err)
+	}
+
+	b := &builder{options: o, ignore: map[string]bool{"_id": true}}
+	for _, path := range o.Ignore {
+		b.ignore[path] = true
+	}
+	if err = b.document("", before, after); err != nil {
+		return nil, err
+	}
+	return b.update(), nil
+}
+
+func toRaw(v interface{}) (bson.Raw, error) {
+	if raw, ok := v.(bson.Raw); ok {
+		return raw, nil
+	}
+	data, err := bson.Marshal(v)
+	return bson.Raw(data), err
+}
+
+type builder struct {
+	options Options
+	ignore  map[string]bool
+	set     bson.D
+	unset   bson.D
+	push    bson.D
+}
+
+func (b *builder) update() bson.D {
+	update := bson.D{}
+	if len(b.set) > 0 {
+		update = append(update, bson.E{"$set", b.set})
+	}
+	if len(b.unset) > 0 {
+		update = append(update, bson.E{"$unset", b.unset})
+	}
+	if len(b.push) > 0 {
+		update = append(update, bson.E{"$push", b.push})
+	}
+	return update
+}
+
+// document compares two embedded documents found at prefix
+func (b *builder) document(prefix string, before, after bson.Raw) error {
+	afterElements, err := after.Elements()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(afterElements))
+	for _, element := range afterElements {
+		key := element.Key()
+		seen[key] = true
+		path := join(prefix, key)
+		if b.ignore[path] {
+			continue
+		}
+		old, err := before.LookupErr(key)
+		if err != nil {
+			b.set = append(b.set, bson.E{path, element.Value()})
+			continue
+		}
+		if err = b.value(path, old, element.Value()); err != nil {
+			return err
+		}
+	}
+
+	beforeElements, err := before.Elements()
+	if err != nil {
+		return err
+	}
+	for _, element := range beforeElements {
+		path := join(prefix, element.Key())
+		if !seen[element.Key()] && !b.ignore[path] {
+			b.unset = append(b.unset, bson.E{path, ""})
+		}
+	}
+	return nil
+}
+
+// value compares two values found at path
+func (b *builder) value(path string, before, after bson.RawValue) error {
+	if before.Equal(after) {
+		return nil
+	}
+	if before.Type != after.Type {
+		b.set = append(b.set, bson.E{path, after})
+		return nil
+	}
+	switch after.Type {
+	case bson.TypeEmbeddedDocument:
+		return b.document(path, before.Document(), after.Document())
+	case bson.TypeArray:
+		return b.array(path, before.Array(), after.Array())
+	}
+	b.set = append(b.set, bson.E{path, after})
+	return nil
+}
+
+// array writes a changed array according to the array strategy
+func (b *builder) array(path string, before, after bson.Raw) error {
+	old, err := before.Values()
+	if err != nil {
+		return err
+	}
+	updated, err := after.Values()
+	if err != nil {
+		return err
+	}
+	replace := bson.E{path, bson.RawValue{Type: bson.TypeArray, Value: after}}
+
+	switch b.options.Arrays {
+	case ByIndex:
+		if len(updated) < len(old) {
+			b.set = append(b.set, replace)
+			return nil
+		}
+		for i, value := range updated {
+			elementPath := fmt.Sprintf("%s.%d", path, i)
+			if i >= len(old) || old[i].Type != value.Type {
+				b.set = append(b.set, bson.E{elementPath, value})
+				continue
+			}
+			if err = b.value(elementPath, old[i], value); err != nil {
+				return err
+			}
+		}
+	case Append:
+		if len(updated) < len(old) {
+			b.set = append(b.set, replace)
+			return nil
+		}
+		for i := range old {
+			if !old[i].Equal(updated[i]) {
+				b.set = append(b.set, replace)
+				return nil
+			}
+		}
+		added := make(bson.A, 0, len(updated)-len(old))
+		for _, value := range updated[len(old):] {
+			added = append(added, value)
+		}
+		b.push = append(b.push, bson.E{path, bson.D{{"$each", added}}})
+	default:
+		b.set = append(b.set, replace)
+	}
+	return nil
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
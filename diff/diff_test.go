@@ -0,0 +1,106 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type profile struct {
+	City    string `bson:"city,omitempty"`
+	Country string `bson:"country,omitempty"`
+}
+
+type user struct {
+	ID      int      `bson:"_id"`
+	Name    string   `bson:"name,omitempty"`
+	Profile *profile `bson:"profile,omitempty"`
+	Tags    []string `bson:"tags,omitempty"`
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		original interface{}
+		edited   interface{}
+		options  Options
+		want     bson.D
+	}{
+		{"equal", user{ID: 1, Name: "Ada"}, user{ID: 1, Name: "Ada"}, Options{}, bson.D{}},
+		{"_id left out", user{ID: 1, Name: "Ada"}, user{ID: 2, Name: "Ada"}, Options{}, bson.D{}},
+		{"changed field", user{Name: "Ada"}, user{Name: "Grace"}, Options{},
+			bson.D{{"$set", bson.D{{"name", "Grace"}}}}},
+		{"added field", user{}, user{Name: "Ada"}, Options{},
+			bson.D{{"$set", bson.D{{"name", "Ada"}}}}},
+		{"removed by omitempty", user{Name: "Ada"}, user{}, Options{},
+			bson.D{{"$unset", bson.D{{"name", ""}}}}},
+		{"nested field", user{Profile: &profile{City: "Berlin", Country: "DE"}}, user{Profile: &profile{City: "Paris", Country: "DE"}}, Options{},
+			bson.D{{"$set", bson.D{{"profile.city", "Paris"}}}}},
+		{"nested field removed", user{Profile: &profile{City: "Berlin", Country: "DE"}}, user{Profile: &profile{Country: "DE"}}, Options{},
+			bson.D{{"$unset", bson.D{{"profile.city", ""}}}}},
+		{"embedded document added", user{}, user{Profile: &profile{City: "Paris"}}, Options{},
+			bson.D{{"$set", bson.D{{"profile", bson.D{{"city", "Paris"}}}}}}},
+		{"type changed", bson.D{{"age", "41"}}, bson.D{{"age", 41}}, Options{},
+			bson.D{{"$set", bson.D{{"age", 41}}}}},
+		{"document replaced by a value", bson.D{{"address", bson.D{{"city", "Berlin"}}}}, bson.D{{"address", "Berlin"}}, Options{},
+			bson.D{{"$set", bson.D{{"address", "Berlin"}}}}},
+		{"set and unset together", bson.D{{"a", 1}, {"b", 2}}, bson.D{{"a", 2}, {"c", 3}}, Options{},
+			bson.D{{"$set", bson.D{{"a", 2}, {"c", 3}}}, {"$unset", bson.D{{"b", ""}}}}},
+		{"ignored paths", bson.D{{"a", 1}, {"meta", bson.D{{"updated", 1}, {"by", "x"}}}}, bson.D{{"a", 1}, {"meta", bson.D{{"updated", 2}, {"by", "y"}}}},
+			Options{Ignore: []string{"meta.updated"}},
+			bson.D{{"$set", bson.D{{"meta.by", "y"}}}}},
+
+		{"array replaced", user{Tags: []string{"a", "b"}}, user{Tags: []string{"a", "c"}}, Options{},
+			bson.D{{"$set", bson.D{{"tags", bson.A{"a", "c"}}}}}},
+		{"equal arrays", user{Tags: []string{"a"}}, user{Tags: []string{"a"}}, Options{Arrays: ByIndex}, bson.D{}},
+		{"array by index", user{Tags: []string{"a", "b"}}, user{Tags: []string{"a", "c", "d"}}, Options{Arrays: ByIndex},
+			bson.D{{"$set", bson.D{{"tags.1", "c"}, {"tags.2", "d"}}}}},
+		{"shrunk array by index", user{Tags: []string{"a", "b"}}, user{Tags: []string{"a"}}, Options{Arrays: ByIndex},
+			bson.D{{"$set", bson.D{{"tags", bson.A{"a"}}}}}},
+		{"documents in an array by index",
+			bson.D{{"devices", bson.A{bson.D{{"os", "ios"}, {"version", 16}}}}},
+			bson.D{{"devices", bson.A{bson.D{{"os", "ios"}, {"version", 17}}}}},
+			Options{Arrays: ByIndex},
+			bson.D{{"$set", bson.D{{"devices.0.version", 17}}}}},
+		{"element type changed by index", bson.D{{"a", bson.A{1, bson.D{{"x", 1}}}}}, bson.D{{"a", bson.A{1, "x"}}}, Options{Arrays: ByIndex},
+			bson.D{{"$set", bson.D{{"a.1", "x"}}}}},
+		{"array appended", user{Tags: []string{"a"}}, user{Tags: []string{"a", "b", "c"}}, Options{Arrays: Append},
+			bson.D{{"$push", bson.D{{"tags", bson.D{{"$each", bson.A{"b", "c"}}}}}}}},
+		{"array edited in place with Append", user{Tags: []string{"a", "b"}}, user{Tags: []string{"x", "b", "c"}}, Options{Arrays: Append},
+			bson.D{{"$set", bson.D{{"tags", bson.A{"x", "b", "c"}}}}}},
+		{"array shrunk with Append", user{Tags: []string{"a", "b"}}, user{Tags: []string{"a"}}, Options{Arrays: Append},
+			bson.D{{"$set", bson.D{{"tags", bson.A{"a"}}}}}},
+		{"array removed", user{Tags: []string{"a"}}, user{}, Options{Arrays: Append},
+			bson.D{{"$unset", bson.D{{"tags", ""}}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Update(test.original, test.edited, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// compare the encoded documents, since the update holds raw values
+			have, err := bson.Marshal(bson.D{{"update", got}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := bson.Marshal(bson.D{{"update", test.want}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(have, want) {
+				t.Errorf("Update =\n%s\nwant\n%s", bson.Raw(have), bson.Raw(want))
+			}
+		})
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	if _, err := Update(42, bson.D{}); err == nil {
+		t.Error("Update accepted an original that is not a document")
+	}
+	if _, err := Update(bson.D{}, "edited"); err == nil {
+		t.Error("Update accepted an edited value that is not a document")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// User represents the schema for the "users" collection
+type User struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty"`
+	Name          string               `bson:"name,omitempty"`
+	Email         string               `bson:"email,omitempty"`
+	Subscriptions []primitive.ObjectID `bson:"subscriptions,omitempty"`
+}
+
+// DigestEpisode is an episode joined with the title of its podcast
+type DigestEpisode struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	PodcastTitle string             `bson:"podcast_title"`
+	Title        string             `bson:"title"`
+	Description  string             `bson:"description"`
+	Duration     int32              `bson:"duration"`
+}
+
+// UserDigest represents an aggregation result-set of a user and their new episodes
+type UserDigest struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	Email    string             `bson:"email"`
+	Episodes []DigestEpisode    `bson:"episodes"`
+}
+
+// Period is the week a digest covers, identified by its ISO week key
+type Period struct {
+	Key   string
+	Start time.Time
+	End   time.Time
+}
+
+// previousWeek returns the last full Monday-to-Monday UTC week before now
+func previousWeek(now time.Time) Period {
+	now = now.UTC()
+	daysSinceMonday := (int(now.Weekday()) + 6) % 7
+	end := time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -7)
+	year, week := start.ISOWeek()
+	return Period{Key: fmt.Sprintf("%d-W%02d", year, week), Start: start, End: end}
+}
+
+// digestPipeline builds the aggregation producing digests for one batch of
+// users: new episodes of subscribed podcasts, minus users already sent, or
+// possibly sent, this period
+func digestPipeline(userIDs []primitive.ObjectID, period Period) mongo.Pipeline {
+	since := primitive.NewObjectIDFromTimestamp(period.Start)
+	until := primitive.NewObjectIDFromTimestamp(period.End)
+
+	matchStage := bson.D{{"$match", bson.D{{"_id", bson.D{{"$in", userIDs}}}}}}
+	sentStage := bson.D{{"$lookup", bson.D{
+		{"from", "digest_sends"},
+		{"let", bson.D{{"user", "$_id"}}},
+		{"pipeline", bson.A{
+			bson.D{{"$match", bson.D{
+				{"period", period.Key},
+				{"status", bson.D{{"$in", bson.A{"sent", "sending"}}}},
+				{"$expr", bson.D{{"$eq", bson.A{"$user", "$$user"}}}},
+			}}},
+			bson.D{{"$limit", 1}},
+		}},
+		{"as", "sent"},
+	}}}
+	notSentStage := bson.D{{"$match", bson.D{{"sent", bson.D{{"$size", 0}}}}}}
+	episodesStage := bson.D{{"$lookup", bson.D{
+		{"from", "episodes"},
+		{"let", bson.D{{"subscriptions", bson.D{{"$ifNull", bson.A{"$subscriptions", bson.A{}}}}}}},
+		{"pipeline", bson.A{
+			bson.D{{"$match", bson.D{
+				{"_id", bson.D{{"$gte", since}, {"$lt", until}}},
+				{"$expr", bson.D{{"$in", bson.A{"$podcast", "$$subscriptions"}}}},
+			}}},
+			bson.D{{"$lookup", bson.D{
+				{"from", "podcasts"},
+				{"localField", "podcast"},
+				{"foreignField", "_id"},
+				{"as", "podcast"},
+			}}},
+			bson.D{{"$unwind", "$podcast"}},
+			bson.D{{"$sort", bson.D{{"podcast.title", 1}, {"_id", 1}}}},
+			bson.D{{"$project", bson.D{
+				{"podcast_title", "$podcast.title"},
+				{"title", 1},
+				{"description", 1},
+				{"duration", 1},
+			}}},
+		}},
+		{"as", "episodes"},
+	}}}
+	nonEmptyStage := bson.D{{"$match", bson.D{{"episodes.0", bson.D{{"$exists", true}}}}}}
+	projectStage := bson.D{{"$project", bson.D{{"name", 1}, {"email", 1}, {"episodes", 1}}}}
+
+	return mongo.Pipeline{matchStage, sentStage, notSentStage, episodesStage, nonEmptyStage, projectStage}
+}
+
+// processBatch runs one aggregation for a batch of users and sends each digest
+func processBatch(ctx context.Context, database *mongo.Database, userIDs []primitive.ObjectID, period Period, renderer *Renderer, mailer Mailer, dryRun bool) (int, error) {
+	cursor, err := database.Collection("users").Aggregate(ctx, digestPipeline(userIDs, period))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	sends := database.Collection("digest_sends")
+	sent := 0
+	for cursor.Next(ctx) {
+		var digest UserDigest
+		if err = cursor.Decode(&digest); err != nil {
+			return sent, err
+		}
+		message, err := renderer.Render(digest, period)
+		if err != nil {
+			return sent, err
+		}
+		if dryRun {
+			fmt.Printf("[dry-run] %s: %d episode(s)\n", digest.Email, len(digest.Episodes))
+			continue
+		}
+		claimed, err := claimSend(ctx, sends, digest.ID, period)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		if err = mailer.Send(ctx, message); err != nil {
+			log.Printf("send to %s: %v", digest.Email, err)
+			// a failed digest is retried by the next run only once marked so
+			if err = markSend(ctx, sends, digest.ID, period, "failed", err.Error()); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if err = markSend(ctx, sends, digest.ID, period, "sent", ""); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, cursor.Err()
+}
+
+// claimSend records that a digest is being sent, and reports false when it
+// was sent already or may have been. Only a digest never tried or marked
+// "failed" can be claimed: the unique {user, period} index turns the upsert
+// for any other into a duplicate key error. A run that crashed between
+// sending and marking leaves its digest "sending"; it is never retried, so
+// re-running the job never emails the same user twice, and such records
+// are left to be checked by hand.
+func claimSend(ctx context.Context, sends *mongo.Collection, user primitive.ObjectID, period Period) (bool, error) {
+	now := time.Now().UTC()
+	_, err := sends.UpdateOne(ctx,
+		bson.D{{"user", user}, {"period", period.Key}, {"status", "failed"}},
+		bson.D{
+			{"$set", bson.D{{"status", "sending"}, {"updated_at", now}}},
+			{"$setOnInsert", bson.D{{"created_at", now}}},
+			{"$inc", bson.D{{"attempts", 1}}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func markSend(ctx context.Context, sends *mongo.Collection, user primitive.ObjectID, period Period, status, reason string) error {
+	set := bson.D{{"status", status}, {"updated_at", time.Now().UTC()}}
+	if reason != "" {
+		set = append(set, bson.E{"error", reason})
+	}
+	_, err := sends.UpdateOne(ctx, bson.D{{"user", user}, {"period", period.Key}}, bson.D{{"$set", set}})
+	return err
+}
+
+var (
+	batchSize = flag.Int("batch", 500, "number of users per aggregation")
+	dryRun    = flag.Bool("dry-run", false, "render digests without sending or recording them")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	_, err = database.Collection("digest_sends").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"user", 1}, {"period", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	renderer, err := NewRenderer()
+	if err != nil {
+		return err
+	}
+	mailer := NewMailer()
+	period := previousWeek(time.Now())
+	fmt.Printf("Building digests for %s (%s - %s)\n", period.Key, period.Start.Format(time.DateOnly), period.End.Format(time.DateOnly))
+
+	opts := options.Find().
+		SetProjection(bson.D{{"_id", 1}}).
+		SetSort(bson.D{{"_id", 1}}).
+		SetBatchSize(int32(*batchSize))
+	cursor, err := database.Collection("users").Find(ctx, bson.D{{"subscriptions.0", bson.D{{"$exists", true}}}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	total := 0
+	batch := make([]primitive.ObjectID, 0, *batchSize)
+	flush := func() error {
+		sent, err := processBatch(ctx, database, batch, period, renderer, mailer, *dryRun)
+		if err != nil {
+			return err
+		}
+		total += sent
+		batch = batch[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var user User
+		if err = cursor.Decode(&user); err != nil {
+			return err
+		}
+		batch = append(batch, user.ID)
+		if len(batch) == *batchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		if err = flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Sent %v digest(s)\n", total)
+	return nil
+}
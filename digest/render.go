@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const textDigest = `Hi {{.Name}},
+
+Here is what's new in your podcasts for {{.Period}}:
+{{range .Podcasts}}
+{{.Title}}
+{{range .Episodes}}  - {{.Title}} ({{.Duration}} min)
+{{end}}{{end}}`
+
+const htmlDigest = `<p>Hi {{.Name}},</p>
+<p>Here is what's new in your podcasts for {{.Period}}:</p>
+{{range .Podcasts}}<h3>{{.Title}}</h3>
+<ul>
+{{range .Episodes}}  <li><strong>{{.Title}}</strong> ({{.Duration}} min)<br>{{.Description}}</li>
+{{end}}</ul>
+{{end}}`
+
+// Message is a rendered digest ready to hand to a Mailer
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type podcastGroup struct {
+	Title    string
+	Episodes []DigestEpisode
+}
+
+type digestView struct {
+	Name     string
+	Period   string
+	Podcasts []podcastGroup
+}
+
+// Renderer turns aggregation results into plain text and HTML emails
+type Renderer struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// NewRenderer parses the digest templates
+func NewRenderer() (*Renderer, error) {
+	text, err := template.New("text").Parse(textDigest)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New("html").Parse(htmlDigest)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{text: text, html: html}, nil
+}
+
+// Render groups a user's episodes by podcast, relying on the pipeline's sort
+func (r *Renderer) Render(digest UserDigest, period Period) (Message, error) {
+	view := digestView{Name: digest.Name, Period: period.Key}
+	for _, episode := range digest.Episodes {
+		last := len(view.Podcasts) - 1
+		if last < 0 || view.Podcasts[last].Title != episode.PodcastTitle {
+			view.Podcasts = append(view.Podcasts, podcastGroup{Title: episode.PodcastTitle})
+			last++
+		}
+		view.Podcasts[last].Episodes = append(view.Podcasts[last].Episodes, episode)
+	}
+
+	var text, html bytes.Buffer
+	if err := r.text.Execute(&text, view); err != nil {
+		return Message{}, err
+	}
+	if err := r.html.Execute(&html, view); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To:      digest.Email,
+		Subject: fmt.Sprintf("Your podcast digest for %s", period.Key),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Mailer delivers a rendered digest
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewMailer returns an SMTP mailer when SMTP_ADDR is set and a mailer that
+// prints to stdout otherwise
+func NewMailer() Mailer {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return stdoutMailer{}
+	}
+	return smtpMailer{addr: addr, from: os.Getenv("SMTP_FROM")}
+}
+
+type stdoutMailer struct{}
+
+func (stdoutMailer) Send(ctx context.Context, message Message) error {
+	fmt.Printf("To: %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Text)
+	return nil
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+}
+
+func (m smtpMailer) Send(ctx context.Context, message Message) error {
+	const boundary = "quickstart-digest"
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", m.from, message.To, message.Subject)
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+	fmt.Fprintf(&body, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, message.Text)
+	fmt.Fprintf(&body, "--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n", boundary, message.HTML)
+	fmt.Fprintf(&body, "--%s--\r\n", boundary)
+	return smtp.SendMail(m.addr, nil, m.from, []string{message.To}, []byte(body.String()))
+}
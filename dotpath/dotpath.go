@@ -0,0 +1,231 @@
+// Package dotpath turns nested Go values into dot notation paths such as
+// "profile.location.city", checked against the struct the collection is
+// decoded into.
+//
+// An embedded document as a value replaces or matches the whole document:
+//
+//	{$set: {profile: {location: {city: "Munich"}}}}  // drops profile.location.country and the rest of profile
+//	{"profile.location": {country: "DE"}}            // only matches a location that is exactly {country: "DE"}
+//
+// Flattened into paths, they touch and compare only the fields given:
+//
+//	update, err := dotpath.Set[Listener]("profile", Profile{Location: Location{City: "Munich"}})
+//	// {$set: {"profile.location.city": "Munich"}}, with omitempty on Profile and Location
+//	filter, err := dotpath.Match[Listener]("profile.location", bson.D{{"country", "DE"}})
+//	// {"profile.location.country": "DE"}
+//
+// Fields left out by omitempty are left out of the paths, embedded structs
+// included, so a struct with only the changed fields set is a partial
+// update. Fields without omitempty are set to their zero value.
+package dotpath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonoptions"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ErrUnknownField is returned for paths naming a field the schema struct
+// does not have
+var ErrUnknownField = errors.New("unknown field")
+
+// registry marshals like the default one, except that a zero struct tagged
+// omitempty is left out instead of written as {}, which as a leaf path would
+// wipe the fields it stands for
+var registry = func() *bsoncodec.Registry {
+	codec, err := bsoncodec.NewStructCodec(bsoncodec.DefaultStructTagParser,
+		bsonoptions.StructCodec().SetEncodeOmitDefaultStruct(true))
+	if err != nil {
+		panic(err)
+	}
+	r := bson.NewRegistry()
+	r.RegisterKindEncoder(reflect.Struct, codec)
+	return r
+}()
+
+// Join returns parts joined into one path, skipping empty parts
+func Join(parts ...string) string {
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ".")
+}
+
+// Flatten returns one element per leaf of v, which may be anything
+// bson.Marshal accepts, keyed by its path below prefix. Arrays and empty
+// documents are leaves; the fields of every other embedded document become
+// paths of their own.
+func Flatten(prefix string, v interface{}) (bson.D, error) {
+	t, data, err := bson.MarshalValueWithRegistry(registry, v)
+	if err != nil {
+		return nil, err
+	}
+	value := bson.RawValue{Type: t, Value: data}
+	if t != bsontype.EmbeddedDocument {
+		if prefix == "" {
+			return nil, fmt.Errorf("%T is a %v, not a document", v, t)
+		}
+		return bson.D{{prefix, value}}, nil
+	}
+	flat := bson.D{}
+	if err = flatten(&flat, prefix, value.Document()); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+func flatten(flat *bson.D, prefix string, document bson.Raw) error {
+	elements, err := document.Elements()
+	if err != nil {
+		return err
+	}
+	if len(elements) == 0 && prefix != "" {
+		*flat = append(*flat, bson.E{Key: prefix, Value: bson.D{}})
+		return nil
+	}
+	for _, element := range elements {
+		key := element.Key()
+		if key == "" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return fmt.Errorf("field %q cannot be part of a path", Join(prefix, key))
+		}
+		path := Join(prefix, key)
+		value := element.Value()
+		if value.Type == bsontype.EmbeddedDocument {
+			if err = flatten(flat, path, value.Document()); err != nil {
+				return err
+			}
+			continue
+		}
+		*flat = append(*flat, bson.E{Key: path, Value: value})
+	}
+	return nil
+}
+
+// Set returns a $set of every leaf of v below prefix, after checking that
+// every path is a field of T
+func Set[T any](prefix string, v interface{}) (bson.D, error) {
+	flat, err := flattenFor[T](prefix, v)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{"$set", flat}}, nil
+}
+
+// Match returns a filter comparing every leaf of v below prefix, after
+// checking that every path is a field of T. Unlike an embedded document it
+// matches documents with more fields, or fields in another order.
+func Match[T any](prefix string, v interface{}) (bson.D, error) {
+	return flattenFor[T](prefix, v)
+}
+
+func flattenFor[T any](prefix string, v interface{}) (bson.D, error) {
+	flat, err := Flatten(prefix, v)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range flat {
+		if err = Check[T](element.Key); err != nil {
+			return nil, err
+		}
+	}
+	return flat, nil
+}
+
+// Check returns an error wrapping ErrUnknownField unless every path names a
+// field of T, going by bson struct tags. A path may go through maps, which
+// accept any key, and through arrays by index, by positional operator ("$",
+// "$[]" or "$[name]") or by naming a field of their elements, as queries do.
+// Below an interface{}, bson.M or bson.D anything goes.
+func Check[T any](paths ...string) error {
+	schema := reflect.TypeOf((*T)(nil)).Elem()
+	for _, path := range paths {
+		if err := check(schema, path, strings.Split(path, ".")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// positional matches what can stand for an array element in an update path
+var positional = regexp.MustCompile(`^\$(\[[a-z][A-Za-z0-9]*\]|\[\])?$`)
+
+var (
+	rawType = reflect.TypeOf(bson.Raw{})
+	dType   = reflect.TypeOf(bson.D{})
+)
+
+func check(t reflect.Type, path string, parts []string) error {
+	for i := 0; i < len(parts); i++ {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		part := parts[i]
+		switch {
+		case t.Kind() == reflect.Interface || t == rawType || t == dType:
+			return nil
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8:
+			t = t.Elem()
+			if _, err := strconv.Atoi(part); err != nil && !positional.MatchString(part) {
+				// a field of the elements, so look it up in the element type
+				i--
+			}
+		case t.Kind() == reflect.Struct:
+			field, ok := lookup(t, part)
+			if !ok {
+				return fmt.Errorf("%w %q in %s (%s)", ErrUnknownField, part, path, t)
+			}
+			t = field
+		default:
+			return fmt.Errorf("%w %q in %s: %s is not a document", ErrUnknownField, part, path, t)
+		}
+	}
+	return nil
+}
+
+// lookup returns the type of the field of struct t stored under name,
+// including the fields of inline structs
+func lookup(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, options, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if key == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			inline := field.Type
+			for inline.Kind() == reflect.Pointer {
+				inline = inline.Elem()
+			}
+			if inline.Kind() == reflect.Map {
+				return inline.Elem(), true
+			}
+			if found, ok := lookup(inline, name); ok {
+				return found, true
+			}
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if key == name {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,146 @@
+package dotpath
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Location struct {
+	Country string `bson:"country,omitempty"`
+	City    string `bson:"city,omitempty"`
+}
+
+type Prefs struct {
+	Theme string `bson:"theme,omitempty"`
+}
+
+type Profile struct {
+	Location Location `bson:"location,omitempty"`
+	Prefs    Prefs    `bson:"prefs,omitempty"`
+	Score    int      `bson:"score"`
+}
+
+type Device struct {
+	OS string `bson:"os"`
+}
+
+type Listener struct {
+	Name    string                 `bson:"name"`
+	Profile Profile                `bson:"profile"`
+	Devices []Device               `bson:"devices"`
+	Counts  map[string]int         `bson:"counts"`
+	Extra   map[string]interface{} `bson:",inline"`
+}
+
+// extJSON renders d the way the tests write their expectations
+func extJSON(t *testing.T, d bson.D) string {
+	t.Helper()
+	out, err := bson.MarshalExtJSON(d, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		v      interface{}
+		want   string
+		err    bool
+	}{
+		{"nested document", "", bson.D{{"a", bson.D{{"b", 1}, {"c", bson.D{{"d", "x"}}}}}},
+			`{"a.b":1,"a.c.d":"x"}`, false},
+		{"prefix", "profile", bson.D{{"score", 3}}, `{"profile.score":3}`, false},
+		{"array is a leaf", "", bson.D{{"tags", bson.A{"a", bson.D{{"b", 1}}}}},
+			`{"tags":["a",{"b":1}]}`, false},
+		{"explicit empty document is a leaf", "", bson.D{{"a", bson.D{}}}, `{"a":{}}`, false},
+		{"scalar with prefix", "name", "Ada", `{"name":"Ada"}`, false},
+		{"scalar without prefix", "", "Ada", "", true},
+		{"zero omitempty struct left out", "profile", Profile{Location: Location{City: "Munich"}},
+			`{"profile.location.city":"Munich","profile.score":0}`, false},
+		{"dotted key", "", bson.D{{"a.b", 1}}, "", true},
+		{"operator key", "", bson.D{{"$set", 1}}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flat, err := Flatten(test.prefix, test.v)
+			if (err != nil) != test.err {
+				t.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			if test.err {
+				return
+			}
+			if got := extJSON(t, flat); got != test.want {
+				t.Errorf("Flatten = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		path    string
+		unknown bool
+	}{
+		{"name", false},
+		{"profile.location.city", false},
+		{"profile.location.street", true},
+		{"devices.os", false},
+		{"devices.0.os", false},
+		{"devices.$.os", false},
+		{"devices.$[].os", false},
+		{"devices.$[mobile].os", false},
+		{"devices.$[Mobile].os", true},
+		{"devices.model", true},
+		{"counts.anything", false},
+		{"name.first", true},
+		// the inline map takes any other top-level field
+		{"nickname.anything", false},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := Check[Listener](test.path)
+			if test.unknown != errors.Is(err, ErrUnknownField) {
+				t.Errorf("Check(%q) = %v, want unknown %v", test.path, err, test.unknown)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		v      interface{}
+		want   string
+		err    bool
+	}{
+		// prefs is left alone rather than set to {}
+		{"partial profile", "profile", Profile{Location: Location{City: "Munich"}},
+			`{"$set":{"profile.location.city":"Munich","profile.score":0}}`, false},
+		{"through a positional operator", "devices.$", Device{OS: "ios"},
+			`{"$set":{"devices.$.os":"ios"}}`, false},
+		{"unknown field", "profile", bson.D{{"nickname", "x"}}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			update, err := Set[Listener](test.prefix, test.v)
+			if (err != nil) != test.err {
+				t.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			if test.err {
+				if !errors.Is(err, ErrUnknownField) {
+					t.Errorf("error = %v, want ErrUnknownField", err)
+				}
+				return
+			}
+			if got := extJSON(t, update); got != test.want {
+				t.Errorf("Set = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
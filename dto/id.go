@@ -0,0 +1,108 @@
+// Package dto holds the JSON-facing types of the REST examples. IDs are
+// rendered as 24-character hex strings, parsed back with field-aware
+// validation errors, and stored as real ObjectIDs in BSON.
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ID is an ObjectID that travels as a hex string in JSON. The zero value
+// renders as null so unset IDs are not shown as "000000000000000000000000".
+type ID primitive.ObjectID
+
+// NilID is the zero ID
+var NilID ID
+
+// NewID generates a new ID
+func NewID() ID {
+	return ID(primitive.NewObjectID())
+}
+
+// ParseID parses a hex string, typically a path parameter
+func ParseID(s string) (ID, error) {
+	oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+	if err != nil {
+		return NilID, &InvalidIDError{Value: s}
+	}
+	return ID(oid), nil
+}
+
+// ObjectID returns the ID as a driver ObjectID for use in filters
+func (id ID) ObjectID() primitive.ObjectID {
+	return primitive.ObjectID(id)
+}
+
+// IsZero reports whether the ID is unset; bson's omitempty relies on it
+func (id ID) IsZero() bool {
+	return id == NilID
+}
+
+// String returns the hex representation
+func (id ID) String() string {
+	return primitive.ObjectID(id).Hex()
+}
+
+// MarshalJSON renders the ID as a hex string, or null when unset
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(id.String())
+}
+
+// UnmarshalJSON accepts a hex string or null
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = NilID
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &InvalidIDError{Value: string(data)}
+	}
+	parsed, err := ParseID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the ID as a BSON ObjectID
+func (id ID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.ObjectID(id))
+}
+
+// UnmarshalBSONValue reads a BSON ObjectID
+func (id *ID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bson.TypeNull {
+		*id = NilID
+		return nil
+	}
+	var oid primitive.ObjectID
+	if err := bson.UnmarshalValue(t, data, &oid); err != nil {
+		return err
+	}
+	*id = ID(oid)
+	return nil
+}
+
+// InvalidIDError reports a value that is not a valid ObjectID hex string
+type InvalidIDError struct {
+	Field string
+	Value string
+}
+
+func (e *InvalidIDError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %q is not a valid id, expected a 24-character hex string", e.Field, e.Value)
+	}
+	return fmt.Sprintf("%q is not a valid id, expected a 24-character hex string", e.Value)
+}
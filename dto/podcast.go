@@ -0,0 +1,59 @@
+package dto
+
+import "strings"
+
+// Podcast is the API representation of a document in the "podcasts" collection
+type Podcast struct {
+	ID     ID       `bson:"_id,omitempty" json:"id"`
+	Title  string   `bson:"title,omitempty" json:"title"`
+	Author string   `bson:"author,omitempty" json:"author"`
+	Tags   []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	// Slug is made from the title by the service; clients cannot set it
+	Slug string `bson:"slug,omitempty" json:"slug,omitempty"`
+}
+
+// Validate checks the fields a client must provide
+func (p *Podcast) Validate() error {
+	v := &ValidationError{}
+	if strings.TrimSpace(p.Title) == "" {
+		v.Add("title", "is required")
+	}
+	if strings.TrimSpace(p.Author) == "" {
+		v.Add("author", "is required")
+	}
+	return v.Err()
+}
+
+// Episode is the API representation of a document in the "episodes" collection
+type Episode struct {
+	ID          ID     `bson:"_id,omitempty" json:"id"`
+	Podcast     ID     `bson:"podcast,omitempty" json:"podcast"`
+	Title       string `bson:"title,omitempty" json:"title"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Duration    int32  `bson:"duration,omitempty" json:"duration"`
+}
+
+// Validate checks the fields a client must provide
+func (e *Episode) Validate() error {
+	v := &ValidationError{}
+	if e.Podcast.IsZero() {
+		v.Add("podcast", "is required")
+	}
+	if strings.TrimSpace(e.Title) == "" {
+		v.Add("title", "is required")
+	}
+	if e.Duration < 0 {
+		v.Add("duration", "must not be negative")
+	}
+	return v.Err()
+}
+
+// ExportedEpisode is an episode together with the title of its podcast, one
+// row of an episode export
+type ExportedEpisode struct {
+	ID           ID     `bson:"_id" json:"id"`
+	Podcast      ID     `bson:"podcast" json:"podcast"`
+	PodcastTitle string `bson:"podcastTitle" json:"podcastTitle"`
+	Title        string `bson:"title" json:"title"`
+	Duration     int32  `bson:"duration" json:"duration"`
+}
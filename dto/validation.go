@@ -0,0 +1,120 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every problem found in a request body; REST
+// handlers render it as a 400 response
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Add records a problem with a field
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns nil when nothing was recorded, so Validate methods can end
+// with "return v.Err()"
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// MaxBodySize limits how much of a request body Decode reads
+var MaxBodySize int64 = 1 << 20
+
+// ErrBodyTooLarge is returned by Decode for bodies over MaxBodySize, which
+// REST handlers render as a 413 response
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Validator is implemented by request types that check their own fields
+type Validator interface {
+	Validate() error
+}
+
+// Decode reads a JSON request body into v, turning malformed IDs and type
+// mismatches into a ValidationError and running v.Validate when available.
+// A body over MaxBodySize, or over the limit of an http.MaxBytesReader,
+// returns an error wrapping ErrBodyTooLarge rather than being cut short.
+func Decode(r io.Reader, v interface{}) error {
+	// one byte more than allowed tells a body at the limit from a longer one
+	body, err := io.ReadAll(io.LimitReader(r, MaxBodySize+1))
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) || int64(len(body)) > MaxBodySize {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrBodyTooLarge, MaxBodySize)
+	}
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var invalidID *InvalidIDError
+		var typeError *json.UnmarshalTypeError
+		var syntaxError *json.SyntaxError
+		validation := &ValidationError{}
+		switch {
+		case errors.As(err, &invalidID):
+			validation.Add(fieldOr(invalidID.Field, fieldWithValue(body, invalidID.Value)), "%q is not a valid id, expected a 24-character hex string", invalidID.Value)
+		case errors.As(err, &typeError):
+			validation.Add(fieldOr(typeError.Field, "body"), "expected %s", typeError.Type)
+		case errors.As(err, &syntaxError):
+			validation.Add("body", "malformed JSON at offset %d", syntaxError.Offset)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			validation.Add(strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), "unknown field")
+		default:
+			validation.Add("body", "%v", err)
+		}
+		return validation
+	}
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// fieldWithValue finds the top-level field holding a rejected value, since
+// encoding/json does not tell an UnmarshalJSON method which field it is decoding
+func fieldWithValue(body []byte, value string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err == nil {
+		for name, raw := range fields {
+			var s string
+			if json.Unmarshal(raw, &s) == nil && s == value || string(raw) == value {
+				return name
+			}
+		}
+	}
+	return "id"
+}
+
+func fieldOr(field, fallback string) string {
+	if field == "" {
+		return fallback
+	}
+	return field
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBodySize(t *testing.T) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 64
+
+	// pad grows a valid body to exactly n bytes
+	pad := func(n int) string {
+		body := `{"title":"","author":"Nic Raboy"}`
+		return strings.Replace(body, `""`, `"`+strings.Repeat("x", n-len(body))+`"`, 1)
+	}
+	tests := []struct {
+		name    string
+		body    string
+		tooLong bool
+	}{
+		{"under the limit", pad(40), false},
+		{"at the limit", pad(64), false},
+		{"one byte over", pad(65), true},
+		{"far over", pad(10000), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var podcast Podcast
+			err := Decode(strings.NewReader(test.body), &podcast)
+			if errors.Is(err, ErrBodyTooLarge) != test.tooLong {
+				t.Fatalf("error = %v, want ErrBodyTooLarge %v", err, test.tooLong)
+			}
+			var validation *ValidationError
+			if test.tooLong && errors.As(err, &validation) {
+				t.Errorf("a body over the limit was reported as invalid: %v", err)
+			}
+		})
+	}
+
+	t.Run("MaxBytesReader", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/podcasts", strings.NewReader(pad(40)))
+		body := http.MaxBytesReader(httptest.NewRecorder(), r.Body, 10)
+		var podcast Podcast
+		if err := Decode(body, &podcast); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("error = %v, want ErrBodyTooLarge", err)
+		}
+	})
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"unknown field", `{"title":"T","author":"A","rating":5}`, "rating"},
+		{"invalid id", `{"id":"nope","title":"T","author":"A"}`, "id"},
+		{"id of the wrong type", `{"id":12,"title":"T","author":"A"}`, "id"},
+		{"invalid podcast id", `{"podcast":"5e3b37e51c9d4400004117e","title":"T"}`, "podcast"},
+		{"wrong type", `{"title":"T","duration":"long"}`, "duration"},
+		{"malformed JSON", `{"title":`, "body"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v interface{} = &Podcast{}
+			if strings.Contains(test.body, "podcast") || strings.Contains(test.body, "duration") {
+				v = &Episode{}
+			}
+			err := Decode(strings.NewReader(test.body), v)
+			var validation *ValidationError
+			if !errors.As(err, &validation) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if len(validation.Fields) != 1 || validation.Fields[0].Field != test.field {
+				t.Errorf("fields = %+v, want one for %q", validation.Fields, test.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// percentiles computed for each podcast, as fractions
+var percentiles = []float64{0.5, 0.9, 0.99}
+
+// Stats represents the episode duration statistics of one podcast
+type Stats struct {
+	Podcast  primitive.ObjectID `bson:"_id"`
+	Episodes int64              `bson:"episodes"`
+	Median   float64            `bson:"median"`
+	// Percentiles holds p50, p90 and p99, in the order of percentiles
+	Percentiles []float64 `bson:"percentiles"`
+}
+
+// hasDuration leaves out episodes without a numeric duration, so both
+// implementations count the same episodes
+var hasDuration = bson.D{{"$match", bson.D{{"duration", bson.D{{"$type", "number"}}}}}}
+
+// serverPipeline uses the $median and $percentile accumulators of MongoDB 7.0.
+// "approximate" is the only method they support.
+func serverPipeline() mongo.Pipeline {
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$podcast"},
+		{"episodes", bson.D{{"$sum", 1}}},
+		{"median", bson.D{{"$median", bson.D{{"input", "$duration"}, {"method", "approximate"}}}}},
+		{"percentiles", bson.D{{"$percentile", bson.D{
+			{"input", "$duration"},
+			{"p", percentiles},
+			{"method", "approximate"},
+		}}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"episodes", -1}}}}
+	return mongo.Pipeline{hasDuration, groupStage, sortStage}
+}
+
+// clientPipeline gathers the durations of each podcast so older servers can
+// compute the percentiles in Go
+func clientPipeline() mongo.Pipeline {
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$podcast"},
+		{"durations", bson.D{{"$push", "$duration"}}},
+	}}}
+	return mongo.Pipeline{hasDuration, groupStage}
+}
+
+// percentile returns the p-th percentile of sorted values by the nearest-rank
+// method: the smallest value with at least p of the values at or below it
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// serverSupportsPercentiles reports whether the server is MongoDB 7.0 or newer
+func serverSupportsPercentiles(ctx context.Context, client *mongo.Client) (bool, error) {
+	var info struct {
+		VersionArray []int32 `bson:"versionArray"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{"buildInfo", 1}}).Decode(&info); err != nil {
+		return false, err
+	}
+	return len(info.VersionArray) > 0 && info.VersionArray[0] >= 7, nil
+}
+
+func serverStats(ctx context.Context, episodes *mongo.Collection) ([]Stats, error) {
+	cursor, err := episodes.Aggregate(ctx, serverPipeline())
+	if err != nil {
+		return nil, err
+	}
+	var stats []Stats
+	err = cursor.All(ctx, &stats)
+	return stats, err
+}
+
+func clientStats(ctx context.Context, episodes *mongo.Collection) ([]Stats, error) {
+	cursor, err := episodes.Aggregate(ctx, clientPipeline())
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Podcast   primitive.ObjectID `bson:"_id"`
+		Durations []float64          `bson:"durations"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	stats := make([]Stats, 0, len(groups))
+	for _, group := range groups {
+		sort.Float64s(group.Durations)
+		podcast := Stats{
+			Podcast:  group.Podcast,
+			Episodes: int64(len(group.Durations)),
+			Median:   percentile(group.Durations, 0.5),
+		}
+		for _, p := range percentiles {
+			podcast.Percentiles = append(podcast.Percentiles, percentile(group.Durations, p))
+		}
+		stats = append(stats, podcast)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Episodes > stats[j].Episodes })
+	return stats, nil
+}
+
+var (
+	forceClient = flag.Bool("client", false, "compute the percentiles in Go even when the server supports them")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	episodesCollection := client.Database("quickstart").Collection("episodes")
+
+	// Use $percentile And $median When The Server Has Them
+	supported, err := serverSupportsPercentiles(ctx, client)
+	if err != nil {
+		return err
+	}
+	var stats []Stats
+	if supported && !*forceClient {
+		fmt.Println("Computing percentiles on the server")
+		stats, err = serverStats(ctx, episodesCollection)
+	} else {
+		// Fall Back To Computing Them In Go
+		fmt.Println("Computing percentiles in Go")
+		stats, err = clientStats(ctx, episodesCollection)
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "PODCAST\tEPISODES\tMEDIAN\tP50\tP90\tP99\t")
+	for _, podcast := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%.0f\t", podcast.Podcast.Hex(), podcast.Episodes, podcast.Median)
+		for _, value := range podcast.Percentiles {
+			fmt.Fprintf(w, "%.0f\t", value)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return nil
+}
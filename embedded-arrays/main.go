@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/filter"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Episode is an element of the episodes array
+type Episode struct {
+	Title    string `bson:"title"`
+	Duration int32  `bson:"duration"`
+	Rating   int32  `bson:"rating"`
+	Featured bool   `bson:"featured,omitempty"`
+}
+
+// Podcast represents the schema for the "arrays_podcasts" collection, which
+// embeds its episodes instead of referencing them
+type Podcast struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Title    string             `bson:"title"`
+	Episodes []Episode          `bson:"episodes"`
+}
+
+var podcasts = []interface{}{
+	Podcast{Title: "Go Time", Episodes: []Episode{
+		{Title: "Generics", Duration: 62, Rating: 4},
+		{Title: "Fuzzing", Duration: 35, Rating: 5},
+	}},
+	Podcast{Title: "The MongoDB Podcast", Episodes: []Episode{
+		{Title: "Transactions", Duration: 55, Rating: 5},
+		{Title: "Atlas Search", Duration: 40, Rating: 3},
+		{Title: "Time Series", Duration: 58, Rating: 5},
+	}},
+	Podcast{Title: "The Polyglot Developer Podcast", Episodes: []Episode{
+		{Title: "GraphQL for API Development", Duration: 25, Rating: 4},
+		{Title: "Progressive Web Application Development", Duration: 32, Rating: 5},
+	}},
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	podcastsCollection := client.Database("quickstart").Collection("arrays_podcasts")
+	if err = podcastsCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop arrays_podcasts: %w", err)
+	}
+	if _, err = podcastsCollection.InsertMany(ctx, podcasts); err != nil {
+		return fmt.Errorf("insert into arrays_podcasts: %w", err)
+	}
+
+	// An index on a path into the array is a multikey index, with one entry
+	// per element, and serves both dot notation and $elemMatch
+	if _, err = podcastsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"episodes.duration", 1}},
+	}); err != nil {
+		return fmt.Errorf("create index on episodes.duration: %w", err)
+	}
+
+	// Dot Notation Conditions Can Be Met By Different Elements
+	// Go Time matches: Generics is long enough and Fuzzing is rated 5, but
+	// no single episode is both
+	anyElements := filter.And(filter.Gt("episodes.duration", 50), filter.Gte("episodes.rating", 5))
+	if err = printTitles(ctx, podcastsCollection, "Dot notation, a long episode AND a well rated episode, maybe not the same one:", anyElements); err != nil {
+		return err
+	}
+
+	// Match Several Conditions On The Same Element With $elemMatch
+	sameElement := filter.ElemMatch("episodes", filter.Gt("duration", 50), filter.Gte("rating", 5))
+	if err = printTitles(ctx, podcastsCollection, "$elemMatch, one episode both long AND well rated:", sameElement); err != nil {
+		return err
+	}
+
+	// A single condition needs no $elemMatch, the two are the same query
+	if err = printTitles(ctx, podcastsCollection, "Dot notation, an episode called Fuzzing:", filter.Eq("episodes.title", "Fuzzing")); err != nil {
+		return err
+	}
+
+	// An embedded document as the value matches whole elements only: every
+	// field, in the same order
+	whole := bson.D{{"episodes", bson.D{{"title", "Fuzzing"}, {"duration", 35}}}}
+	if err = printTitles(ctx, podcastsCollection, "Whole element without its rating:", whole); err != nil {
+		return err
+	}
+
+	// Project Only The Matching Elements
+	// the positional $ projection keeps the first element the filter matched
+	var first Podcast
+	err = podcastsCollection.FindOne(ctx, sameElement,
+		options.FindOne().SetProjection(bson.D{{"title", 1}, {"episodes.$", 1}}),
+	).Decode(&first)
+	if err != nil {
+		return fmt.Errorf("find with positional projection: %w", err)
+	}
+	fmt.Println("First matching episode with episodes.$:")
+	printEpisodes(first)
+
+	// an $elemMatch projection does the same with a condition of its own,
+	// independent of the filter
+	cursor, err := podcastsCollection.Find(ctx, bson.D{},
+		options.Find().SetProjection(bson.D{{"title", 1}, {"episodes", bson.D{{"$elemMatch", bson.D{{"rating", 3}}}}}}),
+	)
+	if err != nil {
+		return fmt.Errorf("find with $elemMatch projection: %w", err)
+	}
+	var projected []Podcast
+	if err = cursor.All(ctx, &projected); err != nil {
+		return fmt.Errorf("decode $elemMatch projection: %w", err)
+	}
+	fmt.Println("First episode rated 3 with an $elemMatch projection, empty when there is none:")
+	for _, podcast := range projected {
+		printEpisodes(podcast)
+	}
+
+	// both keep one element; $filter in an aggregation keeps all of them
+	cursor, err = podcastsCollection.Aggregate(ctx, mongo.Pipeline{
+		{{"$match", sameElement}},
+		{{"$project", bson.D{
+			{"title", 1},
+			{"episodes", bson.D{{"$filter", bson.D{
+				{"input", "$episodes"},
+				{"as", "episode"},
+				{"cond", bson.D{{"$and", bson.A{
+					bson.D{{"$gt", bson.A{"$$episode.duration", 50}}},
+					bson.D{{"$gte", bson.A{"$$episode.rating", 5}}},
+				}}}},
+			}}}},
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("aggregate with $filter: %w", err)
+	}
+	var filtered []Podcast
+	if err = cursor.All(ctx, &filtered); err != nil {
+		return fmt.Errorf("decode $filter results: %w", err)
+	}
+	fmt.Println("Every matching episode with $filter:")
+	for _, podcast := range filtered {
+		printEpisodes(podcast)
+	}
+
+	// Update The First Matching Element With $
+	result, err := podcastsCollection.UpdateOne(ctx,
+		bson.D{{"title", "Go Time"}, {"episodes.title", "Generics"}},
+		bson.D{{"$inc", bson.D{{"episodes.$.rating", 1}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("update episodes.$: %w", err)
+	}
+	fmt.Printf("episodes.$ raised the rating of Generics in %v podcast(s)\n", result.ModifiedCount)
+
+	// Update Every Matching Element With arrayFilters
+	// $[long] names the elements the array filter called long matches;
+	// $[] without a name would update every element
+	result, err = podcastsCollection.UpdateMany(ctx,
+		sameElement,
+		bson.D{{"$set", bson.D{{"episodes.$[long].featured", true}}}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			filter.And(filter.Gt("long.duration", 50), filter.Gte("long.rating", 5)),
+		}}),
+	)
+	if err != nil {
+		return fmt.Errorf("update with arrayFilters: %w", err)
+	}
+	fmt.Printf("arrayFilters featured episodes in %v podcast(s)\n", result.ModifiedCount)
+
+	cursor, err = podcastsCollection.Find(ctx, filter.Eq("episodes.featured", true))
+	if err != nil {
+		return fmt.Errorf("find featured episodes: %w", err)
+	}
+	var featured []Podcast
+	if err = cursor.All(ctx, &featured); err != nil {
+		return fmt.Errorf("decode featured episodes: %w", err)
+	}
+	fmt.Println("After the updates:")
+	for _, podcast := range featured {
+		printEpisodes(podcast)
+	}
+	return nil
+}
+
+// printTitles prints heading and the title of every podcast matching query
+func printTitles(ctx context.Context, collection *mongo.Collection, heading string, query bson.D) error {
+	cursor, err := collection.Find(ctx, query, options.Find().SetSort(bson.D{{"title", 1}}))
+	if err != nil {
+		return fmt.Errorf("find %v: %w", query, err)
+	}
+	var matched []Podcast
+	if err = cursor.All(ctx, &matched); err != nil {
+		return fmt.Errorf("decode %v: %w", query, err)
+	}
+	fmt.Println(heading)
+	for _, podcast := range matched {
+		fmt.Printf("  %s\n", podcast.Title)
+	}
+	return nil
+}
+
+// printEpisodes prints a podcast with the episodes it came back with
+func printEpisodes(podcast Podcast) {
+	fmt.Printf("  %s\n", podcast.Title)
+	for _, episode := range podcast.Episodes {
+		featured := ""
+		if episode.Featured {
+			featured = ", featured"
+		}
+		fmt.Printf("    %s, %d min, rated %d%s\n", episode.Title, episode.Duration, episode.Rating, featured)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EpisodeStatus is where an episode is in its publishing workflow
+type EpisodeStatus int
+
+const (
+	StatusDraft EpisodeStatus = iota
+	StatusScheduled
+	StatusPublished
+	StatusArchived
+)
+
+// episodeStatusNames are the strings stored in MongoDB, indexed by status.
+// The codec and the collection validator are both built from this list, so
+// they cannot disagree.
+var episodeStatusNames = []string{"draft", "scheduled", "published", "archived"}
+
+func (s EpisodeStatus) String() string {
+	if s < 0 || int(s) >= len(episodeStatusNames) {
+		return fmt.Sprintf("EpisodeStatus(%d)", int(s))
+	}
+	return episodeStatusNames[s]
+}
+
+// ErrInvalidEnum is returned when encoding or decoding a value that is not
+// one of an enum's names
+var ErrInvalidEnum = errors.New("invalid enum value")
+
+// Episode represents the schema for the "enum_episodes" collection
+type Episode struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Title  string             `bson:"title"`
+	Status EpisodeStatus      `bson:"status"`
+}
+
+// registerEnum makes the registry store values of type T, an integer enum,
+// as the string at their index in names, and refuse anything else in both
+// directions
+func registerEnum[T ~int](registry *bsoncodec.Registry, names []string) {
+	enumType := reflect.TypeOf(T(0))
+	index := make(map[string]int64, len(names))
+	for i, name := range names {
+		index[name] = int64(i)
+	}
+
+	registry.RegisterTypeEncoder(enumType, bsoncodec.ValueEncoderFunc(
+		func(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+			if val.Type() != enumType {
+				return bsoncodec.ValueEncoderError{Name: enumType.Name() + "EncodeValue", Types: []reflect.Type{enumType}, Received: val}
+			}
+			i := val.Int()
+			if i < 0 || i >= int64(len(names)) {
+				return fmt.Errorf("%w: %s(%d)", ErrInvalidEnum, enumType.Name(), i)
+			}
+			return vw.WriteString(names[i])
+		}))
+
+	registry.RegisterTypeDecoder(enumType, bsoncodec.ValueDecoderFunc(
+		func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+			if !val.CanSet() || val.Type() != enumType {
+				return bsoncodec.ValueDecoderError{Name: enumType.Name() + "DecodeValue", Types: []reflect.Type{enumType}, Received: val}
+			}
+			if vr.Type() != bsontype.String {
+				return fmt.Errorf("%w: cannot decode %v into %s", ErrInvalidEnum, vr.Type(), enumType.Name())
+			}
+			name, err := vr.ReadString()
+			if err != nil {
+				return err
+			}
+			i, ok := index[name]
+			if !ok {
+				return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidEnum, name, enumType.Name())
+			}
+			val.SetInt(i)
+			return nil
+		}))
+}
+
+// enumSchema is the $jsonSchema property restricting a field to names
+func enumSchema(names []string) bson.D {
+	values := make(bson.A, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return bson.D{{"bsonType", "string"}, {"enum", values}}
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	// Register The Enum Codec On The Client
+	registry := bson.NewRegistry()
+	registerEnum[EpisodeStatus](registry, episodeStatusNames)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx, options.Client().SetRegistry(registry))
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// Enforce The Same Values With A $jsonSchema Validator
+	database := client.Database("quickstart")
+	episodesCollection := database.Collection("enum_episodes")
+	if err = episodesCollection.Drop(ctx); err != nil {
+		return err
+	}
+	validator := bson.D{{"$jsonSchema", bson.D{
+		{"bsonType", "object"},
+		{"required", bson.A{"title", "status"}},
+		{"properties", bson.D{
+			{"title", bson.D{{"bsonType", "string"}}},
+			{"status", enumSchema(episodeStatusNames)},
+		}},
+	}}}
+	err = database.CreateCollection(ctx, "enum_episodes", options.CreateCollection().SetValidator(validator))
+	if err != nil {
+		return err
+	}
+
+	// Insert Documents With Enum Fields
+	_, err = episodesCollection.InsertMany(ctx, []interface{}{
+		Episode{Title: "GraphQL for API Development", Status: StatusPublished},
+		Episode{Title: "Progressive Web Application Development", Status: StatusDraft},
+	})
+	if err != nil {
+		return err
+	}
+	var stored bson.M
+	if err = episodesCollection.FindOne(ctx, bson.D{}).Decode(&stored); err != nil {
+		return err
+	}
+	fmt.Println("Stored as:", stored)
+
+	// Filter With Enum Values, Encoded By The Same Codec
+	var published []Episode
+	cursor, err := episodesCollection.Find(ctx, bson.D{{"status", StatusPublished}})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &published); err != nil {
+		return err
+	}
+	for _, episode := range published {
+		fmt.Printf("%q is %v\n", episode.Title, episode.Status)
+	}
+
+	// Out Of Range Values Never Leave The Application
+	_, err = episodesCollection.InsertOne(ctx, Episode{Title: "Bad", Status: EpisodeStatus(42)})
+	fmt.Println("Inserting EpisodeStatus(42):", errors.Is(err, ErrInvalidEnum), err)
+
+	// Writes That Bypass The Codec Are Rejected By The Validator
+	_, err = episodesCollection.InsertOne(ctx, bson.D{{"title", "Raw"}, {"status", "live"}})
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) && writeErr.HasErrorCode(121) {
+		fmt.Println("Inserting status \"live\" failed document validation")
+	} else if err != nil {
+		return err
+	}
+
+	// Unknown Strings Fail To Decode Instead Of Becoming The Zero Value
+	raw, err := bson.Marshal(bson.D{{"title", "Drifted"}, {"status", "live"}})
+	if err != nil {
+		return err
+	}
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(raw))
+	if err != nil {
+		return err
+	}
+	decoder.SetRegistry(registry)
+	var drifted Episode
+	err = decoder.Decode(&drifted)
+	fmt.Println("Decoding status \"live\":", errors.Is(err, ErrInvalidEnum), err)
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package aggregation runs $match, $group, $lookup and $unwind pipelines
+// on the episodes collection
+package aggregation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/typedcoll"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "aggregation",
+		Description: "Group and join episodes with aggregation pipelines",
+		Run:         Run,
+		Order:       60,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Title  string             `bson:"title,omitempty"`
+	Author string             `bson:"author,omitempty"`
+	Tags   []string           `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+}
+
+// PodcastEpisode represents an aggregation result-set for two collections
+type PodcastEpisode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     Podcast            `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+}
+
+// totalDurationPipeline sums the duration of every episode of one podcast
+func totalDurationPipeline(podcast primitive.ObjectID) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"podcast", podcast}}}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", "$podcast"}, {"total", bson.D{{"$sum", "$duration"}}}}}}
+	return mongo.Pipeline{matchStage, groupStage}
+}
+
+// episodesWithPodcastPipeline embeds each episode's podcast document from
+// the podcasts collection
+func episodesWithPodcastPipeline(podcasts string) mongo.Pipeline {
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", podcasts}, {"localField", "podcast"}, {"foreignField", "_id"}, {"as", "podcast"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$podcast"}, {"preserveNullAndEmptyArrays", false}}}}
+	return mongo.Pipeline{lookupStage, unwindStage}
+}
+
+// PodcastTotal is a result of totalDurationPipeline
+type PodcastTotal struct {
+	Podcast primitive.ObjectID `bson:"_id"`
+	Total   int64              `bson:"total"`
+}
+
+// TotalDuration returns the total duration of the episodes of podcast, as
+// one PodcastTotal or none when it has no episodes
+func TotalDuration(ctx context.Context, episodes *mongo.Collection, podcast primitive.ObjectID) ([]PodcastTotal, error) {
+	episodesCollection := typedcoll.New[Episode](episodes)
+	return typedcoll.Aggregate[PodcastTotal](ctx, episodesCollection, totalDurationPipeline(podcast))
+}
+
+// EpisodesWithPodcast returns every episode that has a podcast, with the
+// podcast embedded. $lookup joins within one database, so podcasts must be
+// in the database of episodes.
+func EpisodesWithPodcast(ctx context.Context, episodes, podcasts *mongo.Collection) ([]PodcastEpisode, error) {
+	episodesCollection := typedcoll.New[Episode](episodes)
+	return typedcoll.Aggregate[PodcastEpisode](ctx, episodesCollection, episodesWithPodcastPipeline(podcasts.Name()))
+}
+
+// Run prints the total duration of one podcast and every episode with its
+// podcast embedded, decoded into maps and into structs
+func Run(ctx context.Context, deps examples.Deps) error {
+	episodes := deps.Collection("episodes")
+	podcasts := deps.Collection("podcasts")
+	episodesCollection := typedcoll.New[Episode](episodes)
+
+	id, _ := primitive.ObjectIDFromHex("5e3b37e51c9d4400004117e6")
+
+	// The Result Type Is Named At The Call, Not Hidden In A Pointer
+	showsWithInfo, err := TotalDuration(ctx, episodes, id)
+	if err != nil {
+		return fmt.Errorf("total duration of podcast %s: %w", id.Hex(), err)
+	}
+	deps.Println(showsWithInfo)
+
+	showsLoaded, err := typedcoll.Aggregate[bson.M](ctx, episodesCollection, episodesWithPodcastPipeline(podcasts.Name()))
+	if err != nil {
+		return fmt.Errorf("episodes with their podcast: %w", err)
+	}
+	deps.Println(showsLoaded)
+
+	showsLoadedStruct, err := EpisodesWithPodcast(ctx, episodes, podcasts)
+	if err != nil {
+		return fmt.Errorf("episodes with their podcast as structs: %w", err)
+	}
+	deps.Println(showsLoadedStruct)
+	return nil
+}
@@ -0,0 +1,31 @@
+package aggregation
+
+import (
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/fixtures"
+	"github.com/mongodb-developer/golang-quickstart/internal/golden"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// TestPipelinesGolden runs the example pipelines against fixture data in a
+// scratch database and compares the results with testdata/*.golden.json.
+// Update the golden files with: go test ./examples/aggregation -update
+func TestPipelinesGolden(t *testing.T) {
+	database := mongotest.Database(t)
+	set := fixtures.LoadT(t, database, "testdata/fixtures.yaml")
+	episodesCollection := database.Collection("episodes")
+	sortByTitle := bson.D{{"$sort", bson.D{{"title", 1}}}}
+
+	t.Run("total_duration", func(t *testing.T) {
+		golden.Aggregate(t, episodesCollection, "total_duration", totalDurationPipeline(set.ID("polyglot")))
+	})
+	t.Run("episodes_with_podcast", func(t *testing.T) {
+		golden.Aggregate(t, episodesCollection, "episodes_with_podcast", append(episodesWithPodcastPipeline("podcasts"), sortByTitle))
+	})
+}
@@ -0,0 +1,56 @@
+package aggregation
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestTotalDurationMock checks the pipeline TotalDuration sends and how it
+// decodes the mocked results, without a cluster
+func TestTotalDurationMock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	podcast := primitive.NewObjectID()
+	tests := []struct {
+		name     string
+		response bson.D
+		want     []PodcastTotal
+		err      bool
+	}{
+		// $sum returns an int32 when every duration fits, decoded into int64
+		{"int32 total", mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch,
+			bson.D{{"_id", podcast}, {"total", int32(57)}}), []PodcastTotal{{Podcast: podcast, Total: 57}}, false},
+		{"int64 total", mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch,
+			bson.D{{"_id", podcast}, {"total", int64(1) << 40}}), []PodcastTotal{{Podcast: podcast, Total: 1 << 40}}, false},
+		{"no episodes", mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch), []PodcastTotal{}, false},
+		// a fractional total cannot be stored in an int64 without truncating
+		{"fractional total", mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch,
+			bson.D{{"_id", podcast}, {"total", 57.5}}), nil, true},
+		{"server error", mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code: 40324, Name: "Location40324", Message: "Unrecognized pipeline stage name",
+		}), nil, true},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			mt.AddMockResponses(test.response)
+			totals, err := TotalDuration(mt.Context(), mt.DB.Collection("episodes"), podcast)
+			if (err != nil) != test.err {
+				mt.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			command := mt.GetStartedEvent().Command
+			if collection := command.Lookup("aggregate").StringValue(); collection != "episodes" {
+				mt.Errorf("aggregated %q, want episodes", collection)
+			}
+			if match := command.Lookup("pipeline", "0", "$match", "podcast").ObjectID(); match != podcast {
+				mt.Errorf("$match on podcast %v, want %v", match, podcast)
+			}
+			if !test.err && !reflect.DeepEqual(totals, test.want) {
+				mt.Errorf("totals = %+v, want %+v", totals, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Package all registers every example package with the examples registry.
+// Import it for its side effect:
+//
+//	import _ "github.com/mongodb-developer/golang-quickstart/examples/all"
+package all
+
+import (
+	_ "github.com/mongodb-developer/golang-quickstart/examples/aggregation"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/bulk"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/changestreams"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/connecting"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/creating"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/deleting"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/findandmodify"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/indexes"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/modeling"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/retrieving"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/transactions"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/updating"
+)
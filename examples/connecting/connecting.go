@@ -0,0 +1,36 @@
+// Package connecting checks the connection to a cluster
+package connecting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "connecting",
+		Description: "Ping the cluster and list its databases",
+		Run:         Run,
+		Order:       10,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Run pings the primary and prints the names of the databases
+func Run(ctx context.Context, deps examples.Deps) error {
+	err := deps.Client.Ping(ctx, readpref.Primary())
+	if err != nil {
+		return fmt.Errorf("ping primary: %w", err)
+	}
+	databases, err := deps.Client.ListDatabaseNames(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("list databases: %w", err)
+	}
+	deps.Println(databases)
+	return nil
+}
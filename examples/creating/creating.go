@@ -0,0 +1,81 @@
+// Package creating inserts documents with InsertOne and InsertMany
+package creating
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "creating",
+		Description: "Insert a podcast and two episodes",
+		Run:         Run,
+		Order:       20,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Run inserts one podcast and two of its episodes
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+	deps.Pause("InsertOne adds a podcast")
+	podcastID, err := InsertPodcast(ctx, podcastsCollection)
+	if err != nil {
+		return fmt.Errorf("insert into podcasts: %w", err)
+	}
+	deps.Show(ctx, "After", podcastsCollection, bson.M{"_id": podcastID})
+
+	deps.Pause("InsertMany adds two of its episodes")
+
+	episodeIDs, err := InsertEpisodes(ctx, episodesCollection, podcastID)
+	if err != nil {
+		return fmt.Errorf("insert into episodes: %w", err)
+	}
+	deps.Show(ctx, "After", episodesCollection, bson.M{"podcast": podcastID})
+	deps.Printf("Inserted %v documents into episode collection!\n", len(episodeIDs))
+	return nil
+}
+
+// InsertPodcast inserts The Polyglot Developer Podcast into podcasts and
+// returns its _id
+func InsertPodcast(ctx context.Context, podcasts *mongo.Collection) (interface{}, error) {
+	result, err := podcasts.InsertOne(ctx, bson.D{
+		{"title", "The Polyglot Developer Podcast"},
+		{"author", "Nic Raboy"},
+		{"tags", bson.A{"development", "programming", "coding"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedID, nil
+}
+
+// InsertEpisodes inserts two episodes of podcast into episodes, 25 and 32
+// minutes long, and returns their _ids
+func InsertEpisodes(ctx context.Context, episodes *mongo.Collection, podcast interface{}) ([]interface{}, error) {
+	result, err := episodes.InsertMany(ctx, []interface{}{
+		bson.D{
+			{"podcast", podcast},
+			{"title", "GraphQL for API Development"},
+			{"description", "Learn about GraphQL from the co-creator of GraphQL, Lee Byron."},
+			{"duration", 25},
+		},
+		bson.D{
+			{"podcast", podcast},
+			{"title", "Progressive Web Application Development"},
+			{"description", "Learn about PWA development with Tara Manicsic."},
+			{"duration", 32},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedIDs, nil
+}
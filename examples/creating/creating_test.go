@@ -0,0 +1,37 @@
+package creating
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"github.com/mongodb-developer/golang-quickstart/internal/snapshot"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// TestCreatingDelta runs the example and checks it inserted one podcast and
+// two episodes totalling 57 minutes
+func TestCreatingDelta(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	database := mongotest.Database(t)
+
+	snapshot.Expect(t, database,
+		snapshot.Spec{"podcasts": nil, "episodes": {"duration"}},
+		snapshot.Delta{
+			"podcasts": {Count: 1},
+			"episodes": {Count: 2, Sums: map[string]float64{"duration": 57}},
+		},
+		func() {
+			if err := Run(ctx, examples.Deps{Client: database.Client(), Database: database.Name(), Out: io.Discard}); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+}
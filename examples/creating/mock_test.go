@@ -0,0 +1,66 @@
+package creating
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestInsertMock runs the inserts against mocked server responses, so it
+// needs no cluster
+func TestInsertMock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("podcast", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		id, err := InsertPodcast(mt.Context(), mt.DB.Collection("podcasts"))
+		if err != nil {
+			mt.Fatal(err)
+		}
+		// the driver generates the _id before sending the document
+		if _, ok := id.(primitive.ObjectID); !ok {
+			mt.Errorf("InsertPodcast returned a %T, want an ObjectID", id)
+		}
+		command := mt.GetStartedEvent().Command
+		if collection := command.Lookup("insert").StringValue(); collection != "podcasts" {
+			mt.Errorf("inserted into %q, want podcasts", collection)
+		}
+		document := command.Lookup("documents", "0").Document()
+		if title := document.Lookup("title").StringValue(); title != "The Polyglot Developer Podcast" {
+			mt.Errorf("inserted title %q", title)
+		}
+	})
+
+	mt.Run("duplicate podcast", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index: 0, Code: 11000, Message: "E11000 duplicate key error",
+		}))
+		if _, err := InsertPodcast(mt.Context(), mt.DB.Collection("podcasts")); !mongo.IsDuplicateKeyError(err) {
+			mt.Errorf("InsertPodcast error = %v, want a duplicate key error", err)
+		}
+	})
+
+	mt.Run("episodes", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+		podcast := primitive.NewObjectID()
+		ids, err := InsertEpisodes(mt.Context(), mt.DB.Collection("episodes"), podcast)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if len(ids) != 2 {
+			mt.Errorf("InsertEpisodes returned %d ids, want 2", len(ids))
+		}
+		documents, err := mt.GetStartedEvent().Command.Lookup("documents").Array().Values()
+		if err != nil {
+			mt.Fatal(err)
+		}
+		for _, document := range documents {
+			if got := document.Document().Lookup("podcast").ObjectID(); got != podcast {
+				mt.Errorf("episode of podcast %v, want %v", got, podcast)
+			}
+		}
+	})
+}
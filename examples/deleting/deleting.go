@@ -0,0 +1,83 @@
+// Package deleting removes documents and drops collections
+package deleting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/cascade"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "deleting",
+		Description: "Delete a podcast with its episodes and drop the collections",
+		Run:         Run,
+		Order:       120,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Run deletes a podcast with everything referring to it, then every
+// 25 minute episode, then drops both collections
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	deps.Pause("DeletePodcastCascade deletes a podcast and everything referring to it")
+	title := deps.String("title", "The Polyglot Developer Podcast")
+	var podcast struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := podcastsCollection.FindOne(ctx, bson.M{"title": title}).Decode(&podcast); err != nil {
+		return fmt.Errorf("find podcast to delete: %w", err)
+	}
+	deleter := cascade.New(deps.DB())
+	deleter.Podcasts = podcastsCollection.Name()
+	deleter.Episodes = episodesCollection.Name()
+	deleter.Reviews = deps.Collection("reviews").Name()
+	cascaded, err := deleter.DeletePodcastCascade(ctx, podcast.ID)
+	if err != nil {
+		return fmt.Errorf("delete podcast %s with its episodes: %w", podcast.ID.Hex(), err)
+	}
+	deps.Printf("DeletePodcastCascade removed %v podcast(s), %v episode(s), %v review(s) and %v file(s)\n",
+		cascaded.Podcasts, cascaded.Episodes, cascaded.Reviews, cascaded.Files)
+
+	deps.Show(ctx, "Episodes left", episodesCollection, bson.M{"podcast": podcast.ID})
+
+	deps.Pause("DeleteMany deletes every episode of one duration")
+	duration := deps.Int("duration", 25)
+	deps.Show(ctx, "Before", episodesCollection, bson.M{"duration": duration})
+	deleted, err := DeleteEpisodesByDuration(ctx, episodesCollection, duration)
+	if err != nil {
+		return fmt.Errorf("delete many in episodes: %w", err)
+	}
+	deps.Printf("DeleteMany removed %v document(s)\n", deleted)
+
+	deps.Show(ctx, "After", episodesCollection, bson.M{"duration": duration})
+
+	deps.Pause("Drop removes both collections")
+	if err = podcastsCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop podcasts: %w", err)
+	}
+
+	if err = episodesCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop episodes: %w", err)
+	}
+	return nil
+}
+
+// DeleteEpisodesByDuration deletes every episode lasting minutes and returns
+// how many it deleted
+func DeleteEpisodesByDuration(ctx context.Context, episodes *mongo.Collection, minutes int) (int64, error) {
+	result, err := episodes.DeleteMany(ctx, bson.M{"duration": minutes})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,47 @@
+package deleting
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestDeleteMock checks the delete the function sends and the count it
+// reads from the mocked reply, without a cluster
+func TestDeleteMock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name     string
+		response bson.D
+		deleted  int64
+		err      bool
+	}{
+		{"two deleted", mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}), 2, false},
+		{"none deleted", mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}), 0, false},
+		{"server error", mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code: 13, Name: "Unauthorized", Message: "not authorized on test to execute command",
+		}), 0, true},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			mt.AddMockResponses(test.response)
+			deleted, err := DeleteEpisodesByDuration(mt.Context(), mt.DB.Collection("episodes"), 25)
+			if (err != nil) != test.err {
+				mt.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			if deleted != test.deleted {
+				mt.Errorf("deleted %d, want %d", deleted, test.deleted)
+			}
+			statement := mt.GetStartedEvent().Command.Lookup("deletes", "0").Document()
+			if duration := statement.Lookup("q", "duration").Int32(); duration != 25 {
+				mt.Errorf("deleted episodes of %d minutes, want 25", duration)
+			}
+			// DeleteMany sends limit 0, DeleteOne limit 1
+			if limit := statement.Lookup("limit").Int32(); limit != 0 {
+				mt.Errorf("limit = %d, want 0", limit)
+			}
+		})
+	}
+}
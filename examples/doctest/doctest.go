@@ -0,0 +1,12 @@
+// Package doctest checks that the examples the blog posts quote still print
+// what the posts show. Its test runs each example against a scratch database
+// with Deps.Out captured and compares the output, with ObjectIDs and dates
+// normalized, to testdata/<example>.golden.txt. The database is the one at
+// ATLAS_URI or, when it is not set, a container started by internal/mongotest:
+//
+//	go test ./examples/doctest
+//	go test ./examples/doctest -update
+//
+// The second form rewrites the expectations after an intentional change;
+// review their diff before committing it.
+package doctest
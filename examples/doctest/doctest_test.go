@@ -0,0 +1,66 @@
+package doctest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/all"
+	"github.com/mongodb-developer/golang-quickstart/internal/golden"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+)
+
+// checked are the examples whose output is compared. Each runs after its
+// setup examples, whose output is discarded, so it finds the documents the
+// blog post assumes. Left out: connecting prints the cluster's databases,
+// updating modifies its upsert twice within the same millisecond on a fast
+// server, bulk prints server error messages that change between versions and
+// change-streams never finishes.
+var checked = []struct {
+	name  string
+	setup []string
+}{
+	{"creating", nil},
+	{"retrieving", []string{"creating"}},
+	{"modeling", []string{"creating"}},
+	{"indexes", []string{"creating"}},
+	{"transactions", nil},
+	{"deleting", []string{"creating"}},
+}
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+func TestExampleOutput(t *testing.T) {
+	for _, test := range checked {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			// every example starts from an empty database of its own
+			scratch := mongotest.Database(t)
+			client, database := scratch.Client(), scratch.Name()
+
+			for _, name := range test.setup {
+				run(ctx, t, name, examples.Deps{Client: client, Database: database, Out: io.Discard})
+			}
+			var output bytes.Buffer
+			run(ctx, t, test.name, examples.Deps{Client: client, Database: database, Out: &output})
+			golden.Text(t, test.name, output.Bytes())
+		})
+	}
+}
+
+func run(ctx context.Context, t *testing.T, name string, deps examples.Deps) {
+	t.Helper()
+	example, ok := examples.Lookup(name)
+	if !ok {
+		t.Fatalf("no example called %s is registered", name)
+	}
+	if err := example.Run(ctx, deps); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
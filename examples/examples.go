@@ -0,0 +1,175 @@
+// Package examples holds what every example package needs to be called as a
+// library. Each example lives in a package below this one and exposes
+//
+//	func Run(ctx context.Context, deps examples.Deps) error
+//
+// so a program, an umbrella command or a test can run it against a client it
+// already has. The matching directories at the top of the repository, such as
+// creating, are thin mains around those packages:
+//
+//	func main() {
+//		examples.Main(creating.Run, 10*time.Second)
+//	}
+package examples
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultDatabase is the database the examples use unless Deps names another
+const DefaultDatabase = "quickstart"
+
+// Deps is what an example runs against. The example does not disconnect
+// Client; whoever connected it does.
+type Deps struct {
+	Client *mongo.Client
+	// Database defaults to DefaultDatabase
+	Database string
+	// Collections renames the collections an example uses, keyed by the
+	// name the example gives them, such as "episodes"
+	Collections map[string]string
+	// Out receives everything the example prints and defaults to standard
+	// output
+	Out io.Writer
+	// Workshop makes the example pause, show documents and ask for values;
+	// nil runs it straight through
+	Workshop *Workshop
+}
+
+// DB returns the database the example works in
+func (d Deps) DB() *mongo.Database {
+	if d.Database == "" {
+		return d.Client.Database(DefaultDatabase)
+	}
+	return d.Client.Database(d.Database)
+}
+
+// Collection returns the collection the example calls name, renamed by
+// Collections
+func (d Deps) Collection(name string) *mongo.Collection {
+	if renamed, ok := d.Collections[name]; ok {
+		return d.DB().Collection(renamed)
+	}
+	return d.DB().Collection(name)
+}
+
+// Printf formats to Out
+func (d Deps) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(d.out(), format, args...)
+}
+
+// Println prints its arguments to Out like fmt.Println
+func (d Deps) Println(args ...interface{}) {
+	fmt.Fprintln(d.out(), args...)
+}
+
+func (d Deps) out() io.Writer {
+	if d.Out == nil {
+		return os.Stdout
+	}
+	return d.Out
+}
+
+// Func is the entry point every example package exposes as Run
+type Func func(ctx context.Context, deps Deps) error
+
+// Connect connects with db.Connect, registers the client with down and
+// returns Deps for the default database and standard output
+func Connect(ctx context.Context, down *shutdown.Shutdown, opts ...*options.ClientOptions) (Deps, error) {
+	client, err := db.Connect(ctx, opts...)
+	if err != nil {
+		return Deps{}, err
+	}
+	down.Client(client)
+	return Deps{Client: client, Database: DefaultDatabase, Out: os.Stdout}, nil
+}
+
+// Main is the whole main function of an example without flags of its own: it
+// parses the command line for -uri, connects with opts, calls run and leaves
+// errors and the exit status to shutdown.Main. A timeout above zero bounds
+// connecting and run together.
+func Main(run Func, timeout time.Duration, opts ...*options.ClientOptions) {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(func(ctx context.Context, down *shutdown.Shutdown) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		deps, err := Connect(ctx, down, opts...)
+		if err != nil {
+			return err
+		}
+		return run(ctx, deps)
+	})
+}
+
+// Example is an example package as the umbrella runner sees it
+type Example struct {
+	Name        string
+	Description string
+	Run         Func
+	// Order is the example's place in the tour, which runs the examples in
+	// ascending Order so each finds the data the ones before it left
+	Order int
+	// Timeout bounds one run; zero means no limit
+	Timeout time.Duration
+	// Continuous examples run until their context is canceled and are only
+	// run when asked for by name
+	Continuous bool
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Example{}
+)
+
+// Register makes an example available to List and Lookup. Example packages
+// call it from init; importing examples/all registers all of them. It panics
+// when the name is taken, like sql.Register.
+func Register(example Example) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, taken := registry[example.Name]; taken {
+		panic("examples: Register called twice for " + example.Name)
+	}
+	registry[example.Name] = example
+}
+
+// Lookup returns the registered example called name
+func Lookup(name string) (Example, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	example, ok := registry[name]
+	return example, ok
+}
+
+// List returns the registered examples in tour order
+func List() []Example {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	list := make([]Example, 0, len(registry))
+	for _, example := range registry {
+		list = append(list, example)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Order != list[j].Order {
+			return list[i].Order < list[j].Order
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
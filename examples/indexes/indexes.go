@@ -0,0 +1,140 @@
+// Package indexes creates, lists and drops indexes
+package indexes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "indexes",
+		Description: "Create, list and drop indexes",
+		Run:         Run,
+		Order:       70,
+		Timeout:     30 * time.Second,
+	})
+}
+
+// Run creates single field, compound, unique, sparse, TTL and partial
+// indexes, lists them and drops them again. Only the indexes it created are
+// dropped: the collections are shared with other examples, which keep
+// indexes of their own there.
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	// an index that already existed is left alone, even if the example
+	// creates one with the same name
+	existing, err := indexNames(ctx, episodesCollection)
+	if err != nil {
+		return err
+	}
+	var created []string
+
+	// Create A Single Field Index
+	name, err := episodesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"duration", 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("create index on episodes: %w", err)
+	}
+	created = append(created, name)
+	deps.Println("Created single field index:", name)
+
+	// Create A Compound Index, Serving Filters On Podcast Sorted By Duration
+	name, err = episodesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"podcast", 1}, {"duration", -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("create compound index on episodes: %w", err)
+	}
+	created = append(created, name)
+	deps.Println("Created compound index:", name)
+
+	// Create A Unique Index With An Explicit Name
+	name, err = podcastsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"title", 1}},
+		Options: options.Index().SetUnique(true).SetName("unique_title"),
+	})
+	if err != nil {
+		return fmt.Errorf("create unique index on podcasts: %w", err)
+	}
+	deps.Println("Created unique index:", name)
+
+	// Create Sparse, TTL And Partial Indexes At Once
+	names, err := episodesCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// only documents that have a guest field are indexed
+			Keys:    bson.D{{"guest", 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			// documents are removed an hour after their expires_at date
+			Keys:    bson.D{{"expires_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(3600),
+		},
+		{
+			// only long episodes are indexed, keeping the index small
+			Keys: bson.D{{"title", 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.D{
+				{"duration", bson.D{{"$gt", 30}}},
+			}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create indexes on episodes: %w", err)
+	}
+	created = append(created, names...)
+	deps.Println("Created sparse, TTL and partial indexes:", names)
+
+	// List The Indexes Of A Collection
+	cursor, err := episodesCollection.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("list indexes of episodes: %w", err)
+	}
+	var indexes []bson.M
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("decode indexes of episodes: %w", err)
+	}
+	for _, index := range indexes {
+		deps.Println(index["name"], index["key"])
+	}
+
+	// Drop A Single Index By Name
+	if _, err = podcastsCollection.Indexes().DropOne(ctx, "unique_title"); err != nil {
+		return fmt.Errorf("drop index unique_title of podcasts: %w", err)
+	}
+	deps.Println("Dropped index: unique_title")
+
+	// Drop The Indexes This Example Created, Keeping Everyone Else's
+	for _, name := range created {
+		if existing[name] {
+			continue
+		}
+		if _, err = episodesCollection.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("drop index %s of episodes: %w", name, err)
+		}
+	}
+	deps.Println("Dropped the indexes created on episodes")
+	return nil
+}
+
+// indexNames returns the names of the indexes of collection
+func indexNames(ctx context.Context, collection *mongo.Collection) (map[string]bool, error) {
+	specs, err := collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list indexes of %s: %w", collection.Name(), err)
+	}
+	names := map[string]bool{}
+	for _, spec := range specs {
+		names[spec.Name] = true
+	}
+	return names, nil
+}
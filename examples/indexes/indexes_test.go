@@ -0,0 +1,52 @@
+package indexes
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"github.com/mongodb-developer/golang-quickstart/internal/snapshot"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// TestIndexesDelta runs the example and checks that it cleans up after
+// itself: every index it creates is dropped again, an index another example
+// created beforehand survives and no document changed
+func TestIndexesDelta(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	database := mongotest.Database(t)
+
+	// the collections exist already, as they do for the example, so their
+	// _id indexes do not show up as added
+	if err := database.CreateCollection(ctx, "podcasts"); err != nil {
+		t.Fatal(err)
+	}
+	// referential-integrity's TTL index on the same collection
+	_, err := database.Collection("episodes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"orphaned_at", 1}},
+		Options: options.Index().SetExpireAfterSeconds(3600),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot.Expect(t, database,
+		snapshot.Spec{"podcasts": nil, "episodes": {"duration"}},
+		snapshot.Delta{},
+		func() {
+			if err := Run(ctx, examples.Deps{Client: database.Client(), Database: database.Name(), Out: io.Discard}); err != nil {
+				t.Fatal(err)
+			}
+		},
+	)
+}
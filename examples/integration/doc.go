@@ -0,0 +1,6 @@
+// Package integration tests the create, retrieve, update, delete and
+// aggregation functions of the examples against a real deployment, started
+// by internal/mongotest. It has no code of its own:
+//
+//	go test ./examples/integration
+package integration
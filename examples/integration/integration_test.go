@@ -0,0 +1,287 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/cascade"
+	"github.com/mongodb-developer/golang-quickstart/examples/aggregation"
+	"github.com/mongodb-developer/golang-quickstart/examples/creating"
+	"github.com/mongodb-developer/golang-quickstart/examples/deleting"
+	"github.com/mongodb-developer/golang-quickstart/examples/retrieving"
+	"github.com/mongodb-developer/golang-quickstart/examples/updating"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// seeded holds the _ids of the podcasts seed inserts
+type seeded struct {
+	polyglot primitive.ObjectID
+	mongodb  primitive.ObjectID
+}
+
+// seed fills an empty database with the podcast and episodes of the
+// creating example (25 and 32 minutes), a second podcast with one 40 minute
+// episode and a 10 minute episode whose podcast does not exist
+func seed(ctx context.Context, t *testing.T, database *mongo.Database) seeded {
+	t.Helper()
+	id, err := creating.InsertPodcast(ctx, database.Collection("podcasts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s seeded
+	s.polyglot = id.(primitive.ObjectID)
+	if _, err = creating.InsertEpisodes(ctx, database.Collection("episodes"), s.polyglot); err != nil {
+		t.Fatal(err)
+	}
+	result, err := database.Collection("podcasts").InsertOne(ctx, bson.D{{"title", "The MongoDB Podcast"}, {"author", "Michael Lynn"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mongodb = result.InsertedID.(primitive.ObjectID)
+	_, err = database.Collection("episodes").InsertMany(ctx, []interface{}{
+		bson.D{{"podcast", s.mongodb}, {"title", "MongoDB Transactions"}, {"description", "Multi-document ACID transactions."}, {"duration", 40}},
+		bson.D{{"podcast", primitive.NewObjectID()}, {"title", "Orphaned Episode"}, {"description", "Its podcast was deleted."}, {"duration", 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// titles returns the title of each episode
+func titles(episodes []retrieving.Episode) []string {
+	titles := []string{}
+	for _, episode := range episodes {
+		titles = append(titles, episode.Title)
+	}
+	return titles
+}
+
+func TestCreating(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	database := mongotest.Database(t)
+	s := seed(ctx, t, database)
+
+	tests := []struct {
+		collection string
+		filter     bson.D
+		want       int64
+	}{
+		{"podcasts", bson.D{}, 2},
+		{"podcasts", bson.D{{"title", "The Polyglot Developer Podcast"}, {"tags", "coding"}}, 1},
+		{"episodes", bson.D{{"podcast", s.polyglot}}, 2},
+		{"episodes", bson.D{{"podcast", s.polyglot}, {"duration", 25}}, 1},
+	}
+	for _, test := range tests {
+		count, err := database.Collection(test.collection).CountDocuments(ctx, test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != test.want {
+			t.Errorf("%s matching %v = %d, want %d", test.collection, test.filter, count, test.want)
+		}
+	}
+}
+
+func TestRetrieving(t *testing.T) {
+	tests := []struct {
+		name string
+		find func(context.Context, *mongo.Collection) ([]retrieving.Episode, error)
+		want []string
+	}{
+		{"longer than 24", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.EpisodesLongerThan(ctx, episodes, 24)
+		}, []string{"MongoDB Transactions", "Progressive Web Application Development", "GraphQL for API Development"}},
+		{"longer than 32", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.EpisodesLongerThan(ctx, episodes, 32)
+		}, []string{"MongoDB Transactions"}},
+		{"longer than 60", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.EpisodesLongerThan(ctx, episodes, 60)
+		}, []string{}},
+		{"title or description", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.SearchEpisodes(ctx, episodes, "graphql", "tara")
+		}, []string{"GraphQL for API Development", "Progressive Web Application Development"}},
+		{"ignoring case", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.SearchEpisodes(ctx, episodes, "ACID")
+		}, []string{"MongoDB Transactions"}},
+		{"no match", func(ctx context.Context, episodes *mongo.Collection) ([]retrieving.Episode, error) {
+			return retrieving.SearchEpisodes(ctx, episodes, "kubernetes")
+		}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			database := mongotest.Database(t)
+			seed(ctx, t, database)
+
+			episodes, err := test.find(ctx, database.Collection("episodes"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(episodes); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("titles = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdating(t *testing.T) {
+	tests := []struct {
+		name     string
+		update   func(context.Context, *mongo.Collection, seeded) (*mongo.UpdateResult, error)
+		matched  int64
+		modified int64
+		upserted bool
+	}{
+		{"new author", func(ctx context.Context, podcasts *mongo.Collection, s seeded) (*mongo.UpdateResult, error) {
+			return updating.SetAuthor(ctx, podcasts, s.polyglot, "Nicolas Raboy")
+		}, 1, 1, false},
+		{"same author", func(ctx context.Context, podcasts *mongo.Collection, s seeded) (*mongo.UpdateResult, error) {
+			return updating.SetAuthor(ctx, podcasts, s.polyglot, "Nic Raboy")
+		}, 1, 0, false},
+		{"unknown podcast", func(ctx context.Context, podcasts *mongo.Collection, s seeded) (*mongo.UpdateResult, error) {
+			return updating.SetAuthor(ctx, podcasts, primitive.NewObjectID(), "Nic Raboy")
+		}, 0, 0, false},
+		{"upsert inserts", func(ctx context.Context, podcasts *mongo.Collection, s seeded) (*mongo.UpdateResult, error) {
+			return updating.UpsertPodcast(ctx, podcasts, "The Upsert Podcast", "Nic Raboy")
+		}, 0, 0, true},
+		{"upsert updates", func(ctx context.Context, podcasts *mongo.Collection, s seeded) (*mongo.UpdateResult, error) {
+			return updating.UpsertPodcast(ctx, podcasts, "The MongoDB Podcast", "Nic Raboy")
+		}, 1, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			database := mongotest.Database(t)
+			s := seed(ctx, t, database)
+
+			result, err := test.update(ctx, database.Collection("podcasts"), s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.MatchedCount != test.matched || result.ModifiedCount != test.modified || (result.UpsertedID != nil) != test.upserted {
+				t.Errorf("result = %+v, want %d matched, %d modified, upserted %v", result, test.matched, test.modified, test.upserted)
+			}
+		})
+	}
+}
+
+func TestDeleting(t *testing.T) {
+	tests := []struct {
+		minutes int
+		deleted int64
+		left    int64
+	}{
+		{25, 1, 3},
+		{40, 1, 3},
+		{99, 0, 4},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d minutes", test.minutes), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			database := mongotest.Database(t)
+			seed(ctx, t, database)
+
+			deleted, err := deleting.DeleteEpisodesByDuration(ctx, database.Collection("episodes"), test.minutes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			left, err := database.Collection("episodes").CountDocuments(ctx, bson.D{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != test.deleted || left != test.left {
+				t.Errorf("deleted %d and left %d, want %d and %d", deleted, left, test.deleted, test.left)
+			}
+		})
+	}
+}
+
+// TestDeletePodcastCascade runs the cascade in a transaction, which is why
+// mongotest starts a replica set
+func TestDeletePodcastCascade(t *testing.T) {
+	tests := []struct {
+		name string
+		id   func(seeded) primitive.ObjectID
+		want cascade.Result
+		err  error
+	}{
+		{"two episodes", func(s seeded) primitive.ObjectID { return s.polyglot }, cascade.Result{Podcasts: 1, Episodes: 2, Transactional: true}, nil},
+		{"one episode", func(s seeded) primitive.ObjectID { return s.mongodb }, cascade.Result{Podcasts: 1, Episodes: 1, Transactional: true}, nil},
+		{"unknown podcast", func(seeded) primitive.ObjectID { return primitive.NewObjectID() }, cascade.Result{}, cascade.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			database := mongotest.Database(t)
+			s := seed(ctx, t, database)
+
+			result, err := cascade.New(database).DeletePodcastCascade(ctx, test.id(s))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if err == nil && result != test.want {
+				t.Errorf("result = %+v, want %+v", result, test.want)
+			}
+		})
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	database := mongotest.Database(t)
+	s := seed(ctx, t, database)
+
+	tests := []struct {
+		name    string
+		podcast primitive.ObjectID
+		want    []aggregation.PodcastTotal
+	}{
+		{"two episodes", s.polyglot, []aggregation.PodcastTotal{{Podcast: s.polyglot, Total: 57}}},
+		{"one episode", s.mongodb, []aggregation.PodcastTotal{{Podcast: s.mongodb, Total: 40}}},
+		{"no episodes", primitive.NewObjectID(), []aggregation.PodcastTotal{}},
+	}
+	for _, test := range tests {
+		totals, err := aggregation.TotalDuration(ctx, database.Collection("episodes"), test.podcast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(totals, test.want) {
+			t.Errorf("%s: TotalDuration = %+v, want %+v", test.name, totals, test.want)
+		}
+	}
+
+	episodes, err := aggregation.EpisodesWithPodcast(ctx, database.Collection("episodes"), database.Collection("podcasts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	podcasts := map[string]string{}
+	for _, episode := range episodes {
+		podcasts[episode.Title] = episode.Podcast.Title
+	}
+	want := map[string]string{
+		"GraphQL for API Development":             "The Polyglot Developer Podcast",
+		"Progressive Web Application Development": "The Polyglot Developer Podcast",
+		"MongoDB Transactions":                    "The MongoDB Podcast",
+	}
+	if !reflect.DeepEqual(podcasts, want) {
+		t.Errorf("EpisodesWithPodcast = %v, want %v (the orphaned episode left out)", podcasts, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Package modeling maps documents to native Go structs
+package modeling
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "modeling",
+		Description: "Read and write podcasts and episodes as Go structs",
+		Run:         Run,
+		Order:       50,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Title  string             `bson:"title,omitempty"`
+	Author string             `bson:"author,omitempty"`
+	Tags   []string           `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+}
+
+// Run finds episodes into structs, inserts a podcast struct and reads both
+// through a typed repository
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	var episodes []Episode
+	cursor, err := episodesCollection.Find(ctx, bson.M{"duration": bson.D{{"$gt", 25}}})
+	if err != nil {
+		return fmt.Errorf("find in episodes: %w", err)
+	}
+	if err = cursor.All(ctx, &episodes); err != nil {
+		return fmt.Errorf("decode episodes: %w", err)
+	}
+	deps.Println(episodes)
+
+	podcast := Podcast{
+		Title:  "The Polyglot Developer",
+		Author: "Nic Raboy",
+		Tags:   []string{"development", "programming", "coding"},
+	}
+	insertResult, err := podcastsCollection.InsertOne(ctx, podcast)
+	if err != nil {
+		return fmt.Errorf("insert into podcasts: %w", err)
+	}
+	deps.Println(insertResult.InsertedID)
+
+	// Read And Write The Same Structs Through A Typed Repository
+	episodesRepository := repository.New[Episode](episodesCollection)
+	podcastsRepository := repository.New[Podcast](podcastsCollection)
+	longEpisodes, err := episodesRepository.Find(ctx, bson.M{"duration": bson.D{{"$gt", 25}}})
+	if err != nil {
+		return fmt.Errorf("find long episodes: %w", err)
+	}
+	deps.Println(longEpisodes)
+	inserted, err := podcastsRepository.FindByID(ctx, insertResult.InsertedID)
+	if err != nil {
+		return fmt.Errorf("find podcast %v: %w", insertResult.InsertedID, err)
+	}
+	deps.Println(inserted)
+	return nil
+}
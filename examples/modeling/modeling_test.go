@@ -0,0 +1,169 @@
+package modeling
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pgregory.net/rapid"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+func objectIDGen() *rapid.Generator[primitive.ObjectID] {
+	return rapid.Custom(func(t *rapid.T) primitive.ObjectID {
+		// mostly real ids, sometimes the zero value that omitempty drops
+		if rapid.IntRange(0, 9).Draw(t, "zero") == 0 {
+			return primitive.NilObjectID
+		}
+		var id primitive.ObjectID
+		copy(id[:], rapid.SliceOfN(rapid.Byte(), 12, 12).Draw(t, "bytes"))
+		return id
+	})
+}
+
+func podcastGen() *rapid.Generator[Podcast] {
+	return rapid.Custom(func(t *rapid.T) Podcast {
+		return Podcast{
+			ID:     objectIDGen().Draw(t, "id"),
+			Title:  rapid.String().Draw(t, "title"),
+			Author: rapid.String().Draw(t, "author"),
+			Tags:   rapid.SliceOfN(rapid.String(), 0, 5).Draw(t, "tags"),
+		}
+	})
+}
+
+func episodeGen() *rapid.Generator[Episode] {
+	return rapid.Custom(func(t *rapid.T) Episode {
+		return Episode{
+			ID:          objectIDGen().Draw(t, "id"),
+			Podcast:     objectIDGen().Draw(t, "podcast"),
+			Title:       rapid.String().Draw(t, "title"),
+			Description: rapid.String().Draw(t, "description"),
+			Duration:    rapid.Int32().Draw(t, "duration"),
+		}
+	})
+}
+
+// expectedPodcast is what a Podcast looks like after a round trip: omitempty
+// drops an empty Tags slice, so it decodes as nil. Any other difference is a bug.
+func expectedPodcast(podcast Podcast) Podcast {
+	if len(podcast.Tags) == 0 {
+		podcast.Tags = nil
+	}
+	return podcast
+}
+
+// assertOmitEmpty checks that a field is written exactly when its value is
+// not the zero value, which is what the omitempty tags promise
+func assertOmitEmpty(t *rapid.T, document bson.Raw, field string, zero bool) {
+	_, err := document.LookupErr(field)
+	if present := err == nil; present == zero {
+		t.Fatalf("field %q present=%v for zero=%v in %v", field, present, zero, document)
+	}
+}
+
+func TestPodcastMarshalRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		podcast := podcastGen().Draw(t, "podcast")
+		data, err := bson.Marshal(podcast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := bson.Raw(data)
+		assertOmitEmpty(t, document, "_id", podcast.ID.IsZero())
+		assertOmitEmpty(t, document, "title", podcast.Title == "")
+		assertOmitEmpty(t, document, "author", podcast.Author == "")
+		assertOmitEmpty(t, document, "tags", len(podcast.Tags) == 0)
+
+		var decoded Podcast
+		if err = bson.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if want := expectedPodcast(podcast); !reflect.DeepEqual(decoded, want) {
+			t.Fatalf("round trip changed the podcast\n got: %#v\nwant: %#v", decoded, want)
+		}
+	})
+}
+
+func TestEpisodeMarshalRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		episode := episodeGen().Draw(t, "episode")
+		data, err := bson.Marshal(episode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := bson.Raw(data)
+		assertOmitEmpty(t, document, "podcast", episode.Podcast.IsZero())
+		assertOmitEmpty(t, document, "duration", episode.Duration == 0)
+		// duration must stay an int32, not be widened to int64 or double
+		if value, err := document.LookupErr("duration"); err == nil {
+			if _, ok := value.Int32OK(); !ok {
+				t.Fatalf("duration stored as %v, want int32", value.Type)
+			}
+		}
+
+		var decoded Episode
+		if err = bson.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != episode {
+			t.Fatalf("round trip changed the episode\n got: %#v\nwant: %#v", decoded, episode)
+		}
+	})
+}
+
+// TestDatabaseRoundTrip inserts generated documents and reads them back, so
+// server-side type handling is covered too
+func TestDatabaseRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	database := mongotest.Database(t)
+
+	podcastsCollection := database.Collection("podcasts")
+	episodesCollection := database.Collection("episodes")
+
+	t.Run("podcasts", func(t *testing.T) {
+		rapid.Check(t, func(t *rapid.T) {
+			podcast := podcastGen().Draw(t, "podcast")
+			// a zero id is omitted and generated by the driver on insert
+			result, err := podcastsCollection.InsertOne(ctx, podcast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			podcast.ID = result.InsertedID.(primitive.ObjectID)
+			var decoded Podcast
+			if err = podcastsCollection.FindOne(ctx, bson.M{"_id": podcast.ID}).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			if want := expectedPodcast(podcast); !reflect.DeepEqual(decoded, want) {
+				t.Fatalf("stored podcast differs\n got: %#v\nwant: %#v", decoded, want)
+			}
+			podcastsCollection.DeleteOne(ctx, bson.M{"_id": podcast.ID})
+		})
+	})
+	t.Run("episodes", func(t *testing.T) {
+		rapid.Check(t, func(t *rapid.T) {
+			episode := episodeGen().Draw(t, "episode")
+			result, err := episodesCollection.InsertOne(ctx, episode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			episode.ID = result.InsertedID.(primitive.ObjectID)
+			var decoded Episode
+			if err = episodesCollection.FindOne(ctx, bson.M{"_id": episode.ID}).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != episode {
+				t.Fatalf("stored episode differs\n got: %#v\nwant: %#v", decoded, episode)
+			}
+			episodesCollection.DeleteOne(ctx, bson.M{"_id": episode.ID})
+		})
+	})
+}
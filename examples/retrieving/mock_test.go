@@ -0,0 +1,109 @@
+package retrieving
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestFindMock checks the filters and options the finds send and how they
+// decode the mocked batches, without a cluster
+func TestFindMock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	episode := func(title string, duration int32) bson.D {
+		return bson.D{{"title", title}, {"duration", duration}}
+	}
+	tests := []struct {
+		name      string
+		find      func(context.Context, *mongo.Collection) ([]Episode, error)
+		responses []bson.D
+		filter    string
+		sort      string
+		want      []Episode
+		err       bool
+	}{
+		{
+			name: "longer than",
+			find: func(ctx context.Context, episodes *mongo.Collection) ([]Episode, error) {
+				return EpisodesLongerThan(ctx, episodes, 24)
+			},
+			responses: []bson.D{mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch,
+				episode("Progressive Web Application Development", 32), episode("GraphQL for API Development", 25))},
+			filter: `{"duration": {"$gt": {"$numberInt":"24"}}}`,
+			sort:   `{"duration": {"$numberInt":"-1"}}`,
+			want: []Episode{
+				{Title: "Progressive Web Application Development", Duration: 32},
+				{Title: "GraphQL for API Development", Duration: 25},
+			},
+		},
+		{
+			name: "two batches",
+			find: func(ctx context.Context, episodes *mongo.Collection) ([]Episode, error) {
+				return EpisodesLongerThan(ctx, episodes, 30)
+			},
+			responses: []bson.D{
+				mtest.CreateCursorResponse(42, "test.episodes", mtest.FirstBatch, episode("A", 40)),
+				mtest.CreateCursorResponse(0, "test.episodes", mtest.NextBatch, episode("B", 35)),
+			},
+			filter: `{"duration": {"$gt": {"$numberInt":"30"}}}`,
+			sort:   `{"duration": {"$numberInt":"-1"}}`,
+			want:   []Episode{{Title: "A", Duration: 40}, {Title: "B", Duration: 35}},
+		},
+		{
+			name: "nothing found",
+			find: func(ctx context.Context, episodes *mongo.Collection) ([]Episode, error) {
+				return SearchEpisodes(ctx, episodes, "graphql")
+			},
+			responses: []bson.D{mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch)},
+			filter:    `{"$or": [{"title": {"$in": [{"$regularExpression":{"pattern":"graphql","options":"i"}}]}},{"description": {"$in": [{"$regularExpression":{"pattern":"graphql","options":"i"}}]}}]}`,
+			want:      []Episode{},
+		},
+		{
+			name: "server error",
+			find: func(ctx context.Context, episodes *mongo.Collection) ([]Episode, error) {
+				return EpisodesLongerThan(ctx, episodes, 24)
+			},
+			responses: []bson.D{mtest.CreateCommandErrorResponse(mtest.CommandError{
+				Code: 13, Name: "Unauthorized", Message: "not authorized on test to execute command",
+			})},
+			filter: `{"duration": {"$gt": {"$numberInt":"24"}}}`,
+			sort:   `{"duration": {"$numberInt":"-1"}}`,
+			err:    true,
+		},
+		{
+			name: "undecodable document",
+			find: func(ctx context.Context, episodes *mongo.Collection) ([]Episode, error) {
+				return EpisodesLongerThan(ctx, episodes, 24)
+			},
+			responses: []bson.D{mtest.CreateCursorResponse(0, "test.episodes", mtest.FirstBatch,
+				bson.D{{"title", "A"}, {"duration", "long"}})},
+			filter: `{"duration": {"$gt": {"$numberInt":"24"}}}`,
+			sort:   `{"duration": {"$numberInt":"-1"}}`,
+			err:    true,
+		},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			mt.AddMockResponses(test.responses...)
+			episodes, err := test.find(mt.Context(), mt.DB.Collection("episodes"))
+			if (err != nil) != test.err {
+				mt.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			command := mt.GetStartedEvent().Command
+			if filter := command.Lookup("filter").String(); filter != test.filter {
+				mt.Errorf("filter = %s, want %s", filter, test.filter)
+			}
+			if sort, _ := command.LookupErr("sort"); test.sort != "" && sort.String() != test.sort {
+				mt.Errorf("sort = %s, want %s", sort, test.sort)
+			}
+			if !test.err && !reflect.DeepEqual(episodes, test.want) {
+				mt.Errorf("episodes = %+v, want %+v", episodes, test.want)
+			}
+		})
+	}
+}
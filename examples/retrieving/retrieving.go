@@ -0,0 +1,268 @@
+// Package retrieving reads documents with Find and FindOne, including
+// searches for several terms across several fields and nested paths
+package retrieving
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/compass"
+	"github.com/mongodb-developer/golang-quickstart/dotpath"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/filter"
+	"github.com/mongodb-developer/golang-quickstart/typedcoll"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "retrieving",
+		Description: "Find documents with filters, sorting, a Compass query and multi-term searches",
+		Run:         Run,
+		Order:       30,
+		Timeout:     10 * time.Second,
+	})
+}
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Title  string             `bson:"title,omitempty"`
+	Author string             `bson:"author,omitempty"`
+	Tags   []string           `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+}
+
+// Listener represents the schema for the "retrieving_listeners" collection,
+// which holds the nested documents the nested path searches need
+type Listener struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	Name    string             `bson:"name"`
+	Profile struct {
+		Location struct {
+			Country string `bson:"country"`
+			City    string `bson:"city"`
+		} `bson:"location"`
+	} `bson:"profile"`
+	Devices []struct {
+		OS string `bson:"os"`
+	} `bson:"devices"`
+}
+
+// listeners returns the documents for "retrieving_listeners"
+func listeners() []interface{} {
+	listener := func(name, country, city string, devices ...string) bson.D {
+		deviceDocuments := bson.A{}
+		for _, device := range devices {
+			deviceDocuments = append(deviceDocuments, bson.D{{"os", device}})
+		}
+		return bson.D{
+			{"name", name},
+			{"profile", bson.D{{"location", bson.D{{"country", country}, {"city", city}}}}},
+			{"devices", deviceDocuments},
+		}
+	}
+	return []interface{}{
+		listener("Ada", "DE", "Berlin", "ios", "android"),
+		listener("Grace", "FR", "Paris", "android"),
+		listener("Linus", "US", "Boston", "ios"),
+	}
+}
+
+// titles returns the title of each episode
+func titles(episodes []Episode) []string {
+	titles := []string{}
+	for _, episode := range episodes {
+		titles = append(titles, episode.Title)
+	}
+	return titles
+}
+
+// names returns the name of each listener
+func names(listeners []Listener) []string {
+	names := []string{}
+	for _, listener := range listeners {
+		names = append(names, listener.Name)
+	}
+	return names
+}
+
+// EpisodesLongerThan returns the episodes longer than minutes, longest first
+func EpisodesLongerThan(ctx context.Context, episodes *mongo.Collection, minutes int) ([]Episode, error) {
+	opts := options.Find()
+	opts.SetSort(bson.D{{"duration", -1}})
+	return typedcoll.New[Episode](episodes).
+		Find(ctx, bson.D{{"duration", bson.D{{"$gt", minutes}}}}, opts)
+}
+
+// SearchEpisodes returns the episodes whose title or description contains
+// any of terms, ignoring case. Terms are regular expressions.
+func SearchEpisodes(ctx context.Context, episodes *mongo.Collection, terms ...string) ([]Episode, error) {
+	patterns := make([]primitive.Regex, 0, len(terms))
+	for _, term := range terms {
+		patterns = append(patterns, primitive.Regex{Pattern: term, Options: "i"})
+	}
+	return typedcoll.New[Episode](episodes).
+		Find(ctx, filter.InAny([]string{"title", "description"}, patterns...))
+}
+
+// Run finds all episodes, one podcast, filtered and sorted episodes and
+// episodes matching a query copied from Compass, then searches for several
+// terms at once. Every collection is typed, so every result is decoded into
+// Podcast, Episode or Listener.
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := typedcoll.New[Podcast](deps.Collection("podcasts"))
+	episodesCollection := typedcoll.New[Episode](deps.Collection("episodes"))
+
+	// Retrieve All Documents
+	deps.Pause("Find with an empty filter returns every episode")
+	// range decodes one episode at a time and closes the cursor when the
+	// loop ends, even on an early return
+	for episode, err := range typedcoll.Find[Episode](ctx, episodesCollection.Collection, bson.M{}) {
+		if err != nil {
+			return fmt.Errorf("find in episodes: %w", err)
+		}
+		deps.Println(episode)
+	}
+
+	// Retrieve A Single Document
+	deps.Pause("FindOne returns the first podcast")
+	podcast, err := podcastsCollection.FindOne(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("find one in podcasts: %w", err)
+	}
+	deps.Println(podcast)
+
+	// Find Documents Matching A Filter
+	deps.Pause("Find with a filter returns episodes of one duration")
+	duration := deps.Int("duration", 25)
+	episodesFiltered, err := episodesCollection.Find(ctx, bson.M{"duration": duration})
+	if err != nil {
+		return fmt.Errorf("find episodes with duration %d: %w", duration, err)
+	}
+	deps.Println(episodesFiltered)
+
+	// Find Documents Matching Filter And Sort
+	deps.Pause("Find with $gt and a sort returns longer episodes, longest first")
+	longer := deps.Int("longer than", 24)
+	episodesSorted, err := EpisodesLongerThan(ctx, episodesCollection.Collection, longer)
+	if err != nil {
+		return fmt.Errorf("find sorted episodes: %w", err)
+	}
+	deps.Println(episodesSorted)
+
+	// Find Documents With A Query Copied From Compass
+	deps.Pause("Find with a query copied from Compass")
+	compassQuery, err := compass.ParseQuery(`{
+		"filter": {"podcast": {"$oid": "5dd890a61c9d4400003f3a31"}},
+		"sort": {"duration": -1},
+		"limit": 2
+	}`)
+	if err != nil {
+		return fmt.Errorf("parse Compass query: %w", err)
+	}
+	compassOptions, err := compassQuery.FindOptions()
+	if err != nil {
+		return fmt.Errorf("parse Compass query options: %w", err)
+	}
+	episodesCompass, err := episodesCollection.Find(ctx, compassQuery.Filter, compassOptions)
+	if err != nil {
+		return fmt.Errorf("find episodes with Compass query: %w", err)
+	}
+	deps.Println(episodesCompass)
+
+	// Find Documents Matching Any Of Several Values With $in
+	deps.Pause("$in matches a field against a list of values")
+	episodesIn, err := episodesCollection.Find(ctx, bson.D{{"title", bson.D{{"$in", bson.A{
+		"GraphQL for API Development", "MongoDB Transactions",
+	}}}}})
+	if err != nil {
+		return fmt.Errorf("find episodes with $in: %w", err)
+	}
+	deps.Printf("%q\n", titles(episodesIn))
+
+	// $in takes a single field, so searching several fields for several
+	// terms is an $or with one $in per field. Regular expressions in $in
+	// match parts of a string, here case insensitively.
+	deps.Pause("$or of $in searches several fields for several terms")
+	episodesSearched, err := SearchEpisodes(ctx, episodesCollection.Collection, "graphql", "tara")
+	if err != nil {
+		return fmt.Errorf("find episodes with $or of $in: %w", err)
+	}
+	deps.Printf("%q\n", titles(episodesSearched))
+
+	// Match Arrays Containing Every Value With $all
+	// $in on an array field matches when any element is listed, $all only
+	// when every listed value is an element
+	deps.Pause("$all and $in on the tags array")
+	for _, tagFilter := range []bson.D{
+		filter.All("tags", "development", "coding"),
+		filter.All("tags", "development", "go"),
+		filter.In("tags", "development", "go"),
+	} {
+		count, err := podcastsCollection.CountDocuments(ctx, tagFilter)
+		if err != nil {
+			return fmt.Errorf("count podcasts by tags: %w", err)
+		}
+		deps.Printf("%v matches %d podcast(s)\n", tagFilter, count)
+	}
+
+	// Search Nested Paths With Dot Notation
+	listenersCollection := typedcoll.New[Listener](deps.Collection("retrieving_listeners"))
+	if err = listenersCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop retrieving_listeners: %w", err)
+	}
+	if _, err = listenersCollection.InsertMany(ctx, listeners()); err != nil {
+		return fmt.Errorf("insert into retrieving_listeners: %w", err)
+	}
+	// a path like nested0.nested1.val1 reaches into embedded documents,
+	// and into every element of an array of them
+	deps.Pause("$in on a nested path with dot notation")
+	inCountries, err := listenersCollection.Find(ctx, bson.D{{"profile.location.country", bson.D{{"$in", bson.A{"DE", "FR"}}}}})
+	if err != nil {
+		return fmt.Errorf("find listeners by country: %w", err)
+	}
+	deps.Printf("%q\n", names(inCountries))
+
+	// an embedded document as the value only matches documents whose
+	// location is exactly that document, city and field order included
+	deps.Pause("The same search with an embedded document instead of a path")
+	exactLocation, err := listenersCollection.Find(ctx, bson.D{{"profile.location", bson.D{{"country", "DE"}}}})
+	if err != nil {
+		return fmt.Errorf("find listeners by location: %w", err)
+	}
+	deps.Printf("%q\n", names(exactLocation))
+
+	// dotpath flattens the embedded document into one path per field, which
+	// is what the search meant, and rejects paths Listener does not have
+	deps.Pause("The embedded document flattened into paths with dotpath")
+	byPaths, err := dotpath.Match[Listener]("profile.location", bson.D{{"country", "DE"}})
+	if err != nil {
+		return fmt.Errorf("listener location filter: %w", err)
+	}
+	inLocation, err := listenersCollection.Find(ctx, byPaths)
+	if err != nil {
+		return fmt.Errorf("find listeners by location paths: %w", err)
+	}
+	deps.Printf("%q\n", names(inLocation))
+
+	deps.Pause("$all on a path through an array of documents")
+	bothDevices, err := listenersCollection.Find(ctx, filter.All("devices.os", "ios", "android"))
+	if err != nil {
+		return fmt.Errorf("find listeners by devices: %w", err)
+	}
+	deps.Printf("%q\n", names(bothDevices))
+	return nil
+}
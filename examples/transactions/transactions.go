@@ -0,0 +1,226 @@
+// Package transactions writes several documents in one multi-document
+// transaction and deletes a podcast with its episodes atomically
+package transactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	examples.Register(examples.Example{
+		Name:        "transactions",
+		Description: "Insert episodes and delete a podcast with its episodes in multi-document transactions",
+		Run:         Run,
+		Order:       100,
+		Timeout:     2 * time.Minute,
+	})
+}
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Title  string             `bson:"title,omitempty"`
+	Author string             `bson:"author,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+}
+
+// hasErrorLabel reports whether the server attached label to err.
+// TransientTransactionError means the whole transaction can be retried;
+// UnknownTransactionCommitResult means the commit may or may not have
+// happened and only the commit should be retried.
+func hasErrorLabel(err error, label string) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorLabel(label)
+}
+
+// runTransactionWithRetry runs txnFn again for as long as it fails with a
+// transient transaction error and the context has time left
+func runTransactionWithRetry(deps examples.Deps, sessionContext mongo.SessionContext, txnFn func(mongo.SessionContext) error) error {
+	for {
+		err := txnFn(sessionContext)
+		if err == nil || !hasErrorLabel(err, "TransientTransactionError") || sessionContext.Err() != nil {
+			return err
+		}
+		deps.Println("TransientTransactionError, retrying transaction...")
+	}
+}
+
+// commitWithRetry commits the session's transaction, retrying the commit
+// alone when its outcome is unknown. Commits are idempotent, so retrying one
+// that did succeed is safe.
+func commitWithRetry(deps examples.Deps, sessionContext mongo.SessionContext) error {
+	for {
+		err := sessionContext.CommitTransaction(sessionContext)
+		if err == nil {
+			deps.Println("Transaction committed.")
+			return nil
+		}
+		if !hasErrorLabel(err, "UnknownTransactionCommitResult") || sessionContext.Err() != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+		deps.Println("UnknownTransactionCommitResult, retrying commit operation...")
+	}
+}
+
+// insertEpisodes makes the writes of the transaction
+func insertEpisodes(deps examples.Deps, sessionContext mongo.SessionContext, episodesCollection *mongo.Collection) error {
+	result, err := episodesCollection.InsertOne(
+		sessionContext,
+		Episode{
+			Title:    "A Transaction Episode for the Ages",
+			Duration: 15,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("insert into %s: %w", episodesCollection.Name(), err)
+	}
+	deps.Println(result.InsertedID)
+	result, err = episodesCollection.InsertOne(
+		sessionContext,
+		Episode{
+			Title:    "Transactions for All",
+			Duration: 2,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("insert into %s: %w", episodesCollection.Name(), err)
+	}
+	deps.Println(result.InsertedID)
+	return nil
+}
+
+// errEpisodesFailed stands in for the second delete failing, as it would on a
+// lost primary or a network error after the retries ran out
+var errEpisodesFailed = errors.New("deleting the episodes failed")
+
+// deletePodcast deletes the podcast and then its episodes. When fail is set
+// the episodes are left alone and errEpisodesFailed is returned instead.
+func deletePodcast(sessionContext mongo.SessionContext, podcastsCollection, episodesCollection *mongo.Collection, id primitive.ObjectID, fail bool) error {
+	if _, err := podcastsCollection.DeleteOne(sessionContext, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("delete from %s: %w", podcastsCollection.Name(), err)
+	}
+	if fail {
+		return errEpisodesFailed
+	}
+	if _, err := episodesCollection.DeleteMany(sessionContext, bson.M{"podcast": id}); err != nil {
+		return fmt.Errorf("delete from %s: %w", episodesCollection.Name(), err)
+	}
+	return nil
+}
+
+// countPodcast prints how many documents of the podcast are left in each
+// collection
+func countPodcast(ctx context.Context, deps examples.Deps, podcastsCollection, episodesCollection *mongo.Collection, id primitive.ObjectID) error {
+	podcasts, err := podcastsCollection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("count in %s: %w", podcastsCollection.Name(), err)
+	}
+	episodes, err := episodesCollection.CountDocuments(ctx, bson.M{"podcast": id})
+	if err != nil {
+		return fmt.Errorf("count in %s: %w", episodesCollection.Name(), err)
+	}
+	deps.Printf("%d podcast(s) and %d episode(s) left\n", podcasts, episodes)
+	return nil
+}
+
+// Run inserts two episodes in a transaction managed by hand and again with
+// WithTransaction, then deletes a podcast and its episodes in one
+// transaction, first failing half way and then for real. The cluster must be
+// a replica set.
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	session, err := deps.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	// Manage The Transaction Yourself With WithSession
+	err = mongo.WithSession(ctx, session, func(sessionContext mongo.SessionContext) error {
+		return runTransactionWithRetry(deps, sessionContext, func(sessionContext mongo.SessionContext) error {
+			if err := session.StartTransaction(); err != nil {
+				return fmt.Errorf("start transaction: %w", err)
+			}
+			if err := insertEpisodes(deps, sessionContext, episodesCollection); err != nil {
+				// abort so the next attempt can start a new transaction
+				session.AbortTransaction(context.Background())
+				return err
+			}
+			return commitWithRetry(deps, sessionContext)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("transaction with WithSession: %w", err)
+	}
+
+	// Let WithTransaction Handle Starting, Committing And Retrying
+	// it applies the same two retry rules as the loops above
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, insertEpisodes(deps, sessionContext, episodesCollection)
+	})
+	if err != nil {
+		return fmt.Errorf("transaction with WithTransaction: %w", err)
+	}
+
+	// Delete A Podcast And Its Episodes Across Two Collections
+	podcastResult, err := podcastsCollection.InsertOne(ctx, Podcast{
+		Title:  "The Transactional Podcast",
+		Author: "Nic Raboy",
+	})
+	if err != nil {
+		return fmt.Errorf("insert into podcasts: %w", err)
+	}
+	podcastID := podcastResult.InsertedID.(primitive.ObjectID)
+	_, err = episodesCollection.InsertMany(ctx, []interface{}{
+		Episode{Podcast: podcastID, Title: "Atomicity", Duration: 20},
+		Episode{Podcast: podcastID, Title: "Isolation", Duration: 30},
+	})
+	if err != nil {
+		return fmt.Errorf("insert into episodes: %w", err)
+	}
+	if err = countPodcast(ctx, deps, podcastsCollection, episodesCollection, podcastID); err != nil {
+		return err
+	}
+
+	// when the episodes cannot be deleted WithTransaction aborts, and the
+	// podcast deleted before is back as if nothing happened
+	deps.Pause("Delete the podcast, then fail before deleting its episodes")
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, deletePodcast(sessionContext, podcastsCollection, episodesCollection, podcastID, true)
+	})
+	if !errors.Is(err, errEpisodesFailed) {
+		return fmt.Errorf("transaction expected to fail with %v, got %v", errEpisodesFailed, err)
+	}
+	deps.Printf("Transaction aborted: %v\n", err)
+	if err = countPodcast(ctx, deps, podcastsCollection, episodesCollection, podcastID); err != nil {
+		return err
+	}
+
+	deps.Pause("Delete the podcast and its episodes")
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, deletePodcast(sessionContext, podcastsCollection, episodesCollection, podcastID, false)
+	})
+	if err != nil {
+		return fmt.Errorf("delete podcast %s with its episodes: %w", podcastID.Hex(), err)
+	}
+	deps.Println("Transaction committed.")
+	return countPodcast(ctx, deps, podcastsCollection, episodesCollection, podcastID)
+}
@@ -0,0 +1,101 @@
+package updating
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestUpdateMock checks the updates the functions send and how they read the
+// mocked replies, without a cluster
+func TestUpdateMock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name     string
+		update   func(context.Context, *mongo.Collection) (*mongo.UpdateResult, error)
+		response bson.D
+		upsert   bool
+		matched  int64
+		modified int64
+		upserted interface{}
+		err      bool
+	}{
+		{
+			name: "set author",
+			update: func(ctx context.Context, podcasts *mongo.Collection) (*mongo.UpdateResult, error) {
+				return SetAuthor(ctx, podcasts, id, "Nicolas Raboy")
+			},
+			response: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			matched:  1,
+			modified: 1,
+		},
+		{
+			name: "no such podcast",
+			update: func(ctx context.Context, podcasts *mongo.Collection) (*mongo.UpdateResult, error) {
+				return SetAuthor(ctx, podcasts, id, "Nicolas Raboy")
+			},
+			response: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+		},
+		{
+			name: "upsert inserts",
+			update: func(ctx context.Context, podcasts *mongo.Collection) (*mongo.UpdateResult, error) {
+				return UpsertPodcast(ctx, podcasts, "The Upsert Podcast", "Nic Raboy")
+			},
+			response: mtest.CreateSuccessResponse(
+				bson.E{Key: "n", Value: 1},
+				bson.E{Key: "nModified", Value: 0},
+				bson.E{Key: "upserted", Value: bson.A{bson.D{{"index", 0}, {"_id", id}}}},
+			),
+			upsert:   true,
+			upserted: id,
+		},
+		{
+			name: "upsert updates",
+			update: func(ctx context.Context, podcasts *mongo.Collection) (*mongo.UpdateResult, error) {
+				return UpsertPodcast(ctx, podcasts, "The Upsert Podcast", "Nic Raboy")
+			},
+			response: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			upsert:   true,
+			matched:  1,
+			modified: 1,
+		},
+		{
+			name: "validation failure",
+			update: func(ctx context.Context, podcasts *mongo.Collection) (*mongo.UpdateResult, error) {
+				return SetAuthor(ctx, podcasts, id, "")
+			},
+			response: mtest.CreateWriteErrorsResponse(mtest.WriteError{
+				Index: 0, Code: 121, Message: "Document failed validation",
+			}),
+			err: true,
+		},
+	}
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			mt.AddMockResponses(test.response)
+			result, err := test.update(mt.Context(), mt.DB.Collection("podcasts"))
+			if (err != nil) != test.err {
+				mt.Fatalf("error = %v, want error %v", err, test.err)
+			}
+			statement := mt.GetStartedEvent().Command.Lookup("updates", "0").Document()
+			if upsert, ok := statement.Lookup("upsert").BooleanOK(); upsert != test.upsert || (test.upsert && !ok) {
+				mt.Errorf("upsert = %v, want %v", upsert, test.upsert)
+			}
+			if _, err := statement.LookupErr("u", "$set", "author"); err != nil {
+				mt.Errorf("update %s does not $set the author", statement.Lookup("u"))
+			}
+			if test.err {
+				return
+			}
+			if result.MatchedCount != test.matched || result.ModifiedCount != test.modified || result.UpsertedID != test.upserted {
+				mt.Errorf("result = %+v, want %d matched, %d modified, upserted %v", result, test.matched, test.modified, test.upserted)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package examples
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Workshop makes examples interactive for live teaching: they pause before
+// each operation, show the documents it touches before and after, and ask
+// for the values in their filters and updates so the audience can try other
+// ones without editing code
+type Workshop struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// NewWorkshop reads answers from in and writes prompts to out
+func NewWorkshop(in io.Reader, out io.Writer) *Workshop {
+	return &Workshop{in: bufio.NewScanner(in), out: out}
+}
+
+// Ask prints question and returns the line typed in answer, without
+// surrounding spaces. It returns false when the input is closed.
+func (w *Workshop) Ask(question string) (string, bool) {
+	fmt.Fprint(w.out, question)
+	if !w.in.Scan() {
+		fmt.Fprintln(w.out)
+		return "", false
+	}
+	return strings.TrimSpace(w.in.Text()), true
+}
+
+// Pause announces the next operation and waits for Enter. Like String and
+// Int it does nothing on a nil Workshop, so examples can call it
+// unconditionally.
+func (w *Workshop) Pause(operation string) {
+	if w == nil {
+		return
+	}
+	w.Ask(fmt.Sprintf("\n-- Next: %s [Enter] ", operation))
+}
+
+// String asks for a value, offering value as the default
+func (w *Workshop) String(name, value string) string {
+	if w == nil {
+		return value
+	}
+	answer, _ := w.Ask(fmt.Sprintf("   %s [%s]: ", name, value))
+	if answer == "" {
+		return value
+	}
+	return answer
+}
+
+// Int is String for whole numbers; answers that are not numbers are asked
+// again
+func (w *Workshop) Int(name string, value int) int {
+	if w == nil {
+		return value
+	}
+	for {
+		answer, ok := w.Ask(fmt.Sprintf("   %s [%d]: ", name, value))
+		if !ok || answer == "" {
+			return value
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil {
+			return n
+		}
+		fmt.Fprintf(w.out, "   %q is not a whole number\n", answer)
+	}
+}
+
+// Pause calls Workshop.Pause
+func (d Deps) Pause(operation string) { d.Workshop.Pause(operation) }
+
+// String calls Workshop.String, returning value without a workshop
+func (d Deps) String(name, value string) string { return d.Workshop.String(name, value) }
+
+// Int calls Workshop.Int, returning value without a workshop
+func (d Deps) Int(name string, value int) int { return d.Workshop.Int(name, value) }
+
+// Show prints the documents in collection matching filter as Extended JSON,
+// at most ten of them, in workshop mode only. Failures are printed rather
+// than returned so they never stop the example.
+func (d Deps) Show(ctx context.Context, label string, collection *mongo.Collection, filter interface{}) {
+	if d.Workshop == nil {
+		return
+	}
+	const limit = 10
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		d.Printf("   %s: %v\n", label, err)
+		return
+	}
+	defer cursor.Close(ctx)
+	d.Printf("   %s:\n", label)
+	shown := 0
+	for ; cursor.Next(ctx); shown++ {
+		if shown == limit {
+			d.Printf("     ...\n")
+			return
+		}
+		document, err := bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			d.Printf("     (%v)\n", err)
+			continue
+		}
+		d.Printf("     %s\n", document)
+	}
+	if err = cursor.Err(); err != nil {
+		d.Printf("     (%v)\n", err)
+	} else if shown == 0 {
+		d.Printf("     (no documents)\n")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Variant is one arm of an experiment. Weights are relative.
+type Variant struct {
+	Name   string
+	Weight int
+}
+
+// Experiment splits users between variants
+type Experiment struct {
+	Name     string
+	Variants []Variant
+}
+
+// experiments are the running experiments; the first variant is the control
+var experiments = map[string]Experiment{
+	"player-redesign": {
+		Name:     "player-redesign",
+		Variants: []Variant{{"control", 50}, {"large-controls", 25}, {"waveform", 25}},
+	},
+}
+
+// Event represents the schema for the "experiment_events" collection. Each
+// user has at most one exposure and one conversion per experiment.
+type Event struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Experiment string             `bson:"experiment"`
+	Variant    string             `bson:"variant"`
+	User       primitive.ObjectID `bson:"user"`
+	Type       string             `bson:"type"`
+	At         time.Time          `bson:"at"`
+}
+
+// Result represents an aggregation result-set of one variant's counts, with
+// the rate and its 95% confidence interval computed in Go
+type Result struct {
+	Variant     string `bson:"_id"`
+	Exposures   int64  `bson:"exposures"`
+	Conversions int64  `bson:"conversions"`
+	Rate        float64
+	Low, High   float64
+}
+
+// bucket hashes the user into [0, total) for an experiment. Including the
+// experiment name keeps assignments of different experiments independent.
+func bucket(experiment string, user primitive.ObjectID, total int) int {
+	h := fnv.New64a()
+	h.Write([]byte(experiment))
+	h.Write(user[:])
+	return int(h.Sum64() % uint64(total))
+}
+
+// variantFor returns the variant the hash assigns user to
+func (e Experiment) variantFor(user primitive.ObjectID) string {
+	total := 0
+	for _, variant := range e.Variants {
+		total += variant.Weight
+	}
+	b := bucket(e.Name, user, total)
+	for _, variant := range e.Variants {
+		if b < variant.Weight {
+			return variant.Name
+		}
+		b -= variant.Weight
+	}
+	return e.Variants[0].Name
+}
+
+// Experiments records assignments on users and events in their own collection
+type Experiments struct {
+	Users  *mongo.Collection
+	Events *mongo.Collection
+}
+
+// EnsureIndexes makes events unique per experiment, user and type, so
+// recording the same exposure or conversion twice counts it once
+func (x *Experiments) EnsureIndexes(ctx context.Context) error {
+	_, err := x.Events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"experiment", 1}, {"user", 1}, {"type", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Assign returns the user's variant, storing it in experiments.<name> on the
+// user the first time. The stored value wins over the hash afterwards, so
+// changing the weights never moves a user who was already assigned.
+func (x *Experiments) Assign(ctx context.Context, experiment Experiment, user primitive.ObjectID) (string, error) {
+	field := "experiments." + experiment.Name
+	_, err := x.Users.UpdateOne(ctx,
+		bson.D{{"_id", user}, {field, bson.D{{"$exists", false}}}},
+		bson.D{{"$set", bson.D{{field, experiment.variantFor(user)}}}},
+	)
+	if err != nil {
+		return "", err
+	}
+	var assigned struct {
+		Experiments map[string]string `bson:"experiments"`
+	}
+	opts := options.FindOne().SetProjection(bson.D{{field, 1}})
+	err = x.Users.FindOne(ctx, bson.D{{"_id", user}}, opts).Decode(&assigned)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("user %s does not exist", user.Hex())
+	}
+	return assigned.Experiments[experiment.Name], err
+}
+
+// Record stores an "exposure" or "conversion" event for the user's variant
+func (x *Experiments) Record(ctx context.Context, experiment Experiment, user primitive.ObjectID, eventType string) error {
+	variant, err := x.Assign(ctx, experiment, user)
+	if err != nil {
+		return err
+	}
+	_, err = x.Events.UpdateOne(ctx,
+		bson.D{{"experiment", experiment.Name}, {"user", user}, {"type", eventType}},
+		bson.D{{"$setOnInsert", bson.D{{"variant", variant}, {"at", time.Now().UTC()}}}},
+		options.Update().SetUpsert(true),
+	)
+	// two concurrent upserts of the same event: the other one stored it
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// resultsPipeline counts exposed users and the exposed users who converted,
+// per variant; a conversion without an exposure is not counted
+func resultsPipeline(experiment string) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"experiment", experiment}}}}
+	userStage := bson.D{{"$group", bson.D{
+		{"_id", "$user"},
+		{"variant", bson.D{{"$first", "$variant"}}},
+		{"exposed", bson.D{{"$max", bson.D{{"$eq", bson.A{"$type", "exposure"}}}}}},
+		{"converted", bson.D{{"$max", bson.D{{"$eq", bson.A{"$type", "conversion"}}}}}},
+	}}}
+	variantStage := bson.D{{"$group", bson.D{
+		{"_id", "$variant"},
+		{"exposures", bson.D{{"$sum", bson.D{{"$cond", bson.A{"$exposed", 1, 0}}}}}},
+		{"conversions", bson.D{{"$sum", bson.D{{"$cond", bson.A{bson.D{{"$and", bson.A{"$exposed", "$converted"}}}, 1, 0}}}}}},
+	}}}
+	return mongo.Pipeline{matchStage, userStage, variantStage}
+}
+
+// wilson returns the 95% Wilson score interval of a proportion, which stays
+// sensible for small samples and rates near 0 or 1
+func wilson(successes, trials int64) (low, high float64) {
+	if trials == 0 {
+		return 0, 0
+	}
+	const z = 1.96
+	n := float64(trials)
+	p := float64(successes) / n
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return center - margin, center + margin
+}
+
+// Results aggregates the experiment and computes each variant's rate and
+// interval, in the order the variants are declared
+func (x *Experiments) Results(ctx context.Context, experiment Experiment) ([]Result, error) {
+	cursor, err := x.Events.Aggregate(ctx, resultsPipeline(experiment.Name))
+	if err != nil {
+		return nil, err
+	}
+	var counted []Result
+	if err = cursor.All(ctx, &counted); err != nil {
+		return nil, err
+	}
+	byVariant := map[string]Result{}
+	for _, result := range counted {
+		byVariant[result.Variant] = result
+	}
+	results := make([]Result, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		result := byVariant[variant.Name]
+		result.Variant = variant.Name
+		if result.Exposures > 0 {
+			result.Rate = float64(result.Conversions) / float64(result.Exposures)
+		}
+		result.Low, result.High = wilson(result.Conversions, result.Exposures)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// simulate creates users who are exposed to the experiment and convert with
+// a slightly different probability per variant
+func (x *Experiments) simulate(ctx context.Context, experiment Experiment, users int) error {
+	rates := map[string]float64{}
+	for i, variant := range experiment.Variants {
+		rates[variant.Name] = 0.10 + 0.02*float64(i)
+	}
+	for i := 0; i < users; i++ {
+		user := primitive.NewObjectID()
+		if _, err := x.Users.InsertOne(ctx, bson.D{{"_id", user}, {"name", fmt.Sprintf("simulated-%d", i)}}); err != nil {
+			return err
+		}
+		if err := x.Record(ctx, experiment, user, "exposure"); err != nil {
+			return err
+		}
+		if rand.Float64() < rates[experiment.variantFor(user)] {
+			if err := x.Record(ctx, experiment, user, "conversion"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	name    = flag.String("experiment", "player-redesign", "experiment name")
+	userHex = flag.String("user", "", "user id for assign, expose and convert")
+	users   = flag.Int("users", 1000, "number of users created by simulate in simulated_users")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] assign|expose|convert|results|simulate\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	experiment, ok := experiments[*name]
+	if !ok {
+		return fmt.Errorf("%w: unknown experiment %q", shutdown.ErrUsage, *name)
+	}
+	var user primitive.ObjectID
+	switch flag.Arg(0) {
+	case "assign", "expose", "convert":
+		var err error
+		if user, err = primitive.ObjectIDFromHex(*userHex); err != nil {
+			return fmt.Errorf("%w: %s requires -user with an ObjectID", shutdown.ErrUsage, flag.Arg(0))
+		}
+	case "results", "simulate":
+	default:
+		return shutdown.ErrUsage
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	x := &Experiments{Users: database.Collection("users"), Events: database.Collection("experiment_events")}
+	if flag.Arg(0) == "simulate" {
+		// fake users go in their own collection, never among the real ones
+		x.Users = database.Collection("simulated_users")
+	}
+	if err = x.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	switch flag.Arg(0) {
+	case "assign":
+		variant, err := x.Assign(ctx, experiment, user)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("User %s is in variant %s of %s\n", user.Hex(), variant, experiment.Name)
+	case "expose":
+		err = x.Record(ctx, experiment, user, "exposure")
+	case "convert":
+		err = x.Record(ctx, experiment, user, "conversion")
+	case "simulate":
+		err = x.simulate(ctx, experiment, *users)
+	case "results":
+		results, err := x.Results(ctx, experiment)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VARIANT\tEXPOSURES\tCONVERSIONS\tRATE\t95% CI\tLIFT")
+		control := results[0].Rate
+		for _, result := range results {
+			lift := "-"
+			if result.Variant != results[0].Variant && control > 0 {
+				lift = fmt.Sprintf("%+.1f%%", (result.Rate/control-1)*100)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%.2f%% - %.2f%%\t%s\n", result.Variant, result.Exposures,
+				result.Conversions, result.Rate*100, result.Low*100, result.High*100, lift)
+		}
+		w.Flush()
+		fmt.Println("Intervals that do not overlap the control's suggest a real difference.")
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/scan"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionManifest describes one exported collection file
+type CollectionManifest struct {
+	File      string `json:"file"`
+	Documents int64  `json:"documents"`
+}
+
+// Manifest is written next to the exported files as manifest.json
+type Manifest struct {
+	Database      string                        `json:"database"`
+	CreatedAt     time.Time                     `json:"created_at"`
+	Mode          string                        `json:"mode"`
+	AtClusterTime *primitive.Timestamp          `json:"at_cluster_time,omitempty"`
+	Collections   map[string]CollectionManifest `json:"collections"`
+}
+
+// jsonLinesWriter writes one canonical Extended JSON document per line
+type jsonLinesWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	buffer *bufio.Writer
+	count  int64
+}
+
+func newJSONLinesWriter(path string) (*jsonLinesWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLinesWriter{file: file, buffer: bufio.NewWriter(file)}, nil
+}
+
+func (w *jsonLinesWriter) Write(document bson.Raw) error {
+	line, err := bson.MarshalExtJSON(document, true, false)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.count++
+	if _, err = w.buffer.Write(line); err != nil {
+		return err
+	}
+	return w.buffer.WriteByte('\n')
+}
+
+func (w *jsonLinesWriter) Close() error {
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// exportSnapshot reads every collection inside one session with snapshot read
+// concern, so all files reflect the database at the same cluster time even
+// while writes continue. Snapshot reads must finish within the server's
+// minSnapshotHistoryWindowInSeconds (5 minutes by default).
+func exportSnapshot(ctx context.Context, client *mongo.Client, database *mongo.Database, collections []string, dir string, manifest *Manifest) error {
+	session, err := client.StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	sessionContext := mongo.NewSessionContext(ctx, session)
+
+	for _, name := range collections {
+		count, err := exportCursor(sessionContext, database.Collection(name), dir, manifest)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", name, err)
+		}
+		fmt.Printf("Exported %v documents from %s\n", count, name)
+		// The first read of a snapshot session fixes atClusterTime for the
+		// rest of the session; the driver only exposes it on the low-level session.
+		if manifest.AtClusterTime == nil {
+			if xsession, ok := session.(mongo.XSession); ok {
+				manifest.AtClusterTime = xsession.ClientSession().SnapshotTime
+			}
+		}
+	}
+	return nil
+}
+
+func exportCursor(ctx context.Context, collection *mongo.Collection, dir string, manifest *Manifest) (int64, error) {
+	file := collection.Name() + ".jsonl"
+	writer, err := newJSONLinesWriter(filepath.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	cursor, err := collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		writer.Close()
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		if err = writer.Write(cursor.Current); err != nil {
+			writer.Close()
+			return writer.count, err
+		}
+	}
+	if err = errors.Join(cursor.Err(), writer.Close()); err != nil {
+		return writer.count, err
+	}
+	manifest.Collections[collection.Name()] = CollectionManifest{File: file, Documents: writer.count}
+	return writer.count, nil
+}
+
+// exportParallel scans each collection with concurrent _id range cursors.
+// It is faster but the collections are read at slightly different times.
+func exportParallel(ctx context.Context, database *mongo.Database, collections []string, dir string, workers int, manifest *Manifest) error {
+	for _, name := range collections {
+		file := name + ".jsonl"
+		writer, err := newJSONLinesWriter(filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		_, err = scan.Collection(ctx, database.Collection(name), scan.Options{Workers: workers}, func(ctx context.Context, document bson.Raw) error {
+			return writer.Write(document)
+		})
+		if err = errors.Join(err, writer.Close()); err != nil {
+			return fmt.Errorf("exporting %s: %w", name, err)
+		}
+		manifest.Collections[name] = CollectionManifest{File: file, Documents: writer.count}
+		fmt.Printf("Exported %v documents from %s\n", writer.count, name)
+	}
+	return nil
+}
+
+var (
+	databaseName    = flag.String("db", "quickstart", "database to export")
+	collectionNames = flag.String("collections", "podcasts,episodes", "comma separated collections to export")
+	dir             = flag.String("out", "export-"+time.Now().UTC().Format("20060102T150405Z"), "output directory")
+	snapshot        = flag.Bool("snapshot", false, "read all collections at a single cluster time (requires a replica set)")
+	workers         = flag.Int("workers", 4, "concurrent range cursors per collection when not using -snapshot")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	if err = os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	database := client.Database(*databaseName)
+	collections := strings.Split(*collectionNames, ",")
+	manifest := &Manifest{
+		Database:    database.Name(),
+		CreatedAt:   time.Now().UTC(),
+		Mode:        "parallel",
+		Collections: map[string]CollectionManifest{},
+	}
+
+	if *snapshot {
+		manifest.Mode = "snapshot"
+		err = exportSnapshot(ctx, client, database, collections, *dir, manifest)
+	} else {
+		err = exportParallel(ctx, database, collections, *dir, *workers, manifest)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(*dir, "manifest.json"), data, 0o644); err != nil {
+		return err
+	}
+	if manifest.AtClusterTime != nil {
+		fmt.Printf("Snapshot taken at cluster time %d.%d\n", manifest.AtClusterTime.T, manifest.AtClusterTime.I)
+	}
+	fmt.Printf("Export written to %s\n", *dir)
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Journal operations
+const (
+	OpUpsert = "upsert"
+	OpDelete = "delete"
+)
+
+// JournalEntry represents the schema for the "failover_journal" collection on
+// the DR cluster. Each entry is one write accepted while the primary cluster
+// was unreachable and not yet replayed onto it.
+type JournalEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Op         string             `bson:"op"`
+	Collection string             `bson:"collection"`
+	DocumentID interface{}        `bson:"document_id"`
+	At         time.Time          `bson:"at"`
+}
+
+// Client sends reads and writes to the primary cluster and falls back to the
+// DR cluster when the primary cannot be selected. Writes made during an
+// outage are journaled on the DR cluster and replayed onto the primary, DR
+// copy winning, once it is reachable again. Outside of outages the DR cluster
+// is expected to be kept in sync by cluster-to-cluster replication (mongosync).
+type Client struct {
+	Primary  *mongo.Client
+	DR       *mongo.Client
+	Database string
+
+	degraded  atomic.Bool
+	reconcile sync.Mutex
+}
+
+// Degraded reports whether requests are currently served by the DR cluster
+func (c *Client) Degraded() bool {
+	return c.degraded.Load()
+}
+
+func (c *Client) primary(collection string) *mongo.Collection {
+	return c.Primary.Database(c.Database).Collection(collection)
+}
+
+func (c *Client) dr(collection string) *mongo.Collection {
+	return c.DR.Database(c.Database).Collection(collection)
+}
+
+func (c *Client) journal() *mongo.Collection {
+	return c.dr("failover_journal")
+}
+
+// unavailable reports whether err means the primary cluster could not be
+// reached at all, as opposed to a query or write error
+func unavailable(err error) bool {
+	return errors.As(err, &topology.ServerSelectionError{}) ||
+		errors.Is(err, topology.ErrServerSelectionTimeout) ||
+		mongo.IsNetworkError(err)
+}
+
+func (c *Client) failOver(err error) {
+	if c.degraded.CompareAndSwap(false, true) {
+		log.Printf("primary cluster unavailable, serving from DR: %v", err)
+	}
+}
+
+// FindOne reads from the primary, or from the DR cluster while it is down
+func (c *Client) FindOne(ctx context.Context, collection string, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	if !c.Degraded() {
+		result := c.primary(collection).FindOne(ctx, filter, opts...)
+		if !unavailable(result.Err()) {
+			return result
+		}
+		c.failOver(result.Err())
+	}
+	return c.dr(collection).FindOne(ctx, filter, opts...)
+}
+
+// Find reads from the primary, or from the DR cluster while it is down
+func (c *Client) Find(ctx context.Context, collection string, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	if !c.Degraded() {
+		cursor, err := c.primary(collection).Find(ctx, filter, opts...)
+		if !unavailable(err) {
+			return cursor, err
+		}
+		c.failOver(err)
+	}
+	return c.dr(collection).Find(ctx, filter, opts...)
+}
+
+// Upsert replaces the document with the given _id, inserting it if missing
+func (c *Client) Upsert(ctx context.Context, collection string, id interface{}, document interface{}) error {
+	write := func(coll *mongo.Collection) error {
+		_, err := coll.ReplaceOne(ctx, bson.D{{"_id", id}}, document, options.Replace().SetUpsert(true))
+		return err
+	}
+	return c.write(ctx, collection, OpUpsert, id, write)
+}
+
+// Delete removes the document with the given _id
+func (c *Client) Delete(ctx context.Context, collection string, id interface{}) error {
+	write := func(coll *mongo.Collection) error {
+		_, err := coll.DeleteOne(ctx, bson.D{{"_id", id}})
+		return err
+	}
+	return c.write(ctx, collection, OpDelete, id, write)
+}
+
+func (c *Client) write(ctx context.Context, collection, op string, id interface{}, write func(*mongo.Collection) error) error {
+	if !c.Degraded() {
+		err := write(c.primary(collection))
+		if !unavailable(err) {
+			return err
+		}
+		c.failOver(err)
+	}
+	if err := write(c.dr(collection)); err != nil {
+		return err
+	}
+	_, err := c.journal().InsertOne(ctx, JournalEntry{
+		Op:         op,
+		Collection: collection,
+		DocumentID: id,
+		At:         time.Now().UTC(),
+	})
+	return err
+}
+
+// Run pings the primary while degraded and reconciles once it answers again
+func (c *Client) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !c.Degraded() {
+			continue
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := c.Primary.Ping(pingCtx, nil)
+		cancel()
+		if err != nil {
+			continue
+		}
+		if err = c.Reconcile(ctx); err != nil {
+			log.Printf("reconcile: %v", err)
+		}
+	}
+}
+
+// Reconcile replays journaled writes onto the primary and switches traffic
+// back to it. The journal is drained once more after the switch to pick up
+// writes that raced with it.
+func (c *Client) Reconcile(ctx context.Context) error {
+	c.reconcile.Lock()
+	defer c.reconcile.Unlock()
+	replayed, err := c.replay(ctx)
+	if err != nil {
+		return err
+	}
+	c.degraded.Store(false)
+	more, err := c.replay(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("primary cluster back, replayed %d writes from DR", replayed+more)
+	return nil
+}
+
+// replay applies journal entries in order. The current DR version of each
+// document is copied, so several writes to one document converge on its
+// latest state. Entries are removed only after being applied.
+func (c *Client) replay(ctx context.Context) (int, error) {
+	cursor, err := c.journal().Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	replayed := 0
+	for cursor.Next(ctx) {
+		var entry JournalEntry
+		if err = cursor.Decode(&entry); err != nil {
+			return replayed, err
+		}
+		filter := bson.D{{"_id", entry.DocumentID}}
+		var document bson.Raw
+		err = c.dr(entry.Collection).FindOne(ctx, filter).Decode(&document)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			_, err = c.primary(entry.Collection).DeleteOne(ctx, filter)
+		case err == nil:
+			_, err = c.primary(entry.Collection).ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("replaying %s %v in %s: %w", entry.Op, entry.DocumentID, entry.Collection, err)
+		}
+		if _, err = c.journal().DeleteOne(ctx, bson.D{{"_id", entry.ID}}); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, cursor.Err()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Podcast represents the schema for the "podcasts" collection
+type Podcast struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Title     string             `bson:"title,omitempty"`
+	Author    string             `bson:"author,omitempty"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+func connect(ctx context.Context, uri string, selectionTimeout time.Duration) (*mongo.Client, error) {
+	if err := db.Validate(uri); err != nil {
+		return nil, err
+	}
+	// A short server selection timeout makes an unreachable cluster fail fast
+	// instead of blocking every request for the default 30 seconds. Unlike
+	// db.Connect this does not ping, so the demo can start while the primary
+	// cluster is down.
+	return mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(selectionTimeout))
+}
+
+var (
+	interval         = flag.Duration("interval", 2*time.Second, "time between demo operations and primary health checks")
+	selectionTimeout = flag.Duration("selection-timeout", 2*time.Second, "server selection timeout before failing over")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+
+	primary, err := connect(ctx, db.URI(), *selectionTimeout)
+	if err != nil {
+		return err
+	}
+	down.Client(primary)
+	dr, err := connect(ctx, os.Getenv("ATLAS_URI_DR"), *selectionTimeout)
+	if err != nil {
+		return err
+	}
+	down.Client(dr)
+
+	client := &Client{Primary: primary, DR: dr, Database: "quickstart"}
+	go client.Run(ctx, *interval)
+
+	// Pause or block network access to the primary cluster while this runs:
+	// reads and writes continue against DR and are reconciled afterwards
+	id := primitive.NewObjectID()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for i := 1; ; i++ {
+		opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		podcast := Podcast{ID: id, Title: fmt.Sprintf("Failover Podcast, revision %d", i), Author: "Nic Raboy", UpdatedAt: time.Now().UTC()}
+		if err := client.Upsert(opCtx, "podcasts", id, podcast); err != nil {
+			log.Printf("write: %v", err)
+		}
+		var read Podcast
+		err := client.FindOne(opCtx, "podcasts", bson.D{{"_id", id}}).Decode(&read)
+		cancel()
+		source := "primary"
+		if client.Degraded() {
+			source = "DR"
+		}
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			fmt.Printf("[%s] podcast not found\n", source)
+		case err != nil:
+			log.Printf("read: %v", err)
+		default:
+			fmt.Printf("[%s] %s\n", source, read.Title)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package filter builds query filters from small functions instead of nested
+// bson.M literals, so operators end up where the server expects them:
+//
+//	filter.And(
+//		filter.InAny([]string{"title", "description"}, "go", "mongodb"),
+//		filter.Gte("duration", 20),
+//		filter.Lt("duration", 60),
+//	)
+//
+// becomes
+//
+//	{$or: [{title: {$in: ["go", "mongodb"]}}, {description: {$in: ["go", "mongodb"]}}],
+//	 duration: {$gte: 20, $lt: 60}}
+//
+// Every function returns a bson.D, which can be passed to Find or nested in
+// a $match stage as it is.
+package filter
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Eq matches documents whose field equals value. It uses $eq, so a value
+// that happens to be a document of operators, for instance from decoded user
+// input, is compared rather than run.
+func Eq(field string, value interface{}) bson.D {
+	return op(field, "$eq", value)
+}
+
+// Ne matches documents whose field does not equal value, including those
+// without the field
+func Ne(field string, value interface{}) bson.D {
+	return op(field, "$ne", value)
+}
+
+// Gt matches documents whose field is greater than value
+func Gt(field string, value interface{}) bson.D {
+	return op(field, "$gt", value)
+}
+
+// Gte matches documents whose field is greater than or equal to value
+func Gte(field string, value interface{}) bson.D {
+	return op(field, "$gte", value)
+}
+
+// Lt matches documents whose field is less than value
+func Lt(field string, value interface{}) bson.D {
+	return op(field, "$lt", value)
+}
+
+// Lte matches documents whose field is less than or equal to value
+func Lte(field string, value interface{}) bson.D {
+	return op(field, "$lte", value)
+}
+
+// In matches documents whose field equals any of values or, for an array
+// field, has an element that does
+func In[T any](field string, values ...T) bson.D {
+	return op(field, "$in", array(values))
+}
+
+// Nin matches documents whose field equals none of values
+func Nin[T any](field string, values ...T) bson.D {
+	return op(field, "$nin", array(values))
+}
+
+// All matches documents whose array field contains every one of values
+func All[T any](field string, values ...T) bson.D {
+	return op(field, "$all", array(values))
+}
+
+// InAny matches documents where any of fields equals any of values. $in
+// takes one field, so this is an $or of one $in per field.
+func InAny[T any](fields []string, values ...T) bson.D {
+	conditions := make([]bson.D, 0, len(fields))
+	for _, field := range fields {
+		conditions = append(conditions, In(field, values...))
+	}
+	return Or(conditions...)
+}
+
+// Exists matches documents that have field, or that lack it when exists is
+// false. A field set to null exists.
+func Exists(field string, exists bool) bson.D {
+	return op(field, "$exists", exists)
+}
+
+// Regex matches documents whose string field matches pattern, with options
+// such as "i" for case insensitive matching. Only a case sensitive pattern
+// anchored with ^ can use an index efficiently.
+func Regex(field, pattern, options string) bson.D {
+	return op(field, "$regex", primitive.Regex{Pattern: pattern, Options: options})
+}
+
+// ElemMatch matches documents whose array field has one element meeting all
+// of conditions, rather than each condition being met by any element
+func ElemMatch(field string, conditions ...bson.D) bson.D {
+	return op(field, "$elemMatch", And(conditions...))
+}
+
+// And matches documents meeting every condition. Conditions on different
+// fields, and different operators on the same field, are merged into one
+// document; only conflicting ones fall back to $and.
+func And(conditions ...bson.D) bson.D {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	merged := bson.D{}
+	for _, condition := range conditions {
+		for _, element := range condition {
+			var ok bool
+			if merged, ok = merge(merged, element); !ok {
+				return bson.D{{"$and", array(conditions)}}
+			}
+		}
+	}
+	return merged
+}
+
+// Or matches documents meeting at least one condition
+func Or(conditions ...bson.D) bson.D {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return bson.D{{"$or", array(conditions)}}
+}
+
+// Nor matches documents meeting none of conditions
+func Nor(conditions ...bson.D) bson.D {
+	return bson.D{{"$nor", array(conditions)}}
+}
+
+func op(field, operator string, value interface{}) bson.D {
+	return bson.D{{field, bson.D{{operator, value}}}}
+}
+
+func array[T any](values []T) bson.A {
+	a := make(bson.A, 0, len(values))
+	for _, value := range values {
+		a = append(a, value)
+	}
+	return a
+}
+
+// merge adds element to document, combining the operators of a field that
+// is already there. It reports false when the two cannot be combined.
+func merge(document bson.D, element bson.E) (bson.D, bool) {
+	for i, existing := range document {
+		if existing.Key != element.Key {
+			continue
+		}
+		have, ok := operators(existing.Value)
+		if !ok {
+			return nil, false
+		}
+		add, ok := operators(element.Value)
+		if !ok {
+			return nil, false
+		}
+		for _, operator := range add {
+			for _, present := range have {
+				if present.Key == operator.Key {
+					return nil, false
+				}
+			}
+		}
+		document[i].Value = append(append(bson.D{}, have...), add...)
+		return document, true
+	}
+	return append(document, element), true
+}
+
+// operators returns value when it is a document of query operators
+func operators(value interface{}) (bson.D, bool) {
+	document, ok := value.(bson.D)
+	if !ok || len(document) == 0 {
+		return nil, false
+	}
+	for _, element := range document {
+		if !strings.HasPrefix(element.Key, "$") {
+			return nil, false
+		}
+	}
+	return document, true
+}
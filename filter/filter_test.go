@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []bson.D
+		want       bson.D
+	}{
+		{"nothing", nil, bson.D{}},
+		{"one condition as it is", []bson.D{Eq("title", "Go")}, bson.D{{"title", bson.D{{"$eq", "Go"}}}}},
+		{"different fields", []bson.D{Eq("title", "Go"), Gt("duration", 20)},
+			bson.D{{"title", bson.D{{"$eq", "Go"}}}, {"duration", bson.D{{"$gt", 20}}}}},
+		{"operators on one field merge", []bson.D{Gte("duration", 20), Lt("duration", 60)},
+			bson.D{{"duration", bson.D{{"$gte", 20}, {"$lt", 60}}}}},
+		{"merged in order across conditions", []bson.D{Gte("duration", 20), Eq("title", "Go"), Lt("duration", 60), Ne("title", "Java")},
+			bson.D{{"duration", bson.D{{"$gte", 20}, {"$lt", 60}}}, {"title", bson.D{{"$eq", "Go"}, {"$ne", "Java"}}}}},
+		{"same operator twice", []bson.D{Gt("duration", 20), Gt("duration", 30)},
+			bson.D{{"$and", bson.A{bson.D{{"duration", bson.D{{"$gt", 20}}}}, bson.D{{"duration", bson.D{{"$gt", 30}}}}}}}},
+		{"plain value and operator", []bson.D{{{"duration", 25}}, Gt("duration", 20)},
+			bson.D{{"$and", bson.A{bson.D{{"duration", 25}}, bson.D{{"duration", bson.D{{"$gt", 20}}}}}}}},
+		{"duplicate plain key", []bson.D{{{"tags", "go"}}, {{"tags", "mongodb"}}},
+			bson.D{{"$and", bson.A{bson.D{{"tags", "go"}}, bson.D{{"tags", "mongodb"}}}}}},
+		{"embedded document is not operators", []bson.D{{{"profile", bson.D{{"city", "Berlin"}}}}, Exists("profile", true)},
+			bson.D{{"$and", bson.A{bson.D{{"profile", bson.D{{"city", "Berlin"}}}}, bson.D{{"profile", bson.D{{"$exists", true}}}}}}}},
+		{"two $or", []bson.D{Or(Eq("a", 1), Eq("b", 1)), Or(Eq("c", 1), Eq("d", 1))},
+			bson.D{{"$and", bson.A{Or(Eq("a", 1), Eq("b", 1)), Or(Eq("c", 1), Eq("d", 1))}}}},
+		{"$or next to a field", []bson.D{Or(Eq("a", 1), Eq("b", 1)), Gt("duration", 20)},
+			bson.D{{"$or", bson.A{Eq("a", 1), Eq("b", 1)}}, {"duration", bson.D{{"$gt", 20}}}}},
+		{"nested And merges", []bson.D{And(Gte("duration", 20), Eq("title", "Go")), Lt("duration", 60)},
+			bson.D{{"duration", bson.D{{"$gte", 20}, {"$lt", 60}}}, {"title", bson.D{{"$eq", "Go"}}}}},
+		{"nested $and kept beside other fields", []bson.D{And(Gt("duration", 20), Gt("duration", 30)), Eq("title", "Go")},
+			bson.D{
+				{"$and", bson.A{bson.D{{"duration", bson.D{{"$gt", 20}}}}, bson.D{{"duration", bson.D{{"$gt", 30}}}}}},
+				{"title", bson.D{{"$eq", "Go"}}},
+			}},
+		{"two nested $and", []bson.D{And(Gt("a", 1), Gt("a", 2)), And(Gt("b", 1), Gt("b", 2))},
+			bson.D{{"$and", bson.A{And(Gt("a", 1), Gt("a", 2)), And(Gt("b", 1), Gt("b", 2))}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := And(test.conditions...); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("And =\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAndLeavesConditionsAlone(t *testing.T) {
+	first, second := Gte("duration", 20), Lt("duration", 60)
+	And(first, second)
+	And(first, Eq("title", "Go"))
+	if want := Gte("duration", 20); !reflect.DeepEqual(first, want) {
+		t.Errorf("first condition = %v after And, want %v", first, want)
+	}
+	if want := Lt("duration", 60); !reflect.DeepEqual(second, want) {
+		t.Errorf("second condition = %v after And, want %v", second, want)
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	tests := []struct {
+		name string
+		got  bson.D
+		want bson.D
+	}{
+		{"In", In("tags", "go", "mongodb"), bson.D{{"tags", bson.D{{"$in", bson.A{"go", "mongodb"}}}}}},
+		{"Nin", Nin("duration", 25, 30), bson.D{{"duration", bson.D{{"$nin", bson.A{25, 30}}}}}},
+		{"All", All("tags", "go"), bson.D{{"tags", bson.D{{"$all", bson.A{"go"}}}}}},
+		{"InAny", InAny([]string{"title", "description"}, "go"),
+			bson.D{{"$or", bson.A{bson.D{{"title", bson.D{{"$in", bson.A{"go"}}}}}, bson.D{{"description", bson.D{{"$in", bson.A{"go"}}}}}}}}},
+		{"InAny on one field", InAny([]string{"title"}, "go"), bson.D{{"title", bson.D{{"$in", bson.A{"go"}}}}}},
+		{"Or of one", Or(Eq("a", 1)), bson.D{{"a", bson.D{{"$eq", 1}}}}},
+		{"Nor", Nor(Eq("a", 1)), bson.D{{"$nor", bson.A{bson.D{{"a", bson.D{{"$eq", 1}}}}}}}},
+		{"ElemMatch", ElemMatch("devices", Eq("os", "ios"), Gte("version", 17)),
+			bson.D{{"devices", bson.D{{"$elemMatch", bson.D{{"os", bson.D{{"$eq", "ios"}}}, {"version", bson.D{{"$gte", 17}}}}}}}}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
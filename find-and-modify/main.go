@@ -0,0 +1,12 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/findandmodify"
+)
+
+func main() {
+	examples.Main(findandmodify.Run, 10*time.Second)
+}
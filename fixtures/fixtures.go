@@ -0,0 +1,253 @@
+// Package fixtures loads declarative test data into collections and removes
+// it again afterwards. A fixture file maps collection names to documents and
+// may be written in YAML or JSON:
+//
+//	podcasts:
+//	  - _id: '{{id "gopher"}}'
+//	    title: The Go Gopher Podcast
+//	    created_at: '{{ago "72h"}}'
+//	episodes:
+//	  - podcast: '{{id "gopher"}}'
+//	    title: Episode 1
+//	    published_at: '{{ago "2d"}}'
+//
+// Placeholders are replaced by typed values when they make up the whole
+// string, and by their text form when embedded in a longer string:
+//
+//	{{id "name"}}      an ObjectID, the same one for every use of name in a Set
+//	{{now}}            the time the Set was created
+//	{{ago "1h30m"}}    now minus a duration, which may also be given in days ("3d")
+//	{{fromNow "1h"}}   now plus a duration
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/yaml.v3"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)(?:\s+"([^"]*)")?\s*\}\}`)
+
+// Set is the data loaded from one or more fixture files
+type Set struct {
+	Database *mongo.Database
+	Now      time.Time
+
+	mu       sync.Mutex
+	ids      map[string]primitive.ObjectID
+	inserted map[string][]interface{}
+}
+
+// New returns an empty Set writing to database
+func New(database *mongo.Database) *Set {
+	return &Set{
+		Database: database,
+		Now:      time.Now().UTC().Truncate(time.Millisecond),
+		ids:      map[string]primitive.ObjectID{},
+		inserted: map[string][]interface{}{},
+	}
+}
+
+// Load reads the fixture files and inserts their documents
+func Load(ctx context.Context, database *mongo.Database, paths ...string) (*Set, error) {
+	set := New(database)
+	for _, path := range paths {
+		if err := set.LoadFile(ctx, path); err != nil {
+			return set, errors.Join(err, set.Cleanup(ctx))
+		}
+	}
+	return set, nil
+}
+
+// LoadT loads the fixture files for a test, failing it on error and removing
+// the documents when the test finishes
+func LoadT(t testing.TB, database *mongo.Database, paths ...string) *Set {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	set, err := Load(ctx, database, paths...)
+	if err != nil {
+		t.Fatalf("loading fixtures: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := set.Cleanup(ctx); err != nil {
+			t.Errorf("cleaning up fixtures: %v", err)
+		}
+	})
+	return set
+}
+
+// ID returns the ObjectID bound to name, creating it on first use
+func (s *Set) ID(name string) primitive.ObjectID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.ids[name]
+	if !ok {
+		id = primitive.NewObjectID()
+		s.ids[name] = id
+	}
+	return id
+}
+
+// LoadFile reads one fixture file and inserts its documents. Documents
+// without an _id get a new ObjectID so they can be removed by Cleanup.
+func (s *Set) LoadFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// JSON is valid YAML, so one decoder handles both formats
+	var collections map[string][]map[string]interface{}
+	if err = yaml.Unmarshal(data, &collections); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		documents := make([]interface{}, 0, len(collections[name]))
+		for i, raw := range collections[name] {
+			value, err := s.Expand(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %s[%d]: %w", path, name, i, err)
+			}
+			document := value.(bson.M)
+			if _, ok := document["_id"]; !ok {
+				document["_id"] = primitive.NewObjectID()
+			}
+			documents = append(documents, document)
+		}
+		if len(documents) == 0 {
+			continue
+		}
+		if _, err = s.Database.Collection(name).InsertMany(ctx, documents); err != nil {
+			return fmt.Errorf("%s: inserting into %s: %w", path, name, err)
+		}
+		s.mu.Lock()
+		for _, document := range documents {
+			s.inserted[name] = append(s.inserted[name], document.(bson.M)["_id"])
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Cleanup deletes every document the Set inserted
+func (s *Set) Cleanup(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for name, ids := range s.inserted {
+		if _, err := s.Database.Collection(name).DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}}); err != nil {
+			errs = append(errs, fmt.Errorf("cleaning %s: %w", name, err))
+			continue
+		}
+		delete(s.inserted, name)
+	}
+	return errors.Join(errs...)
+}
+
+// Expand converts a value decoded from YAML or JSON into BSON friendly values,
+// resolving placeholders the same way LoadFile does
+func (s *Set) Expand(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		document := bson.M{}
+		for key, field := range v {
+			expanded, err := s.Expand(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			document[key] = expanded
+		}
+		return document, nil
+	case []interface{}:
+		array := make(bson.A, len(v))
+		for i, item := range v {
+			expanded, err := s.Expand(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			array[i] = expanded
+		}
+		return array, nil
+	case string:
+		return s.expandString(v)
+	}
+	return value, nil
+}
+
+func (s *Set) expandString(text string) (interface{}, error) {
+	if match := placeholder.FindStringSubmatch(text); match != nil && match[0] == text {
+		return s.resolve(match[1], match[2])
+	}
+	var err error
+	expanded := placeholder.ReplaceAllStringFunc(text, func(action string) string {
+		match := placeholder.FindStringSubmatch(action)
+		value, resolveErr := s.resolve(match[1], match[2])
+		if resolveErr != nil {
+			err = resolveErr
+			return action
+		}
+		switch v := value.(type) {
+		case primitive.ObjectID:
+			return v.Hex()
+		case time.Time:
+			return v.Format(time.RFC3339)
+		}
+		return fmt.Sprint(value)
+	})
+	return expanded, err
+}
+
+func (s *Set) resolve(function, argument string) (interface{}, error) {
+	switch function {
+	case "id":
+		if argument == "" {
+			return nil, errors.New(`id needs a name, as in {{id "podcast"}}`)
+		}
+		return s.ID(argument), nil
+	case "now":
+		return s.Now, nil
+	case "ago", "fromNow":
+		duration, err := parseDuration(argument)
+		if err != nil {
+			return nil, err
+		}
+		if function == "ago" {
+			duration = -duration
+		}
+		return s.Now.Add(duration), nil
+	}
+	return nil, fmt.Errorf("unknown placeholder %q", function)
+}
+
+// parseDuration accepts time.ParseDuration syntax plus whole days ("3d")
+func parseDuration(text string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(text)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+// PersonalData names a collection holding data about a user and the field
+// referencing the user's _id
+type PersonalData struct {
+	Collection string
+	Field      string
+}
+
+// personalData lists every collection of the quickstart database that holds
+// data about a user. GridFS files reference their owner in metadata.user.
+var personalData = []PersonalData{
+	{"users", "_id"},
+	{"listens", "user"},
+	{"reviews", "user"},
+	{"digest_sends", "user"},
+	// the sessions example keys each user's summary by the user's _id
+	{"user_sessions", "_id"},
+	{"experiment_events", "user"},
+}
+
+// AuditRecord represents the schema for the "gdpr_audit" collection. It keeps
+// the id of the subject and the counts, never the personal data itself.
+type AuditRecord struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Action      string             `bson:"action"`
+	Subject     primitive.ObjectID `bson:"subject"`
+	Operator    string             `bson:"operator"`
+	Counts      map[string]int64   `bson:"counts"`
+	RequestedAt time.Time          `bson:"requested_at"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty"`
+}
+
+// Requests handles data subject requests against one database
+type Requests struct {
+	Database *mongo.Database
+	Bucket   *gridfs.Bucket
+	Operator string
+}
+
+func (r *Requests) audit() *mongo.Collection {
+	return r.Database.Collection("gdpr_audit")
+}
+
+// exportPipeline gathers the user's documents from every collection in one
+// stream with $unionWith, tagging each with the collection it came from.
+// Streaming avoids the 16MB limit a single $lookup document would hit.
+func exportPipeline(user primitive.ObjectID) mongo.Pipeline {
+	first := personalData[0]
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{first.Field, user}}}},
+		{{"$project", bson.D{{"collection", first.Collection}, {"document", "$$ROOT"}}}},
+	}
+	for _, source := range personalData[1:] {
+		pipeline = append(pipeline, bson.D{{"$unionWith", bson.D{
+			{"coll", source.Collection},
+			{"pipeline", bson.A{
+				bson.D{{"$match", bson.D{{source.Field, user}}}},
+				bson.D{{"$project", bson.D{{"collection", source.Collection}, {"document", "$$ROOT"}}}},
+			}},
+		}}})
+	}
+	return pipeline
+}
+
+// Export writes everything stored about user to w as one Extended JSON
+// document: the documents of each collection, then the user's GridFS files
+// with their contents when withFiles is set
+func (r *Requests) Export(ctx context.Context, user primitive.ObjectID, w io.Writer, withFiles bool) (map[string]int64, error) {
+	counts := map[string]int64{}
+	out := bufio.NewWriter(w)
+	header, err := bson.MarshalExtJSON(bson.D{{"subject", user}, {"exported_at", time.Now().UTC()}}, false, false)
+	if err != nil {
+		return nil, err
+	}
+	// open the header object and add the collections field to it
+	fmt.Fprintf(out, "%s,\n  \"collections\": {", bytes.TrimSuffix(header, []byte("}")))
+
+	users := r.Database.Collection(personalData[0].Collection)
+	cursor, err := users.Aggregate(ctx, exportPipeline(user))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	current := ""
+	for cursor.Next(ctx) {
+		var item struct {
+			Collection string   `bson:"collection"`
+			Document   bson.Raw `bson:"document"`
+		}
+		if err = cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		if item.Collection != current {
+			if current != "" {
+				out.WriteString("\n    ],")
+			}
+			fmt.Fprintf(out, "\n    %q: [", item.Collection)
+			current = item.Collection
+		} else {
+			out.WriteString(",")
+		}
+		document, err := bson.MarshalExtJSON(item.Document, false, false)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "\n      %s", document)
+		counts[item.Collection]++
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+	if current != "" {
+		out.WriteString("\n    ]")
+	}
+	out.WriteString("\n  },\n  \"files\": [")
+
+	files, err := r.files(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		entry := bson.D{{"file", file}}
+		if withFiles {
+			var contents bytes.Buffer
+			if _, err = r.Bucket.DownloadToStream(file.Lookup("_id"), &contents); err != nil {
+				return nil, fmt.Errorf("downloading file %v: %w", file.Lookup("_id"), err)
+			}
+			entry = append(entry, bson.E{"contents", primitive.Binary{Data: contents.Bytes()}})
+		}
+		data, err := bson.MarshalExtJSON(entry, false, false)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out.WriteString(",")
+		}
+		fmt.Fprintf(out, "\n    %s", data)
+	}
+	counts["files"] = int64(len(files))
+	out.WriteString("\n  ]\n}\n")
+	if err = out.Flush(); err != nil {
+		return nil, err
+	}
+	return counts, r.record(ctx, "export", user, counts)
+}
+
+// files returns the GridFS file documents owned by user
+func (r *Requests) files(ctx context.Context, user primitive.ObjectID) ([]bson.Raw, error) {
+	cursor, err := r.Bucket.FindContext(ctx, bson.D{{"metadata.user", user}})
+	if err != nil {
+		return nil, err
+	}
+	var files []bson.Raw
+	err = cursor.All(ctx, &files)
+	return files, err
+}
+
+// Erase deletes the user's documents from every collection in one
+// transaction, together with an audit record, then removes their GridFS
+// files. Files are deleted after the commit because a large file can exceed
+// what a transaction should hold; running Erase again finishes the job if
+// that step fails.
+func (r *Requests) Erase(ctx context.Context, user primitive.ObjectID) (map[string]int64, error) {
+	session, err := r.Database.Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(context.Background())
+
+	var record AuditRecord
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		// WithTransaction may retry, so start from fresh counts every time
+		record = AuditRecord{
+			Action:      "erase",
+			Subject:     user,
+			Operator:    r.Operator,
+			Counts:      map[string]int64{},
+			RequestedAt: time.Now().UTC(),
+		}
+		for _, source := range personalData {
+			result, err := r.Database.Collection(source.Collection).DeleteMany(sessionContext, bson.D{{source.Field, user}})
+			if err != nil {
+				return nil, fmt.Errorf("erasing from %s: %w", source.Collection, err)
+			}
+			record.Counts[source.Collection] = result.DeletedCount
+		}
+		result, err := r.audit().InsertOne(sessionContext, record)
+		if err != nil {
+			return nil, err
+		}
+		record.ID = result.InsertedID.(primitive.ObjectID)
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := r.files(ctx, user)
+	if err != nil {
+		return record.Counts, err
+	}
+	for _, file := range files {
+		if err = r.Bucket.DeleteContext(ctx, file.Lookup("_id")); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return record.Counts, fmt.Errorf("deleting file %v: %w", file.Lookup("_id"), err)
+		}
+	}
+	record.Counts["files"] = int64(len(files))
+	_, err = r.audit().UpdateByID(ctx, record.ID, bson.D{{"$set", bson.D{
+		{"counts.files", record.Counts["files"]},
+		{"completed_at", time.Now().UTC()},
+	}}})
+	return record.Counts, err
+}
+
+// record writes a completed audit record
+func (r *Requests) record(ctx context.Context, action string, user primitive.ObjectID, counts map[string]int64) error {
+	now := time.Now().UTC()
+	_, err := r.audit().InsertOne(ctx, AuditRecord{
+		Action:      action,
+		Subject:     user,
+		Operator:    r.Operator,
+		Counts:      counts,
+		RequestedAt: now,
+		CompletedAt: &now,
+	})
+	return err
+}
+
+var (
+	userHex   = flag.String("user", "", "id of the user the request is about")
+	operator  = flag.String("operator", os.Getenv("USER"), "person handling the request, recorded in the audit log")
+	output    = flag.String("o", "", "file to write the export to (default: standard output)")
+	withFiles = flag.Bool("files", true, "include the contents of the user's GridFS files in the export")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -user <id> [flags] export|erase\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	user, err := primitive.ObjectIDFromHex(*userHex)
+	if err != nil || flag.NArg() != 1 {
+		return shutdown.ErrUsage
+	}
+	if *operator == "" {
+		return fmt.Errorf("%w: -operator is required for the audit log", shutdown.ErrUsage)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	bucket, err := gridfs.NewBucket(database)
+	if err != nil {
+		return err
+	}
+	requests := &Requests{Database: database, Bucket: bucket, Operator: *operator}
+
+	switch flag.Arg(0) {
+	case "export":
+		w := os.Stdout
+		if *output != "" {
+			if w, err = os.Create(*output); err != nil {
+				return err
+			}
+			defer w.Close()
+		}
+		counts, err := requests.Export(ctx, user, w, *withFiles)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %v for user %s\n", counts, user.Hex())
+	case "erase":
+		counts, err := requests.Erase(ctx, user)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Erased %v for user %s\n", counts, user.Hex())
+	default:
+		return shutdown.ErrUsage
+	}
+	return nil
+}
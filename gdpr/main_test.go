@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// seed stores one document and one GridFS file for each of user and other
+// in every collection holding personal data
+func seed(t *testing.T, requests *Requests, user, other primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	for _, owner := range []primitive.ObjectID{user, other} {
+		for _, source := range personalData {
+			document := bson.D{{source.Field, owner}}
+			if source.Field != "_id" {
+				document = append(document, bson.E{"_id", primitive.NewObjectID()})
+			}
+			if _, err := requests.Database.Collection(source.Collection).InsertOne(ctx, document); err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := options.GridFSUpload().SetMetadata(bson.D{{"user", owner}})
+		if _, err := requests.Bucket.UploadFromStream("avatar.png", strings.NewReader("png"), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newRequests(t *testing.T) *Requests {
+	t.Helper()
+	database := mongotest.Database(t)
+	bucket, err := gridfs.NewBucket(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Requests{Database: database, Bucket: bucket, Operator: "test"}
+}
+
+func TestExport(t *testing.T) {
+	requests := newRequests(t)
+	user, other := primitive.NewObjectID(), primitive.NewObjectID()
+	seed(t, requests, user, other)
+
+	var out bytes.Buffer
+	counts, err := requests.Export(context.Background(), user, &out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		Collections map[string][]json.RawMessage `json:"collections"`
+		Files       []json.RawMessage            `json:"files"`
+	}
+	if err = json.Unmarshal(out.Bytes(), &export); err != nil {
+		t.Fatalf("export is not JSON: %v\n%s", err, out.String())
+	}
+	for _, source := range personalData {
+		if counts[source.Collection] != 1 || len(export.Collections[source.Collection]) != 1 {
+			t.Errorf("%s: counted %d, exported %d, want the user's one document",
+				source.Collection, counts[source.Collection], len(export.Collections[source.Collection]))
+		}
+	}
+	if counts["files"] != 1 || len(export.Files) != 1 {
+		t.Errorf("files: counted %d, exported %d, want 1", counts["files"], len(export.Files))
+	}
+}
+
+func TestErase(t *testing.T) {
+	ctx := context.Background()
+	requests := newRequests(t)
+	user, other := primitive.NewObjectID(), primitive.NewObjectID()
+	seed(t, requests, user, other)
+
+	counts, err := requests.Erase(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range personalData {
+		if counts[source.Collection] != 1 {
+			t.Errorf("%s: erased %d, want 1", source.Collection, counts[source.Collection])
+		}
+		collection := requests.Database.Collection(source.Collection)
+		if n, err := collection.CountDocuments(ctx, bson.D{{source.Field, user}}); err != nil || n != 0 {
+			t.Errorf("%s: %d of the user's documents left (%v)", source.Collection, n, err)
+		}
+		if n, err := collection.CountDocuments(ctx, bson.D{{source.Field, other}}); err != nil || n != 1 {
+			t.Errorf("%s: %d of another user's documents left (%v), want 1", source.Collection, n, err)
+		}
+	}
+	if files, err := requests.files(ctx, user); err != nil || len(files) != 0 {
+		t.Errorf("%d of the user's files left (%v)", len(files), err)
+	}
+	if files, err := requests.files(ctx, other); err != nil || len(files) != 1 {
+		t.Errorf("%d of another user's files left (%v), want 1", len(files), err)
+	}
+
+	var record AuditRecord
+	if err = requests.audit().FindOne(ctx, bson.D{{"action", "erase"}, {"subject", user}}).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.CompletedAt == nil || record.Counts["files"] != 1 || record.Counts["user_sessions"] != 1 {
+		t.Errorf("audit record = %+v, want it completed with the counts", record)
+	}
+
+	// erasing again finds nothing left
+	if counts, err = requests.Erase(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	for collection, n := range counts {
+		if n != 0 {
+			t.Errorf("second erase removed %d from %s", n, collection)
+		}
+	}
+}
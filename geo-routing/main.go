@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Listener represents the schema for the regional "listeners_<region>" collections
+type Listener struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Name   string             `bson:"name,omitempty"`
+	Email  string             `bson:"email,omitempty"`
+	Region string             `bson:"region,omitempty"`
+}
+
+var regions = []string{"eu", "us", "apac"}
+
+// InsertListener writes a listener to the collection for their region only
+func InsertListener(ctx context.Context, router *Router, listener Listener) (primitive.ObjectID, error) {
+	collection, err := router.For(listener.Region)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	result, err := collection.InsertOne(ctx, listener)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Each region may live on its own cluster (ATLAS_URI_EU, ATLAS_URI_US, ...);
+	// regions without a dedicated URI share the default ATLAS_URI cluster.
+	clients := map[string]*mongo.Client{}
+	connect := func(uri string) (*mongo.Client, error) {
+		if client, ok := clients[uri]; ok {
+			return client, nil
+		}
+		client, err := db.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			return nil, err
+		}
+		clients[uri] = client
+		down.Client(client)
+		return client, nil
+	}
+
+	targets := map[string]*mongo.Collection{}
+	for _, region := range regions {
+		uri := os.Getenv("ATLAS_URI_" + strings.ToUpper(region))
+		if uri == "" {
+			uri = db.URI()
+		}
+		client, err := connect(uri)
+		if err != nil {
+			return err
+		}
+		targets[region] = client.Database("quickstart").Collection("listeners_" + region)
+	}
+	router := NewRouter(targets)
+
+	listeners := []Listener{
+		{Name: "Ada", Email: "ada@example.com", Region: "eu"},
+		{Name: "Grace", Email: "grace@example.com", Region: "us"},
+		{Name: "Hiro", Email: "hiro@example.com", Region: "apac"},
+	}
+	for _, listener := range listeners {
+		id, err := InsertListener(ctx, router, listener)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Inserted %s into listeners_%s as %s\n", listener.Name, listener.Region, id.Hex())
+	}
+
+	if _, err := InsertListener(ctx, router, Listener{Name: "Nobody", Region: "mars"}); err != nil {
+		fmt.Println("Rejected write:", err)
+	}
+
+	// Regional read: served entirely by the cluster that owns the data
+	euListeners, err := router.For("eu")
+	if err != nil {
+		return err
+	}
+	var ada Listener
+	if err = euListeners.FindOne(ctx, bson.M{"name": "Ada"}).Decode(&ada); err != nil {
+		return err
+	}
+	fmt.Println(ada)
+
+	// Global read: $unionWith on a single cluster, fan-out across clusters
+	everyone, err := FindGlobal[Listener](ctx, router, bson.D{{"email", bson.D{{"$regex", regexp.QuoteMeta("@example.com") + "$"}}}})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found %v listeners across %v regions\n", len(everyone), len(router.Regions()))
+	for _, listener := range everyone {
+		fmt.Println(listener)
+	}
+	return nil
+}
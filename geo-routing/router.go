@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnknownRegion is returned for writes whose region has no configured target
+var ErrUnknownRegion = errors.New("no target configured for region")
+
+// Router maps a region to the collection holding that region's data
+type Router struct {
+	targets map[string]*mongo.Collection
+}
+
+// NewRouter creates a router from region to collection
+func NewRouter(targets map[string]*mongo.Collection) *Router {
+	return &Router{targets: targets}
+}
+
+// Regions returns the configured regions in a stable order
+func (r *Router) Regions() []string {
+	regions := make([]string, 0, len(r.targets))
+	for region := range r.targets {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// For returns the collection data for a region must be written to
+func (r *Router) For(region string) (*mongo.Collection, error) {
+	collection, ok := r.targets[region]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+	}
+	return collection, nil
+}
+
+// colocated reports whether every target lives in the same database of the
+// same cluster, which is what $unionWith requires
+func (r *Router) colocated() bool {
+	var first *mongo.Database
+	for _, collection := range r.targets {
+		database := collection.Database()
+		if first == nil {
+			first = database
+			continue
+		}
+		if database.Client() != first.Client() || database.Name() != first.Name() {
+			return false
+		}
+	}
+	return true
+}
+
+// FindGlobal runs a read across every region. When the regional collections
+// share a cluster and database a single $unionWith aggregation is used,
+// otherwise each region is queried on its own cluster and the results are merged.
+func FindGlobal[T any](ctx context.Context, r *Router, filter bson.D) ([]T, error) {
+	regions := r.Regions()
+	if len(regions) == 0 {
+		return nil, nil
+	}
+	if r.colocated() {
+		return unionWith[T](ctx, r, regions, filter)
+	}
+	return fanOut[T](ctx, r, regions, filter)
+}
+
+func unionWith[T any](ctx context.Context, r *Router, regions []string, filter bson.D) ([]T, error) {
+	matchStage := bson.D{{"$match", filter}}
+	pipeline := mongo.Pipeline{matchStage}
+	for _, region := range regions[1:] {
+		pipeline = append(pipeline, bson.D{{"$unionWith", bson.D{
+			{"coll", r.targets[region].Name()},
+			{"pipeline", bson.A{matchStage}},
+		}}})
+	}
+	cursor, err := r.targets[regions[0]].Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []T
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func fanOut[T any](ctx context.Context, r *Router, regions []string, filter bson.D) ([]T, error) {
+	var results []T
+	for _, region := range regions {
+		cursor, err := r.targets[region].Find(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("region %s: %w", region, err)
+		}
+		var documents []T
+		if err = cursor.All(ctx, &documents); err != nil {
+			return nil, fmt.Errorf("region %s: %w", region, err)
+		}
+		results = append(results, documents...)
+	}
+	return results, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Point is a GeoJSON point. GeoJSON lists the longitude first.
+type Point struct {
+	Type        string     `bson:"type"`
+	Coordinates [2]float64 `bson:"coordinates"`
+}
+
+// NewPoint returns the point at the given latitude and longitude
+func NewPoint(latitude, longitude float64) Point {
+	return Point{Type: "Point", Coordinates: [2]float64{longitude, latitude}}
+}
+
+// Listener represents the schema for the "geo_listeners" collection
+type Listener struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Name     string             `bson:"name"`
+	City     string             `bson:"city"`
+	Location Point              `bson:"location"`
+}
+
+// earthRadius in kilometers converts distances to the radians $centerSphere
+// expects
+const earthRadius = 6378.1
+
+var listeners = []interface{}{
+	Listener{Name: "Ada", City: "London", Location: NewPoint(51.5072, -0.1276)},
+	Listener{Name: "Grace", City: "Paris", Location: NewPoint(48.8566, 2.3522)},
+	Listener{Name: "Linus", City: "Amsterdam", Location: NewPoint(52.3676, 4.9041)},
+	Listener{Name: "Barbara", City: "Berlin", Location: NewPoint(52.5200, 13.4050)},
+	Listener{Name: "Ken", City: "Madrid", Location: NewPoint(40.4168, -3.7038)},
+	Listener{Name: "Margaret", City: "New York", Location: NewPoint(40.7128, -74.0060)},
+	Listener{Name: "Dennis", City: "Boston", Location: NewPoint(42.3601, -71.0589)},
+	Listener{Name: "Radia", City: "Sydney", Location: NewPoint(-33.8688, 151.2093)},
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	listenersCollection := client.Database("quickstart").Collection("geo_listeners")
+	if err = listenersCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop geo_listeners: %w", err)
+	}
+
+	// Create A 2dsphere Index
+	// $near and $geoNear need it; $geoWithin uses it when present
+	index, err := listenersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"location", "2dsphere"}},
+	})
+	if err != nil {
+		return fmt.Errorf("create 2dsphere index on geo_listeners: %w", err)
+	}
+	fmt.Println("Created index", index)
+
+	result, err := listenersCollection.InsertMany(ctx, listeners)
+	if err != nil {
+		return fmt.Errorf("insert into geo_listeners: %w", err)
+	}
+	fmt.Printf("Inserted %v listeners\n", len(result.InsertedIDs))
+
+	// Find Listeners Near A Point With $near
+	// results come back nearest first; the distances are in meters
+	brussels := NewPoint(50.8503, 4.3517)
+	cursor, err := listenersCollection.Find(ctx, bson.D{{"location", bson.D{{"$near", bson.D{
+		{"$geometry", brussels},
+		{"$maxDistance", 500_000},
+	}}}}})
+	if err != nil {
+		return fmt.Errorf("find near Brussels: %w", err)
+	}
+	var near []Listener
+	if err = cursor.All(ctx, &near); err != nil {
+		return fmt.Errorf("decode listeners near Brussels: %w", err)
+	}
+	fmt.Println("Listeners within 500 km of Brussels, nearest first:")
+	for _, listener := range near {
+		fmt.Printf("  %s in %s\n", listener.Name, listener.City)
+	}
+
+	// Find Listeners Inside An Area With $geoWithin
+	// a polygon's ring must be closed, ending on the point it starts from
+	eastCoast := bson.D{
+		{"type", "Polygon"},
+		{"coordinates", bson.A{bson.A{
+			bson.A{-80.0, 38.0}, bson.A{-66.0, 38.0}, bson.A{-66.0, 46.0}, bson.A{-80.0, 46.0}, bson.A{-80.0, 38.0},
+		}}},
+	}
+	cursor, err = listenersCollection.Find(ctx, bson.D{{"location", bson.D{{"$geoWithin", bson.D{
+		{"$geometry", eastCoast},
+	}}}}})
+	if err != nil {
+		return fmt.Errorf("find within the east coast: %w", err)
+	}
+	var within []Listener
+	if err = cursor.All(ctx, &within); err != nil {
+		return fmt.Errorf("decode listeners within the east coast: %w", err)
+	}
+	fmt.Println("Listeners on the US east coast:")
+	for _, listener := range within {
+		fmt.Printf("  %s in %s\n", listener.Name, listener.City)
+	}
+
+	// $centerSphere takes its radius in radians rather than meters
+	count, err := listenersCollection.CountDocuments(ctx, bson.D{{"location", bson.D{{"$geoWithin", bson.D{
+		{"$centerSphere", bson.A{brussels.Coordinates, 1000 / earthRadius}},
+	}}}}})
+	if err != nil {
+		return fmt.Errorf("count within 1000 km of Brussels: %w", err)
+	}
+	fmt.Printf("%d listeners live within 1000 km of Brussels\n", count)
+
+	// Calculate Distances With $geoNear
+	// $geoNear must be the first stage; it adds each document's distance, here
+	// converted to kilometers, and can filter with a query of its own
+	geoNearStage := bson.D{{"$geoNear", bson.D{
+		{"near", NewPoint(48.1351, 11.5820)},
+		{"distanceField", "distance"},
+		{"distanceMultiplier", 0.001},
+		{"spherical", true},
+		{"query", bson.D{{"city", bson.D{{"$ne", "Sydney"}}}}},
+	}}}
+	limitStage := bson.D{{"$limit", 5}}
+	cursor, err = listenersCollection.Aggregate(ctx, mongo.Pipeline{geoNearStage, limitStage})
+	if err != nil {
+		return fmt.Errorf("aggregate distances from Munich: %w", err)
+	}
+	var distances []struct {
+		Listener `bson:",inline"`
+		Distance float64 `bson:"distance"`
+	}
+	if err = cursor.All(ctx, &distances); err != nil {
+		return fmt.Errorf("decode distances from Munich: %w", err)
+	}
+	fmt.Println("Distances from Munich:")
+	for _, listener := range distances {
+		fmt.Printf("  %-8s %-10s %6.0f km\n", listener.Name, listener.City, listener.Distance)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// File represents the schema for the "audio.files" collection GridFS keeps
+// alongside the chunks
+type File struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Length     int64              `bson:"length"`
+	ChunkSize  int32              `bson:"chunkSize"`
+	UploadDate time.Time          `bson:"uploadDate"`
+	Filename   string             `bson:"filename"`
+	Metadata   bson.M             `bson:"metadata"`
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	podcast := primitive.NewObjectID()
+
+	// Open A Bucket Named "audio", Stored In audio.files And audio.chunks
+	bucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName("audio"))
+	if err != nil {
+		return err
+	}
+	if err = bucket.Drop(); err != nil {
+		return err
+	}
+
+	// Upload A File From A Reader
+	trailer := bytes.NewReader([]byte("ID3 a short trailer for the show"))
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.D{
+		{"podcast", podcast},
+		{"kind", "trailer"},
+		{"content_type", "audio/mpeg"},
+	})
+	trailerID, err := bucket.UploadFromStream("trailer.mp3", trailer, uploadOpts)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Uploaded trailer.mp3 as", trailerID.Hex())
+
+	// Download A File Into A Writer
+	var downloaded bytes.Buffer
+	size, err := bucket.DownloadToStream(trailerID, &downloaded)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %d bytes: %q\n", size, downloaded.String())
+
+	// Stream A Large File With A Custom Chunk Size
+	// the upload stream is an io.Writer, so the episode never has to fit in
+	// memory; 1MB chunks mean fewer, larger documents than the 255KB default
+	const episodeSize = 20 << 20
+	uploadOpts = options.GridFSUpload().
+		SetChunkSizeBytes(1 << 20).
+		SetMetadata(bson.D{
+			{"podcast", podcast},
+			{"kind", "episode"},
+			{"content_type", "audio/mpeg"},
+		})
+	upload, err := bucket.OpenUploadStream("episode-1.mp3", uploadOpts)
+	if err != nil {
+		return err
+	}
+	uploadHash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(upload, uploadHash), io.LimitReader(rand.Reader, episodeSize)); err != nil {
+		upload.Abort()
+		return err
+	}
+	// Close writes the last chunk and the files document
+	if err = upload.Close(); err != nil {
+		return err
+	}
+	episodeID := upload.FileID
+	fmt.Printf("Streamed %d MB into episode-1.mp3 (%v)\n", episodeSize>>20, episodeID)
+
+	download, err := bucket.OpenDownloadStream(episodeID)
+	if err != nil {
+		return err
+	}
+	downloadHash := sha256.New()
+	size, err = io.Copy(downloadHash, download)
+	download.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Streamed %d bytes back, checksums match: %v\n", size,
+		bytes.Equal(uploadHash.Sum(nil), downloadHash.Sum(nil)))
+
+	// Query Files By Metadata
+	cursor, err := bucket.FindContext(ctx, bson.D{
+		{"metadata.podcast", podcast},
+		{"metadata.kind", "episode"},
+	}, options.GridFSFind().SetSort(bson.D{{"uploadDate", -1}}))
+	if err != nil {
+		return err
+	}
+	var files []File
+	if err = cursor.All(ctx, &files); err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Printf("%s: %d bytes in %d byte chunks, uploaded %s\n",
+			file.Filename, file.Length, file.ChunkSize, file.UploadDate.Format(time.RFC3339))
+	}
+
+	// Rename And Delete Files
+	if err = bucket.RenameContext(ctx, trailerID, "trailer-v2.mp3"); err != nil {
+		return err
+	}
+	if err = bucket.DeleteContext(ctx, episodeID); err != nil {
+		return err
+	}
+	fmt.Println("Renamed the trailer and deleted the episode with its chunks")
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package db connects the examples to MongoDB in one consistent way. The
+// connection string comes from the -uri flag, for programs that register it
+// with RegisterFlags, or the ATLAS_URI environment variable, is validated
+// before dialing, and the client gets timeouts that make a wrong address
+// fail in seconds instead of hanging:
+//
+//	func main() {
+//		db.RegisterFlags(flag.CommandLine)
+//		flag.Parse()
+//		shutdown.Main(run)
+//	}
+package db
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Timeouts applied to every client unless the caller's options override them
+const (
+	ConnectTimeout         = 10 * time.Second
+	ServerSelectionTimeout = 10 * time.Second
+	DisconnectTimeout      = 10 * time.Second
+)
+
+// ErrNoURI is returned when neither -uri nor ATLAS_URI is set
+var ErrNoURI = errors.New("no connection string: set ATLAS_URI or pass -uri")
+
+// uriFlag is the value of -uri once RegisterFlags has registered it
+var uriFlag string
+
+// placeholders are the parts of the connection strings in the blog posts,
+// the README and the Atlas UI that must be replaced before connecting
+var placeholders = []string{"<ATLAS_URI_HERE>", "<username>", "<user>", "<password>", "<db_password>", "<cluster>"}
+
+// RegisterFlags registers the -uri flag on fs. Programs call it before
+// parsing their command line; the package never parses it itself.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&uriFlag, "uri", "", "MongoDB connection string (default: $ATLAS_URI)")
+}
+
+// URI returns the connection string from the -uri flag or, without it, from
+// ATLAS_URI
+func URI() string {
+	if uriFlag != "" {
+		return uriFlag
+	}
+	return os.Getenv("ATLAS_URI")
+}
+
+// Validate checks that uri is a usable connection string and not one of the
+// placeholders the examples used to ship with
+func Validate(uri string) error {
+	if uri == "" {
+		return ErrNoURI
+	}
+	for _, placeholder := range placeholders {
+		if strings.Contains(uri, placeholder) {
+			return fmt.Errorf("connection string still contains the placeholder %s", placeholder)
+		}
+	}
+	if _, err := connstring.ParseAndValidate(uri); err != nil {
+		return fmt.Errorf("invalid connection string: %w", err)
+	}
+	return nil
+}
+
+// Connect connects to the URI returned by URI and pings the primary so a bad
+// address or credentials fail here rather than on the first query. Options
+// are applied after the defaults; one that sets its own URI with ApplyURI
+// replaces the -uri flag and ATLAS_URI.
+func Connect(ctx context.Context, opts ...*options.ClientOptions) (*mongo.Client, error) {
+	base := options.Client().
+		SetConnectTimeout(ConnectTimeout).
+		SetServerSelectionTimeout(ServerSelectionTimeout)
+	uri := ""
+	for _, opt := range opts {
+		if opt != nil && opt.GetURI() != "" {
+			uri = opt.GetURI()
+		}
+	}
+	if uri == "" {
+		uri = URI()
+		base.ApplyURI(uri)
+	}
+	if err := Validate(uri); err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.Connect(ctx, append([]*options.ClientOptions{base}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", Hosts(uri), err)
+	}
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("connecting to %s: %w", Hosts(uri), err)
+	}
+	return client, nil
+}
+
+// Hosts returns the hosts of a valid connection string, leaving out the
+// credentials so the result can be logged
+func Hosts(uri string) string {
+	cs, err := connstring.Parse(uri)
+	if err != nil {
+		return "cluster"
+	}
+	return strings.Join(cs.Hosts, ",")
+}
+
+// Explain returns advice for the failures people hit most while trying the
+// examples, or "" when err is not one of them
+func Explain(err error) string {
+	var serverErr mongo.ServerError
+	isServerErr := errors.As(err, &serverErr)
+	switch {
+	case errors.Is(err, ErrNoURI):
+		return "copy the connection string from the Atlas UI (Connect > Drivers) into ATLAS_URI"
+	case strings.Contains(err.Error(), "AuthenticationFailed"), isServerErr && serverErr.HasErrorCode(18):
+		return "check the user name and password in the connection string; special characters must be percent-encoded"
+	case isServerErr && serverErr.HasErrorCode(13):
+		return "the database user lacks a role for this operation; grant it readWrite on the quickstart database"
+	case isServerErr && (serverErr.HasErrorCode(20) || serverErr.HasErrorCode(40573)):
+		return "transactions and change streams need a replica set; use an Atlas cluster or start mongod with --replSet"
+	case errors.As(err, new(topology.ServerSelectionError)):
+		return "the cluster did not answer; check it is running and not paused, and that your IP is on the Atlas access list"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "the example ran out of time; the cluster may be slow to respond or the data set larger than expected"
+	}
+	return ""
+}
+
+// Disconnect closes the client with a timeout of its own, so it still works
+// in a defer after the caller's context has expired
+func Disconnect(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), DisconnectTimeout)
+	defer cancel()
+	if err := client.Disconnect(ctx); err != nil {
+		log.Printf("disconnecting: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Package deadline derives server-side maxTimeMS limits from a context's
+// deadline so the server stops working on a query as soon as the client
+// would give up waiting for it.
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Margin is held back from the remaining budget to leave room for the
+// network round trip and decoding the reply
+var Margin = 50 * time.Millisecond
+
+// Budget returns how long the server may spend on an operation started now,
+// or false when the context has no deadline
+func Budget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	budget := time.Until(deadline) - Margin
+	if budget < time.Millisecond {
+		// maxTimeMS of 0 means "no limit", so never go below one millisecond
+		budget = time.Millisecond
+	}
+	return budget, true
+}
+
+// Find returns options limiting a Find to the context's remaining budget.
+// Pass it last so it takes precedence: coll.Find(ctx, filter, opts, deadline.Find(ctx))
+func Find(ctx context.Context) *options.FindOptions {
+	budget, ok := Budget(ctx)
+	if !ok {
+		return nil
+	}
+	return options.Find().SetMaxTime(budget)
+}
+
+// FindOne returns options limiting a FindOne to the context's remaining budget
+func FindOne(ctx context.Context) *options.FindOneOptions {
+	budget, ok := Budget(ctx)
+	if !ok {
+		return nil
+	}
+	return options.FindOne().SetMaxTime(budget)
+}
+
+// Aggregate returns options limiting an Aggregate to the context's remaining budget
+func Aggregate(ctx context.Context) *options.AggregateOptions {
+	budget, ok := Budget(ctx)
+	if !ok {
+		return nil
+	}
+	return options.Aggregate().SetMaxTime(budget)
+}
@@ -0,0 +1,172 @@
+// Package golden compares query results against golden files stored in the
+// calling package's testdata directory. Results are written as canonical
+// Extended JSON, so a changed type (an int32 becoming a double) is a diff too.
+// ObjectIDs and dates, which differ on every run, are normalized first.
+// Text does the same for what a program printed.
+//
+// Run the tests with -update to rewrite the golden files after an
+// intentional change, and review the diff before committing it.
+package golden
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current results")
+
+// Normalize renders documents as indented canonical Extended JSON with
+// sorted keys. Every distinct ObjectID is replaced by "ObjectId(n)", numbered
+// by first appearance, so references between documents are still visible;
+// every date is replaced by "Date".
+func Normalize(documents []bson.Raw) ([]byte, error) {
+	values := make([]interface{}, len(documents))
+	ids := map[string]int{}
+	for i, document := range documents {
+		extJSON, err := bson.MarshalExtJSON(document, true, false)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err = json.Unmarshal(extJSON, &value); err != nil {
+			return nil, err
+		}
+		values[i] = normalize(value, ids)
+	}
+	// encoding/json writes map keys in sorted order
+	output, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(output, '\n'), nil
+}
+
+func normalize(value interface{}, ids map[string]int) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if oid, ok := v["$oid"].(string); ok {
+				n, seen := ids[oid]
+				if !seen {
+					n = len(ids) + 1
+					ids[oid] = n
+				}
+				return fmt.Sprintf("ObjectId(%d)", n)
+			}
+			if _, ok := v["$date"]; ok {
+				return "Date"
+			}
+		}
+		// map iteration order is random, so visit keys sorted to number the
+		// ObjectIDs the same way on every run
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v[key] = normalize(v[key], ids)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item, ids)
+		}
+		return v
+	}
+	return value
+}
+
+// Assert compares documents with testdata/<name>.golden.json
+func Assert(t testing.TB, name string, documents []bson.Raw) {
+	t.Helper()
+	got, err := Normalize(documents)
+	if err != nil {
+		t.Fatalf("normalizing %s: %v", name, err)
+	}
+	compare(t, name, filepath.Join("testdata", name+".golden.json"), got)
+}
+
+// objectIDText matches an ObjectID printed by fmt, ObjectID("..."), or as
+// bare hex
+var objectIDText = regexp.MustCompile(`ObjectID\("([0-9a-f]{24})"\)|\b[0-9a-f]{24}\b`)
+
+// dateText matches time.Time as printed by fmt, with or without the
+// monotonic clock reading, and RFC 3339 timestamps
+var dateText = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?( [+-]\d{4} \w+( m=[+-]\d+\.\d+)?|Z|[+-]\d{2}:\d{2})?`)
+
+// NormalizeText replaces every distinct ObjectID in output by ObjectID(n),
+// numbered by first appearance like Normalize, and every date by Date
+func NormalizeText(output []byte) []byte {
+	ids := map[string]int{}
+	output = objectIDText.ReplaceAllFunc(output, func(match []byte) []byte {
+		hex := string(match)
+		if sub := objectIDText.FindSubmatch(match); len(sub[1]) > 0 {
+			hex = string(sub[1])
+		}
+		n, seen := ids[hex]
+		if !seen {
+			n = len(ids) + 1
+			ids[hex] = n
+		}
+		return []byte("ObjectID(" + strconv.Itoa(n) + ")")
+	})
+	return dateText.ReplaceAll(output, []byte("Date"))
+}
+
+// Text compares what a program printed with testdata/<name>.golden.txt
+func Text(t testing.TB, name string, output []byte) {
+	t.Helper()
+	compare(t, name, filepath.Join("testdata", name+".golden.txt"), NormalizeText(output))
+}
+
+func compare(t testing.TB, name, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match %s\n--- got\n%s\n--- want\n%s", name, path, got, want)
+	}
+}
+
+// Aggregate runs pipeline on collection and asserts its results against the
+// golden file called name. Pipelines without a final $sort should have one
+// appended so the result order is stable.
+func Aggregate(t testing.TB, collection *mongo.Collection, name string, pipeline mongo.Pipeline) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("running %s: %v", name, err)
+	}
+	var documents []bson.Raw
+	if err = cursor.All(ctx, &documents); err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	Assert(t, name, documents)
+}
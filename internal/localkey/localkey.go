@@ -0,0 +1,50 @@
+// Package localkey keeps the local master key used by the encryption
+// examples in a file. A local key is for demos: in production the master
+// key stays in a KMS (AWS, Azure, GCP or KMIP) and never touches the disk.
+package localkey
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Size is the length of a local master key
+const Size = 96
+
+// Load reads the base64 master key from path, creating a random one the
+// first time
+func Load(path string) ([]byte, error) {
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, Size)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+		log.Printf("created a new local master key in %s", path)
+		return key, os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("master key %s: %w", path, err)
+	}
+	if len(key) != Size {
+		return nil, fmt.Errorf("master key %s: %d bytes, a local master key has %d", path, len(key), Size)
+	}
+	return key, nil
+}
+
+// Providers returns the KMS providers option holding key as the local
+// provider
+func Providers(key []byte) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"local": {"key": key},
+	}
+}
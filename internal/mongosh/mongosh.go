@@ -0,0 +1,371 @@
+// Package mongosh prints the commands a client sends as the equivalent mongosh
+// statements, so an operation built in Go can be pasted into the shell or
+// Compass and reproduced there. It only reads CommandStartedEvents and has no
+// effect on the operations themselves.
+//
+// Enable it while debugging, for example with QUICKSTART_MONGOSH=1.
+package mongosh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mongodb-developer/golang-quickstart/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Enabled reports whether QUICKSTART_MONGOSH is set to a non-empty value
+func Enabled() bool {
+	return os.Getenv("QUICKSTART_MONGOSH") != ""
+}
+
+// ignored are driver housekeeping commands nobody types into the shell
+var ignored = map[string]bool{
+	"hello":             true,
+	"isMaster":          true,
+	"ismaster":          true,
+	"ping":              true,
+	"buildInfo":         true,
+	"saslStart":         true,
+	"saslContinue":      true,
+	"getMore":           true,
+	"killCursors":       true,
+	"endSessions":       true,
+	"commitTransaction": true,
+	"abortTransaction":  true,
+}
+
+// skipped are command fields that only carry session or routing state
+var skipped = map[string]bool{
+	"lsid":             true,
+	"txnNumber":        true,
+	"startTransaction": true,
+	"autocommit":       true,
+	"$db":              true,
+	"$clusterTime":     true,
+	"$readPreference":  true,
+	"apiVersion":       true,
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Printer writes each command as a mongosh statement, preceded by
+// "use <db>" whenever the database changes
+type Printer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	database string
+}
+
+// New returns a Printer writing to w
+func New(w io.Writer) *Printer {
+	return &Printer{w: w}
+}
+
+// CommandMonitor returns the monitor to pass to options.Client().SetMonitor
+func (p *Printer) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, started *event.CommandStartedEvent) {
+			if ignored[started.CommandName] {
+				return
+			}
+			statements := Statements(started.Command, started.CommandName)
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if started.DatabaseName != p.database {
+				fmt.Fprintf(p.w, "use %s\n", started.DatabaseName)
+				p.database = started.DatabaseName
+			}
+			for _, statement := range statements {
+				fmt.Fprintln(p.w, statement)
+			}
+		},
+	}
+}
+
+// Statements renders a command as mongosh statements against the current
+// database. Bulk update, delete and insert commands become one statement per
+// operation; commands without a shell helper become db.runCommand(...).
+func Statements(command bson.Raw, name string) []string {
+	collection, _ := command.Lookup(name).StringValueOK()
+	target := "db." + collectionName(collection)
+
+	switch name {
+	case "find":
+		return []string{target + find(command)}
+	case "aggregate":
+		if collection == "" {
+			// a database-level aggregation such as $documents or $currentOp
+			target = "db"
+		}
+		return []string{target + call("aggregate", command.Lookup("pipeline"), options(command, "pipeline", "cursor"))}
+	case "count":
+		// EstimatedDocumentCount sends count without a query
+		if query, err := command.LookupErr("query"); err == nil {
+			return []string{target + call("countDocuments", query, options(command, "query"))}
+		}
+		return []string{target + ".estimatedDocumentCount()"}
+	case "distinct":
+		return []string{target + call("distinct", command.Lookup("key"), command.Lookup("query"), options(command, "key", "query"))}
+	case "insert":
+		return inserts(target, command)
+	case "update":
+		return updates(target, command)
+	case "delete":
+		return deletes(target, command)
+	case "findAndModify":
+		return []string{target + findAndModify(command)}
+	case "createIndexes":
+		return createIndexes(target, command)
+	case "dropIndexes":
+		return []string{target + call("dropIndex", command.Lookup("index"))}
+	case "drop":
+		return []string{target + ".drop()"}
+	case "create":
+		return []string{call("db.createCollection", command.Lookup("create"), options(command, "create"))}
+	}
+	return []string{call("db.runCommand", options(command))}
+}
+
+// collectionName returns the shell accessor for a collection
+func collectionName(collection string) string {
+	if identifier.MatchString(collection) {
+		return collection
+	}
+	return fmt.Sprintf("getCollection(%s)", pipeline.Shell(collection))
+}
+
+// call renders method(arg, ...) dropping trailing empty arguments, so
+// find({}, {}) prints as find()
+func call(method string, args ...interface{}) string {
+	rendered := make([]string, len(args))
+	for i, arg := range args {
+		rendered[i] = render(arg)
+	}
+	for len(rendered) > 0 && rendered[len(rendered)-1] == "" {
+		rendered = rendered[:len(rendered)-1]
+	}
+	for i := range rendered {
+		if rendered[i] == "" {
+			rendered[i] = "{}"
+		}
+	}
+	if !strings.HasPrefix(method, "db") {
+		method = "." + method
+	}
+	return method + "(" + strings.Join(rendered, ", ") + ")"
+}
+
+// required wraps an argument mongosh has no default for, such as the filter
+// of deleteMany, so call prints it as {} instead of dropping it
+type required struct {
+	value interface{}
+}
+
+// render returns "" for missing values and empty documents
+func render(arg interface{}) string {
+	switch value := arg.(type) {
+	case required:
+		if rendered := render(value.value); rendered != "" {
+			return rendered
+		}
+		return "{}"
+	case bson.RawValue:
+		if value.Type == 0 {
+			return ""
+		}
+		if document, ok := value.DocumentOK(); ok && len(mustElements(document)) == 0 {
+			return ""
+		}
+	case bson.D:
+		if len(value) == 0 {
+			return ""
+		}
+	}
+	return pipeline.Shell(arg)
+}
+
+func mustElements(document bson.Raw) []bson.RawElement {
+	elements, _ := document.Elements()
+	return elements
+}
+
+// arrayValues returns the items of an array field, or nil if it is missing
+func arrayValues(document bson.Raw, field string) []bson.RawValue {
+	array, ok := document.Lookup(field).ArrayOK()
+	if !ok {
+		return nil
+	}
+	values, _ := array.Values()
+	return values
+}
+
+// options copies the command's fields except the command name, the given
+// fields and session state, for use as the trailing options argument
+func options(command bson.Raw, exclude ...string) bson.D {
+	excluded := map[string]bool{}
+	for _, field := range exclude {
+		excluded[field] = true
+	}
+	elements, _ := command.Elements()
+	var opts bson.D
+	for i, element := range elements {
+		key := element.Key()
+		// the first field is the command name, except for db.runCommand
+		if (i == 0 && len(exclude) > 0) || excluded[key] || skipped[key] {
+			continue
+		}
+		opts = append(opts, bson.E{key, element.Value()})
+	}
+	return opts
+}
+
+// find renders a find command as .find(filter, projection) followed by the
+// usual cursor methods. FindOne sends limit 1 with singleBatch and becomes
+// the shell's findOne, which takes the remaining fields as options instead.
+func find(command bson.Raw) string {
+	fields := []string{"sort", "skip", "limit", "hint", "collation", "comment", "maxTimeMS"}
+	filter, projection := command.Lookup("filter"), command.Lookup("projection")
+	if single, ok := command.Lookup("singleBatch").BooleanOK(); ok && single {
+		opts := bson.D{}
+		for _, field := range fields {
+			if value, err := command.LookupErr(field); err == nil && field != "limit" {
+				opts = append(opts, bson.E{field, value})
+			}
+		}
+		return call("findOne", filter, projection, opts)
+	}
+	var b strings.Builder
+	b.WriteString(call("find", filter, projection))
+	for _, field := range fields {
+		if value, err := command.LookupErr(field); err == nil {
+			b.WriteString(call(field, value))
+		}
+	}
+	return b.String()
+}
+
+func inserts(target string, command bson.Raw) []string {
+	documents := arrayValues(command, "documents")
+	if len(documents) == 1 {
+		return []string{target + call("insertOne", documents[0])}
+	}
+	return []string{target + call("insertMany", command.Lookup("documents"), orderedOption(command))}
+}
+
+func orderedOption(command bson.Raw) bson.D {
+	if ordered, ok := command.Lookup("ordered").BooleanOK(); ok && !ordered {
+		return bson.D{{"ordered", false}}
+	}
+	return nil
+}
+
+func updates(target string, command bson.Raw) []string {
+	statements := arrayValues(command, "updates")
+	var rendered []string
+	for _, statement := range statements {
+		update, ok := statement.DocumentOK()
+		if !ok {
+			continue
+		}
+		method := "updateOne"
+		if multi, ok := update.Lookup("multi").BooleanOK(); ok && multi {
+			method = "updateMany"
+		} else if isReplacement(update.Lookup("u")) {
+			method = "replaceOne"
+		}
+		opts := bson.D{}
+		for _, field := range []string{"upsert", "arrayFilters", "hint", "collation"} {
+			if value, err := update.LookupErr(field); err == nil {
+				opts = append(opts, bson.E{field, value})
+			}
+		}
+		rendered = append(rendered, target+call(method, update.Lookup("q"), update.Lookup("u"), opts))
+	}
+	return rendered
+}
+
+// isReplacement reports whether an update is a plain document rather than
+// update operators or a pipeline
+func isReplacement(update bson.RawValue) bool {
+	document, ok := update.DocumentOK()
+	if !ok {
+		return false
+	}
+	elements := mustElements(document)
+	return len(elements) > 0 && !strings.HasPrefix(elements[0].Key(), "$")
+}
+
+func deletes(target string, command bson.Raw) []string {
+	statements := arrayValues(command, "deletes")
+	var rendered []string
+	for _, statement := range statements {
+		remove, ok := statement.DocumentOK()
+		if !ok {
+			continue
+		}
+		method := "deleteMany"
+		if limit, ok := remove.Lookup("limit").AsInt64OK(); ok && limit == 1 {
+			method = "deleteOne"
+		}
+		opts := bson.D{}
+		for _, field := range []string{"hint", "collation"} {
+			if value, err := remove.LookupErr(field); err == nil {
+				opts = append(opts, bson.E{field, value})
+			}
+		}
+		rendered = append(rendered, target+call(method, required{remove.Lookup("q")}, opts))
+	}
+	return rendered
+}
+
+// findAndModify picks the shell helper matching the FindOneAnd* call
+func findAndModify(command bson.Raw) string {
+	query := command.Lookup("query")
+	opts := bson.D{}
+	for _, field := range []string{"sort", "fields", "upsert", "arrayFilters", "hint", "collation"} {
+		if value, err := command.LookupErr(field); err == nil {
+			key := field
+			if field == "fields" {
+				key = "projection"
+			}
+			opts = append(opts, bson.E{key, value})
+		}
+	}
+	if returnNew, ok := command.Lookup("new").BooleanOK(); ok && returnNew {
+		opts = append(opts, bson.E{"returnDocument", "after"})
+	}
+	if remove, ok := command.Lookup("remove").BooleanOK(); ok && remove {
+		return call("findOneAndDelete", required{query}, opts)
+	}
+	update := command.Lookup("update")
+	if isReplacement(update) {
+		return call("findOneAndReplace", query, update, opts)
+	}
+	return call("findOneAndUpdate", query, update, opts)
+}
+
+func createIndexes(target string, command bson.Raw) []string {
+	indexes := arrayValues(command, "indexes")
+	var rendered []string
+	for _, index := range indexes {
+		spec, ok := index.DocumentOK()
+		if !ok {
+			continue
+		}
+		opts := bson.D{}
+		for _, element := range mustElements(spec) {
+			if element.Key() != "key" {
+				opts = append(opts, bson.E{element.Key(), element.Value()})
+			}
+		}
+		rendered = append(rendered, target+call("createIndex", spec.Lookup("key"), opts))
+	}
+	return rendered
+}
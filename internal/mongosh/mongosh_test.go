@@ -0,0 +1,118 @@
+package mongosh
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func command(t *testing.T, document bson.D) bson.Raw {
+	t.Helper()
+	data, err := bson.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestStatements(t *testing.T) {
+	session := bson.E{"lsid", bson.D{{"id", "x"}}}
+	tests := []struct {
+		name    string
+		command bson.D
+		want    []string
+	}{
+		{"find everything", bson.D{{"find", "episodes"}, {"filter", bson.D{}}, session, {"$db", "quickstart"}},
+			[]string{"db.episodes.find()"}},
+		{"find with cursor methods", bson.D{{"find", "episodes"}, {"filter", bson.D{{"duration", bson.D{{"$gt", 25}}}}}, {"sort", bson.D{{"duration", -1}}}, {"skip", 5}, {"limit", 2}},
+			[]string{"db.episodes.find({ duration: { $gt: 25 } }).sort({ duration: -1 }).skip(5).limit(2)"}},
+		{"find with a projection only", bson.D{{"find", "episodes"}, {"projection", bson.D{{"title", 1}}}},
+			[]string{"db.episodes.find({}, { title: 1 })"}},
+		{"findOne", bson.D{{"find", "episodes"}, {"filter", bson.D{{"title", "Go"}}}, {"limit", 1}, {"singleBatch", true}, {"sort", bson.D{{"_id", 1}}}},
+			[]string{`db.episodes.findOne({ title: "Go" }, {}, { sort: { _id: 1 } })`}},
+		{"collection that is not an identifier", bson.D{{"find", "my-episodes"}},
+			[]string{`db.getCollection("my-episodes").find()`}},
+		{"aggregate", bson.D{{"aggregate", "episodes"}, {"pipeline", bson.A{bson.D{{"$limit", 1}}}}, {"cursor", bson.D{}}, {"allowDiskUse", true}},
+			[]string{"db.episodes.aggregate([{ $limit: 1 }], { allowDiskUse: true })"}},
+		{"database aggregate", bson.D{{"aggregate", 1}, {"pipeline", bson.A{bson.D{{"$currentOp", bson.D{}}}}}, {"cursor", bson.D{}}},
+			[]string{"db.aggregate([{ $currentOp: {} }])"}},
+		{"countDocuments", bson.D{{"count", "episodes"}, {"query", bson.D{{"podcast", "a"}}}},
+			[]string{`db.episodes.countDocuments({ podcast: "a" })`}},
+		{"estimatedDocumentCount", bson.D{{"count", "episodes"}},
+			[]string{"db.episodes.estimatedDocumentCount()"}},
+		{"distinct", bson.D{{"distinct", "episodes"}, {"key", "podcast"}, {"query", bson.D{}}},
+			[]string{`db.episodes.distinct("podcast")`}},
+		{"insertOne", bson.D{{"insert", "podcasts"}, {"documents", bson.A{bson.D{{"title", "Go"}}}}, {"ordered", true}},
+			[]string{`db.podcasts.insertOne({ title: "Go" })`}},
+		{"unordered insertMany", bson.D{{"insert", "podcasts"}, {"documents", bson.A{bson.D{{"n", 1}}, bson.D{{"n", 2}}}}, {"ordered", false}},
+			[]string{"db.podcasts.insertMany([{ n: 1 }, { n: 2 }], { ordered: false })"}},
+		{"one statement per update", bson.D{{"update", "episodes"}, {"updates", bson.A{
+			bson.D{{"q", bson.D{{"n", 1}}}, {"u", bson.D{{"$set", bson.D{{"n", 2}}}}}},
+			bson.D{{"q", bson.D{}}, {"u", bson.D{{"$inc", bson.D{{"n", 1}}}}}, {"multi", true}},
+			bson.D{{"q", bson.D{{"n", 3}}}, {"u", bson.D{{"n", 4}}}, {"upsert", true}},
+			bson.D{{"q", bson.D{{"n", 5}}}, {"u", bson.A{bson.D{{"$set", bson.D{{"n", 6}}}}}}},
+		}}},
+			[]string{
+				"db.episodes.updateOne({ n: 1 }, { $set: { n: 2 } })",
+				"db.episodes.updateMany({}, { $inc: { n: 1 } })",
+				"db.episodes.replaceOne({ n: 3 }, { n: 4 }, { upsert: true })",
+				"db.episodes.updateOne({ n: 5 }, [{ $set: { n: 6 } }])",
+			}},
+		{"deletes", bson.D{{"delete", "episodes"}, {"deletes", bson.A{
+			bson.D{{"q", bson.D{{"n", 1}}}, {"limit", 1}},
+			bson.D{{"q", bson.D{}}, {"limit", 0}},
+		}}},
+			[]string{"db.episodes.deleteOne({ n: 1 })", "db.episodes.deleteMany({})"}},
+		{"findOneAndUpdate", bson.D{{"findAndModify", "episodes"}, {"query", bson.D{{"n", 1}}}, {"update", bson.D{{"$inc", bson.D{{"n", 1}}}}}, {"new", true}},
+			[]string{`db.episodes.findOneAndUpdate({ n: 1 }, { $inc: { n: 1 } }, { returnDocument: "after" })`}},
+		{"findOneAndReplace", bson.D{{"findAndModify", "episodes"}, {"query", bson.D{{"n", 1}}}, {"update", bson.D{{"n", 2}}}, {"fields", bson.D{{"n", 1}}}},
+			[]string{"db.episodes.findOneAndReplace({ n: 1 }, { n: 2 }, { projection: { n: 1 } })"}},
+		{"findOneAndDelete", bson.D{{"findAndModify", "episodes"}, {"query", bson.D{{"n", 1}}}, {"remove", true}},
+			[]string{"db.episodes.findOneAndDelete({ n: 1 })"}},
+		{"findOneAndDelete of any document", bson.D{{"findAndModify", "episodes"}, {"query", bson.D{}}, {"remove", true}},
+			[]string{"db.episodes.findOneAndDelete({})"}},
+		{"createIndexes", bson.D{{"createIndexes", "episodes"}, {"indexes", bson.A{
+			bson.D{{"key", bson.D{{"podcast", 1}}}, {"name", "podcast_1"}},
+		}}},
+			[]string{`db.episodes.createIndex({ podcast: 1 }, { name: "podcast_1" })`}},
+		{"dropIndex", bson.D{{"dropIndexes", "episodes"}, {"index", "podcast_1"}},
+			[]string{`db.episodes.dropIndex("podcast_1")`}},
+		{"drop", bson.D{{"drop", "episodes"}}, []string{"db.episodes.drop()"}},
+		{"createCollection", bson.D{{"create", "events"}, {"capped", true}, {"size", 4096}},
+			[]string{`db.createCollection("events", { capped: true, size: 4096 })`}},
+		{"runCommand", bson.D{{"collMod", "events"}, {"validationLevel", "moderate"}, session},
+			[]string{`db.runCommand({ collMod: "events", validationLevel: "moderate" })`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Statements(command(t, test.command), test.command[0].Key)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Statements =\n%q\nwant\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommandMonitor(t *testing.T) {
+	var out bytes.Buffer
+	monitor := New(&out).CommandMonitor()
+	started := func(database string, document bson.D) {
+		monitor.Started(context.Background(), &event.CommandStartedEvent{
+			Command:      command(t, document),
+			DatabaseName: database,
+			CommandName:  document[0].Key,
+		})
+	}
+	started("quickstart", bson.D{{"ping", 1}})
+	started("quickstart", bson.D{{"drop", "a"}})
+	started("quickstart", bson.D{{"drop", "b"}})
+	started("admin", bson.D{{"drop", "c"}})
+	want := "use quickstart\ndb.a.drop()\ndb.b.drop()\nuse admin\ndb.c.drop()\n"
+	if out.String() != want {
+		t.Errorf("printed\n%s\nwant\n%s", out.String(), want)
+	}
+}
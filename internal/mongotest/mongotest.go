@@ -0,0 +1,207 @@
+// Package mongotest gives integration tests a MongoDB deployment to run
+// against: the one at ATLAS_URI when it is set, otherwise a single node
+// replica set started in a container with testcontainers-go. A replica set
+// rather than a standalone server keeps transactions and change streams
+// working. Packages using it start and stop the container in TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		mongotest.Main(m)
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		database := mongotest.Database(t)
+//		...
+//	}
+//
+// Tests that build their own clients, say with a monitor, connect to URI(t)
+// instead.
+//
+// Tests are skipped when ATLAS_URI is not set and Docker is not available.
+package mongotest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Image is the MongoDB image the container runs
+var Image = "mongo:7.0"
+
+var (
+	once      sync.Once
+	container *mongodb.MongoDBContainer
+	client    *mongo.Client
+	uri       string
+	startErr  error
+
+	dockerOnce sync.Once
+	noDocker   string
+)
+
+// Main runs the tests and then stops the container and disconnects the
+// client, if the tests needed them
+func Main(m *testing.M) {
+	code := m.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if client != nil {
+		client.Disconnect(ctx)
+	}
+	if container != nil {
+		if err := container.Terminate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "terminate mongo container: %v\n", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// Client returns a client connected to the test deployment, starting the
+// container on first use
+func Client(t *testing.T) *mongo.Client {
+	t.Helper()
+	start(t)
+	return client
+}
+
+// URI returns the connection string of the test deployment, starting the
+// container on first use
+func URI(t *testing.T) string {
+	t.Helper()
+	start(t)
+	return uri
+}
+
+// start connects to the test deployment once, skipping t when there is none
+func start(t *testing.T) {
+	t.Helper()
+	atlas := os.Getenv("ATLAS_URI")
+	if atlas == "" {
+		dockerOnce.Do(func() { noDocker = dockerProblem() })
+		if noDocker != "" {
+			t.Skip("ATLAS_URI is not set and Docker is not available: " + noDocker)
+		}
+	}
+	once.Do(func() {
+		uri, startErr = resolve(atlas)
+		if startErr == nil {
+			client, startErr = connect(uri)
+		}
+	})
+	if startErr != nil {
+		t.Fatal(startErr)
+	}
+}
+
+// dockerProblem returns why testcontainers cannot reach a Docker daemon, or
+// "" when it can. Finding the daemon panics in some testcontainers versions
+// when there is none, so the panic is recovered as the reason.
+func dockerProblem() (problem string) {
+	defer func() {
+		if r := recover(); r != nil {
+			problem = fmt.Sprint(r)
+		}
+	}()
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err.Error()
+	}
+	defer provider.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = provider.Health(ctx); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// resolve returns atlas or, when it is empty, the address of a new container
+func resolve(atlas string) (_ string, err error) {
+	if atlas != "" {
+		return atlas, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("start mongo container: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	container, err = mongodb.Run(ctx, Image, mongodb.WithReplicaSet("rs"))
+	if err != nil {
+		return "", fmt.Errorf("start mongo container: %w", err)
+	}
+	address, err := container.ConnectionString(ctx)
+	if err != nil {
+		return "", fmt.Errorf("mongo container address: %w", err)
+	}
+	// the replica set knows its member by the container's own address,
+	// which the host may not reach
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("mongo container address: %w", err)
+	}
+	query := parsed.Query()
+	query.Set("directConnection", "true")
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// connect connects to uri and checks that the deployment answers
+func connect(uri string) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	c, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", db.Hosts(uri), err)
+	}
+	if err = c.Ping(ctx, nil); err != nil {
+		c.Disconnect(ctx)
+		return nil, fmt.Errorf("ping %s: %w", db.Hosts(uri), err)
+	}
+	return c, nil
+}
+
+// unsafe matches what database names cannot contain
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Database returns an empty database named after the test, dropped again
+// when the test ends, so tests and subtests can run in parallel
+func Database(t *testing.T) *mongo.Database {
+	t.Helper()
+	name := "test_" + strings.Trim(unsafe.ReplaceAllString(t.Name(), "_"), "_")
+	// database names are limited to 63 bytes; a hash of the whole name
+	// keeps long subtest names sharing a prefix apart
+	if len(name) > 63 {
+		sum := sha256.Sum256([]byte(name))
+		name = name[:54] + "_" + hex.EncodeToString(sum[:4])
+	}
+	database := Client(t).Database(name)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := database.Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := database.Drop(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	return database
+}
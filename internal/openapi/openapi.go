@@ -0,0 +1,287 @@
+// Package openapi is a typed route registry: handlers are registered
+// together with their request and response types, and the registry serves
+// an OpenAPI 3 document built from them at /openapi.json plus a Swagger UI
+// page at /docs.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param documents a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Type        string
+	Required    bool
+}
+
+// Route describes one operation. Request and Response are sample values
+// (usually zero values) of the JSON body types; leave them nil for no body.
+type Route struct {
+	Method      string
+	Path        string
+	Summary     string
+	Tags        []string
+	Query       []Param
+	Request     interface{}
+	Response    interface{}
+	Status      int
+	Errors      []int
+	Description string
+}
+
+// Registry records routes and mounts them on an http.ServeMux
+type Registry struct {
+	Title   string
+	Version string
+
+	mux    *http.ServeMux
+	routes []Route
+}
+
+// schemaBuilder collects the component schemas referenced while building a spec
+type schemaBuilder struct {
+	schemas map[string]interface{}
+	// types records which Go type each component name stands for
+	types map[string]reflect.Type
+}
+
+// New creates a registry that already serves /openapi.json and /docs
+func New(title, version string) *Registry {
+	r := &Registry{Title: title, Version: version, mux: http.NewServeMux()}
+	r.mux.HandleFunc("GET /openapi.json", r.serveSpec)
+	r.mux.HandleFunc("GET /docs", serveDocs)
+	return r
+}
+
+// Handle mounts handler for route.Method and route.Path (Go 1.22 pattern
+// syntax, which matches OpenAPI's {param} syntax) and records the route
+func (r *Registry) Handle(route Route, handler http.HandlerFunc) {
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+	r.routes = append(r.routes, route)
+	r.mux.HandleFunc(route.Method+" "+route.Path, handler)
+}
+
+// ServeHTTP dispatches to the registered handlers
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+var pathParam = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\.*\}`)
+
+// Spec builds the OpenAPI document for every registered route
+func (r *Registry) Spec() map[string]interface{} {
+	b := &schemaBuilder{schemas: map[string]interface{}{}, types: map[string]reflect.Type{}}
+	paths := map[string]map[string]interface{}{}
+	for _, route := range r.routes {
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		var parameters []interface{}
+		for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range route.Query {
+			typ := param.Type
+			if typ == "" {
+				typ = "string"
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name": param.Name, "in": "query", "required": param.Required,
+				"description": param.Description, "schema": map[string]interface{}{"type": typ},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"responses":   b.responses(route),
+		}
+		if route.Description != "" {
+			operation["description"] = route.Description
+		}
+		if len(route.Tags) > 0 {
+			operation["tags"] = route.Tags
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(b.schema(reflect.TypeOf(route.Request))),
+			}
+		}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": r.Title, "version": r.Version},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": b.schemas},
+	}
+}
+
+func (b *schemaBuilder) responses(route Route) map[string]interface{} {
+	responses := map[string]interface{}{}
+	success := map[string]interface{}{"description": http.StatusText(route.Status)}
+	if route.Response != nil {
+		success["content"] = jsonContent(b.schema(reflect.TypeOf(route.Response)))
+	}
+	responses[strconv.Itoa(route.Status)] = success
+	for _, status := range route.Errors {
+		responses[strconv.Itoa(status)] = map[string]interface{}{"description": http.StatusText(status)}
+	}
+	return responses
+}
+
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		part = strings.Trim(part, "{}.")
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema maps a Go type to an OpenAPI schema, registering named structs as
+// components. Types with a custom MarshalJSON over a [12]byte (ObjectIDs)
+// are documented as hex strings.
+func (b *schemaBuilder) schema(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Array && t.Len() == 12 && t.Elem().Kind() == reflect.Uint8 && t.Implements(jsonMarshalerType):
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$", "example": "5e3b37e51c9d4400004117e6"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.structSchema(t)
+	}
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) interface{} {
+	name := b.name(t)
+	if name != "" {
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, seen := b.schemas[name]; seen {
+			return ref
+		}
+		// placeholder first so recursive types terminate
+		b.schemas[name] = map[string]interface{}{}
+	}
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(tag, ",")
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		properties[jsonName] = b.schema(field.Type)
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if name == "" {
+		return schema
+	}
+	b.schemas[name] = schema
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// name returns the component name of a named type: its bare name, or, when
+// a type of another package already has that name, the name qualified with
+// its package, as in dto.Podcast
+func (b *schemaBuilder) name(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	candidates := []string{
+		t.Name(),
+		path.Base(t.PkgPath()) + "." + t.Name(),
+		strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name(),
+	}
+	for _, name := range candidates {
+		if owner, taken := b.types[name]; !taken || owner == t {
+			b.types[name] = t
+			return name
+		}
+	}
+	// only types declared inside functions of one package get this far
+	return candidates[len(candidates)-1]
+}
+
+func (r *Registry) serveSpec(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(r.Spec())
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>API documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func serveDocs(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
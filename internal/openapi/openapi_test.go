@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Cookie shares its name with http.Cookie
+type Cookie struct {
+	Flavor string `json:"flavor"`
+}
+
+type node struct {
+	Name     string  `json:"name"`
+	Children []*node `json:"children,omitempty"`
+	Secret   string  `json:"-"`
+	hidden   string
+}
+
+func TestSchema(t *testing.T) {
+	type anonymous = struct {
+		At time.Time `json:"at"`
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"string", "", map[string]interface{}{"type": "string"}},
+		{"int64", int64(0), map[string]interface{}{"type": "integer", "format": "int64"}},
+		{"slice", []float64{}, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}}},
+		{"map", map[string]bool{}, map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "boolean"}}},
+		{"unnamed struct", anonymous{}, map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+			"at": map[string]interface{}{"type": "string", "format": "date-time"},
+		}}},
+		{"named struct", &Cookie{}, map[string]interface{}{"$ref": "#/components/schemas/Cookie"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &schemaBuilder{schemas: map[string]interface{}{}, types: map[string]reflect.Type{}}
+			if got := b.schema(reflect.TypeOf(test.value)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("schema = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	b := &schemaBuilder{schemas: map[string]interface{}{}, types: map[string]reflect.Type{}}
+	b.schema(reflect.TypeOf(node{}))
+	want := map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+		"name":     map[string]interface{}{"type": "string"},
+		"children": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/node"}},
+	}}
+	if !reflect.DeepEqual(b.schemas["node"], want) {
+		t.Errorf("node schema = %v, want %v", b.schemas["node"], want)
+	}
+}
+
+func TestSameNameInTwoPackages(t *testing.T) {
+	r := New("test", "1")
+	handler := func(http.ResponseWriter, *http.Request) {}
+	r.Handle(Route{Method: "GET", Path: "/cookies/{id}", Response: Cookie{}}, handler)
+	r.Handle(Route{Method: "POST", Path: "/cookies", Request: http.Cookie{}, Response: Cookie{}}, handler)
+
+	spec := r.Spec()
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if len(schemas) != 2 {
+		t.Fatalf("schemas %v, want one per Cookie type", keys(schemas))
+	}
+	flavor := schemas["Cookie"].(map[string]interface{})["properties"].(map[string]interface{})
+	if _, ok := flavor["flavor"]; !ok {
+		t.Errorf("Cookie = %v, want the test package's Cookie", schemas["Cookie"])
+	}
+	standard := schemas["http.Cookie"].(map[string]interface{})["properties"].(map[string]interface{})
+	if _, ok := standard["Domain"]; !ok {
+		t.Errorf("http.Cookie = %v, want net/http's Cookie", schemas["http.Cookie"])
+	}
+	post := spec["paths"].(map[string]map[string]interface{})["/cookies"]["post"].(map[string]interface{})
+	body := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})
+	if want := "#/components/schemas/http.Cookie"; body["schema"].(map[string]interface{})["$ref"] != want {
+		t.Errorf("request body = %v, want a $ref to %s", body["schema"], want)
+	}
+}
+
+func keys(m map[string]interface{}) []string {
+	var all []string
+	for key := range m {
+		all = append(all, key)
+	}
+	return all
+}
+
+func TestServeSpec(t *testing.T) {
+	r := New("podcasts", "1.0")
+	r.Handle(Route{Method: "GET", Path: "/podcasts/{id}", Summary: "Get a podcast", Response: Cookie{}, Errors: []int{http.StatusNotFound}},
+		func(w http.ResponseWriter, req *http.Request) {})
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest("GET", "/openapi.json", nil))
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string                     `json:"operationId"`
+			Parameters  []map[string]interface{}   `json:"parameters"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	get := spec.Paths["/podcasts/{id}"]["get"]
+	if get.OperationID != "getPodcastsId" || len(get.Parameters) != 1 || get.Parameters[0]["in"] != "path" {
+		t.Errorf("operation = %+v, want getPodcastsId with the id path parameter", get)
+	}
+	if _, ok := get.Responses["404"]; !ok || len(get.Responses) != 2 {
+		t.Errorf("responses = %v, want 200 and 404", get.Responses)
+	}
+}
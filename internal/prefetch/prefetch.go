@@ -0,0 +1,131 @@
+// Package prefetch wraps a cursor so the next batch is fetched with getMore
+// in a background goroutine while the caller is still working through the
+// current one, hiding server round trips behind slow consumers.
+package prefetch
+
+import (
+	"context"
+	"sync"
+)
+
+// Cursor is the subset of *mongo.Cursor the iterator drives
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	RemainingBatchLength() int
+	Err() error
+	Close(ctx context.Context) error
+}
+
+type batch[T any] struct {
+	documents []T
+	err       error
+}
+
+// Iterator yields decoded documents of type T, keeping up to depth batches
+// fetched ahead of the consumer
+type Iterator[T any] struct {
+	batches chan batch[T]
+	cancel  context.CancelFunc
+	done    chan struct{}
+	cursor  Cursor
+
+	current []T
+	pos     int
+	err     error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New starts prefetching from cursor. The iterator owns the cursor from now
+// on: do not use it directly, and call Close when done.
+func New[T any](ctx context.Context, cursor Cursor, depth int) *Iterator[T] {
+	if depth < 1 {
+		depth = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator[T]{
+		batches: make(chan batch[T], depth),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		cursor:  cursor,
+	}
+	go it.fetch(ctx)
+	return it
+}
+
+// fetch decodes one server batch at a time. cursor.Next only issues a getMore
+// once RemainingBatchLength reaches zero, so each loop iteration maps to one
+// round trip.
+func (it *Iterator[T]) fetch(ctx context.Context) {
+	defer close(it.done)
+	defer close(it.batches)
+	for {
+		if !it.cursor.Next(ctx) {
+			if err := it.cursor.Err(); err != nil {
+				it.send(ctx, batch[T]{err: err})
+			}
+			return
+		}
+		documents := make([]T, 0, it.cursor.RemainingBatchLength()+1)
+		for {
+			var document T
+			if err := it.cursor.Decode(&document); err != nil {
+				it.send(ctx, batch[T]{documents: documents, err: err})
+				return
+			}
+			documents = append(documents, document)
+			if it.cursor.RemainingBatchLength() == 0 || !it.cursor.Next(ctx) {
+				break
+			}
+		}
+		if !it.send(ctx, batch[T]{documents: documents}) {
+			return
+		}
+	}
+}
+
+func (it *Iterator[T]) send(ctx context.Context, b batch[T]) bool {
+	select {
+	case it.batches <- b:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Next returns the next document, or false once the cursor is exhausted or
+// an error occurred; check Err afterwards
+func (it *Iterator[T]) Next() (T, bool) {
+	var zero T
+	for it.pos >= len(it.current) {
+		if it.err != nil {
+			return zero, false
+		}
+		b, ok := <-it.batches
+		if !ok {
+			return zero, false
+		}
+		it.current, it.pos, it.err = b.documents, 0, b.err
+	}
+	document := it.current[it.pos]
+	it.current[it.pos] = zero
+	it.pos++
+	return document, true
+}
+
+// Err returns the first error encountered while fetching or decoding
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Close stops prefetching and closes the underlying cursor
+func (it *Iterator[T]) Close(ctx context.Context) error {
+	it.closeOnce.Do(func() {
+		it.cancel()
+		<-it.done
+		it.closeErr = it.cursor.Close(ctx)
+	})
+	return it.closeErr
+}
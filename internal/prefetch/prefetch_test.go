@@ -0,0 +1,156 @@
+package prefetch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type item struct {
+	N int `bson:"n"`
+}
+
+// fakeCursor serves pre-built batches, sleeping for latency on every
+// simulated getMore like a cursor talking to a remote server
+type fakeCursor struct {
+	batches [][]bson.Raw
+	latency time.Duration
+	current []bson.Raw
+	pos     int
+	err     error
+	closed  bool
+}
+
+func newFakeCursor(total, batchSize int, latency time.Duration) *fakeCursor {
+	c := &fakeCursor{latency: latency}
+	for start := 0; start < total; start += batchSize {
+		var b []bson.Raw
+		for n := start; n < start+batchSize && n < total; n++ {
+			raw, _ := bson.Marshal(item{N: n})
+			b = append(b, raw)
+		}
+		c.batches = append(c.batches, b)
+	}
+	return c
+}
+
+func (c *fakeCursor) Next(ctx context.Context) bool {
+	if c.pos+1 < len(c.current) {
+		c.pos++
+		return true
+	}
+	if len(c.batches) == 0 || c.err != nil {
+		return false
+	}
+	select {
+	case <-time.After(c.latency):
+	case <-ctx.Done():
+		c.err = ctx.Err()
+		return false
+	}
+	c.current, c.batches, c.pos = c.batches[0], c.batches[1:], 0
+	return true
+}
+
+func (c *fakeCursor) Decode(val interface{}) error    { return bson.Unmarshal(c.current[c.pos], val) }
+func (c *fakeCursor) RemainingBatchLength() int       { return len(c.current) - c.pos - 1 }
+func (c *fakeCursor) Err() error                      { return c.err }
+func (c *fakeCursor) Close(ctx context.Context) error { c.closed = true; return nil }
+
+func TestIteratorYieldsEveryDocumentInOrder(t *testing.T) {
+	cursor := newFakeCursor(1050, 100, 0)
+	it := New[item](context.Background(), cursor, 2)
+	defer it.Close(context.Background())
+
+	want := 0
+	for doc, ok := it.Next(); ok; doc, ok = it.Next() {
+		if doc.N != want {
+			t.Fatalf("got document %d, want %d", doc.N, want)
+		}
+		want++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want != 1050 {
+		t.Fatalf("got %d documents, want 1050", want)
+	}
+}
+
+func TestIteratorCloseStopsPrefetching(t *testing.T) {
+	cursor := newFakeCursor(1000, 10, time.Millisecond)
+	it := New[item](context.Background(), cursor, 1)
+	if _, ok := it.Next(); !ok {
+		t.Fatal("expected a first document")
+	}
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !cursor.closed {
+		t.Fatal("underlying cursor was not closed")
+	}
+	if len(cursor.batches) == 0 {
+		t.Fatal("iterator kept fetching after Close")
+	}
+}
+
+func TestIteratorReportsCursorErrors(t *testing.T) {
+	cursor := newFakeCursor(10, 5, 0)
+	cursor.err = errors.New("boom")
+	it := New[item](context.Background(), cursor, 1)
+	defer it.Close(context.Background())
+	if _, ok := it.Next(); ok {
+		t.Fatal("expected no documents")
+	}
+	if it.Err() == nil {
+		t.Fatal("expected the cursor error")
+	}
+}
+
+// The benchmarks model a slow consumer (50µs per document) reading
+// 100-document batches from a server 2ms away. Without prefetching every
+// getMore round trip adds to the total; with it the round trips overlap
+// with processing.
+const (
+	benchDocuments = 2000
+	benchBatchSize = 100
+	benchLatency   = 2 * time.Millisecond
+	benchWork      = 50 * time.Microsecond
+)
+
+func consume() {
+	deadline := time.Now().Add(benchWork)
+	for time.Now().Before(deadline) {
+	}
+}
+
+func BenchmarkPlainCursor(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cursor := newFakeCursor(benchDocuments, benchBatchSize, benchLatency)
+		ctx := context.Background()
+		for cursor.Next(ctx) {
+			var doc item
+			if err := cursor.Decode(&doc); err != nil {
+				b.Fatal(err)
+			}
+			consume()
+		}
+	}
+}
+
+func BenchmarkPrefetchIterator(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cursor := newFakeCursor(benchDocuments, benchBatchSize, benchLatency)
+		it := New[item](context.Background(), cursor, 2)
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+			consume()
+		}
+		if err := it.Err(); err != nil {
+			b.Fatal(err)
+		}
+		it.Close(context.Background())
+	}
+}
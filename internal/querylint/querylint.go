@@ -0,0 +1,233 @@
+// Package querylint warns about queries that scan a whole collection. It
+// watches commands through a CommandMonitor and, the first time a query shape
+// is seen, explains it; a COLLSCAN over a collection larger than the
+// threshold is logged with the shape so the missing index is easy to spot.
+//
+// Explaining every new shape costs an extra round trip, so enable it only
+// while developing, for example with QUICKSTART_QUERYLINT=1.
+package querylint
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Enabled reports whether QUICKSTART_QUERYLINT is set to a non-empty value
+func Enabled() bool {
+	return os.Getenv("QUICKSTART_QUERYLINT") != ""
+}
+
+// linted are the commands that can be explained and take a query
+var linted = map[string]bool{
+	"find":          true,
+	"aggregate":     true,
+	"count":         true,
+	"distinct":      true,
+	"findAndModify": true,
+	"update":        true,
+	"delete":        true,
+}
+
+// skipped are command fields that explain rejects or that only carry session state
+var skipped = map[string]bool{
+	"lsid":             true,
+	"txnNumber":        true,
+	"startTransaction": true,
+	"autocommit":       true,
+	"readConcern":      true,
+	"writeConcern":     true,
+	"$db":              true,
+	"$clusterTime":     true,
+	"$readPreference":  true,
+	"apiVersion":       true,
+}
+
+type internalKey struct{}
+
+// Linter explains new query shapes and logs collection scans
+type Linter struct {
+	Threshold int64
+	Logger    *log.Logger
+
+	client *mongo.Client
+	seen   sync.Map
+	wg     sync.WaitGroup
+}
+
+// New returns a Linter warning about scans of collections holding at least
+// threshold documents
+func New(threshold int64) *Linter {
+	return &Linter{Threshold: threshold, Logger: log.Default()}
+}
+
+// Attach sets the client used to run explain, usually the monitored client itself
+func (l *Linter) Attach(client *mongo.Client) {
+	l.client = client
+}
+
+// Wait blocks until every pending explain has finished, so short-lived
+// programs do not exit before their warnings are logged
+func (l *Linter) Wait() {
+	l.wg.Wait()
+}
+
+// CommandMonitor returns the monitor to pass to options.Client().SetMonitor
+func (l *Linter) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			if l.client == nil || !linted[started.CommandName] || ctx.Value(internalKey{}) != nil {
+				return
+			}
+			collection, ok := started.Command.Lookup(started.CommandName).StringValueOK()
+			if !ok {
+				return
+			}
+			shape := started.DatabaseName + "." + collection + " " + Shape(started.Command, started.CommandName)
+			if _, loaded := l.seen.LoadOrStore(shape, struct{}{}); loaded {
+				return
+			}
+			// the event's command is only valid during the callback
+			command := explainable(append(bson.Raw(nil), started.Command...), started.CommandName)
+			l.wg.Add(1)
+			go func() {
+				defer l.wg.Done()
+				l.check(started.DatabaseName, collection, shape, command)
+			}()
+		},
+	}
+}
+
+// check explains the command and logs a warning for a large collection scan
+func (l *Linter) check(databaseName, collection, shape string, command bson.D) {
+	ctx := context.WithValue(context.Background(), internalKey{}, true)
+	database := l.client.Database(databaseName)
+	var explain bson.Raw
+	err := database.RunCommand(ctx, bson.D{{"explain", command}, {"verbosity", "queryPlanner"}}).Decode(&explain)
+	if err != nil {
+		l.Logger.Printf("querylint: explain %s: %v", shape, err)
+		return
+	}
+	planner, err := explain.LookupErr("queryPlanner")
+	if err != nil {
+		// aggregations that cannot use the query planner report stages instead
+		planner, err = explain.LookupErr("stages")
+		if err != nil {
+			return
+		}
+	}
+	if !hasStage(planner, "COLLSCAN") {
+		return
+	}
+	count, err := database.Collection(collection).EstimatedDocumentCount(ctx)
+	if err != nil || count < l.Threshold {
+		return
+	}
+	l.Logger.Printf("querylint: COLLSCAN over %d documents in %s.%s, consider an index for %s", count, databaseName, collection, shape)
+}
+
+// explainable copies the command without session fields and, for update and
+// delete, keeps only the first statement since explain accepts just one
+func explainable(command bson.Raw, name string) bson.D {
+	elements, _ := command.Elements()
+	explained := make(bson.D, 0, len(elements))
+	for _, element := range elements {
+		key := element.Key()
+		if skipped[key] {
+			continue
+		}
+		value := element.Value()
+		if (name == "update" && key == "updates") || (name == "delete" && key == "deletes") {
+			if first, err := value.Array().IndexErr(0); err == nil {
+				explained = append(explained, bson.E{key, bson.A{first.Value()}})
+			}
+			continue
+		}
+		explained = append(explained, bson.E{key, value})
+	}
+	return explained
+}
+
+// Shape describes the query of a command with its values replaced by their
+// BSON type, so queries that differ only in values share a shape
+func Shape(command bson.Raw, name string) string {
+	var fields []string
+	switch name {
+	case "find":
+		fields = []string{"filter", "sort"}
+	case "aggregate":
+		fields = []string{"pipeline"}
+	case "count", "distinct", "findAndModify":
+		fields = []string{"query", "key", "sort"}
+	case "update":
+		fields = []string{"updates.0.q"}
+	case "delete":
+		fields = []string{"deletes.0.q"}
+	}
+	shape := bson.D{}
+	for _, field := range fields {
+		if value, err := command.LookupErr(strings.Split(field, ".")...); err == nil {
+			shape = append(shape, bson.E{field, strip(value)})
+		}
+	}
+	return fmt.Sprintf("%s %v", name, shape)
+}
+
+// strip keeps the structure of documents and arrays and replaces every other
+// value with its type name. Field names and operators are what make a shape,
+// so an $in list of scalars counts as one shape whatever its length.
+func strip(value bson.RawValue) interface{} {
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		document := make(bson.D, len(elements))
+		for i, element := range elements {
+			document[i] = bson.E{element.Key(), strip(element.Value())}
+		}
+		return document
+	case bson.TypeArray:
+		values, _ := value.Array().Values()
+		array := make(bson.A, 0, len(values))
+		for _, item := range values {
+			if item.Type == bson.TypeEmbeddedDocument || item.Type == bson.TypeArray {
+				array = append(array, strip(item))
+			}
+		}
+		if len(array) == 0 {
+			return "array"
+		}
+		return array
+	}
+	return value.Type.String()
+}
+
+func hasStage(value bson.RawValue, stage string) bool {
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		document := value.Document()
+		if name, ok := document.Lookup("stage").StringValueOK(); ok && name == stage {
+			return true
+		}
+		elements, _ := document.Elements()
+		for _, element := range elements {
+			if hasStage(element.Value(), stage) {
+				return true
+			}
+		}
+	case bson.TypeArray:
+		values, _ := value.Array().Values()
+		for _, item := range values {
+			if hasStage(item, stage) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package querylint
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func raw(t *testing.T, document interface{}) bson.Raw {
+	t.Helper()
+	data, err := bson.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestShape(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  bson.D
+		equal bool
+	}{
+		{"values differ",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"title", "Go"}}}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"title", "Rust"}}}}, true},
+		{"types differ",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"duration", 25}}}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"duration", "25"}}}}, false},
+		{"fields differ",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"title", "Go"}}}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"podcast", "Go"}}}}, false},
+		{"$in lists of any length",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"tags", bson.D{{"$in", bson.A{"a"}}}}}}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{{"tags", bson.D{{"$in", bson.A{"a", "b", "c"}}}}}}}, true},
+		{"sort is part of the shape",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}, {"sort", bson.D{{"duration", 1}}}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}, {"sort", bson.D{{"title", 1}}}}, false},
+		{"limit is not",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}, {"limit", 1}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}, {"limit", 5}}, true},
+		{"pipelines",
+			bson.D{{"aggregate", "episodes"}, {"pipeline", bson.A{bson.D{{"$match", bson.D{{"podcast", 1}}}}}}},
+			bson.D{{"aggregate", "episodes"}, {"pipeline", bson.A{bson.D{{"$match", bson.D{{"podcast", 2}}}}}}}, true},
+		{"first update statement",
+			bson.D{{"update", "episodes"}, {"updates", bson.A{bson.D{{"q", bson.D{{"n", 1}}}, {"u", bson.D{}}}}}},
+			bson.D{{"update", "episodes"}, {"updates", bson.A{bson.D{{"q", bson.D{{"m", 1}}}, {"u", bson.D{}}}}}}, false},
+		{"delete statements with equal queries",
+			bson.D{{"delete", "episodes"}, {"deletes", bson.A{bson.D{{"q", bson.D{{"n", 1}}}, {"limit", 1}}}}},
+			bson.D{{"delete", "episodes"}, {"deletes", bson.A{bson.D{{"q", bson.D{{"n", 2}}}, {"limit", 0}}}}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := Shape(raw(t, test.a), test.a[0].Key)
+			b := Shape(raw(t, test.b), test.b[0].Key)
+			if (a == b) != test.equal {
+				t.Errorf("shapes %s and %s, want equal %v", a, b, test.equal)
+			}
+		})
+	}
+}
+
+func TestExplainable(t *testing.T) {
+	tests := []struct {
+		name    string
+		command bson.D
+		want    bson.D
+	}{
+		{"session state dropped",
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}, {"lsid", bson.D{{"id", 1}}}, {"txnNumber", int64(1)}, {"$db", "quickstart"}},
+			bson.D{{"find", "episodes"}, {"filter", bson.D{}}}},
+		{"first update only",
+			bson.D{{"update", "episodes"}, {"updates", bson.A{bson.D{{"q", bson.D{{"n", 1}}}}, bson.D{{"q", bson.D{{"n", 2}}}}}}, {"writeConcern", bson.D{{"w", 1}}}},
+			bson.D{{"update", "episodes"}, {"updates", bson.A{bson.D{{"q", bson.D{{"n", 1}}}}}}}},
+		{"first delete only",
+			bson.D{{"delete", "episodes"}, {"deletes", bson.A{bson.D{{"q", bson.D{{"n", 1}}}}, bson.D{{"q", bson.D{{"n", 2}}}}}}},
+			bson.D{{"delete", "episodes"}, {"deletes", bson.A{bson.D{{"q", bson.D{{"n", 1}}}}}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := raw(t, explainable(raw(t, test.command), test.command[0].Key))
+			if want := raw(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("explainable = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHasStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		planner bson.D
+		want    bool
+	}{
+		{"index scan", bson.D{{"winningPlan", bson.D{{"stage", "FETCH"}, {"inputStage", bson.D{{"stage", "IXSCAN"}}}}}}, false},
+		{"collection scan", bson.D{{"winningPlan", bson.D{{"stage", "COLLSCAN"}}}}, true},
+		{"nested in a stage", bson.D{{"winningPlan", bson.D{{"stage", "SORT"}, {"inputStage", bson.D{{"stage", "COLLSCAN"}}}}}}, true},
+		{"in an array", bson.D{{"winningPlan", bson.D{{"stage", "OR"}, {"inputStages", bson.A{
+			bson.D{{"stage", "IXSCAN"}}, bson.D{{"stage", "COLLSCAN"}},
+		}}}}}, true},
+		{"name in a value is not a stage", bson.D{{"parsedQuery", bson.D{{"title", bson.D{{"$eq", "COLLSCAN"}}}}}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: raw(t, test.planner)}
+			if got := hasStage(value, "COLLSCAN"); got != test.want {
+				t.Errorf("hasStage = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
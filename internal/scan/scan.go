@@ -0,0 +1,240 @@
+// Package scan reads a large collection concurrently by splitting it into
+// _id ranges whose bounds are picked from a random sample of the collection.
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Range is a half-open [Min, Max) interval of _id values. A nil bound
+// means the range is unbounded on that side. Both bounds must be of one
+// type, since a query only compares _id values of the bound's type.
+type Range struct {
+	Min interface{}
+	Max interface{}
+}
+
+// Filter returns the query selecting the documents inside the range. A
+// range with only Max also holds the _ids of every other type, so the ranges
+// Split returns cover _ids of any type.
+func (r Range) Filter() bson.D {
+	if r.Min == nil && r.Max != nil {
+		return bson.D{{"_id", bson.D{{"$not", bson.D{{"$gte", r.Max}}}}}}
+	}
+	bounds := bson.D{}
+	if r.Min != nil {
+		bounds = append(bounds, bson.E{"$gte", r.Min})
+	}
+	if r.Max != nil {
+		bounds = append(bounds, bson.E{"$lt", r.Max})
+	}
+	if len(bounds) == 0 {
+		return bson.D{}
+	}
+	return bson.D{{"_id", bounds}}
+}
+
+// ErrMixedBounds is returned for ranges whose bounds differ in type
+var ErrMixedBounds = errors.New("range bounds are of different types")
+
+// bracket returns the type v is compared as: numbers of every type compare
+// with each other, and so do strings and symbols
+func bracket(v interface{}) (bsontype.Type, error) {
+	t, _, err := bson.MarshalValue(v)
+	if err != nil {
+		return 0, err
+	}
+	switch t {
+	case bsontype.Int32, bsontype.Int64, bsontype.Decimal128:
+		return bsontype.Double, nil
+	case bsontype.Symbol:
+		return bsontype.String, nil
+	}
+	return t, nil
+}
+
+// check returns ErrMixedBounds unless Min and Max compare as one type
+func (r Range) check() error {
+	if r.Min == nil || r.Max == nil {
+		return nil
+	}
+	lower, err := bracket(r.Min)
+	if err != nil {
+		return err
+	}
+	upper, err := bracket(r.Max)
+	if err != nil {
+		return err
+	}
+	if lower != upper {
+		return fmt.Errorf("%w: %v and %v", ErrMixedBounds, r.Min, r.Max)
+	}
+	return nil
+}
+
+// Split samples the collection and returns up to n contiguous ranges that
+// together cover every _id. Skewed or tiny collections may produce fewer
+// ranges, and a sample holding _ids of several types produces one.
+func Split(ctx context.Context, collection *mongo.Collection, n int) ([]Range, error) {
+	if n <= 1 {
+		return []Range{{}}, nil
+	}
+	sampleStage := bson.D{{"$sample", bson.D{{"size", n * 20}}}}
+	bucketStage := bson.D{{"$bucketAuto", bson.D{{"groupBy", "$_id"}, {"buckets", n}}}}
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{sampleStage, bucketStage})
+	if err != nil {
+		return nil, err
+	}
+	var buckets []struct {
+		ID struct {
+			Min interface{} `bson:"min"`
+		} `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+	if len(buckets) <= 1 {
+		return []Range{{}}, nil
+	}
+	// ranges between bounds of different types would match nothing
+	first, err := bracket(buckets[1].ID.Min)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets[2:] {
+		if t, err := bracket(bucket.ID.Min); err != nil || t != first {
+			return []Range{{}}, err
+		}
+	}
+	// The first bucket starts at the smallest sampled _id, which is not
+	// necessarily the smallest _id in the collection, so it stays unbounded.
+	ranges := make([]Range, 0, len(buckets))
+	var lower interface{}
+	for _, bucket := range buckets[1:] {
+		ranges = append(ranges, Range{Min: lower, Max: bucket.ID.Min})
+		lower = bucket.ID.Min
+	}
+	return append(ranges, Range{Min: lower}), nil
+}
+
+// Progress is reported while a range is being scanned and once it finishes
+type Progress struct {
+	Range   int
+	Scanned int64
+	Total   int64
+	Done    bool
+	Err     error
+}
+
+// Options configures a parallel scan
+type Options struct {
+	// Workers is the number of ranges scanned concurrently
+	Workers int
+	// Filter is combined with each range's _id bounds
+	Filter bson.D
+	// Projection limits the fields returned to the process function
+	Projection bson.D
+	BatchSize  int32
+	// ReportEvery is the number of documents between progress reports per range
+	ReportEvery int64
+	OnProgress  func(Progress)
+}
+
+// Collection scans every matching document of the collection with
+// opts.Workers concurrent cursors, calling process for each one. process
+// must be safe for concurrent use. Errors from every range are returned joined.
+func Collection(ctx context.Context, collection *mongo.Collection, opts Options, process func(ctx context.Context, document bson.Raw) error) (int64, error) {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	ranges, err := Split(ctx, collection, opts.Workers)
+	if err != nil {
+		return 0, fmt.Errorf("splitting %s: %w", collection.Name(), err)
+	}
+	return Ranges(ctx, collection, ranges, opts, process)
+}
+
+// Ranges scans pre-computed ranges concurrently, one goroutine per range.
+// The document passed to process is only valid until process returns.
+func Ranges(ctx context.Context, collection *mongo.Collection, ranges []Range, opts Options, process func(ctx context.Context, document bson.Raw) error) (int64, error) {
+	for i, r := range ranges {
+		if err := r.check(); err != nil {
+			return 0, fmt.Errorf("range %d: %w", i, err)
+		}
+	}
+	if opts.ReportEvery < 1 {
+		opts.ReportEvery = 1000
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var total atomic.Int64
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		go func(index int, r Range) {
+			defer wg.Done()
+			scanned, err := scanRange(ctx, collection, r, opts, process, func(scanned int64) {
+				if opts.OnProgress != nil {
+					opts.OnProgress(Progress{Range: index, Scanned: scanned, Total: total.Add(opts.ReportEvery)})
+				}
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("range %d: %w", index, err))
+				mu.Unlock()
+				// stop the other ranges, the scan is incomplete anyway
+				cancel()
+			}
+			if opts.OnProgress != nil {
+				opts.OnProgress(Progress{Range: index, Scanned: scanned, Total: total.Add(scanned % opts.ReportEvery), Done: true, Err: err})
+			} else {
+				total.Add(scanned % opts.ReportEvery)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	return total.Load(), errors.Join(errs...)
+}
+
+func scanRange(ctx context.Context, collection *mongo.Collection, r Range, opts Options, process func(context.Context, bson.Raw) error, report func(int64)) (int64, error) {
+	filter := r.Filter()
+	if len(opts.Filter) > 0 {
+		filter = bson.D{{"$and", bson.A{opts.Filter, filter}}}
+	}
+	findOptions := options.Find().SetSort(bson.D{{"_id", 1}}).SetHint(bson.D{{"_id", 1}})
+	if opts.Projection != nil {
+		findOptions.SetProjection(opts.Projection)
+	}
+	if opts.BatchSize > 0 {
+		findOptions.SetBatchSize(opts.BatchSize)
+	}
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	var scanned int64
+	for cursor.Next(ctx) {
+		if err = process(ctx, cursor.Current); err != nil {
+			return scanned, err
+		}
+		scanned++
+		if scanned%opts.ReportEvery == 0 {
+			report(scanned)
+		}
+	}
+	return scanned, cursor.Err()
+}
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		want bson.D
+	}{
+		{"unbounded", Range{}, bson.D{}},
+		{"below", Range{Max: 10}, bson.D{{"_id", bson.D{{"$not", bson.D{{"$gte", 10}}}}}}},
+		{"between", Range{Min: 10, Max: 20}, bson.D{{"_id", bson.D{{"$gte", 10}, {"$lt", 20}}}}},
+		{"above", Range{Min: 20}, bson.D{{"_id", bson.D{{"$gte", 20}}}}},
+	}
+	for _, test := range tests {
+		if got := test.r.Filter(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Filter = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMixedBounds(t *testing.T) {
+	tests := []struct {
+		r     Range
+		mixed bool
+	}{
+		{Range{Min: int32(1), Max: int64(5)}, false},
+		{Range{Min: 1.5, Max: primitive.NewDecimal128(0, 5)}, false},
+		{Range{Min: "a", Max: primitive.Symbol("b")}, false},
+		{Range{Min: 1, Max: "a"}, true},
+		{Range{Min: "a", Max: primitive.NewObjectID()}, true},
+		{Range{Max: "a"}, false},
+	}
+	for _, test := range tests {
+		if err := test.r.check(); errors.Is(err, ErrMixedBounds) != test.mixed {
+			t.Errorf("check(%v) = %v, want mixed %v", test.r, err, test.mixed)
+		}
+	}
+}
+
+func TestCollectionWithMixedIDs(t *testing.T) {
+	ctx := context.Background()
+	collection := mongotest.Database(t).Collection("items")
+	// mostly ObjectIds, with a few numbers, strings and dates that the
+	// sample may miss
+	var documents []interface{}
+	for i := 0; i < 500; i++ {
+		documents = append(documents, bson.D{{"_id", primitive.NewObjectID()}})
+	}
+	documents = append(documents,
+		bson.D{{"_id", 1}}, bson.D{{"_id", 2.5}}, bson.D{{"_id", "a"}}, bson.D{{"_id", "z"}},
+		bson.D{{"_id", primitive.NewDateTimeFromTime(time.Now())}},
+	)
+	if _, err := collection.InsertMany(ctx, documents); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	total, err := Collection(ctx, collection, Options{Workers: 4}, func(ctx context.Context, document bson.Raw) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[document.Lookup("_id").String()]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != int64(len(documents)) || len(seen) != len(documents) {
+		t.Errorf("scanned %d documents, %d distinct, want each of %d once", total, len(seen), len(documents))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("%s scanned %d times", id, n)
+		}
+	}
+
+	_, err = Ranges(ctx, collection, []Range{{Max: 1}, {Min: 1, Max: "a"}, {Min: "a"}}, Options{}, func(context.Context, bson.Raw) error {
+		return nil
+	})
+	if !errors.Is(err, ErrMixedBounds) {
+		t.Errorf("Ranges = %v, want ErrMixedBounds", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package shutdown stops the examples cleanly on SIGINT or SIGTERM. Start
+// returns a context that is canceled on the first signal, so every
+// operation made with it returns; Run then closes what was registered,
+// newest first, within Timeout:
+//
+//	ctx, down := shutdown.Start(context.Background())
+//	defer down.Run()
+//	client, err := db.Connect(ctx)
+//	...
+//	down.Client(client)
+//
+// A second signal exits at once, for cleanups that hang.
+//
+// Examples written as run(ctx, down) error leave all of that, and the exit
+// code, to Main:
+//
+//	func main() {
+//		flag.Parse()
+//		shutdown.Main(run)
+//	}
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Timeout bounds the time Run spends on all closers together
+var Timeout = 10 * time.Second
+
+// Shutdown holds the closers to run when the program ends
+type Shutdown struct {
+	cancel context.CancelFunc
+	stop   func()
+
+	mu      sync.Mutex
+	closers []closer
+	signal  os.Signal
+	once    sync.Once
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Start returns a copy of parent that is canceled on SIGINT or SIGTERM, and
+// the Shutdown to register closers with. Call Run, usually deferred, before
+// main returns.
+func Start(parent context.Context) (context.Context, *Shutdown) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	s := &Shutdown{cancel: cancel}
+	s.stop = func() {
+		signal.Stop(signals)
+		close(done)
+	}
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("received %v, shutting down (repeat to force)", sig)
+			s.mu.Lock()
+			s.signal = sig
+			s.mu.Unlock()
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			log.Print("forced shutdown")
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	return ctx, s
+}
+
+// Close registers a function to run on shutdown with a context bounded by
+// Timeout
+func (s *Shutdown) Close(name string, close func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, closer{name, close})
+}
+
+// Client disconnects client on shutdown. Register it before the cursors
+// and streams using it, so it runs after they are closed.
+func (s *Shutdown) Client(client *mongo.Client) {
+	s.Close("client", client.Disconnect)
+}
+
+// Cursor closes cursor on shutdown, releasing it on the server instead of
+// leaving it open until it times out. Closing a closed cursor is harmless.
+func (s *Shutdown) Cursor(cursor *mongo.Cursor) {
+	s.Close("cursor", cursor.Close)
+}
+
+// Stream closes a change stream on shutdown, which also ends a Next call
+// blocked on it
+func (s *Shutdown) Stream(stream *mongo.ChangeStream) {
+	s.Close("change stream", stream.Close)
+}
+
+// Run cancels the context returned by Start, then runs the closers in the
+// reverse order of registration within Timeout and stops listening for
+// signals. Only the first call does anything.
+func (s *Shutdown) Run() {
+	s.once.Do(func() {
+		s.cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+		s.mu.Lock()
+		closers := s.closers
+		s.mu.Unlock()
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].close(ctx); err != nil {
+				log.Printf("closing %s: %v", closers[i].name, err)
+			}
+		}
+		s.stop()
+	})
+}
+
+// Signal returns the signal that started the shutdown, or nil
+func (s *Shutdown) Signal() os.Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signal
+}
+
+// ErrUsage reports invalid arguments. Wrap it to say what was wrong:
+//
+//	return fmt.Errorf("%w: unknown command %q", shutdown.ErrUsage, flag.Arg(0))
+var ErrUsage = errors.New("usage")
+
+// Main calls run with the context and Shutdown from Start, runs the closers
+// and exits with the status a shell expects: 2 after printing the usage for
+// ErrUsage, 128 plus the signal number when a signal made run fail, and 1
+// after printing any other error with the advice from db.Explain. When run
+// succeeds Main returns, so tests can call main.
+func Main(run func(ctx context.Context, down *Shutdown) error) {
+	ctx, down := Start(context.Background())
+	err := run(ctx, down)
+	down.Run()
+	if err == nil {
+		return
+	}
+	if sig, ok := down.Signal().(syscall.Signal); ok {
+		os.Exit(128 + int(sig))
+	}
+	if errors.Is(err, ErrUsage) {
+		if err != ErrUsage {
+			fmt.Fprintln(flag.CommandLine.Output(), err)
+		}
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.Print(err)
+	if advice := db.Explain(err); advice != "" {
+		log.Print("hint: ", advice)
+	}
+	os.Exit(1)
+}
+
+// Serve runs server until ctx is canceled, then stops accepting requests
+// and waits up to Timeout for the ones in flight, which keep their own
+// contexts so they can finish their database operations
+func Serve(ctx context.Context, server *http.Server) error {
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// TestMainExitsOnSignal runs Main in a child process that interrupts
+// itself, since Main exits the process
+func TestMainExitsOnSignal(t *testing.T) {
+	if os.Getenv("SHUTDOWN_TEST_CHILD") == "1" {
+		Main(func(ctx context.Context, down *Shutdown) error {
+			if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainExitsOnSignal$")
+	cmd.Env = append(os.Environ(), "SHUTDOWN_TEST_CHILD=1")
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		t.Fatalf("child error = %v, want an exit status", err)
+	}
+	if want := 128 + int(syscall.SIGINT); exit.ExitCode() != want {
+		t.Errorf("exit status = %d, want %d", exit.ExitCode(), want)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	ctx, down := Start(context.Background())
+	defer down.Run()
+	if sig := down.Signal(); sig != nil {
+		t.Errorf("Signal before any = %v, want nil", sig)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	<-ctx.Done()
+	if sig := down.Signal(); sig != syscall.SIGTERM {
+		t.Errorf("Signal = %v, want %v", sig, syscall.SIGTERM)
+	}
+}
@@ -0,0 +1,201 @@
+// Package snapshot records the aggregate state of a database (document
+// counts, sums of numeric fields and index names per collection) so a test
+// can run an example and assert exactly what it changed:
+//
+//	snapshot.Expect(t, database, snapshot.Spec{"episodes": {"duration"}},
+//		snapshot.Delta{"episodes": {Count: 2, Sums: map[string]float64{"duration": 57}}},
+//		main)
+//
+// Deltas rather than absolute values keep the assertions valid whatever the
+// database held before the run.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Spec lists the collections to snapshot and, for each, the numeric fields
+// whose sums are recorded
+type Spec map[string][]string
+
+// Collection is the recorded state of one collection
+type Collection struct {
+	Count   int64
+	Sums    map[string]float64
+	Indexes []string
+}
+
+// Snapshot is the recorded state of every collection in a Spec
+type Snapshot map[string]Collection
+
+// Change is how one collection differs between two snapshots. Sums only
+// lists fields whose sum changed.
+type Change struct {
+	Count   int64
+	Sums    map[string]float64
+	Added   []string
+	Dropped []string
+}
+
+// Delta holds the changed collections; unchanged ones are left out
+type Delta map[string]Change
+
+// Take records the collections in spec. A collection that does not exist
+// is recorded as empty.
+func Take(ctx context.Context, database *mongo.Database, spec Spec) (Snapshot, error) {
+	snapshot := Snapshot{}
+	for name, fields := range spec {
+		collection := database.Collection(name)
+		group := bson.D{{"_id", nil}, {"count", bson.D{{"$sum", 1}}}}
+		for i, field := range fields {
+			group = append(group, bson.E{Key: fmt.Sprintf("sum%d", i), Value: bson.D{{"$sum", "$" + field}}})
+		}
+		cursor, err := collection.Aggregate(ctx, mongo.Pipeline{{{"$group", group}}})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		var results []bson.M
+		if err = cursor.All(ctx, &results); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		state := Collection{Sums: map[string]float64{}}
+		for i, field := range fields {
+			state.Sums[field] = 0
+			if len(results) > 0 {
+				state.Sums[field] = toFloat(results[0][fmt.Sprintf("sum%d", i)])
+			}
+		}
+		if len(results) > 0 {
+			state.Count = int64(toFloat(results[0]["count"]))
+		}
+
+		specs, err := collection.Indexes().ListSpecifications(ctx)
+		var commandErr mongo.CommandError
+		if err != nil && !(errors.As(err, &commandErr) && commandErr.Name == "NamespaceNotFound") {
+			return nil, fmt.Errorf("%s indexes: %w", name, err)
+		}
+		for _, spec := range specs {
+			state.Indexes = append(state.Indexes, spec.Name)
+		}
+		sort.Strings(state.Indexes)
+		snapshot[name] = state
+	}
+	return snapshot, nil
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// Diff returns the changes from before to after
+func Diff(before, after Snapshot) Delta {
+	delta := Delta{}
+	for name, a := range after {
+		b := before[name]
+		change := Change{Count: a.Count - b.Count}
+		for field, sum := range a.Sums {
+			if d := sum - b.Sums[field]; d != 0 {
+				if change.Sums == nil {
+					change.Sums = map[string]float64{}
+				}
+				change.Sums[field] = d
+			}
+		}
+		change.Added = missing(a.Indexes, b.Indexes)
+		change.Dropped = missing(b.Indexes, a.Indexes)
+		if !reflect.DeepEqual(change, Change{}) {
+			delta[name] = change
+		}
+	}
+	return delta
+}
+
+// missing returns the names in from that are not in in, or nil
+func missing(from, in []string) []string {
+	var names []string
+	for _, name := range from {
+		found := false
+		for _, other := range in {
+			found = found || other == name
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// String lists the changes one collection per line, sorted by name
+func (d Delta) String() string {
+	if len(d) == 0 {
+		return "no changes\n"
+	}
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		change := d[name]
+		fmt.Fprintf(&b, "%s: count %+d", name, change.Count)
+		fields := make([]string, 0, len(change.Sums))
+		for field := range change.Sums {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fmt.Fprintf(&b, ", sum(%s) %+g", field, change.Sums[field])
+		}
+		if len(change.Added) > 0 {
+			fmt.Fprintf(&b, ", added indexes %v", change.Added)
+		}
+		if len(change.Dropped) > 0 {
+			fmt.Fprintf(&b, ", dropped indexes %v", change.Dropped)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Expect snapshots the collections in spec, calls run, snapshots them again
+// and fails the test unless the changes are exactly want. Collections in
+// spec but not in want must be unchanged, and want leaves Sums, Added and
+// Dropped nil when they have no entries, as Diff does.
+func Expect(t testing.TB, database *mongo.Database, spec Spec, want Delta, run func()) {
+	t.Helper()
+	take := func(when string) Snapshot {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		snapshot, err := Take(ctx, database, spec)
+		if err != nil {
+			t.Fatalf("snapshot %s: %v", when, err)
+		}
+		return snapshot
+	}
+	before := take("before")
+	run()
+	got := Diff(before, take("after"))
+	if (len(got) > 0 || len(want) > 0) && !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected changes\n--- got\n%s--- want\n%s", got, want)
+	}
+}
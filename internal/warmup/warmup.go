@@ -0,0 +1,251 @@
+// Package warmup pre-establishes pooled connections at startup and spreads
+// reconnects out with jitter after a pool is cleared, so a fleet of
+// instances does not open all of its connections at the same moment.
+package warmup
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Stats is a point-in-time copy of the pool events seen by a Monitor
+type Stats struct {
+	Created     int64
+	Ready       int64
+	Closed      int64
+	CheckedOut  int64
+	CheckedIn   int64
+	CheckFailed int64
+	Cleared     int64
+	Open        int64
+}
+
+// Monitor counts connection pool events per server, tracks which servers
+// take writes and signals pool clears
+type Monitor struct {
+	created, ready, closed atomic.Int64
+	checkedOut, checkedIn  atomic.Int64
+	checkFailed, cleared   atomic.Int64
+	clearedC               chan string
+
+	mu sync.Mutex
+	// open counts the ready connections of each server, and live holds
+	// them so a connection closed before it was ready is not subtracted
+	open map[string]int64
+	live map[connection]bool
+	// primaries are the servers a primary read or write can select
+	primaries []string
+}
+
+// connection identifies one pooled connection; IDs are unique per pool
+type connection struct {
+	address string
+	id      uint64
+}
+
+// NewMonitor creates a Monitor; pass PoolMonitor() to
+// options.Client().SetPoolMonitor and ServerMonitor() to SetServerMonitor
+func NewMonitor() *Monitor {
+	return &Monitor{
+		clearedC: make(chan string, 16),
+		open:     map[string]int64{},
+		live:     map[connection]bool{},
+	}
+}
+
+// PoolMonitor returns the driver hook feeding this Monitor
+func (m *Monitor) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.handle}
+}
+
+// ServerMonitor returns the driver hook telling this Monitor which servers
+// WarmUp fills
+func (m *Monitor) ServerMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{TopologyDescriptionChanged: m.topologyChanged}
+}
+
+func (m *Monitor) topologyChanged(e *event.TopologyDescriptionChangedEvent) {
+	var primaries []string
+	for _, server := range e.NewDescription.Servers {
+		switch server.Kind {
+		case description.RSPrimary, description.Standalone, description.Mongos, description.LoadBalancer:
+			primaries = append(primaries, server.Addr.String())
+		}
+	}
+	m.mu.Lock()
+	m.primaries = primaries
+	m.mu.Unlock()
+}
+
+func (m *Monitor) handle(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		m.created.Add(1)
+	case event.ConnectionReady:
+		m.ready.Add(1)
+		m.mu.Lock()
+		m.live[connection{e.Address, e.ConnectionID}] = true
+		m.open[e.Address]++
+		m.mu.Unlock()
+	case event.ConnectionClosed:
+		m.closed.Add(1)
+		m.mu.Lock()
+		if c := (connection{e.Address, e.ConnectionID}); m.live[c] {
+			delete(m.live, c)
+			m.open[e.Address]--
+		}
+		m.mu.Unlock()
+	case event.GetSucceeded:
+		m.checkedOut.Add(1)
+	case event.ConnectionReturned:
+		m.checkedIn.Add(1)
+	case event.GetFailed:
+		m.checkFailed.Add(1)
+	case event.PoolCleared:
+		m.cleared.Add(1)
+		select {
+		case m.clearedC <- e.Address:
+		default:
+		}
+	}
+}
+
+// Cleared delivers the address of every server whose pool was cleared,
+// which is what a failover or network error looks like from the driver
+func (m *Monitor) Cleared() <-chan string {
+	return m.clearedC
+}
+
+// Stats returns the current counters, with Open summed over all servers
+func (m *Monitor) Stats() Stats {
+	m.mu.Lock()
+	var open int64
+	for _, n := range m.open {
+		open += n
+	}
+	m.mu.Unlock()
+	return Stats{
+		Created:     m.created.Load(),
+		Ready:       m.ready.Load(),
+		Closed:      m.closed.Load(),
+		CheckedOut:  m.checkedOut.Load(),
+		CheckedIn:   m.checkedIn.Load(),
+		CheckFailed: m.checkFailed.Load(),
+		Cleared:     m.cleared.Load(),
+		Open:        open,
+	}
+}
+
+// Open returns the number of connections open to each server
+func (m *Monitor) Open() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	open := make(map[string]int64, len(m.open))
+	for address, n := range m.open {
+		open[address] = n
+	}
+	return open
+}
+
+// warm reports whether every server taking primary reads has at least size
+// connections open. Secondaries are not counted: connections to them say
+// nothing about the pool the pings filled.
+func (m *Monitor) warm(size int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.primaries) == 0 {
+		return false
+	}
+	for _, address := range m.primaries {
+		if m.open[address] < int64(size) {
+			return false
+		}
+	}
+	return true
+}
+
+// WarmUp runs size concurrent pings against the primary so the pool opens
+// connections before the application starts serving traffic, then waits until
+// at least size connections are open to the primary, or to every mongos of a
+// sharded cluster. size should match the client's minPoolSize so the
+// driver's pool maintenance keeps them open afterwards. The client must have
+// been created with both of monitor's hooks.
+func WarmUp(ctx context.Context, client *mongo.Client, monitor *Monitor, size int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, size)
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Ping(ctx, readpref.Primary()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for !monitor.warm(size) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Reconnector re-warms the pool after it is cleared, waiting a random
+// delay first so every instance of a deployment does not reconnect at once
+type Reconnector struct {
+	Client  *mongo.Client
+	Monitor *Monitor
+	Size    int
+	// MaxJitter bounds the random delay; zero or less re-warms at once
+	MaxJitter time.Duration
+	// OnReconnect is called after each re-warm attempt, for logging
+	OnReconnect func(address string, delay time.Duration, err error)
+}
+
+// Run handles pool clears until the context is cancelled
+func (r *Reconnector) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case address := <-r.Monitor.Cleared():
+			var delay time.Duration
+			if r.MaxJitter > 0 {
+				delay = time.Duration(rand.Int63n(int64(r.MaxJitter) + 1))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			// drain clears reported while we were waiting, one re-warm covers them
+			for len(r.Monitor.Cleared()) > 0 {
+				<-r.Monitor.Cleared()
+			}
+			warmCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			err := WarmUp(warmCtx, r.Client, r.Monitor, r.Size)
+			cancel()
+			if r.OnReconnect != nil {
+				r.OnReconnect(address, delay, err)
+			}
+		}
+	}
+}
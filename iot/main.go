@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Meta is the metaField of the time series collection. Measurements sharing
+// the same meta value are stored together in buckets.
+type Meta struct {
+	Sensor string `bson:"sensor" json:"sensor"`
+	Site   string `bson:"site" json:"site"`
+	Kind   string `bson:"kind" json:"kind"`
+}
+
+// Reading represents the schema for the "sensor_readings" time series collection
+type Reading struct {
+	Timestamp time.Time `bson:"ts" json:"ts"`
+	Meta      Meta      `bson:"meta" json:"meta"`
+	Value     float64   `bson:"value" json:"value"`
+}
+
+// createTimeSeries creates the readings collection unless it already exists
+func createTimeSeries(ctx context.Context, database *mongo.Database) error {
+	names, err := database.ListCollectionNames(ctx, bson.D{{"name", "sensor_readings"}})
+	if err != nil || len(names) > 0 {
+		return err
+	}
+	timeSeries := options.TimeSeries().
+		SetTimeField("ts").
+		SetMetaField("meta").
+		SetGranularity("seconds")
+	opts := options.CreateCollection().
+		SetTimeSeriesOptions(timeSeries).
+		SetExpireAfterSeconds(30 * 24 * 60 * 60)
+	return database.CreateCollection(ctx, "sensor_readings", opts)
+}
+
+// batchWriter buffers readings and writes them with one InsertMany per batch
+type batchWriter struct {
+	collection *mongo.Collection
+	readings   chan Reading
+	size       int
+	interval   time.Duration
+}
+
+func (w *batchWriter) run(ctx context.Context) {
+	batch := make([]interface{}, 0, w.size)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// unordered so one bad document does not drop the rest of the batch
+		writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result, err := w.collection.InsertMany(writeCtx, batch, options.InsertMany().SetOrdered(false))
+		cancel()
+		if err != nil {
+			log.Printf("insert batch of %d: %v", len(batch), err)
+		}
+		if result != nil {
+			fmt.Printf("Inserted %v readings\n", len(result.InsertedIDs))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case reading := <-w.readings:
+			batch = append(batch, reading)
+			if len(batch) >= w.size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case reading := <-w.readings:
+					batch = append(batch, reading)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// downsample rolls raw readings up into hourly min/max/avg documents in
+// "sensor_readings_hourly", recomputing the hours touched since the given time
+func downsample(ctx context.Context, database *mongo.Database, since time.Time) error {
+	hour := bson.D{{"$dateTrunc", bson.D{{"date", "$ts"}, {"unit", "hour"}}}}
+	matchStage := bson.D{{"$match", bson.D{{"ts", bson.D{{"$gte", since.Truncate(time.Hour)}}}}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{{"sensor", "$meta.sensor"}, {"hour", hour}}},
+		{"meta", bson.D{{"$first", "$meta"}}},
+		{"count", bson.D{{"$sum", 1}}},
+		{"min", bson.D{{"$min", "$value"}}},
+		{"max", bson.D{{"$max", "$value"}}},
+		{"avg", bson.D{{"$avg", "$value"}}},
+	}}}
+	mergeStage := bson.D{{"$merge", bson.D{
+		{"into", "sensor_readings_hourly"},
+		{"on", "_id"},
+		{"whenMatched", "replace"},
+		{"whenNotMatched", "insert"},
+	}}}
+	cursor, err := database.Collection("sensor_readings").Aggregate(ctx, mongo.Pipeline{matchStage, groupStage, mergeStage})
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// parseReading accepts a JSON body on a topic shaped like sites/<site>/sensors/<sensor>/<kind>
+func parseReading(topic string, payload []byte) (Reading, error) {
+	var reading Reading
+	if err := json.Unmarshal(payload, &reading); err != nil {
+		return reading, err
+	}
+	parts := strings.Split(topic, "/")
+	if len(parts) == 5 {
+		reading.Meta = Meta{Site: parts[1], Sensor: parts[3], Kind: parts[4]}
+	}
+	if reading.Meta.Sensor == "" {
+		return reading, fmt.Errorf("no sensor in topic %q or payload", topic)
+	}
+	if reading.Timestamp.IsZero() {
+		reading.Timestamp = time.Now().UTC()
+	}
+	return reading, nil
+}
+
+var (
+	broker          = flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
+	topic           = flag.String("topic", "sites/+/sensors/+/+", "MQTT topic filter")
+	batchSize       = flag.Int("batch", 500, "readings per InsertMany")
+	flushInterval   = flag.Duration("flush", 2*time.Second, "maximum time a reading waits in the buffer")
+	downsampleEvery = flag.Duration("downsample", 5*time.Minute, "how often hourly rollups are refreshed")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	if err = createTimeSeries(connectCtx, database); err != nil {
+		return err
+	}
+
+	writer := &batchWriter{
+		collection: database.Collection("sensor_readings"),
+		readings:   make(chan Reading, *batchSize*4),
+		size:       *batchSize,
+		interval:   *flushInterval,
+	}
+	writerDone := make(chan struct{})
+	go func() {
+		writer.run(ctx)
+		close(writerDone)
+	}()
+
+	go func() {
+		ticker := time.NewTicker(*downsampleEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := downsample(ctx, database, time.Now().Add(-*downsampleEvery)); err != nil {
+					log.Printf("downsample: %v", err)
+				}
+			}
+		}
+	}()
+
+	mqttOptions := mqtt.NewClientOptions().
+		AddBroker(*broker).
+		SetClientID("quickstart-iot").
+		SetAutoReconnect(true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// (re)subscribe on every connect so reconnects keep receiving messages
+			token := c.Subscribe(*topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+				reading, err := parseReading(message.Topic(), message.Payload())
+				if err != nil {
+					log.Printf("dropping message on %s: %v", message.Topic(), err)
+					return
+				}
+				writer.readings <- reading
+			})
+			if token.Wait() && token.Error() != nil {
+				log.Printf("subscribe %s: %v", *topic, token.Error())
+			}
+		})
+	mqttClient := mqtt.NewClient(mqttOptions)
+	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	fmt.Printf("Subscribed to %s on %s\n", *topic, *broker)
+
+	<-ctx.Done()
+	mqttClient.Disconnect(250)
+	<-writerDone
+	return nil
+}
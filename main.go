@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	_ "github.com/mongodb-developer/golang-quickstart/examples/all"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+)
+
+// renames collects the -collection flags
+type renames map[string]string
+
+func (r renames) String() string {
+	pairs := make([]string, 0, len(r))
+	for name, renamed := range r {
+		pairs = append(pairs, name+"="+renamed)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (r renames) Set(value string) error {
+	name, renamed, ok := strings.Cut(value, "=")
+	if !ok || name == "" || renamed == "" {
+		return fmt.Errorf("want name=other, got %q", value)
+	}
+	r[name] = renamed
+	return nil
+}
+
+var collections = renames{}
+
+var (
+	list     = flag.Bool("list", false, "list the registered examples and exit")
+	database = flag.String("db", examples.DefaultDatabase, "database the examples work in")
+	step     = flag.Bool("step", false, "wait for Enter before each example, for presenting")
+	workshop = flag.Bool("workshop", false, "pause before every operation, show documents before and after it and ask for filter values; implies -step")
+)
+
+func main() {
+	flag.Var(collections, "collection", "use another collection for one the examples name, as episodes=my_episodes; repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [example ...]\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(flag.CommandLine.Output(), "Without example names every example except the continuous ones runs, in tour order.")
+		fmt.Fprintln(flag.CommandLine.Output(), "From a checkout: go run . [flags] creating retrieving ...")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION")
+		for _, example := range examples.List() {
+			description := example.Description
+			if example.Continuous {
+				description += " (continuous)"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", example.Name, description)
+		}
+		return w.Flush()
+	}
+
+	tour, err := selected(flag.Args())
+	if err != nil {
+		return err
+	}
+
+	// One Client Shared By Every Example
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	deps, err := examples.Connect(connectCtx, down)
+	if err != nil {
+		return err
+	}
+	deps.Database = *database
+	deps.Collections = collections
+
+	prompts := examples.NewWorkshop(os.Stdin, os.Stdout)
+	if *workshop {
+		deps.Workshop = prompts
+	}
+	for i, example := range tour {
+		fmt.Printf("\n== %d/%d %s: %s\n", i+1, len(tour), example.Name, example.Description)
+		if *step || *workshop {
+			if _, ok := prompts.Ask("Press Enter to run it "); !ok {
+				return nil
+			}
+		}
+		if err = runOne(ctx, example, deps); err != nil {
+			return fmt.Errorf("%s: %w", example.Name, err)
+		}
+	}
+	return nil
+}
+
+// selected returns the named examples in the order given, or the tour
+// without the continuous examples when no names are given
+func selected(names []string) ([]examples.Example, error) {
+	if len(names) == 0 {
+		var tour []examples.Example
+		for _, example := range examples.List() {
+			if !example.Continuous {
+				tour = append(tour, example)
+			}
+		}
+		return tour, nil
+	}
+	tour := make([]examples.Example, 0, len(names))
+	var unknown []string
+	for _, name := range names {
+		example, ok := examples.Lookup(name)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		tour = append(tour, example)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: unknown example %s, see -list", shutdown.ErrUsage, strings.Join(unknown, ", "))
+	}
+	return tour, nil
+}
+
+// runOne runs an example within its own timeout, except in workshop mode
+// where it waits for the presenter
+func runOne(ctx context.Context, example examples.Example, deps examples.Deps) error {
+	if example.Timeout > 0 && deps.Workshop == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, example.Timeout)
+		defer cancel()
+	}
+	return example.Run(ctx, deps)
+}
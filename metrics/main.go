@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Metrics turns driver command and connection pool events into Prometheus
+// metrics. The error rate of a command is
+//
+//	rate(mongodb_commands_total{status="failed"}[5m]) / rate(mongodb_commands_total[5m])
+type Metrics struct {
+	commands        *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	poolEvents      *prometheus.CounterVec
+	checkoutWait    *prometheus.HistogramVec
+	open            *prometheus.GaugeVec
+	checkedOut      *prometheus.GaugeVec
+}
+
+// NewMetrics creates the metrics and registers them with registerer
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mongodb_commands_total",
+			Help: "Commands sent to the server, by command name and whether they succeeded or failed.",
+		}, []string{"command", "status"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mongodb_command_duration_seconds",
+			Help:    "Round trip time of commands, including failed ones.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"command"}),
+		poolEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mongodb_pool_events_total",
+			Help: "Connection pool events such as ConnectionCheckedOut, ConnectionCheckedIn and ConnectionPoolCleared.",
+		}, []string{"address", "event"}),
+		checkoutWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mongodb_pool_checkout_duration_seconds",
+			Help:    "Time spent waiting for a connection from the pool.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"address"}),
+		open: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mongodb_pool_connections",
+			Help: "Open connections in the pool.",
+		}, []string{"address"}),
+		checkedOut: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mongodb_pool_connections_in_use",
+			Help: "Connections checked out of the pool.",
+		}, []string{"address"}),
+	}
+	registerer.MustRegister(m.commands, m.commandDuration, m.poolEvents, m.checkoutWait, m.open, m.checkedOut)
+	return m
+}
+
+// CommandMonitor returns the monitor to pass to options.Client().SetMonitor
+func (m *Metrics) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, succeeded *event.CommandSucceededEvent) {
+			m.command(succeeded.CommandFinishedEvent, "succeeded")
+		},
+		Failed: func(_ context.Context, failed *event.CommandFailedEvent) {
+			m.command(failed.CommandFinishedEvent, "failed")
+		},
+	}
+}
+
+func (m *Metrics) command(finished event.CommandFinishedEvent, status string) {
+	m.commands.WithLabelValues(finished.CommandName, status).Inc()
+	m.commandDuration.WithLabelValues(finished.CommandName).Observe(finished.Duration.Seconds())
+}
+
+// PoolMonitor returns the monitor to pass to options.Client().SetPoolMonitor
+func (m *Metrics) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			m.poolEvents.WithLabelValues(e.Address, e.Type).Inc()
+			switch e.Type {
+			case event.ConnectionCreated:
+				m.open.WithLabelValues(e.Address).Inc()
+			case event.ConnectionClosed:
+				m.open.WithLabelValues(e.Address).Dec()
+			case event.GetSucceeded:
+				m.checkedOut.WithLabelValues(e.Address).Inc()
+				m.checkoutWait.WithLabelValues(e.Address).Observe(e.Duration.Seconds())
+			case event.GetFailed:
+				m.checkoutWait.WithLabelValues(e.Address).Observe(e.Duration.Seconds())
+			case event.ConnectionReturned:
+				m.checkedOut.WithLabelValues(e.Address).Dec()
+			}
+		},
+	}
+}
+
+var (
+	addr     = flag.String("addr", "localhost:2112", "address serving metrics on /metrics")
+	interval = flag.Duration("interval", time.Second, "time between rounds of demo operations")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	metrics := NewMetrics(registry)
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx, options.Client().
+		SetMonitor(metrics.CommandMonitor()).
+		SetPoolMonitor(metrics.PoolMonitor()))
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	episodesCollection := client.Database("quickstart").Collection("metrics_episodes")
+	if err = episodesCollection.Drop(connectCtx); err != nil {
+		return fmt.Errorf("drop metrics_episodes: %w", err)
+	}
+
+	go workload(ctx, episodesCollection, *interval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	log.Printf("metrics on http://%s/metrics", *addr)
+	return shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: mux})
+}
+
+// workload keeps a few operations going so every metric has values,
+// including a failing command every tenth round
+func workload(ctx context.Context, episodesCollection *mongo.Collection, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 1; ; i++ {
+		opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := episodesCollection.InsertOne(opCtx, bson.D{{"title", fmt.Sprintf("Episode %d", i)}, {"duration", 20 + i%30}})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("insert into metrics_episodes: %v", err)
+		}
+		if _, err = episodesCollection.CountDocuments(opCtx, bson.D{{"duration", bson.D{{"$gt", 30}}}}); err != nil && ctx.Err() == nil {
+			log.Printf("count metrics_episodes: %v", err)
+		}
+		if i%10 == 0 {
+			// An unknown operator fails on the server and counts as a failed find
+			cursor, err := episodesCollection.Find(opCtx, bson.D{{"duration", bson.D{{"$unknown", 1}}}})
+			if err == nil {
+				cursor.Close(opCtx)
+			}
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// noise are command fields that only carry session, cluster or routing state
+var noise = map[string]bool{
+	"lsid":            true,
+	"txnNumber":       true,
+	"$db":             true,
+	"$clusterTime":    true,
+	"$readPreference": true,
+	"apiVersion":      true,
+}
+
+// shapes are fields whose values describe the query rather than the data, so
+// they are logged as they are: a sort order or projection is not personal
+var shapes = map[string]bool{
+	"sort":       true,
+	"projection": true,
+	"hint":       true,
+	"key":        true,
+	"$sort":      true,
+	"$project":   true,
+	"$group":     true,
+}
+
+// duplicateKey matches the values a duplicate key error message repeats
+var duplicateKey = regexp.MustCompile(`dup key: \{.*\}`)
+
+// Stats sums up the finished events of one command name
+type Stats struct {
+	Count  int
+	Failed int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Monitor logs every command a client sends and how it ended, with literal
+// values replaced by their type so documents and filters holding personal
+// data never reach the logs
+type Monitor struct {
+	Logger *log.Logger
+	// ShowValues logs commands and failures as they are, for local debugging
+	ShowValues bool
+	// Slow only logs commands that succeed when they took at least this long;
+	// started events are logged with them instead of up front
+	Slow time.Duration
+
+	mu      sync.Mutex
+	pending map[int64]string
+	stats   map[string]*Stats
+}
+
+// CommandMonitor returns the monitor to pass to options.Client().SetMonitor
+func (m *Monitor) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, started *event.CommandStartedEvent) {
+			line := fmt.Sprintf("%s.%s %s", started.DatabaseName, started.CommandName, m.command(started.Command))
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.Slow > 0 {
+				if m.pending == nil {
+					m.pending = map[int64]string{}
+				}
+				m.pending[started.RequestID] = line
+				return
+			}
+			m.Logger.Printf("[%d] started %s", started.RequestID, line)
+		},
+		Succeeded: func(_ context.Context, succeeded *event.CommandSucceededEvent) {
+			line, slow := m.finish(succeeded.CommandFinishedEvent, false)
+			if slow {
+				m.Logger.Printf("[%d] slow %s succeeded in %v", succeeded.RequestID, line, succeeded.Duration)
+			} else if m.Slow == 0 {
+				m.Logger.Printf("[%d] succeeded in %v", succeeded.RequestID, succeeded.Duration)
+			}
+		},
+		Failed: func(_ context.Context, failed *event.CommandFailedEvent) {
+			line, _ := m.finish(failed.CommandFinishedEvent, true)
+			failure := failed.Failure
+			if !m.ShowValues {
+				failure = duplicateKey.ReplaceAllString(failure, "dup key: { redacted }")
+			}
+			if line != "" {
+				m.Logger.Printf("[%d] %s failed in %v: %s", failed.RequestID, line, failed.Duration, failure)
+			} else {
+				m.Logger.Printf("[%d] failed in %v: %s", failed.RequestID, failed.Duration, failure)
+			}
+		},
+	}
+}
+
+// finish records a finished command and returns its started line when that
+// was held back, and whether the command counts as slow
+func (m *Monitor) finish(finished event.CommandFinishedEvent, failed bool) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats == nil {
+		m.stats = map[string]*Stats{}
+	}
+	stats := m.stats[finished.CommandName]
+	if stats == nil {
+		stats = &Stats{}
+		m.stats[finished.CommandName] = stats
+	}
+	stats.Count++
+	stats.Total += finished.Duration
+	if finished.Duration > stats.Max {
+		stats.Max = finished.Duration
+	}
+	if failed {
+		stats.Failed++
+	}
+	line := m.pending[finished.RequestID]
+	delete(m.pending, finished.RequestID)
+	return line, m.Slow > 0 && finished.Duration >= m.Slow
+}
+
+// command renders a command as relaxed Extended JSON, redacted unless
+// ShowValues is set. Authentication commands arrive empty from the driver.
+func (m *Monitor) command(command bson.Raw) string {
+	var rendered interface{} = command
+	if !m.ShowValues {
+		rendered = Redact(command)
+	}
+	data, err := bson.MarshalExtJSON(rendered, false, false)
+	if err != nil {
+		return fmt.Sprintf("(%v)", err)
+	}
+	return string(data)
+}
+
+// Write prints the stats of every command name seen so far
+func (m *Monitor) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.stats))
+	for name := range m.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMAND\tCOUNT\tFAILED\tAVG\tMAX")
+	for _, name := range names {
+		stats := m.stats[name]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\n", name, stats.Count, stats.Failed,
+			(stats.Total / time.Duration(stats.Count)).Round(time.Microsecond), stats.Max.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
+
+// Redact drops the session and routing fields of a command and replaces
+// every value below its top level with its BSON type name, keeping field
+// names and operators. Top-level values, such as the collection name, limit
+// or ordered, are options rather than data and are kept.
+func Redact(command bson.Raw) bson.D {
+	elements, _ := command.Elements()
+	redacted := bson.D{}
+	for _, element := range elements {
+		key, value := element.Key(), element.Value()
+		if noise[key] {
+			continue
+		}
+		if value.Type == bson.TypeEmbeddedDocument || value.Type == bson.TypeArray {
+			redacted = append(redacted, bson.E{key, redact(key, value)})
+		} else {
+			redacted = append(redacted, bson.E{key, value})
+		}
+	}
+	return redacted
+}
+
+func redact(key string, value bson.RawValue) interface{} {
+	if shapes[key] {
+		return value
+	}
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		document := make(bson.D, len(elements))
+		for i, element := range elements {
+			document[i] = bson.E{element.Key(), redact(element.Key(), element.Value())}
+		}
+		return document
+	case bson.TypeArray:
+		values, _ := value.Array().Values()
+		array := make(bson.A, len(values))
+		for i, item := range values {
+			array[i] = redact("", item)
+		}
+		return array
+	}
+	return value.Type.String()
+}
+
+var (
+	showValues = flag.Bool("show-values", false, "log literal values in commands and errors (never in production)")
+	slow       = flag.Duration("slow", 0, "only log commands slower than this, and failures (0 logs every command)")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	monitor := &Monitor{
+		Logger:     log.New(os.Stderr, "", log.Ltime|log.Lmicroseconds),
+		ShowValues: *showValues,
+		Slow:       *slow,
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx, options.Client().SetMonitor(monitor.CommandMonitor()))
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	episodesCollection := client.Database("quickstart").Collection("monitoring_episodes")
+	if err = episodesCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop monitoring_episodes: %w", err)
+	}
+	_, err = episodesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"title", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("create index on monitoring_episodes: %w", err)
+	}
+
+	// Every Operation Below Shows Up As Started And Succeeded Or Failed Events
+	_, err = episodesCollection.InsertMany(ctx, []interface{}{
+		bson.D{{"title", "GraphQL for API Development"}, {"guest", "Lee Byron"}, {"duration", 25}},
+		bson.D{{"title", "Progressive Web Application Development"}, {"guest", "Tara Manicsic"}, {"duration", 32}},
+		bson.D{{"title", "Monitoring MongoDB From Go"}, {"guest", "Nic Raboy"}, {"duration", 41}},
+	})
+	if err != nil {
+		return fmt.Errorf("insert into monitoring_episodes: %w", err)
+	}
+	cursor, err := episodesCollection.Find(ctx,
+		bson.D{{"duration", bson.D{{"$gt", 30}}}},
+		options.Find().SetSort(bson.D{{"duration", -1}}).SetBatchSize(1))
+	if err != nil {
+		return fmt.Errorf("find in monitoring_episodes: %w", err)
+	}
+	var long []bson.M
+	if err = cursor.All(ctx, &long); err != nil {
+		return fmt.Errorf("decode monitoring_episodes: %w", err)
+	}
+	fmt.Printf("Found %d long episodes\n", len(long))
+	_, err = episodesCollection.UpdateOne(ctx,
+		bson.D{{"guest", "Nic Raboy"}},
+		bson.D{{"$inc", bson.D{{"duration", 1}}}})
+	if err != nil {
+		return fmt.Errorf("update one in monitoring_episodes: %w", err)
+	}
+
+	// A Failed Command Is Logged With Its Error, The Duplicate Value Redacted
+	_, err = episodesCollection.InsertOne(ctx, bson.D{{"title", "Monitoring MongoDB From Go"}})
+	if !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("insert duplicate into monitoring_episodes: expected a duplicate key error, got %v", err)
+	}
+
+	if _, err = episodesCollection.DeleteMany(ctx, bson.D{}); err != nil {
+		return fmt.Errorf("delete many in monitoring_episodes: %w", err)
+	}
+	monitor.Write(os.Stdout)
+	return nil
+}
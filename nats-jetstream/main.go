@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Message represents the schema for the "bus_messages" collection. The
+// {stream, sequence} pair is unique, so a redelivered message can never be
+// stored twice.
+type Message struct {
+	Stream     string    `bson:"stream"`
+	Sequence   int64     `bson:"sequence"`
+	Subject    string    `bson:"subject"`
+	Headers    bson.M    `bson:"headers,omitempty"`
+	Payload    bson.M    `bson:"payload,omitempty"`
+	Data       []byte    `bson:"data,omitempty"`
+	Published  time.Time `bson:"published_at"`
+	ReceivedAt time.Time `bson:"received_at"`
+}
+
+func newMessage(msg jetstream.Msg) (Message, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return Message{}, err
+	}
+	message := Message{
+		Stream:     meta.Stream,
+		Sequence:   int64(meta.Sequence.Stream),
+		Subject:    msg.Subject(),
+		Published:  meta.Timestamp.UTC(),
+		ReceivedAt: time.Now().UTC(),
+	}
+	if len(msg.Headers()) > 0 {
+		message.Headers = bson.M{}
+		for key, values := range msg.Headers() {
+			message.Headers[key] = values
+		}
+	}
+	// JSON payloads are stored as documents so they can be queried,
+	// anything else is kept as raw bytes
+	if err := bson.UnmarshalExtJSON(msg.Data(), false, &message.Payload); err != nil {
+		message.Payload = nil
+		message.Data = msg.Data()
+	}
+	return message, nil
+}
+
+// persist inserts a fetched batch and returns, for each message, whether it
+// is safely stored. Duplicate key errors mean an earlier delivery was already
+// written but its ack was lost, so those count as stored too.
+func persist(ctx context.Context, collection *mongo.Collection, messages []Message) ([]bool, error) {
+	stored := make([]bool, len(messages))
+	for i := range stored {
+		stored[i] = true
+	}
+	documents := make([]interface{}, len(messages))
+	for i, message := range messages {
+		documents[i] = message
+	}
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				stored[writeErr.Index] = false
+			}
+		}
+		return stored, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// consume fetches batches from the durable consumer, writes them to MongoDB
+// and only then acknowledges them. Messages that fail to insert are nak'ed
+// and redelivered later.
+func consume(ctx context.Context, consumer jetstream.Consumer, collection *mongo.Collection, batchSize int) error {
+	for ctx.Err() == nil {
+		batch, err := consumer.Fetch(batchSize, jetstream.FetchMaxWait(5*time.Second))
+		if err != nil {
+			return err
+		}
+		var msgs []jetstream.Msg
+		var messages []Message
+		for msg := range batch.Messages() {
+			message, err := newMessage(msg)
+			if err != nil {
+				log.Printf("terminating message without metadata: %v", err)
+				msg.Term()
+				continue
+			}
+			msgs = append(msgs, msg)
+			messages = append(messages, message)
+		}
+		if err = batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			log.Printf("fetch: %v", err)
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		stored, err := persist(writeCtx, collection, messages)
+		cancel()
+		if err != nil {
+			log.Printf("insert batch: %v", err)
+			for _, msg := range msgs {
+				msg.NakWithDelay(time.Second)
+			}
+			continue
+		}
+		acked := 0
+		for i, msg := range msgs {
+			if !stored[i] {
+				msg.NakWithDelay(time.Second)
+				continue
+			}
+			// DoubleAck waits for the server to confirm the ack; if it is
+			// lost the message is redelivered and deduplicated by the index
+			ackCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := msg.DoubleAck(ackCtx); err != nil {
+				log.Printf("ack %s #%d: %v", messages[i].Stream, messages[i].Sequence, err)
+			} else {
+				acked++
+			}
+			cancel()
+		}
+		fmt.Printf("Persisted %v of %v messages\n", acked, len(msgs))
+	}
+	return nil
+}
+
+var (
+	natsURL    = flag.String("nats", nats.DefaultURL, "NATS server URL")
+	streamName = flag.String("stream", "EVENTS", "JetStream stream to consume")
+	subject    = flag.String("subject", "events.>", "subject filter for the consumer")
+	durable    = flag.String("durable", "quickstart-mongo", "durable consumer name")
+	batchSize  = flag.Int("batch", 100, "messages fetched and inserted at a time")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	collection := client.Database("quickstart").Collection("bus_messages")
+	_, err = collection.Indexes().CreateOne(connectCtx, mongo.IndexModel{
+		Keys:    bson.D{{"stream", 1}, {"sequence", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	nc, err := nats.Connect(*natsURL)
+	if err != nil {
+		return err
+	}
+	defer nc.Drain()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return err
+	}
+	consumer, err := js.CreateOrUpdateConsumer(connectCtx, *streamName, jetstream.ConsumerConfig{
+		Durable:       *durable,
+		FilterSubject: *subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxAckPending: *batchSize * 10,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Consuming %s (%s) as %s\n", *streamName, *subject, *durable)
+
+	if err = consume(ctx, consumer, collection, *batchSize); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Guest decodes the same documents three ways: a plain field cannot tell
+// missing, null and zero apart, a pointer tells missing or null from zero,
+// and a raw value tells all three apart
+type Guest struct {
+	Name     string        `bson:"name"`
+	Rating   int32         `bson:"rating"`
+	RatingP  *int32        `bson:"rating_p"`
+	RatingRV bson.RawValue `bson:"rating_rv"`
+}
+
+// describe prints which of the three states a raw value is in
+func describe(value bson.RawValue) string {
+	switch {
+	case value.Type == 0:
+		return "missing"
+	case value.Type == bson.TypeNull:
+		return "null"
+	default:
+		return value.String()
+	}
+}
+
+// query is a filter and the mongosh syntax it is printed as
+type query struct {
+	label  string
+	filter bson.D
+}
+
+func printNames(ctx context.Context, collection *mongo.Collection, queries []query) error {
+	for _, query := range queries {
+		cursor, err := collection.Find(ctx, query.filter)
+		if err != nil {
+			return err
+		}
+		var guests []Guest
+		if err = cursor.All(ctx, &guests); err != nil {
+			return err
+		}
+		names := make([]string, len(guests))
+		for i, guest := range guests {
+			names[i] = guest.Name
+		}
+		fmt.Printf("%-34s %v\n", query.label, names)
+	}
+	return nil
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	guestsCollection := client.Database("quickstart").Collection("nulls_guests")
+	if err = guestsCollection.Drop(ctx); err != nil {
+		return err
+	}
+
+	// Insert A Missing, A Null And A Zero Rating
+	// each rating is stored under three names so the decoding section can
+	// read it into each kind of Go field
+	rating := func(value interface{}) bson.D {
+		return bson.D{{"rating", value}, {"rating_p", value}, {"rating_rv", value}}
+	}
+	_, err = guestsCollection.InsertMany(ctx, []interface{}{
+		bson.D{{"name", "missing"}},
+		append(bson.D{{"name", "null"}}, rating(nil)...),
+		append(bson.D{{"name", "zero"}}, rating(0)...),
+		append(bson.D{{"name", "five"}}, rating(5)...),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Filter On Missing, Null And Zero
+	// {rating: null} matches null and missing fields alike
+	err = printNames(ctx, guestsCollection, []query{
+		{"{rating: null}", bson.D{{"rating", nil}}},
+		{"{rating: {$exists: false}}", bson.D{{"rating", bson.D{{"$exists", false}}}}},
+		{"{rating: {$exists: true}}", bson.D{{"rating", bson.D{{"$exists", true}}}}},
+		{"{rating: {$type: \"null\"}}", bson.D{{"rating", bson.D{{"$type", "null"}}}}},
+		{"{rating: 0}", bson.D{{"rating", 0}}},
+		// $ne: null is the usual way to ask for documents that have a real value
+		{"{rating: {$ne: null}}", bson.D{{"rating", bson.D{{"$ne", nil}}}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Decode Into Plain, Pointer And Raw Fields
+	cursor, err := guestsCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	var guests []Guest
+	if err = cursor.All(ctx, &guests); err != nil {
+		return err
+	}
+	for _, guest := range guests {
+		pointer := "nil"
+		if guest.RatingP != nil {
+			pointer = fmt.Sprint(*guest.RatingP)
+		}
+		fmt.Printf("%-8s int32=%d  *int32=%s  RawValue=%s\n", guest.Name, guest.Rating, pointer, describe(guest.RatingRV))
+	}
+
+	// Encoding Zero Values: Without omitempty A Zero Is Written, With It Nothing Is
+	type WithZero struct {
+		Rating int32 `bson:"rating"`
+	}
+	type OmitZero struct {
+		Rating int32 `bson:"rating,omitempty"`
+	}
+	type NilPointer struct {
+		Rating *int32 `bson:"rating"`
+	}
+	for _, value := range []interface{}{WithZero{}, OmitZero{}, NilPointer{}} {
+		data, err := bson.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-10T encodes as %s\n", value, bson.Raw(data))
+	}
+
+	// Update With $unset Versus Setting Null
+	// $unset removes the field, so it is missing afterwards; $set to nil
+	// keeps it with a null value, and both match {rating: null}
+	if _, err = guestsCollection.UpdateOne(ctx, bson.D{{"name", "five"}}, bson.D{{"$unset", bson.D{{"rating", ""}}}}); err != nil {
+		return err
+	}
+	if _, err = guestsCollection.UpdateOne(ctx, bson.D{{"name", "zero"}}, bson.D{{"$set", bson.D{{"rating", nil}}}}); err != nil {
+		return err
+	}
+	for _, name := range []string{"five", "zero"} {
+		var document bson.Raw
+		if err = guestsCollection.FindOne(ctx, bson.D{{"name", name}}).Decode(&document); err != nil {
+			return err
+		}
+		fmt.Printf("after update %-5s rating is %s\n", name, describe(document.Lookup("rating")))
+	}
+	return printNames(ctx, guestsCollection, []query{
+		{"{rating: {$exists: false}}", bson.D{{"rating", bson.D{{"$exists", false}}}}},
+		{"{rating: {$type: \"null\"}}", bson.D{{"rating", bson.D{{"$type", "null"}}}}},
+	})
+}
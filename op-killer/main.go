@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Operation represents a $currentOp result for an in-progress operation
+type Operation struct {
+	OpID        interface{} `bson:"opid"`
+	Op          string      `bson:"op"`
+	Namespace   string      `bson:"ns"`
+	SecsRunning int64       `bson:"secs_running"`
+	Client      string      `bson:"client"`
+	AppName     string      `bson:"appName"`
+	Command     bson.Raw    `bson:"command"`
+	PlanSummary string      `bson:"planSummary"`
+}
+
+// slowOperations lists active operations on a database that have been
+// running for at least the threshold, longest running first
+func slowOperations(ctx context.Context, admin *mongo.Database, database string, threshold time.Duration) ([]Operation, error) {
+	currentOpStage := bson.D{{"$currentOp", bson.D{{"allUsers", true}, {"idleConnections", false}}}}
+	matchStage := bson.D{{"$match", bson.D{
+		{"active", true},
+		{"secs_running", bson.D{{"$gte", int64(threshold.Seconds())}}},
+		{"ns", bson.D{{"$regex", "^" + regexp.QuoteMeta(database) + `\.`}}},
+		{"op", bson.D{{"$in", bson.A{"query", "getmore", "update", "remove", "insert", "command"}}}},
+		// never offer to kill the $currentOp aggregation we are running ourselves
+		{"command.$currentOp", bson.D{{"$exists", false}}},
+		{"command.pipeline.$currentOp", bson.D{{"$exists", false}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"secs_running", -1}}}}
+	cursor, err := admin.Aggregate(ctx, mongo.Pipeline{currentOpStage, matchStage, sortStage})
+	if err != nil {
+		return nil, err
+	}
+	var operations []Operation
+	if err = cursor.All(ctx, &operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func killOp(ctx context.Context, admin *mongo.Database, opid interface{}) error {
+	return admin.RunCommand(ctx, bson.D{{"killOp", 1}, {"op", opid}}).Err()
+}
+
+func summarize(command bson.Raw) string {
+	text := command.String()
+	if len(text) > 80 {
+		text = text[:77] + "..."
+	}
+	return text
+}
+
+var (
+	threshold = flag.Duration("threshold", 10*time.Second, "minimum running time of operations to list")
+	database  = flag.String("db", "quickstart", "database whose operations are inspected")
+	selected  = flag.String("opid", "", "comma separated opids to kill (default: every listed operation)")
+	dryRun    = flag.Bool("dry-run", true, "only list operations, set to false to kill them")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	admin := client.Database("admin")
+	operations, err := slowOperations(ctx, admin, *database, *threshold)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		fmt.Printf("No operations on %s running longer than %v\n", *database, *threshold)
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "OPID\tOP\tNAMESPACE\tSECS\tCLIENT\tPLAN\tCOMMAND")
+	for _, operation := range operations {
+		fmt.Fprintf(table, "%v\t%s\t%s\t%d\t%s\t%s\t%s\n", operation.OpID, operation.Op, operation.Namespace,
+			operation.SecsRunning, operation.Client, operation.PlanSummary, summarize(operation.Command))
+	}
+	table.Flush()
+
+	wanted := map[string]bool{}
+	for _, opid := range strings.Split(*selected, ",") {
+		if opid = strings.TrimSpace(opid); opid != "" {
+			wanted[opid] = true
+		}
+	}
+	for _, operation := range operations {
+		opid := fmt.Sprint(operation.OpID)
+		if len(wanted) > 0 && !wanted[opid] {
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("[dry-run] would kill opid %s (%s on %s, %ds)\n", opid, operation.Op, operation.Namespace, operation.SecsRunning)
+			continue
+		}
+		if err = killOp(ctx, admin, operation.OpID); err != nil {
+			log.Printf("killOp %s: %v", opid, err)
+			continue
+		}
+		fmt.Printf("Killed opid %s\n", opid)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Member represents a member entry of the replSetGetStatus command
+type Member struct {
+	Name       string    `bson:"name"`
+	State      string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+	Self       bool      `bson:"self"`
+}
+
+// ReplicaSetStatus represents the parts of replSetGetStatus we report on
+type ReplicaSetStatus struct {
+	Set     string   `bson:"set"`
+	Members []Member `bson:"members"`
+}
+
+// OplogWindow is the time span covered by the oplog on the connected member
+type OplogWindow struct {
+	First  time.Time
+	Last   time.Time
+	Window time.Duration
+}
+
+// Report is the outcome of one check
+type Report struct {
+	Status ReplicaSetStatus
+	Lag    map[string]time.Duration
+	Oplog  OplogWindow
+}
+
+// replicationLag returns how far each secondary's last applied optime
+// trails the primary's
+func replicationLag(status ReplicaSetStatus) map[string]time.Duration {
+	var primary *Member
+	for i := range status.Members {
+		if status.Members[i].State == "PRIMARY" {
+			primary = &status.Members[i]
+		}
+	}
+	lag := map[string]time.Duration{}
+	if primary == nil {
+		return lag
+	}
+	for _, member := range status.Members {
+		if member.State == "SECONDARY" {
+			lag[member.Name] = primary.OptimeDate.Sub(member.OptimeDate)
+		}
+	}
+	return lag
+}
+
+// oplogWindow reads the first and last entries of local.oplog.rs in natural order
+func oplogWindow(ctx context.Context, client *mongo.Client) (OplogWindow, error) {
+	oplog := client.Database("local").Collection("oplog.rs")
+	var entry struct {
+		TS primitive.Timestamp `bson:"ts"`
+	}
+	var window OplogWindow
+	opts := options.FindOne().SetProjection(bson.D{{"ts", 1}})
+	if err := oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{"$natural", 1}})).Decode(&entry); err != nil {
+		return window, fmt.Errorf("reading first oplog entry: %w", err)
+	}
+	window.First = time.Unix(int64(entry.TS.T), 0).UTC()
+	if err := oplog.FindOne(ctx, bson.D{}, opts.SetSort(bson.D{{"$natural", -1}})).Decode(&entry); err != nil {
+		return window, fmt.Errorf("reading last oplog entry: %w", err)
+	}
+	window.Last = time.Unix(int64(entry.TS.T), 0).UTC()
+	window.Window = window.Last.Sub(window.First)
+	return window, nil
+}
+
+func check(ctx context.Context, client *mongo.Client) (Report, error) {
+	var report Report
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{"replSetGetStatus", 1}}).Decode(&report.Status); err != nil {
+		return report, fmt.Errorf("replSetGetStatus: %w", err)
+	}
+	report.Lag = replicationLag(report.Status)
+	window, err := oplogWindow(ctx, client)
+	if err != nil {
+		return report, err
+	}
+	report.Oplog = window
+	return report, nil
+}
+
+// alerts lists every threshold the report violates
+func alerts(report Report, minWindow, maxLag time.Duration) []string {
+	var messages []string
+	if report.Oplog.Window < minWindow {
+		messages = append(messages, fmt.Sprintf("oplog window %v is below %v: change streams and secondaries can fall off the oplog", report.Oplog.Window.Round(time.Second), minWindow))
+	}
+	for member, lag := range report.Lag {
+		if lag > maxLag {
+			messages = append(messages, fmt.Sprintf("%s is lagging %v behind the primary (limit %v)", member, lag, maxLag))
+		}
+	}
+	return messages
+}
+
+var (
+	minWindow = flag.Duration("min-window", 24*time.Hour, "alert when the oplog covers less time than this")
+	maxLag    = flag.Duration("max-lag", 10*time.Second, "alert when a secondary lags more than this")
+	interval  = flag.Duration("interval", time.Minute, "time between checks")
+	once      = flag.Bool("once", false, "run a single check and exit non-zero on alerts")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		report, err := check(checkCtx, client)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s oplog window %v (%s - %s)\n", report.Status.Set, report.Oplog.Window.Round(time.Second),
+			report.Oplog.First.Format(time.RFC3339), report.Oplog.Last.Format(time.RFC3339))
+		for _, member := range report.Status.Members {
+			fmt.Printf("  %-30s %-10s lag %v\n", member.Name, member.State, report.Lag[member.Name])
+		}
+		messages := alerts(report, *minWindow, *maxLag)
+		for _, message := range messages {
+			log.Printf("ALERT: %s", message)
+		}
+
+		if *once {
+			if len(messages) > 0 {
+				return fmt.Errorf("%d alert(s)", len(messages))
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Podcast  primitive.ObjectID `bson:"podcast,omitempty" json:"podcast"`
+	Title    string             `bson:"title,omitempty" json:"title"`
+	Duration int32              `bson:"duration,omitempty" json:"duration"`
+}
+
+// Page is one page of results as an API would return it. Offset pages fill
+// in Page and Total; cursor pages fill in NextCursor, which is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Page       int64  `json:"page,omitempty"`
+	Total      int64  `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// OffsetPage returns page number page (from 1) of size documents. Simple
+// and lets clients jump to any page, but the server still walks every
+// skipped document, so late pages get slower, and a concurrent insert or
+// delete shifts items between pages.
+func OffsetPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.D, page, size int64) (Page[T], error) {
+	result := Page[T]{Items: []T{}, Page: page}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return result, err
+	}
+	result.Total = total
+	opts := options.Find().
+		SetSort(bson.D{{"_id", 1}}).
+		SetSkip((page - 1) * size).
+		SetLimit(size)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return result, err
+	}
+	err = cursor.All(ctx, &result.Items)
+	return result, err
+}
+
+// CursorPage returns the size documents following the cursor of a previous
+// page, or the first page for an empty cursor. The _id range filter is served
+// by the _id index, so every page costs the same however deep it is, and
+// concurrent writes never cause skipped or repeated items.
+func CursorPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.D, after string, size int64) (Page[T], error) {
+	result := Page[T]{Items: []T{}}
+	conditions := bson.A{filter}
+	if after != "" {
+		last, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return result, fmt.Errorf("invalid cursor %q", after)
+		}
+		conditions = append(conditions, bson.D{{"_id", bson.D{{"$gt", last}}}})
+	}
+	// one extra document tells whether there is a next page
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetLimit(size + 1)
+	cursor, err := collection.Find(ctx, bson.D{{"$and", conditions}}, opts)
+	if err != nil {
+		return result, err
+	}
+	var documents []bson.Raw
+	if err = cursor.All(ctx, &documents); err != nil {
+		return result, err
+	}
+	if int64(len(documents)) > size {
+		documents = documents[:size]
+		result.NextCursor = documents[size-1].Lookup("_id").ObjectID().Hex()
+	}
+	for _, document := range documents {
+		var item T
+		if err = bson.Unmarshal(document, &item); err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
+
+func printPage[T any](label string, page Page[T]) error {
+	data, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s:\n%s\n", label, data)
+	return nil
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	episodesCollection := client.Database("quickstart").Collection("episodes")
+	filter := bson.D{}
+	if flag.NArg() > 0 {
+		podcast, err := primitive.ObjectIDFromHex(flag.Arg(0))
+		if err != nil {
+			return fmt.Errorf("%w: the optional argument is a podcast id", shutdown.ErrUsage)
+		}
+		filter = bson.D{{"podcast", podcast}}
+	}
+
+	// Offset Pagination With SetSkip And SetLimit
+	for page := int64(1); page <= 2; page++ {
+		result, err := OffsetPage[Episode](ctx, episodesCollection, filter, page, 3)
+		if err != nil {
+			return err
+		}
+		if err = printPage(fmt.Sprintf("Offset page %d", page), result); err != nil {
+			return err
+		}
+	}
+
+	// Cursor Pagination With _id Range Filters
+	// the client passes back next_cursor to get the following page
+	next := ""
+	for page := 1; ; page++ {
+		result, err := CursorPage[Episode](ctx, episodesCollection, filter, next, 3)
+		if err != nil {
+			return err
+		}
+		if err = printPage(fmt.Sprintf("Cursor page %d", page), result); err != nil {
+			return err
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		next = result.NextCursor
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var identifier = regexp.MustCompile(`^[$A-Za-z_][$A-Za-z0-9_]*$`)
+
+// String renders the pipeline as mongosh text, one stage per line, ready to
+// paste into db.collection.aggregate(...) in the shell or Compass
+func String(p mongo.Pipeline) string {
+	if len(p) == 0 {
+		return "[]"
+	}
+	var b strings.Builder
+	b.WriteString("[\n")
+	for i, stage := range p {
+		b.WriteString("  ")
+		b.WriteString(Shell(stage))
+		if i < len(p)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// Shell renders any value the driver can marshal as mongosh syntax, using
+// ObjectId(), ISODate(), NumberLong() and friends so types survive the trip
+func Shell(value interface{}) string {
+	// wrap the value so scalars marshal too, then render the wrapped element
+	data, err := bson.Marshal(bson.D{{"v", value}})
+	if err != nil {
+		return fmt.Sprintf("/* %v */", err)
+	}
+	var b strings.Builder
+	writeValue(&b, bson.Raw(data).Lookup("v"))
+	return b.String()
+}
+
+func writeKey(b *strings.Builder, key string) {
+	if identifier.MatchString(key) {
+		b.WriteString(key)
+		return
+	}
+	writeString(b, key)
+}
+
+func writeString(b *strings.Builder, s string) {
+	quoted, _ := json.Marshal(s)
+	b.Write(quoted)
+}
+
+func writeValue(b *strings.Builder, value bson.RawValue) {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		if len(elements) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{ ")
+		for i, element := range elements {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeKey(b, element.Key())
+			b.WriteString(": ")
+			writeValue(b, element.Value())
+		}
+		b.WriteString(" }")
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		b.WriteByte('[')
+		for i, item := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeValue(b, item)
+		}
+		b.WriteByte(']')
+	case bsontype.String:
+		writeString(b, value.StringValue())
+	case bsontype.ObjectID:
+		fmt.Fprintf(b, "ObjectId(%q)", value.ObjectID().Hex())
+	case bsontype.DateTime:
+		fmt.Fprintf(b, "ISODate(%q)", value.Time().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	case bsontype.Int32:
+		b.WriteString(strconv.FormatInt(int64(value.Int32()), 10))
+	case bsontype.Int64:
+		fmt.Fprintf(b, "NumberLong(%q)", strconv.FormatInt(value.Int64(), 10))
+	case bsontype.Double:
+		f := value.Double()
+		switch {
+		case math.IsNaN(f):
+			b.WriteString("NaN")
+		case math.IsInf(f, 1):
+			b.WriteString("Infinity")
+		case math.IsInf(f, -1):
+			b.WriteString("-Infinity")
+		default:
+			b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case bsontype.Decimal128:
+		fmt.Fprintf(b, "NumberDecimal(%q)", value.Decimal128().String())
+	case bsontype.Boolean:
+		b.WriteString(strconv.FormatBool(value.Boolean()))
+	case bsontype.Null:
+		b.WriteString("null")
+	case bsontype.Undefined:
+		b.WriteString("undefined")
+	case bsontype.Regex:
+		pattern, options := value.Regex()
+		fmt.Fprintf(b, "/%s/%s", escapeSlashes(pattern), options)
+	case bsontype.Binary:
+		subtype, data := value.Binary()
+		fmt.Fprintf(b, "BinData(%d, %q)", subtype, base64.StdEncoding.EncodeToString(data))
+	case bsontype.Timestamp:
+		t, i := value.Timestamp()
+		fmt.Fprintf(b, "Timestamp({ t: %d, i: %d })", t, i)
+	case bsontype.MinKey:
+		b.WriteString("MinKey()")
+	case bsontype.MaxKey:
+		b.WriteString("MaxKey()")
+	case bsontype.JavaScript:
+		fmt.Fprintf(b, "Code(%q)", value.JavaScript())
+	default:
+		// remaining deprecated types fall back to Extended JSON
+		b.WriteString(value.String())
+	}
+}
+
+// escapeSlashes escapes the slashes of a regex pattern for a /literal/,
+// leaving the ones the pattern already escapes alone. An empty pattern
+// becomes (?:), since // starts a comment.
+func escapeSlashes(pattern string) string {
+	if pattern == "" {
+		return "(?:)"
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		if r == '/' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestShell(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5e3b37e51c9d4400004117e6")
+	decimal, _ := primitive.ParseDecimal128("1.50")
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"document", bson.D{{"title", "Go"}, {"duration", 25}}, `{ title: "Go", duration: 25 }`},
+		{"empty document", bson.D{}, "{}"},
+		{"quoted keys", bson.D{{"a.b", 1}, {"$gt", 2}, {"has space", 3}}, `{ "a.b": 1, $gt: 2, "has space": 3 }`},
+		{"array", bson.A{"a", 1, true, nil}, `["a", 1, true, null]`},
+		{"string escaping", `say "hi"` + "\n", `"say \"hi\"\n"`},
+		{"ObjectId", id, `ObjectId("5e3b37e51c9d4400004117e6")`},
+		{"date", time.Date(2020, 2, 5, 10, 30, 0, 0, time.UTC), `ISODate("2020-02-05T10:30:00.000Z")`},
+		{"int64", int64(7), `NumberLong("7")`},
+		{"double", 2.5, "2.5"},
+		{"NaN", math.NaN(), "NaN"},
+		{"infinity", math.Inf(-1), "-Infinity"},
+		{"decimal", decimal, `NumberDecimal("1.50")`},
+		{"binary", primitive.Binary{Subtype: 0, Data: []byte("go")}, `BinData(0, "Z28=")`},
+		{"timestamp", primitive.Timestamp{T: 10, I: 2}, "Timestamp({ t: 10, i: 2 })"},
+		{"regex", primitive.Regex{Pattern: "^go", Options: "i"}, "/^go/i"},
+		{"regex with a slash", primitive.Regex{Pattern: "a/b"}, `/a\/b/`},
+		{"regex with an escaped slash", primitive.Regex{Pattern: `a\/b`}, `/a\/b/`},
+		{"regex with an escaped backslash before a slash", primitive.Regex{Pattern: `a\\/b`}, `/a\\\/b/`},
+		{"empty regex", primitive.Regex{}, "/(?:)/"},
+		{"MinKey", primitive.MinKey{}, "MinKey()"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Shell(test.value); got != test.want {
+				t.Errorf("Shell = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline mongo.Pipeline
+		want     string
+	}{
+		{"empty", nil, "[]"},
+		{"one stage per line", mongo.Pipeline{
+			{{"$match", bson.D{{"duration", bson.D{{"$gt", 10}}}}}},
+			{{"$limit", 5}},
+		}, "[\n  { $match: { duration: { $gt: 10 } } },\n  { $limit: 5 }\n]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := String(test.pipeline); got != test.want {
+				t.Errorf("String =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
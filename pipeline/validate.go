@@ -0,0 +1,364 @@
+// Package pipeline helps with hand-written aggregation pipelines: Validate
+// catches structural mistakes before the pipeline reaches the server and
+// String prints it in mongosh syntax for debugging.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// stages lists the aggregation stages accepted by db.collection.aggregate
+var stages = map[string]bool{
+	"$addFields": true, "$bucket": true, "$bucketAuto": true, "$changeStream": true,
+	"$changeStreamSplitLargeEvent": true, "$collStats": true, "$count": true, "$currentOp": true, "$densify": true,
+	"$documents": true, "$facet": true, "$fill": true, "$geoNear": true, "$graphLookup": true,
+	"$group": true, "$indexStats": true, "$limit": true, "$listLocalSessions": true, "$listSampledQueries": true,
+	"$listSearchIndexes": true, "$listSessions": true, "$lookup": true, "$match": true,
+	"$merge": true, "$out": true, "$planCacheStats": true, "$project": true, "$queryStats": true, "$redact": true,
+	"$replaceRoot": true, "$replaceWith": true, "$sample": true, "$search": true,
+	"$searchMeta": true, "$set": true, "$setWindowFields": true, "$skip": true, "$sort": true,
+	"$sortByCount": true, "$unionWith": true, "$unset": true, "$unwind": true, "$vectorSearch": true,
+}
+
+// first and last list stages that are only valid in that position
+var (
+	first = map[string]bool{
+		"$changeStream": true, "$collStats": true, "$currentOp": true, "$documents": true, "$geoNear": true,
+		"$indexStats": true, "$search": true, "$searchMeta": true, "$vectorSearch": true,
+		"$listLocalSessions": true, "$listSampledQueries": true, "$listSearchIndexes": true,
+		"$listSessions": true, "$planCacheStats": true, "$queryStats": true,
+	}
+	last = map[string]bool{"$out": true, "$merge": true}
+)
+
+// StageError describes a problem with one stage
+type StageError struct {
+	Index   int
+	Stage   string
+	Message string
+}
+
+func (e *StageError) Error() string {
+	if e.Stage == "" {
+		return fmt.Sprintf("stage %d: %s", e.Index, e.Message)
+	}
+	return fmt.Sprintf("stage %d (%s): %s", e.Index, e.Stage, e.Message)
+}
+
+// Validate performs local structural checks on p: every stage must be a
+// single known stage operator in a valid position, and $match, $sort and
+// $group must not refer to top-level fields an earlier $project, $unset,
+// $group or $count has removed. It returns all problems joined together.
+// Passing Validate does not mean the server will accept the pipeline.
+func Validate(p mongo.Pipeline) error {
+	var errs []error
+	fail := func(index int, stage, format string, args ...interface{}) {
+		errs = append(errs, &StageError{Index: index, Stage: stage, Message: fmt.Sprintf(format, args...)})
+	}
+	// fields tracks the top-level fields after the previous stage
+	fields := unknownFields()
+
+	for i, stage := range p {
+		if len(stage) != 1 {
+			fail(i, "", "a stage must have exactly one field, found %d", len(stage))
+			continue
+		}
+		name := stage[0].Key
+		if !stages[name] {
+			fail(i, name, "unknown stage")
+			continue
+		}
+		if first[name] && i != 0 {
+			fail(i, name, "must be the first stage")
+		}
+		if last[name] && i != len(p)-1 {
+			fail(i, name, "must be the last stage")
+		}
+
+		spec, err := marshalSpec(stage[0].Value)
+		if err != nil {
+			fail(i, name, "%v", err)
+			fields = unknownFields()
+			continue
+		}
+		for _, field := range referencedFields(name, spec) {
+			if !fields.has(field) {
+				fail(i, name, "field %q was removed by an earlier stage", field)
+			}
+		}
+		fields = nextFields(name, spec, fields)
+	}
+	return errors.Join(errs...)
+}
+
+// marshalSpec turns a stage's value into raw BSON so any Go type is handled alike
+func marshalSpec(value interface{}) (bson.RawValue, error) {
+	data, err := bson.Marshal(bson.D{{"spec", value}})
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	return bson.Raw(data).Lookup("spec"), nil
+}
+
+// referencedFields returns the top-level fields a stage reads directly
+func referencedFields(name string, spec bson.RawValue) []string {
+	var refs []string
+	switch name {
+	case "$match":
+		if spec.Type == bsontype.EmbeddedDocument {
+			refs = queryFields(spec.Document())
+		}
+	case "$sort":
+		if spec.Type == bsontype.EmbeddedDocument {
+			elements, _ := spec.Document().Elements()
+			for _, element := range elements {
+				refs = append(refs, topLevel(element.Key()))
+			}
+		}
+	case "$group":
+		if spec.Type == bsontype.EmbeddedDocument {
+			refs = fieldPaths(spec)
+		}
+	}
+	return refs
+}
+
+// queryFields collects the fields of a query document, descending into
+// $and, $or and $nor. Other operators such as $expr are not inspected.
+func queryFields(query bson.Raw) []string {
+	var refs []string
+	elements, _ := query.Elements()
+	for _, element := range elements {
+		key := element.Key()
+		if !strings.HasPrefix(key, "$") {
+			refs = append(refs, topLevel(key))
+			continue
+		}
+		if key == "$and" || key == "$or" || key == "$nor" {
+			values, _ := element.Value().Array().Values()
+			for _, value := range values {
+				if value.Type == bsontype.EmbeddedDocument {
+					refs = append(refs, queryFields(value.Document())...)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// fieldPaths collects "$field" references in an expression, skipping
+// "$$variables"
+func fieldPaths(value bson.RawValue) []string {
+	var refs []string
+	switch value.Type {
+	case bsontype.String:
+		s := value.StringValue()
+		if strings.HasPrefix(s, "$") && !strings.HasPrefix(s, "$$") {
+			refs = append(refs, topLevel(s[1:]))
+		}
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		for _, element := range elements {
+			refs = append(refs, fieldPaths(element.Value())...)
+		}
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		for _, item := range values {
+			refs = append(refs, fieldPaths(item)...)
+		}
+	}
+	return refs
+}
+
+func topLevel(path string) string {
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// fieldSet tracks which top-level fields documents can have. A closed set
+// lists every field that may exist (after an inclusion $project or a $group);
+// an open set allows any field except the ones known to be removed.
+type fieldSet struct {
+	closed  bool
+	present map[string]bool
+	removed map[string]bool
+}
+
+func unknownFields() fieldSet {
+	return fieldSet{removed: map[string]bool{}}
+}
+
+func onlyFields(fields ...string) fieldSet {
+	set := fieldSet{closed: true, present: map[string]bool{}}
+	for _, field := range fields {
+		set.present[field] = true
+	}
+	return set
+}
+
+func (s fieldSet) has(field string) bool {
+	if s.closed {
+		return s.present[field]
+	}
+	return !s.removed[field]
+}
+
+// copy returns a set that can be changed without affecting s
+func (s fieldSet) copy() fieldSet {
+	next := fieldSet{closed: s.closed, present: map[string]bool{}, removed: map[string]bool{}}
+	for field := range s.present {
+		next.present[field] = true
+	}
+	for field := range s.removed {
+		next.removed[field] = true
+	}
+	return next
+}
+
+func (s fieldSet) remove(fields ...string) fieldSet {
+	next := s.copy()
+	for _, field := range fields {
+		delete(next.present, field)
+		next.removed[field] = true
+	}
+	return next
+}
+
+func (s fieldSet) add(fields ...string) fieldSet {
+	next := s.copy()
+	for _, field := range fields {
+		next.present[field] = true
+		delete(next.removed, field)
+	}
+	return next
+}
+
+// nextFields returns the fields available after the stage
+func nextFields(name string, spec bson.RawValue, fields fieldSet) fieldSet {
+	switch name {
+	case "$project":
+		if spec.Type != bsontype.EmbeddedDocument {
+			return unknownFields()
+		}
+		elements, _ := spec.Document().Elements()
+		included := []string{"_id"}
+		var excluded []string
+		inclusion := false
+		for _, element := range elements {
+			key := topLevel(element.Key())
+			if isExclusion(element.Value()) {
+				// a dotted path only removes part of a field
+				if !strings.Contains(element.Key(), ".") {
+					excluded = append(excluded, key)
+				}
+				continue
+			}
+			inclusion = true
+			included = append(included, key)
+		}
+		if inclusion {
+			// only _id may be excluded alongside included fields
+			return onlyFields(included...).remove(excluded...)
+		}
+		return fields.remove(excluded...)
+	case "$unset":
+		var removed []string
+		switch spec.Type {
+		case bsontype.String:
+			removed = []string{spec.StringValue()}
+		case bsontype.Array:
+			values, _ := spec.Array().Values()
+			for _, value := range values {
+				if s, ok := value.StringValueOK(); ok {
+					removed = append(removed, s)
+				}
+			}
+		}
+		// dotted paths only remove part of a field
+		var topLevelOnly []string
+		for _, field := range removed {
+			if !strings.Contains(field, ".") {
+				topLevelOnly = append(topLevelOnly, field)
+			}
+		}
+		return fields.remove(topLevelOnly...)
+	case "$group":
+		next := onlyFields("_id")
+		if spec.Type == bsontype.EmbeddedDocument {
+			elements, _ := spec.Document().Elements()
+			for _, element := range elements {
+				next.present[element.Key()] = true
+			}
+		}
+		return next
+	case "$count":
+		if s, ok := spec.StringValueOK(); ok {
+			return onlyFields(s)
+		}
+		return unknownFields()
+	case "$sortByCount":
+		return onlyFields("_id", "count")
+	case "$addFields", "$set", "$lookup", "$graphLookup", "$setWindowFields", "$unwind":
+		return fields.add(addedFields(name, spec)...)
+	case "$match", "$sort", "$limit", "$skip", "$sample", "$unionWith", "$densify", "$fill":
+		return fields
+	}
+	return unknownFields()
+}
+
+// addedFields returns the top-level fields a stage adds to each document
+func addedFields(name string, spec bson.RawValue) []string {
+	if spec.Type != bsontype.EmbeddedDocument {
+		return nil
+	}
+	document := spec.Document()
+	switch name {
+	case "$addFields", "$set":
+		var added []string
+		elements, _ := document.Elements()
+		for _, element := range elements {
+			added = append(added, topLevel(element.Key()))
+		}
+		return added
+	case "$lookup", "$graphLookup":
+		if as, ok := document.Lookup("as").StringValueOK(); ok {
+			return []string{topLevel(as)}
+		}
+	case "$setWindowFields":
+		if output, ok := document.Lookup("output").DocumentOK(); ok {
+			var added []string
+			elements, _ := output.Elements()
+			for _, element := range elements {
+				added = append(added, topLevel(element.Key()))
+			}
+			return added
+		}
+	case "$unwind":
+		if index, ok := document.Lookup("includeArrayIndex").StringValueOK(); ok {
+			return []string{index}
+		}
+	}
+	return nil
+}
+
+func isExclusion(value bson.RawValue) bool {
+	switch value.Type {
+	case bsontype.Boolean:
+		return !value.Boolean()
+	case bsontype.Int32:
+		return value.Int32() == 0
+	case bsontype.Int64:
+		return value.Int64() == 0
+	case bsontype.Double:
+		return value.Double() == 0
+	}
+	return false
+}
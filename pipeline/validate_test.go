@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline mongo.Pipeline
+		// problems are substrings of the expected errors, one per problem
+		problems []string
+	}{
+		{"empty", mongo.Pipeline{}, nil},
+		{"match, group and sort", mongo.Pipeline{
+			{{"$match", bson.D{{"duration", bson.D{{"$gt", 10}}}}}},
+			{{"$group", bson.D{{"_id", "$podcast"}, {"total", bson.D{{"$sum", "$duration"}}}}}},
+			{{"$sort", bson.D{{"total", -1}}}},
+		}, nil},
+		{"op-killer's $currentOp pipeline", mongo.Pipeline{
+			{{"$currentOp", bson.D{{"allUsers", true}, {"idleConnections", false}}}},
+			{{"$match", bson.D{{"active", true}, {"command.$currentOp", bson.D{{"$exists", false}}}}}},
+			{{"$sort", bson.D{{"secs_running", -1}}}},
+		}, nil},
+		{"$listLocalSessions", mongo.Pipeline{{{"$listLocalSessions", bson.D{}}}}, nil},
+		{"$queryStats", mongo.Pipeline{{{"$queryStats", bson.D{}}}}, nil},
+		{"unknown stage", mongo.Pipeline{{{"$matches", bson.D{}}}}, []string{"stage 0 ($matches): unknown stage"}},
+		{"two operators in one stage", mongo.Pipeline{{{"$match", bson.D{}}, {"$limit", 1}}},
+			[]string{"exactly one field, found 2"}},
+		{"$currentOp after another stage", mongo.Pipeline{
+			{{"$match", bson.D{}}},
+			{{"$currentOp", bson.D{}}},
+		}, []string{"stage 1 ($currentOp): must be the first stage"}},
+		{"$out before the end", mongo.Pipeline{
+			{{"$out", "copy"}},
+			{{"$limit", 1}},
+		}, []string{"must be the last stage"}},
+		{"field removed by $project", mongo.Pipeline{
+			{{"$project", bson.D{{"title", 1}}}},
+			{{"$match", bson.D{{"duration", 25}}}},
+		}, []string{`field "duration" was removed`}},
+		{"_id kept by an inclusion $project", mongo.Pipeline{
+			{{"$project", bson.D{{"title", 1}}}},
+			{{"$sort", bson.D{{"_id", 1}}}},
+		}, nil},
+		{"field removed by an exclusion $project", mongo.Pipeline{
+			{{"$project", bson.D{{"description", 0}}}},
+			{{"$match", bson.D{{"description.lang", "en"}}}},
+		}, []string{`field "description" was removed`}},
+		{"dotted exclusion keeps the rest of the field", mongo.Pipeline{
+			{{"$project", bson.D{{"profile.email", 0}}}},
+			{{"$match", bson.D{{"profile.city", "Munich"}}}},
+		}, nil},
+		{"dotted $unset keeps the rest of the field", mongo.Pipeline{
+			{{"$unset", "profile.email"}},
+			{{"$match", bson.D{{"profile.city", "Munich"}}}},
+		}, nil},
+		{"field removed by $unset", mongo.Pipeline{
+			{{"$unset", bson.A{"tags", "notes"}}},
+			{{"$match", bson.D{{"$or", bson.A{bson.D{{"tags", "go"}}, bson.D{{"title", "x"}}}}}}},
+		}, []string{`field "tags" was removed`}},
+		{"field left behind by $group", mongo.Pipeline{
+			{{"$group", bson.D{{"_id", "$podcast"}, {"total", bson.D{{"$sum", "$duration"}}}}}},
+			{{"$group", bson.D{{"_id", "$title"}}}},
+		}, []string{`field "title" was removed`}},
+		{"field added back by $set", mongo.Pipeline{
+			{{"$count", "n"}},
+			{{"$set", bson.D{{"label", "all"}}}},
+			{{"$sort", bson.D{{"label", 1}, {"n", -1}}}},
+		}, nil},
+		{"every problem reported", mongo.Pipeline{
+			{{"$limit", 1}},
+			{{"$documents", bson.A{}}},
+			{{"$nope", 1}},
+		}, []string{"must be the first stage", "unknown stage"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.pipeline)
+			if len(test.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate = nil, want %q", test.problems)
+			}
+			var stageErr *StageError
+			if !errors.As(err, &stageErr) {
+				t.Errorf("Validate = %v, want StageErrors", err)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(test.problems) {
+				t.Fatalf("Validate = %q, want %d problem(s)", lines, len(test.problems))
+			}
+			for i, problem := range test.problems {
+				if !strings.Contains(lines[i], problem) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, lines[i], problem)
+				}
+			}
+		})
+	}
+}
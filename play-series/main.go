@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Point represents an aggregation result-set of one day of a podcast's play
+// count series. Days without listens have Observed false and Plays 0;
+// Interpolated is the straight line between the surrounding observed days,
+// and nil before the first or after the last of them.
+type Point struct {
+	Podcast      primitive.ObjectID `bson:"podcast"`
+	Day          time.Time          `bson:"day"`
+	Plays        int64              `bson:"plays"`
+	Interpolated *float64           `bson:"interpolated"`
+	Observed     bool               `bson:"observed"`
+}
+
+// seriesPipeline counts listens per podcast and day, adds the days without
+// listens with $densify (MongoDB 5.1+) and fills their values with $fill
+// (MongoDB 5.3+)
+func seriesPipeline(from, to time.Time) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"listened_at", bson.D{{"$gte", from}, {"$lt", to}}}}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{
+			{"podcast", "$podcast"},
+			{"day", bson.D{{"$dateTrunc", bson.D{{"date", "$listened_at"}, {"unit", "day"}}}}},
+		}},
+		{"plays", bson.D{{"$sum", 1}}},
+	}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"podcast", "$_id.podcast"},
+		{"day", "$_id.day"},
+		{"plays", 1},
+		{"interpolated", "$plays"},
+		{"observed", bson.D{{"$literal", true}}},
+	}}}
+	// one document per podcast and day in [from, to), the new ones only
+	// carrying podcast and day
+	densifyStage := bson.D{{"$densify", bson.D{
+		{"field", "day"},
+		{"partitionByFields", bson.A{"podcast"}},
+		{"range", bson.D{{"step", 1}, {"unit", "day"}, {"bounds", bson.A{from, to}}}},
+	}}}
+	fillStage := bson.D{{"$fill", bson.D{
+		{"partitionByFields", bson.A{"podcast"}},
+		{"sortBy", bson.D{{"day", 1}}},
+		{"output", bson.D{
+			{"plays", bson.D{{"value", 0}}},
+			{"interpolated", bson.D{{"method", "linear"}}},
+			{"observed", bson.D{{"value", false}}},
+		}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"podcast", 1}, {"day", 1}}}}
+	return mongo.Pipeline{matchStage, groupStage, projectStage, densifyStage, fillStage, sortStage}
+}
+
+// seed inserts listens for one podcast on a few scattered days
+func seed(ctx context.Context, listens *mongo.Collection, from time.Time, days int) (primitive.ObjectID, error) {
+	podcast := primitive.NewObjectID()
+	var documents []interface{}
+	for day := 0; day < days; day += 1 + rand.Intn(4) {
+		for i := rand.Intn(20) + 1; i > 0; i-- {
+			listenedAt := from.AddDate(0, 0, day).Add(time.Duration(rand.Intn(24*60)) * time.Minute)
+			documents = append(documents, bson.D{
+				{"user", primitive.NewObjectID()},
+				{"podcast", podcast},
+				{"listened_at", listenedAt},
+			})
+		}
+	}
+	_, err := listens.InsertMany(ctx, documents)
+	return podcast, err
+}
+
+var (
+	days     = flag.Int("days", 21, "length of the series in days, ending today")
+	seedData = flag.Bool("seed", false, "insert sample listens with gaps for a new podcast first")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	listensCollection := client.Database("quickstart").Collection("listens")
+	to := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -*days)
+	if *seedData {
+		podcast, err := seed(ctx, listensCollection, from, *days)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Seeded listens for podcast", podcast.Hex())
+	}
+
+	// Build Complete Daily Series With $densify And $fill
+	cursor, err := listensCollection.Aggregate(ctx, seriesPipeline(from, to))
+	if err != nil {
+		return err
+	}
+	var points []Point
+	if err = cursor.All(ctx, &points); err != nil {
+		return err
+	}
+
+	// Chart Each Podcast's Series, Marking The Filled Days
+	var current primitive.ObjectID
+	for _, point := range points {
+		if point.Podcast != current {
+			current = point.Podcast
+			fmt.Printf("\nPodcast %s\n", current.Hex())
+		}
+		trend := "   -"
+		if point.Interpolated != nil {
+			trend = fmt.Sprintf("%4.1f", *point.Interpolated)
+		}
+		marker := " "
+		if !point.Observed {
+			marker = "*"
+		}
+		fmt.Printf("%s %s %3d %s  %s\n", point.Day.Format("2006-01-02"), marker, point.Plays, trend,
+			strings.Repeat("#", int(point.Plays)))
+	}
+	fmt.Println("\n* no listens that day; the fourth column interpolates linearly between observed days")
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Total represents an aggregation result-set of the episode count and total
+// duration of one podcast
+type Total struct {
+	Podcast  primitive.ObjectID `bson:"_id" json:"podcast"`
+	Episodes int64              `bson:"episodes" json:"episodes"`
+	Duration int64              `bson:"total" json:"total"`
+}
+
+// contribution is what one episode adds to its podcast's total
+type contribution struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Podcast  primitive.ObjectID `bson:"podcast"`
+	Duration int64              `bson:"duration"`
+}
+
+// totalsPipeline is the totalDurationPipeline of the aggregation example for
+// every podcast at once, with an episode count
+func totalsPipeline() mongo.Pipeline {
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$podcast"},
+		{"episodes", bson.D{{"$sum", 1}}},
+		{"total", bson.D{{"$sum", "$duration"}}},
+	}}}
+	return mongo.Pipeline{groupStage}
+}
+
+// Cache keeps the podcast totals in memory. It remembers each episode's
+// contribution, so an update or delete event can take the old value back out
+// and applying the same event twice changes nothing.
+type Cache struct {
+	Episodes *mongo.Collection
+
+	mu            sync.RWMutex
+	contributions map[primitive.ObjectID]contribution
+	totals        map[primitive.ObjectID]*Total
+}
+
+// Load replaces the cache with contributions read from the collection
+func (c *Cache) Load(ctx context.Context) error {
+	opts := options.Find().SetProjection(bson.D{{"podcast", 1}, {"duration", 1}})
+	cursor, err := c.Episodes.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+	var loaded []contribution
+	if err = cursor.All(ctx, &loaded); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contributions = make(map[primitive.ObjectID]contribution, len(loaded))
+	c.totals = map[primitive.ObjectID]*Total{}
+	for _, episode := range loaded {
+		c.set(episode.ID, &episode)
+	}
+	return nil
+}
+
+// set replaces the contribution of one episode; nil removes it. The caller
+// holds the lock.
+func (c *Cache) set(id primitive.ObjectID, episode *contribution) {
+	if old, ok := c.contributions[id]; ok {
+		total := c.totals[old.Podcast]
+		total.Episodes--
+		total.Duration -= old.Duration
+		if total.Episodes == 0 {
+			delete(c.totals, old.Podcast)
+		}
+		delete(c.contributions, id)
+	}
+	if episode == nil {
+		return
+	}
+	total, ok := c.totals[episode.Podcast]
+	if !ok {
+		total = &Total{Podcast: episode.Podcast}
+		c.totals[episode.Podcast] = total
+	}
+	total.Episodes++
+	total.Duration += episode.Duration
+	c.contributions[id] = *episode
+}
+
+// event is the part of a change event the cache needs. With updateLookup,
+// FullDocument is the current version of the episode, or missing when it
+// was deleted before the lookup.
+type event struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *contribution `bson:"fullDocument"`
+}
+
+// Apply adjusts the totals for one change event. It reports false for
+// events that invalidate the stream, after which the cache must be reloaded.
+func (c *Cache) Apply(change event) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch change.OperationType {
+	case "insert", "update", "replace":
+		c.set(change.DocumentKey.ID, change.FullDocument)
+	case "delete":
+		c.set(change.DocumentKey.ID, nil)
+	case "drop", "rename", "dropDatabase", "invalidate":
+		return false
+	}
+	return true
+}
+
+// Totals returns every total, longest first
+func (c *Cache) Totals() []Total {
+	c.mu.RLock()
+	totals := make([]Total, 0, len(c.totals))
+	for _, total := range c.totals {
+		totals = append(totals, *total)
+	}
+	c.mu.RUnlock()
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Duration > totals[j].Duration })
+	return totals
+}
+
+// Total returns the total of one podcast
+func (c *Cache) Total(podcast primitive.ObjectID) (Total, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	total, ok := c.totals[podcast]
+	if !ok {
+		return Total{}, false
+	}
+	return *total, true
+}
+
+// Reconcile runs the totals pipeline and compares its result with the cache,
+// returning how many podcasts disagree. Events still waiting to be applied
+// can make a podcast disagree briefly, so a mismatch calls for a reload
+// rather than proving the cache wrong.
+func (c *Cache) Reconcile(ctx context.Context) (int, error) {
+	cursor, err := c.Episodes.Aggregate(ctx, totalsPipeline())
+	if err != nil {
+		return 0, err
+	}
+	var fresh []Total
+	if err = cursor.All(ctx, &fresh); err != nil {
+		return 0, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	mismatches := 0
+	seen := make(map[primitive.ObjectID]bool, len(fresh))
+	for _, total := range fresh {
+		seen[total.Podcast] = true
+		if cached, ok := c.totals[total.Podcast]; !ok || *cached != total {
+			mismatches++
+		}
+	}
+	// podcasts still cached but missing from the result lost all their episodes
+	for podcast := range c.totals {
+		if !seen[podcast] {
+			mismatches++
+		}
+	}
+	return mismatches, nil
+}
+
+// Watch applies change events until ctx is done, reconciling every interval.
+// The stream is opened before the initial load, so no change is missed
+// between the two; events already reflected by the load are harmless.
+func (c *Cache) Watch(ctx context.Context, interval time.Duration) error {
+	for ctx.Err() == nil {
+		stream, err := c.Episodes.Watch(ctx, mongo.Pipeline{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+		if err != nil {
+			return err
+		}
+		if err = c.Load(ctx); err != nil {
+			stream.Close(context.Background())
+			return err
+		}
+		log.Printf("loaded totals for %d podcasts", len(c.Totals()))
+		err = c.follow(ctx, stream, interval)
+		stream.Close(context.Background())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("change stream stopped, reloading: %v", err)
+		}
+	}
+	return nil
+}
+
+// follow applies the events of one stream, returning when the stream has to
+// be reopened
+func (c *Cache) follow(ctx context.Context, stream *mongo.ChangeStream, interval time.Duration) error {
+	next := time.Now().Add(interval)
+	for {
+		// TryNext returns after each server round trip even without events,
+		// which leaves room to reconcile on schedule
+		if stream.TryNext(ctx) {
+			var change event
+			if err := stream.Decode(&change); err != nil {
+				return err
+			}
+			if !c.Apply(change) {
+				return nil
+			}
+			continue
+		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+		if time.Now().After(next) {
+			next = time.Now().Add(interval)
+			mismatches, err := c.Reconcile(ctx)
+			if err != nil {
+				return err
+			}
+			if mismatches > 0 {
+				log.Printf("reconciliation found %d podcast(s) out of date, reloading", mismatches)
+				if err = c.Load(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var (
+	addr     = flag.String("addr", ":8080", "HTTP listen address")
+	interval = flag.Duration("reconcile", 10*time.Minute, "time between full reconciliations against the aggregation")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	cache := &Cache{Episodes: client.Database("quickstart").Collection("episodes")}
+	// A failed watch stops the server, since the totals would go stale
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	watchErr := make(chan error, 1)
+	go func() {
+		err := cache.Watch(ctx, *interval)
+		if ctx.Err() != nil {
+			err = nil
+		}
+		watchErr <- err
+		stop()
+	}()
+
+	routes := openapi.New("Quickstart podcast totals", "1.0.0")
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/totals", Summary: "Episode count and total duration of every podcast",
+		Response: []Total{},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, cache.Totals())
+	})
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/totals/{podcast}", Summary: "Episode count and total duration of one podcast",
+		Response: Total{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		podcast, err := primitive.ObjectIDFromHex(r.PathValue("podcast"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "podcast must be an ObjectID"})
+			return
+		}
+		total, ok := cache.Total(podcast)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "podcast has no episodes"})
+			return
+		}
+		writeJSON(w, http.StatusOK, total)
+	})
+
+	log.Printf("serving podcast totals on %s", *addr)
+	if err = shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: routes}); err != nil {
+		return err
+	}
+	return <-watchErr
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mongodb-developer/golang-quickstart/compass"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	databaseName = flag.String("db", "quickstart", "database to query")
+	sortFlag     = flag.String("sort", "", "sort document for a filter, as Extended JSON")
+	projectFlag  = flag.String("project", "", "projection for a filter, as Extended JSON")
+	skip         = flag.Int64("skip", 0, "number of documents to skip for a filter")
+	limit        = flag.Int64("limit", 0, "maximum number of documents to return (0 for no limit)")
+	pageSize     = flag.Int("page", 20, "documents per page when the output is interactive")
+	canonical    = flag.Bool("canonical", false, "print canonical instead of relaxed Extended JSON")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <collection> [filter|pipeline]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "The query is an Extended JSON document (a filter) or array (a pipeline).")
+		fmt.Fprintln(flag.CommandLine.Output(), "Without it, or with \"-\", the query is read from standard input.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		return shutdown.ErrUsage
+	}
+
+	query := flag.Arg(1)
+	fromStdin := query == "" || query == "-"
+	if fromStdin {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		query = string(data)
+	}
+
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+	collection := client.Database(*databaseName).Collection(flag.Arg(0))
+
+	var cursor *mongo.Cursor
+	if strings.HasPrefix(strings.TrimSpace(query), "[") {
+		pipeline, err := compass.ParsePipeline(query)
+		if err != nil {
+			return fmt.Errorf("pipeline: %v", err)
+		}
+		if *limit > 0 {
+			pipeline = append(pipeline, bson.D{{"$limit", *limit}})
+		}
+		cursor, err = collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+	} else {
+		filter, err := compass.Parse(query)
+		if err != nil {
+			return fmt.Errorf("filter: %v", err)
+		}
+		opts := options.Find().SetSkip(*skip)
+		if *limit > 0 {
+			opts.SetLimit(*limit)
+		}
+		if *sortFlag != "" {
+			sort, err := compass.Parse(*sortFlag)
+			if err != nil {
+				return fmt.Errorf("-sort: %v", err)
+			}
+			opts.SetSort(sort)
+		}
+		if *projectFlag != "" {
+			projection, err := compass.Parse(*projectFlag)
+			if err != nil {
+				return fmt.Errorf("-project: %v", err)
+			}
+			opts.SetProjection(projection)
+		}
+		cursor, err = collection.Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+	}
+	down.Cursor(cursor)
+
+	// page only when a person is reading and can answer the prompt
+	var prompt *bufio.Scanner
+	if !fromStdin && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		prompt = bufio.NewScanner(os.Stdin)
+	}
+	if err = printResults(ctx, cursor, os.Stdout, *canonical, *pageSize, prompt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// printResults prints every document as indented Extended JSON. With a
+// prompt it stops after each page and asks before continuing, like mongosh.
+func printResults(ctx context.Context, cursor *mongo.Cursor, w io.Writer, canonical bool, pageSize int, prompt *bufio.Scanner) error {
+	count := 0
+	for cursor.Next(ctx) {
+		if prompt != nil && pageSize > 0 && count > 0 && count%pageSize == 0 {
+			fmt.Fprint(w, `Type "it" for more, anything else to stop: `)
+			if !prompt.Scan() {
+				break
+			}
+			if answer := strings.TrimSpace(prompt.Text()); answer != "it" && answer != "" {
+				break
+			}
+		}
+		data, err := bson.MarshalExtJSONIndent(cursor.Current, canonical, false, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", data)
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d document(s)\n", count)
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
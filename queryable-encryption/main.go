@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/localkey"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const keyVaultNamespace = "encryption.__keyVault"
+
+// Patient is stored with ssn and insurance encrypted. ssn supports equality
+// queries; insurance can only be read back, never filtered on.
+type Patient struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	SSN       string             `bson:"ssn"`
+	Insurance Insurance          `bson:"insurance"`
+}
+
+// Insurance is encrypted as a whole embedded document
+type Insurance struct {
+	Provider string `bson:"provider"`
+	Policy   string `bson:"policy"`
+}
+
+// encryptedFields lists the fields the server stores encrypted. A null keyId
+// asks CreateEncryptedCollection to create a data key for that field. Unlike
+// CSFLE's $jsonSchema, the server keeps this document with the collection and
+// rejects plaintext writes to these fields from any client.
+var encryptedFields = bson.D{
+	{"fields", bson.A{
+		bson.D{
+			{"path", "ssn"},
+			{"bsonType", "string"},
+			{"keyId", nil},
+			{"queries", bson.D{{"queryType", "equality"}}},
+		},
+		bson.D{
+			{"path", "insurance"},
+			{"bsonType", "object"},
+			{"keyId", nil},
+		},
+	}},
+}
+
+var (
+	keyFile     = flag.String("key-file", "qe-master-key.txt", "local master key, created when missing")
+	cryptShared = flag.String("crypt-shared", os.Getenv("CRYPT_SHARED_LIB_PATH"), "path to the crypt_shared library (default: $CRYPT_SHARED_LIB_PATH); mongocryptd is spawned without it")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	key, err := localkey.Load(*keyFile)
+	if err != nil {
+		return err
+	}
+	kmsProviders := localkey.Providers(key)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// The Encrypted Client Finds Each Collection's encryptedFields On The
+	// Server. The empty map also makes Drop remove the metadata collections
+	// Queryable Encryption keeps next to an encrypted collection.
+	autoEncryption := options.AutoEncryption().
+		SetKeyVaultNamespace(keyVaultNamespace).
+		SetKmsProviders(kmsProviders).
+		SetEncryptedFieldsMap(map[string]interface{}{})
+	if *cryptShared != "" {
+		autoEncryption.SetExtraOptions(map[string]interface{}{
+			"cryptSharedLibPath":     *cryptShared,
+			"cryptSharedLibRequired": true,
+		})
+	}
+	encryptedClient, err := db.Connect(ctx, options.Client().SetAutoEncryptionOptions(autoEncryption))
+	if err != nil {
+		return fmt.Errorf("connect with automatic encryption (needs libmongocrypt and a build with -tags cse): %w", err)
+	}
+	down.Client(encryptedClient)
+
+	encryption, err := mongo.NewClientEncryption(client, options.ClientEncryption().
+		SetKeyVaultNamespace(keyVaultNamespace).
+		SetKmsProviders(kmsProviders))
+	if err != nil {
+		return fmt.Errorf("client encryption: %w", err)
+	}
+	defer encryption.Close(context.Background())
+
+	// Create The Encrypted Collection And One Data Key Per Field
+	database := encryptedClient.Database("quickstart")
+	if err = database.Collection("qe_patients").Drop(ctx); err != nil {
+		return fmt.Errorf("drop qe_patients: %w", err)
+	}
+	patientsCollection, fields, err := encryption.CreateEncryptedCollection(ctx, database, "qe_patients",
+		options.CreateCollection().SetEncryptedFields(encryptedFields), "local", nil)
+	if err != nil {
+		return fmt.Errorf("create encrypted collection qe_patients (needs MongoDB 7.0 on a replica set): %w", err)
+	}
+	for _, field := range fields["fields"].(bson.A) {
+		field := field.(bson.M)
+		fmt.Printf("Encrypting %s with data key %x\n", field["path"], field["keyId"].(primitive.Binary).Data)
+	}
+
+	_, err = patientsCollection.InsertMany(ctx, []interface{}{
+		Patient{Name: "Jon Doe", SSN: "987-65-4320", Insurance: Insurance{Provider: "MaestCare", Policy: "123142"}},
+		Patient{Name: "Jane Roe", SSN: "123-45-6789", Insurance: Insurance{Provider: "MaestCare", Policy: "591826"}},
+	})
+	if err != nil {
+		return fmt.Errorf("insert into qe_patients: %w", err)
+	}
+
+	// Query An Encrypted Field By Equality
+	// the driver encrypts the value in the filter and the server matches it
+	// without ever seeing the plaintext
+	var patient Patient
+	if err = patientsCollection.FindOne(ctx, bson.D{{"ssn", "123-45-6789"}}).Decode(&patient); err != nil {
+		return fmt.Errorf("find patient by ssn: %w", err)
+	}
+	fmt.Printf("Encrypted client: %s, ssn %s, insured by %s under policy %s\n",
+		patient.Name, patient.SSN, patient.Insurance.Provider, patient.Insurance.Policy)
+
+	// Fields Without Queries Cannot Be Filtered On
+	err = patientsCollection.FindOne(ctx, bson.D{{"insurance.policy", "591826"}}).Err()
+	fmt.Printf("Filtering on insurance.policy fails: %v\n", err)
+
+	// Without The Keys The Server's Copy Only Holds Ciphertext
+	var raw bson.M
+	err = client.Database("quickstart").Collection("qe_patients").
+		FindOne(ctx, bson.D{{"name", "Jane Roe"}}).Decode(&raw)
+	if err != nil {
+		return fmt.Errorf("find patient without encryption: %w", err)
+	}
+	ssn := raw["ssn"].(primitive.Binary)
+	// __safeContent__ holds the tags the server matches equality queries on
+	fmt.Printf("Plain client: %s ssn=Binary(subtype %d, %d bytes), %d __safeContent__ tag(s)\n",
+		raw["name"], ssn.Subtype, len(ssn.Data), len(raw["__safeContent__"].(bson.A)))
+	return nil
+}
@@ -0,0 +1,274 @@
+// Package querycache memoizes the results of repository reads for a short
+// time, for read-heavy endpoints such as top charts where results a few
+// seconds old are fine:
+//
+//	chart := querycache.New[Podcast](repository.New[Podcast](collection), 5*time.Second)
+//	top, err := chart.Aggregate(ctx, pipeline)
+//
+// Results are keyed by a hash of the canonicalized filter or pipeline and of
+// the options that change the result, so maxTimeMS and other limits do not
+// split the cache. Writes made through the Store clear it; writes made
+// anywhere else show up once the entries expire.
+//
+// A Store whose results also depend on the context, such as a
+// scoped.Repository reading only the owner's documents, must say so with
+// Scope, or one owner would be served another's cached results. New sets it
+// for a scoped.Repository; set it yourself when the scoped store is wrapped
+// in something else.
+package querycache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"github.com/mongodb-developer/golang-quickstart/scoped"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMaxEntries is the number of results a Store keeps unless told
+// otherwise
+const DefaultMaxEntries = 1000
+
+// Stats counts the reads served from the cache and from the database
+type Stats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// Store caches the results of Find and Aggregate on another Store. The
+// slices it returns are copies, but the documents in them are shallow
+// copies of the cached ones: callers must not modify maps or slices inside
+// them.
+type Store[T any] struct {
+	Next repository.Store[T]
+	TTL  time.Duration
+	// MaxEntries bounds memory use. When the cache is full, new results are
+	// not cached until older ones expire.
+	MaxEntries int
+	// Scope returns what the results of Next depend on besides the query,
+	// which becomes part of the key. Reads for which it returns false are
+	// not cached. Nil means the results depend on the query alone.
+	Scope func(ctx context.Context) (interface{}, bool)
+
+	mu         sync.Mutex
+	entries    map[string]entry[T]
+	generation uint64
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+type entry[T any] struct {
+	documents []T
+	expires   time.Time
+}
+
+var _ repository.Store[struct{}] = (*Store[struct{}])(nil)
+
+// New returns a Store keeping results of next for ttl, scoped by owner when
+// next is a scoped.Repository
+func New[T any](next repository.Store[T], ttl time.Duration) *Store[T] {
+	s := &Store[T]{Next: next, TTL: ttl, MaxEntries: DefaultMaxEntries}
+	if _, ok := next.(*scoped.Repository[T]); ok {
+		s.Scope = scoped.Owner
+	}
+	return s
+}
+
+// Find returns the documents matching filter, from the cache when the same
+// query ran less than TTL ago
+func (s *Store[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	merged := options.MergeFindOptions(opts...)
+	key, err := s.key(ctx, "find", filter, bson.D{
+		{"sort", merged.Sort},
+		{"projection", merged.Projection},
+		{"skip", merged.Skip},
+		{"limit", merged.Limit},
+		{"collation", merged.Collation},
+		{"let", merged.Let},
+	})
+	if err != nil {
+		// the driver cannot marshal the filter either and will say why, or
+		// the read has no scope to cache it under
+		return s.Next.Find(ctx, filter, opts...)
+	}
+	return s.cached(key, func() ([]T, error) {
+		return s.Next.Find(ctx, filter, opts...)
+	})
+}
+
+// Aggregate returns the results of pipeline, from the cache when the same
+// pipeline ran less than TTL ago. Pipelines ending in $out or $merge must
+// not be cached, since a hit would skip the write.
+func (s *Store[T]) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]T, error) {
+	merged := options.MergeAggregateOptions(opts...)
+	key, err := s.key(ctx, "aggregate", pipeline, bson.D{
+		{"collation", merged.Collation},
+		{"let", merged.Let},
+	})
+	if err != nil {
+		return s.Next.Aggregate(ctx, pipeline, opts...)
+	}
+	return s.cached(key, func() ([]T, error) {
+		return s.Next.Aggregate(ctx, pipeline, opts...)
+	})
+}
+
+// FindByID is not cached: a read by _id is already cheap
+func (s *Store[T]) FindByID(ctx context.Context, id interface{}) (T, error) {
+	return s.Next.FindByID(ctx, id)
+}
+
+// Insert stores document and clears the cache
+func (s *Store[T]) Insert(ctx context.Context, document T) (interface{}, error) {
+	defer s.Clear()
+	return s.Next.Insert(ctx, document)
+}
+
+// UpdateByID updates a document and clears the cache
+func (s *Store[T]) UpdateByID(ctx context.Context, id interface{}, update interface{}) error {
+	defer s.Clear()
+	return s.Next.UpdateByID(ctx, id, update)
+}
+
+// DeleteByID deletes a document and clears the cache
+func (s *Store[T]) DeleteByID(ctx context.Context, id interface{}) error {
+	defer s.Clear()
+	return s.Next.DeleteByID(ctx, id)
+}
+
+// Clear drops every cached result. Reads already running when it is called
+// return their results without caching them, since they may predate a write.
+func (s *Store[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+	s.generation++
+}
+
+// Stats returns the hit and miss counts so far and the current number of
+// entries, ready to publish with expvar.Func
+func (s *Store[T]) Stats() Stats {
+	s.mu.Lock()
+	entries := len(s.entries)
+	s.mu.Unlock()
+	return Stats{Hits: s.hits.Load(), Misses: s.misses.Load(), Entries: entries}
+}
+
+// errNoScope keeps reads for which Scope returns false out of the cache
+var errNoScope = errors.New("no scope")
+
+// key returns Key with the scope of the read added to opts
+func (s *Store[T]) key(ctx context.Context, operation string, query interface{}, opts bson.D) (string, error) {
+	if s.Scope != nil {
+		scope, ok := s.Scope(ctx)
+		if !ok {
+			return "", errNoScope
+		}
+		opts = append(opts, bson.E{Key: "scope", Value: scope})
+	}
+	return Key(operation, query, opts)
+}
+
+// cached returns the unexpired entry for key or stores the result of load
+func (s *Store[T]) cached(key string, load func() ([]T, error)) ([]T, error) {
+	s.mu.Lock()
+	cached, ok := s.entries[key]
+	generation := s.generation
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		s.hits.Add(1)
+		return slices.Clone(cached.documents), nil
+	}
+
+	s.misses.Add(1)
+	documents, err := load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return documents, nil
+	}
+	if len(s.entries) >= s.MaxEntries {
+		for key, expired := range s.entries {
+			if !now.Before(expired.expires) {
+				delete(s.entries, key)
+			}
+		}
+	}
+	if len(s.entries) < s.MaxEntries {
+		if s.entries == nil {
+			s.entries = map[string]entry[T]{}
+		}
+		s.entries[key] = entry[T]{documents: documents, expires: now.Add(s.TTL)}
+	}
+	return slices.Clone(documents), nil
+}
+
+// Key hashes an operation, its filter or pipeline and the options that change
+// its result. Filters that differ only in the order of their top-level fields
+// or of the operators on a field, such as {$lt: 5, $gt: 1} and
+// {$gt: 1, $lt: 5}, get the same key. The order of fields inside literal
+// documents is kept, since {a: {x: 1, y: 2}} matches different documents than
+// {a: {y: 2, x: 1}}.
+func Key(operation string, query interface{}, opts bson.D) (string, error) {
+	if query == nil {
+		query = bson.D{}
+	}
+	t, data, err := bson.MarshalValue(query)
+	if err != nil {
+		return "", err
+	}
+	document, err := bson.Marshal(bson.D{
+		{"operation", operation},
+		{"query", canonical(bson.RawValue{Type: t, Value: data}, t == bsontype.EmbeddedDocument)},
+		{"options", opts},
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(document)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonical sorts the fields of documents whose order does not matter: the
+// top level of a filter, when sortFields is set, and documents made only of
+// operators
+func canonical(value bson.RawValue, sortFields bool) interface{} {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		elements, _ := value.Document().Elements()
+		document := make(bson.D, 0, len(elements))
+		operators := true
+		for _, element := range elements {
+			operators = operators && strings.HasPrefix(element.Key(), "$")
+			document = append(document, bson.E{Key: element.Key(), Value: canonical(element.Value(), false)})
+		}
+		if sortFields || operators {
+			sort.SliceStable(document, func(i, j int) bool { return document[i].Key < document[j].Key })
+		}
+		return document
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		array := make(bson.A, 0, len(values))
+		for _, element := range values {
+			array = append(array, canonical(element, false))
+		}
+		return array
+	}
+	return value
+}
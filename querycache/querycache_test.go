@@ -0,0 +1,243 @@
+package querycache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/scoped"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// memory is a Store answering every read with the same documents and
+// counting the reads that reach it
+type memory struct {
+	documents []string
+	reads     int
+	// during runs inside each read, before it returns
+	during func()
+}
+
+func (m *memory) read() ([]string, error) {
+	m.reads++
+	if m.during != nil {
+		m.during()
+	}
+	return m.documents, nil
+}
+
+func (m *memory) Insert(ctx context.Context, document string) (interface{}, error) {
+	m.documents = append(m.documents, document)
+	return len(m.documents) - 1, nil
+}
+
+func (m *memory) FindByID(ctx context.Context, id interface{}) (string, error) {
+	return m.documents[id.(int)], nil
+}
+
+func (m *memory) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]string, error) {
+	return m.read()
+}
+
+func (m *memory) UpdateByID(ctx context.Context, id interface{}, update interface{}) error {
+	return nil
+}
+
+func (m *memory) DeleteByID(ctx context.Context, id interface{}) error {
+	return nil
+}
+
+func (m *memory) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]string, error) {
+	return m.read()
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      interface{}
+		aOp, bOp  string
+		aOpts     bson.D
+		bOpts     bson.D
+		wantEqual bool
+	}{
+		{name: "top-level fields in another order",
+			a: bson.D{{"a", 1}, {"b", 2}}, b: bson.D{{"b", 2}, {"a", 1}}, wantEqual: true},
+		{name: "operators in another order",
+			a: bson.D{{"n", bson.D{{"$lt", 5}, {"$gt", 1}}}}, b: bson.D{{"n", bson.D{{"$gt", 1}, {"$lt", 5}}}}, wantEqual: true},
+		{name: "literal document fields in another order",
+			a: bson.D{{"a", bson.D{{"x", 1}, {"y", 2}}}}, b: bson.D{{"a", bson.D{{"y", 2}, {"x", 1}}}}},
+		{name: "nil and empty filter", a: nil, b: bson.D{}, wantEqual: true},
+		{name: "bson.M and bson.D", a: bson.M{"a": 1}, b: bson.D{{"a", 1}}, wantEqual: true},
+		{name: "other value", a: bson.D{{"a", 1}}, b: bson.D{{"a", 2}}},
+		{name: "other operation", a: bson.D{}, b: bson.D{}, bOp: "aggregate"},
+		{name: "other options", a: bson.D{}, b: bson.D{},
+			aOpts: bson.D{{"limit", 1}}, bOpts: bson.D{{"limit", 2}}},
+		{name: "pipeline stages in another order",
+			a: bson.A{bson.D{{"$match", bson.D{}}}, bson.D{{"$limit", 1}}},
+			b: bson.A{bson.D{{"$limit", 1}}, bson.D{{"$match", bson.D{}}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.aOp == "" {
+				test.aOp = "find"
+			}
+			if test.bOp == "" {
+				test.bOp = "find"
+			}
+			a, err := Key(test.aOp, test.a, test.aOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Key(test.bOp, test.b, test.bOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a == b) != test.wantEqual {
+				t.Errorf("keys equal = %v, want %v", a == b, test.wantEqual)
+			}
+		})
+	}
+}
+
+func TestHitsAndMisses(t *testing.T) {
+	next := &memory{documents: []string{"a", "b"}}
+	cache := New[string](next, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Find(ctx, bson.D{{"x", 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cache.Find(ctx, bson.D{{"x", 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Aggregate(ctx, bson.A{}); err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{Hits: 2, Misses: 3, Entries: 3}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+	if next.reads != 3 {
+		t.Errorf("reads = %d, want 3", next.reads)
+	}
+
+	// the result is a copy, so changing it leaves the cache alone
+	documents, _ := cache.Find(ctx, bson.D{{"x", 1}})
+	documents[0] = "changed"
+	if again, _ := cache.Find(ctx, bson.D{{"x", 1}}); again[0] != "a" {
+		t.Errorf("cached document = %q after the caller changed its copy", again[0])
+	}
+}
+
+func TestTTL(t *testing.T) {
+	next := &memory{}
+	cache := New[string](next, 20*time.Millisecond)
+	ctx := context.Background()
+
+	cache.Find(ctx, nil)
+	cache.Find(ctx, nil)
+	if next.reads != 1 {
+		t.Fatalf("reads within TTL = %d, want 1", next.reads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	cache.Find(ctx, nil)
+	if next.reads != 2 {
+		t.Errorf("reads after TTL = %d, want 2", next.reads)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	next := &memory{}
+	cache := New[string](next, time.Minute)
+	cache.MaxEntries = 2
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		cache.Find(ctx, bson.D{{"x", i}})
+	}
+	if entries := cache.Stats().Entries; entries != 2 {
+		t.Errorf("Entries = %d, want 2", entries)
+	}
+	cache.Find(ctx, bson.D{{"x", 2}})
+	if next.reads != 4 {
+		t.Errorf("reads = %d, want 4 since the third result was not cached", next.reads)
+	}
+}
+
+func TestWritesClear(t *testing.T) {
+	ctx := context.Background()
+	writes := []struct {
+		name  string
+		write func(*Store[string]) error
+	}{
+		{"Insert", func(s *Store[string]) error { _, err := s.Insert(ctx, "c"); return err }},
+		{"UpdateByID", func(s *Store[string]) error { return s.UpdateByID(ctx, 0, bson.D{}) }},
+		{"DeleteByID", func(s *Store[string]) error { return s.DeleteByID(ctx, 0) }},
+	}
+	for _, write := range writes {
+		t.Run(write.name, func(t *testing.T) {
+			next := &memory{documents: []string{"a"}}
+			cache := New[string](next, time.Minute)
+			cache.Find(ctx, nil)
+			if err := write.write(cache); err != nil {
+				t.Fatal(err)
+			}
+			cache.Find(ctx, nil)
+			if next.reads != 2 {
+				t.Errorf("reads = %d, want 2", next.reads)
+			}
+		})
+	}
+}
+
+// TestClearDuringRead checks that a read started before a write does not
+// cache its possibly stale result
+func TestClearDuringRead(t *testing.T) {
+	next := &memory{}
+	cache := New[string](next, time.Minute)
+	ctx := context.Background()
+
+	next.during = cache.Clear
+	cache.Find(ctx, nil)
+	next.during = nil
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("Entries = %d, want 0", entries)
+	}
+	cache.Find(ctx, nil)
+	cache.Find(ctx, nil)
+	if next.reads != 2 {
+		t.Errorf("reads = %d, want 2", next.reads)
+	}
+}
+
+func TestScope(t *testing.T) {
+	next := &memory{}
+	cache := New[string](next, time.Minute)
+	cache.Scope = scoped.Owner
+	ada := scoped.WithOwner(context.Background(), "ada")
+	grace := scoped.WithOwner(context.Background(), "grace")
+
+	cache.Find(ada, nil)
+	cache.Find(grace, nil)
+	cache.Find(ada, nil)
+	if next.reads != 2 {
+		t.Errorf("reads = %d, want 2, one per owner", next.reads)
+	}
+	// without an owner the read goes to Next, which rejects it
+	cache.Find(context.Background(), nil)
+	cache.Find(context.Background(), nil)
+	if next.reads != 4 {
+		t.Errorf("reads = %d, want 4 since reads without an owner are not cached", next.reads)
+	}
+}
+
+func TestNewScopesScopedRepository(t *testing.T) {
+	if cache := New[string](scoped.New[string](nil), time.Minute); cache.Scope == nil {
+		t.Error("New left Scope nil for a scoped.Repository")
+	}
+	if cache := New[string](&memory{}, time.Minute); cache.Scope != nil {
+		t.Error("New set Scope for an unscoped Store")
+	}
+}
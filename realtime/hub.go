@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Timeouts of one WebSocket connection
+const (
+	// writeWait bounds writing one message, so a client that stopped
+	// reading cannot block its writer forever
+	writeWait = 10 * time.Second
+	// pongWait is how long a client may stay silent before it is
+	// considered gone; pings every pingPeriod keep healthy clients talking
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// Hub fans every message out to the connected clients. Each client has its
+// own queue of Buffer messages drained by its own goroutine, so Broadcast
+// never waits for the network. A client that falls Buffer messages behind
+// is disconnected instead of slowing down the change stream and everyone
+// else; it can reconnect and fetch what it missed.
+type Hub struct {
+	Buffer int
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+// client is one WebSocket connection and the messages queued for it
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	// closeCode and closeText are sent in the close frame once send is
+	// closed, set by whoever removes the client from the hub
+	closeCode int
+	closeText string
+}
+
+// NewHub returns a Hub queuing up to buffer messages per client
+func NewHub(buffer int) *Hub {
+	return &Hub{Buffer: buffer, clients: map[*client]struct{}{}}
+}
+
+// Broadcast queues message for every client, disconnecting the ones whose
+// queue is full
+func (h *Hub) Broadcast(message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c.send <- message:
+		default:
+			log.Printf("%s is %d messages behind, disconnecting it", c.conn.RemoteAddr(), h.Buffer)
+			h.remove(c, websocket.CloseTryAgainLater, "too slow")
+		}
+	}
+}
+
+// Close disconnects every client, telling them the server is going away
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		h.remove(c, websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// Len returns the number of connected clients
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// remove closes the queue of c, which makes its writer send the close frame
+// and close the connection. It must be called with h.mu held and closes the
+// queue only once, whichever of Broadcast, Close or the reader gets there
+// first.
+func (h *Hub) remove(c *client, code int, text string) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	c.closeCode, c.closeText = code, text
+	close(c.send)
+}
+
+// ServeHTTP upgrades the request to a WebSocket and keeps it registered
+// until the client disconnects
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	c := &client{conn: conn, send: make(chan []byte, h.Buffer)}
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	count := len(h.clients)
+	h.mu.Unlock()
+	log.Printf("%s connected, %d client(s)", conn.RemoteAddr(), count)
+
+	go c.write()
+	c.read()
+
+	h.mu.Lock()
+	h.remove(c, websocket.CloseNormalClosure, "")
+	count = len(h.clients)
+	h.mu.Unlock()
+	log.Printf("%s disconnected, %d client(s)", conn.RemoteAddr(), count)
+}
+
+// read discards what the client sends until the connection fails, is closed
+// by either side or stays silent past pongWait. Reading is also what
+// processes the client's pongs and close frame.
+func (c *client) read() {
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("read from %s: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// write sends the queued messages and a ping every pingPeriod. When the
+// queue is closed it sends the close frame and closes the connection, which
+// also ends read.
+func (c *client) write() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				closeMessage := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+				c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait))
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	addr   = flag.String("addr", ":8080", "address to listen on")
+	buffer = flag.Int("buffer", 16, "messages queued per client before a slow client is disconnected")
+)
+
+// changeEvent holds the parts of a change stream event sent to clients
+type changeEvent struct {
+	OperationType string    `bson:"operationType"`
+	WallTime      time.Time `bson:"wallTime"`
+	DocumentKey   struct {
+		ID bson.RawValue `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      bson.Raw `bson:"fullDocument,omitempty"`
+	UpdateDescription struct {
+		UpdatedFields bson.Raw `bson:"updatedFields,omitempty"`
+	} `bson:"updateDescription"`
+}
+
+// message is the JSON text every client receives for a change
+type message struct {
+	Operation     string          `json:"operation"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	ID            json.RawMessage `json:"id"`
+	Episode       json.RawMessage `json:"episode,omitempty"`
+	UpdatedFields json.RawMessage `json:"updated_fields,omitempty"`
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *buffer < 1 {
+		return fmt.Errorf("%w: -buffer must be at least 1", shutdown.ErrUsage)
+	}
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+	episodesCollection := client.Database("quickstart").Collection("episodes")
+
+	hub := NewHub(*buffer)
+
+	// A failed watch stops the server, since clients would get nothing more
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	watchErr := make(chan error, 1)
+	go func() {
+		err := watchEpisodes(ctx, episodesCollection, hub)
+		if ctx.Err() != nil {
+			err = nil
+		}
+		watchErr <- err
+		stop()
+	}()
+	// Shutdown does not wait for hijacked connections, so the hub closes them
+	go func() {
+		<-ctx.Done()
+		hub.Close()
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", hub)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	log.Printf("open http://localhost%s and insert or update episodes to see them arrive", *addr)
+	if err = shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: mux}); err != nil {
+		return err
+	}
+	return <-watchErr
+}
+
+// watchEpisodes broadcasts every insert, update and replace in episodes
+// until ctx is canceled. Only the stream's goroutine reads events, and
+// Broadcast only queues them, so a slow client never delays the stream.
+func watchEpisodes(ctx context.Context, episodes *mongo.Collection, hub *Hub) error {
+	matchStage := bson.D{{"$match", bson.D{
+		{"operationType", bson.D{{"$in", bson.A{"insert", "update", "replace"}}}},
+	}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	stream, err := episodes.Watch(ctx, mongo.Pipeline{matchStage}, opts)
+	if err != nil {
+		return fmt.Errorf("watch episodes: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err = stream.Decode(&event); err != nil {
+			return fmt.Errorf("decode change event: %w", err)
+		}
+		text, err := buildMessage(event)
+		if err != nil {
+			return err
+		}
+		hub.Broadcast(text)
+		log.Printf("%s %s sent to %d client(s)", event.OperationType, event.DocumentKey.ID, hub.Len())
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+func buildMessage(event changeEvent) ([]byte, error) {
+	id, err := bson.MarshalExtJSON(bson.D{{"_id", event.DocumentKey.ID}}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("encoding _id: %w", err)
+	}
+	m := message{Operation: event.OperationType, OccurredAt: event.WallTime}
+	// MarshalExtJSON needs a document, so the _id is taken back out of one
+	var key struct {
+		ID json.RawMessage `json:"_id"`
+	}
+	if err = json.Unmarshal(id, &key); err != nil {
+		return nil, fmt.Errorf("encoding _id: %w", err)
+	}
+	m.ID = key.ID
+	// the episode may have been deleted before the update was looked up
+	if len(event.FullDocument) > 0 {
+		if m.Episode, err = bson.MarshalExtJSON(event.FullDocument, false, false); err != nil {
+			return nil, fmt.Errorf("encoding episode: %w", err)
+		}
+	}
+	if len(event.UpdateDescription.UpdatedFields) > 0 {
+		if m.UpdatedFields, err = bson.MarshalExtJSON(event.UpdateDescription.UpdatedFields, false, false); err != nil {
+			return nil, fmt.Errorf("encoding updated fields: %w", err)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// page prints every message it receives and reconnects after a disconnect
+const page = `<!DOCTYPE html>
+<title>Episodes in real time</title>
+<pre id="log"></pre>
+<script>
+const log = document.getElementById("log");
+function connect() {
+	const ws = new WebSocket("ws://" + location.host + "/ws");
+	ws.onopen = () => log.textContent += "connected\n";
+	ws.onmessage = (e) => log.textContent += e.data + "\n";
+	ws.onclose = (e) => {
+		log.textContent += "disconnected (" + e.code + " " + e.reason + "), reconnecting\n";
+		setTimeout(connect, 2000);
+	};
+}
+connect();
+</script>
+`
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/deadline"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title  string             `bson:"title,omitempty" json:"title"`
+	Author string             `bson:"author,omitempty" json:"author"`
+	Tags   []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Listen represents the schema for the "listens" collection
+type Listen struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	User       primitive.ObjectID `bson:"user"`
+	Podcast    primitive.ObjectID `bson:"podcast"`
+	Liked      bool               `bson:"liked"`
+	ListenedAt time.Time          `bson:"listened_at"`
+}
+
+// Recommendation is a podcast co-liked with another one and how often
+type Recommendation struct {
+	Podcast primitive.ObjectID `bson:"podcast" json:"podcast"`
+	Score   int32              `bson:"score" json:"score"`
+	Title   string             `bson:"-" json:"title,omitempty"`
+}
+
+// Recommendations represents the schema for the "recommendations" collection,
+// keyed by the podcast the recommendations are for
+type Recommendations struct {
+	ID          primitive.ObjectID `bson:"_id" json:"podcast"`
+	Items       []Recommendation   `bson:"items" json:"items"`
+	RefreshedAt time.Time          `bson:"refreshed_at" json:"refreshed_at"`
+}
+
+// coOccurrencePipeline scores every pair of podcasts by the number of users
+// who liked both and merges the top results per podcast into "recommendations"
+func coOccurrencePipeline(limit int, refreshedAt time.Time) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"liked", true}}}}
+	perUserStage := bson.D{{"$group", bson.D{
+		{"_id", "$user"},
+		{"podcasts", bson.D{{"$addToSet", "$podcast"}}},
+	}}}
+	multipleStage := bson.D{{"$match", bson.D{{"podcasts.1", bson.D{{"$exists", true}}}}}}
+	pairsStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"podcast", "$podcasts"},
+		{"other", "$podcasts"},
+	}}}
+	unwindPodcastStage := bson.D{{"$unwind", "$podcast"}}
+	unwindOtherStage := bson.D{{"$unwind", "$other"}}
+	distinctStage := bson.D{{"$match", bson.D{{"$expr", bson.D{{"$ne", bson.A{"$podcast", "$other"}}}}}}}
+	countStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{{"podcast", "$podcast"}, {"other", "$other"}}},
+		{"score", bson.D{{"$sum", 1}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"_id.podcast", 1}, {"score", -1}, {"_id.other", 1}}}}
+	collectStage := bson.D{{"$group", bson.D{
+		{"_id", "$_id.podcast"},
+		{"items", bson.D{{"$push", bson.D{{"podcast", "$_id.other"}, {"score", "$score"}}}}},
+	}}}
+	topStage := bson.D{{"$project", bson.D{
+		{"items", bson.D{{"$slice", bson.A{"$items", limit}}}},
+		{"refreshed_at", refreshedAt},
+	}}}
+	mergeStage := bson.D{{"$merge", bson.D{
+		{"into", "recommendations"},
+		{"on", "_id"},
+		{"whenMatched", "replace"},
+		{"whenNotMatched", "insert"},
+	}}}
+	return mongo.Pipeline{
+		matchStage, perUserStage, multipleStage, pairsStage, unwindPodcastStage,
+		unwindOtherStage, distinctStage, countStage, sortStage, collectStage, topStage, mergeStage,
+	}
+}
+
+// refresh recomputes the cache and removes entries for podcasts nobody co-likes anymore
+func refresh(ctx context.Context, database *mongo.Database, limit int) error {
+	refreshedAt := time.Now().UTC().Truncate(time.Millisecond)
+	cursor, err := database.Collection("listens").Aggregate(ctx, coOccurrencePipeline(limit, refreshedAt), options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	if err = cursor.Close(ctx); err != nil {
+		return err
+	}
+	result, err := database.Collection("recommendations").DeleteMany(ctx, bson.D{{"refreshed_at", bson.D{{"$lt", refreshedAt}}}})
+	if err != nil {
+		return err
+	}
+	log.Printf("recommendations refreshed, %d stale entries removed", result.DeletedCount)
+	return nil
+}
+
+type server struct {
+	podcasts        *mongo.Collection
+	recommendations *mongo.Collection
+}
+
+func (s *server) recommendationsFor(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "id must be an ObjectID", http.StatusBadRequest)
+		return
+	}
+	var result Recommendations
+	err = s.recommendations.FindOne(r.Context(), bson.M{"_id": id}, deadline.FindOne(r.Context())).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		result = Recommendations{ID: id, Items: []Recommendation{}}
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(result.Items))
+	for _, item := range result.Items {
+		ids = append(ids, item.Podcast)
+	}
+	cursor, err := s.podcasts.Find(r.Context(), bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"title": 1}), deadline.Find(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var podcasts []Podcast
+	if err = cursor.All(r.Context(), &podcasts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	titles := make(map[primitive.ObjectID]string, len(podcasts))
+	for _, podcast := range podcasts {
+		titles[podcast.ID] = podcast.Title
+	}
+	for i := range result.Items {
+		result.Items[i].Title = titles[result.Items[i].Podcast]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+var (
+	limit    = flag.Int("limit", 10, "recommendations kept per podcast")
+	interval = flag.Duration("interval", time.Hour, "time between cache refreshes")
+	addr     = flag.String("addr", ":8080", "HTTP listen address")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	_, err = database.Collection("listens").Indexes().CreateOne(connectCtx, mongo.IndexModel{
+		Keys: bson.D{{"liked", 1}, {"user", 1}, {"podcast", 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			refreshCtx, cancel := context.WithTimeout(ctx, *interval)
+			if err := refresh(refreshCtx, database, *limit); err != nil && ctx.Err() == nil {
+				log.Printf("refresh recommendations: %v", err)
+			}
+			cancel()
+			select {
+			case <-ctx.Done():
+			case <-time.After(*interval):
+			}
+		}
+	}()
+
+	s := &server{
+		podcasts:        database.Collection("podcasts"),
+		recommendations: database.Collection("recommendations"),
+	}
+	routes := openapi.New("Quickstart recommendations", "1.0.0")
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/podcasts/{id}/recommendations", Summary: "Podcasts co-liked with a podcast",
+		Response: Recommendations{}, Errors: []int{http.StatusBadRequest},
+	}, s.recommendationsFor)
+	log.Printf("serving recommendations on %s", *addr)
+	server := &http.Server{Addr: *addr, Handler: http.TimeoutHandler(routes, 5*time.Second, "request timed out")}
+	if err = shutdown.Serve(ctx, server); err != nil {
+		return err
+	}
+	return nil
+}
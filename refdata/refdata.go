@@ -0,0 +1,176 @@
+// Package refdata keeps small reference collections (categories and
+// languages) fully in memory. The data is reloaded on an interval and
+// whenever a change stream reports a write to one of the collections.
+package refdata
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Category represents the schema for the "categories" collection
+type Category struct {
+	ID     string `bson:"_id" json:"id"`
+	Name   string `bson:"name" json:"name"`
+	Parent string `bson:"parent,omitempty" json:"parent,omitempty"`
+}
+
+// Language represents the schema for the "languages" collection, keyed by ISO 639-1 code
+type Language struct {
+	Code       string `bson:"_id" json:"code"`
+	Name       string `bson:"name" json:"name"`
+	NativeName string `bson:"native_name,omitempty" json:"native_name,omitempty"`
+}
+
+// Store serves reference data from memory
+type Store struct {
+	database *mongo.Database
+
+	mu         sync.RWMutex
+	categories map[string]Category
+	languages  map[string]Language
+	loadedAt   time.Time
+}
+
+// New creates an empty Store; call Load or Run before using the lookups
+func New(database *mongo.Database) *Store {
+	return &Store{
+		database:   database,
+		categories: map[string]Category{},
+		languages:  map[string]Language{},
+	}
+}
+
+// Load reads both collections and swaps them in atomically
+func (s *Store) Load(ctx context.Context) error {
+	var categories []Category
+	cursor, err := s.database.Collection("categories").Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &categories); err != nil {
+		return err
+	}
+	var languages []Language
+	cursor, err = s.database.Collection("languages").Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &languages); err != nil {
+		return err
+	}
+
+	categoriesByID := make(map[string]Category, len(categories))
+	for _, category := range categories {
+		categoriesByID[category.ID] = category
+	}
+	languagesByCode := make(map[string]Language, len(languages))
+	for _, language := range languages {
+		languagesByCode[language.Code] = language
+	}
+
+	s.mu.Lock()
+	s.categories = categoriesByID
+	s.languages = languagesByCode
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Run reloads the data every interval and on change stream invalidation
+// until the context is cancelled. Without a replica set only the interval applies.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	go s.watch(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload(ctx, "interval")
+		}
+	}
+}
+
+func (s *Store) reload(ctx context.Context, reason string) {
+	loadCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := s.Load(loadCtx); err != nil {
+		log.Printf("refdata: reload on %s: %v", reason, err)
+	}
+}
+
+func (s *Store) watch(ctx context.Context) {
+	matchStage := bson.D{{"$match", bson.D{{"ns.coll", bson.D{{"$in", bson.A{"categories", "languages"}}}}}}}
+	for ctx.Err() == nil {
+		stream, err := s.database.Watch(ctx, mongo.Pipeline{matchStage})
+		if err != nil {
+			log.Printf("refdata: change stream unavailable, relying on interval refresh: %v", err)
+			return
+		}
+		// a reload catches anything written while the stream was (re)opening
+		s.reload(ctx, "watch start")
+		for stream.Next(ctx) {
+			s.reload(ctx, "change")
+		}
+		if err = stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("refdata: change stream error, reopening: %v", err)
+			time.Sleep(time.Second)
+		}
+		stream.Close(context.Background())
+	}
+}
+
+// LoadedAt returns when the data was last refreshed
+func (s *Store) LoadedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadedAt
+}
+
+// Category looks up a category by its id
+func (s *Store) Category(id string) (Category, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	category, ok := s.categories[id]
+	return category, ok
+}
+
+// Language looks up a language by its ISO 639-1 code
+func (s *Store) Language(code string) (Language, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	language, ok := s.languages[code]
+	return language, ok
+}
+
+// Categories returns every category sorted by name
+func (s *Store) Categories() []Category {
+	s.mu.RLock()
+	categories := make([]Category, 0, len(s.categories))
+	for _, category := range s.categories {
+		categories = append(categories, category)
+	}
+	s.mu.RUnlock()
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories
+}
+
+// Languages returns every language sorted by name
+func (s *Store) Languages() []Language {
+	s.mu.RLock()
+	languages := make([]Language, 0, len(s.languages))
+	for _, language := range s.languages {
+		languages = append(languages, language)
+	}
+	s.mu.RUnlock()
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Name < languages[j].Name })
+	return languages
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reference describes a field holding the _id of a document in another
+// collection. Many is set when the field is an array of ids.
+type Reference struct {
+	Collection string
+	Field      string
+	Target     string
+	Many       bool
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s.%s -> %s._id", r.Collection, r.Field, r.Target)
+}
+
+// references lists the foreign keys used across the quickstart examples
+var references = []Reference{
+	{Collection: "episodes", Field: "podcast", Target: "podcasts"},
+	{Collection: "listens", Field: "podcast", Target: "podcasts"},
+	{Collection: "listens", Field: "user", Target: "users"},
+	{Collection: "users", Field: "subscriptions", Target: "podcasts", Many: true},
+	{Collection: "webhook_deliveries", Field: "endpoint", Target: "webhook_endpoints"},
+}
+
+// orphanPipeline matches documents whose reference points at a missing
+// document. For arrays, a document is orphaned if any of its ids is missing.
+func orphanPipeline(ref Reference) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{ref.Field, bson.D{{"$exists", true}, {"$ne", nil}}}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{
+		{"from", ref.Target},
+		{"localField", ref.Field},
+		{"foreignField", "_id"},
+		{"as", "_referenced"},
+	}}}
+	var orphanStage bson.D
+	if ref.Many {
+		orphanStage = bson.D{{"$match", bson.D{{"$expr", bson.D{{"$lt", bson.A{
+			bson.D{{"$size", "$_referenced"}},
+			bson.D{{"$size", bson.D{{"$setUnion", bson.A{"$" + ref.Field}}}}},
+		}}}}}}}
+	} else {
+		orphanStage = bson.D{{"$match", bson.D{{"_referenced", bson.D{{"$size", 0}}}}}}
+	}
+	projectStage := bson.D{{"$project", bson.D{{"_id", 1}}}}
+	return mongo.Pipeline{matchStage, lookupStage, orphanStage, projectStage}
+}
+
+// orphanIDs returns the _id of every document with a dangling reference
+func orphanIDs(ctx context.Context, database *mongo.Database, ref Reference) ([]interface{}, error) {
+	cursor, err := database.Collection(ref.Collection).Aggregate(ctx, orphanPipeline(ref))
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	ids := make([]interface{}, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids, nil
+}
+
+// checkAll reports dangling references for every known foreign key and
+// returns the total number of violations
+func checkAll(ctx context.Context, database *mongo.Database) (int, error) {
+	total := 0
+	for _, ref := range references {
+		ids, err := orphanIDs(ctx, database, ref)
+		if err != nil {
+			return total, fmt.Errorf("checking %s: %w", ref, err)
+		}
+		total += len(ids)
+		fmt.Printf("%-50s %d dangling\n", ref, len(ids))
+		for i, id := range ids {
+			if i == 5 {
+				fmt.Printf("    ... and %d more\n", len(ids)-i)
+				break
+			}
+			if oid, ok := id.(primitive.ObjectID); ok {
+				id = oid.Hex()
+			}
+			fmt.Printf("    %v\n", id)
+		}
+	}
+	return total, nil
+}
+
+// cleanupEpisodes deletes orphaned episodes, or flags them with orphaned_at
+// and lets a TTL index remove them once the grace period has passed.
+// Flagged episodes whose podcast is restored in time are unflagged.
+func cleanupEpisodes(ctx context.Context, database *mongo.Database, action string) error {
+	episodes := database.Collection("episodes")
+	ids, err := orphanIDs(ctx, database, references[0])
+	if err != nil {
+		return err
+	}
+	if action == "delete" {
+		if len(ids) == 0 {
+			return nil
+		}
+		result, err := episodes.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %v orphaned episodes\n", result.DeletedCount)
+		return nil
+	}
+
+	restored, err := episodes.UpdateMany(ctx,
+		bson.D{{"orphaned_at", bson.D{{"$exists", true}}}, {"_id", bson.D{{"$nin", ids}}}},
+		bson.D{{"$unset", bson.D{{"orphaned_at", ""}}}},
+	)
+	if err != nil {
+		return err
+	}
+	flagged, err := episodes.UpdateMany(ctx,
+		bson.D{{"_id", bson.D{{"$in", ids}}}, {"orphaned_at", bson.D{{"$exists", false}}}},
+		bson.D{{"$set", bson.D{{"orphaned_at", time.Now().UTC()}}}},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Flagged %v orphaned episodes, unflagged %v\n", flagged.ModifiedCount, restored.ModifiedCount)
+	return nil
+}
+
+var (
+	action   = flag.String("action", "flag", "what to do with orphaned episodes: flag or delete")
+	grace    = flag.Duration("grace", 7*24*time.Hour, "how long flagged episodes are kept before the TTL index removes them")
+	interval = flag.Duration("interval", time.Hour, "time between cleanup runs")
+	once     = flag.Bool("once", false, "run the cleanup a single time and exit")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] check|cleanup\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if flag.NArg() != 1 || (*action != "flag" && *action != "delete") {
+		return shutdown.ErrUsage
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+	database := client.Database("quickstart")
+
+	switch flag.Arg(0) {
+	case "check":
+		total, err := checkAll(ctx, database)
+		if err != nil {
+			return err
+		}
+		if total > 0 {
+			return fmt.Errorf("%d dangling reference(s)", total)
+		}
+	case "cleanup":
+		_, err = database.Collection("episodes").Indexes().CreateOne(connectCtx, mongo.IndexModel{
+			Keys:    bson.D{{"orphaned_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(grace.Seconds())),
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			if err = cleanupEpisodes(ctx, database, *action); err != nil {
+				log.Printf("cleanup: %v", err)
+			}
+			if *once {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(*interval):
+			}
+		}
+	default:
+		return shutdown.ErrUsage
+	}
+	return nil
+}
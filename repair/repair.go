@@ -0,0 +1,243 @@
+// Package repair fixes or backfills documents in batches, as a safer
+// alternative to a one-off UpdateMany. A Job names the documents to repair
+// with a filter, changes each one in Go with Transform and checks the result
+// with Verify before anything is written:
+//
+//	job := &repair.Job[Episode]{
+//		Name:        "duration-to-int",
+//		Collection:  database.Collection("episodes"),
+//		Checkpoints: database.Collection("repair_jobs"),
+//		Filter:      bson.D{{"duration", bson.D{{"$type", "string"}}}},
+//		Transform:   parseDuration,
+//		Verify:      durationIsPositive,
+//		DryRun:      true,
+//	}
+//	checkpoint, err := job.Run(ctx)
+//
+// A dry run prints the update each document would get and writes nothing.
+// Otherwise each batch is written with one BulkWrite and followed by a
+// checkpoint, so an interrupted job resumes after the last batch it wrote.
+//
+// Updates are the diff between the document as decoded into T and as
+// returned by Transform, so fields T does not have are never touched. T
+// must be able to decode the broken documents; a field with the wrong type
+// can be an interface{} or bson.RawValue.
+package repair
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/diff"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrFilterChanged is returned when a checkpoint exists for the job's name
+// but was saved with another filter or collection
+var ErrFilterChanged = errors.New("job was started with a different collection or filter")
+
+// Checkpoint is the progress of a job, stored in the Checkpoints collection
+// under the job's name
+type Checkpoint struct {
+	Job        string `bson:"_id"`
+	Collection string `bson:"collection"`
+	Filter     string `bson:"filter"`
+	// LastID is the _id of the last document of the last batch written;
+	// the next batch starts after it
+	LastID interface{} `bson:"last_id"`
+	// Scanned documents matched the filter, Changed were updated or, in a
+	// dry run, would be, Unchanged needed no update and Failed were
+	// rejected by Transform or Verify
+	Scanned   int64     `bson:"scanned"`
+	Changed   int64     `bson:"changed"`
+	Unchanged int64     `bson:"unchanged"`
+	Failed    int64     `bson:"failed"`
+	StartedAt time.Time `bson:"started_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	Finished  bool      `bson:"finished"`
+}
+
+// Job repairs the documents of Collection matching Filter
+type Job[T any] struct {
+	Name        string
+	Collection  *mongo.Collection
+	Checkpoints *mongo.Collection
+	Filter      bson.D
+	// Transform returns the repaired document. Returning it unchanged
+	// leaves it alone; returning an error skips it and counts it as failed.
+	Transform func(ctx context.Context, document T) (T, error)
+	// Verify checks a repaired document before it is written. A document
+	// it rejects is not written and counts as failed. Verify is optional.
+	Verify func(document T) error
+	// DiffOptions tune how the update is built, for instance how arrays
+	// are written
+	DiffOptions diff.Options
+	BatchSize   int64
+	// Pause between batches lets secondaries keep up
+	Pause  time.Duration
+	DryRun bool
+	// Out receives the dry run diffs and the problems of failed documents,
+	// standard output by default
+	Out io.Writer
+}
+
+// Run repairs every matching document after the checkpoint and returns the
+// final one. A dry run starts from the saved checkpoint too but saves none.
+func (j *Job[T]) Run(ctx context.Context) (*Checkpoint, error) {
+	if j.BatchSize <= 0 {
+		j.BatchSize = 500
+	}
+	if j.Out == nil {
+		j.Out = os.Stdout
+	}
+	checkpoint, err := j.load(ctx)
+	if err != nil || checkpoint.Finished {
+		return checkpoint, err
+	}
+	for {
+		n, err := j.batch(ctx, checkpoint)
+		if err != nil {
+			return checkpoint, err
+		}
+		if n == 0 {
+			checkpoint.Finished = true
+			return checkpoint, j.save(ctx, checkpoint)
+		}
+		if err = j.save(ctx, checkpoint); err != nil {
+			return checkpoint, err
+		}
+		select {
+		case <-ctx.Done():
+			return checkpoint, ctx.Err()
+		case <-time.After(j.Pause):
+		}
+	}
+}
+
+// load returns the saved checkpoint of the job, or a new one
+func (j *Job[T]) load(ctx context.Context) (*Checkpoint, error) {
+	filter, err := bson.MarshalExtJSON(j.Filter, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	fresh := &Checkpoint{Job: j.Name, Collection: j.Collection.Name(), Filter: string(filter), StartedAt: time.Now().UTC()}
+	checkpoint := &Checkpoint{}
+	err = j.Checkpoints.FindOne(ctx, bson.D{{"_id", j.Name}}).Decode(checkpoint)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return fresh, nil
+	case err != nil:
+		return nil, fmt.Errorf("load checkpoint %q: %w", j.Name, err)
+	case checkpoint.Collection != fresh.Collection || checkpoint.Filter != fresh.Filter:
+		return nil, fmt.Errorf("%w: %q", ErrFilterChanged, j.Name)
+	}
+	return checkpoint, nil
+}
+
+func (j *Job[T]) save(ctx context.Context, checkpoint *Checkpoint) error {
+	if j.DryRun {
+		return nil
+	}
+	checkpoint.UpdatedAt = time.Now().UTC()
+	_, err := j.Checkpoints.ReplaceOne(ctx, bson.D{{"_id", checkpoint.Job}}, checkpoint, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("save checkpoint %q: %w", checkpoint.Job, err)
+	}
+	return nil
+}
+
+// batch repairs the next BatchSize documents after the checkpoint, in _id
+// order so the _id index finds where to continue, and returns how many it
+// read
+func (j *Job[T]) batch(ctx context.Context, checkpoint *Checkpoint) (int, error) {
+	filter := j.Filter
+	if checkpoint.LastID != nil {
+		filter = bson.D{{"$and", bson.A{bson.D{{"_id", bson.D{{"$gt", checkpoint.LastID}}}}, j.Filter}}}
+	}
+	cursor, err := j.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{"_id", 1}}).SetLimit(j.BatchSize))
+	if err != nil {
+		return 0, fmt.Errorf("find in %s: %w", j.Collection.Name(), err)
+	}
+	var documents []bson.Raw
+	if err = cursor.All(ctx, &documents); err != nil {
+		return 0, fmt.Errorf("read %s: %w", j.Collection.Name(), err)
+	}
+
+	var models []mongo.WriteModel
+	for _, raw := range documents {
+		var id interface{}
+		if err = raw.Lookup("_id").Unmarshal(&id); err != nil {
+			return 0, fmt.Errorf("_id of %s: %w", raw, err)
+		}
+		checkpoint.Scanned++
+		update, err := j.repair(ctx, raw)
+		switch {
+		case err != nil:
+			checkpoint.Failed++
+			fmt.Fprintf(j.Out, "%v: %v\n", id, err)
+		case len(update) == 0:
+			checkpoint.Unchanged++
+		default:
+			checkpoint.Changed++
+			if j.DryRun {
+				printable, err := bson.MarshalExtJSON(update, false, false)
+				if err != nil {
+					return 0, err
+				}
+				fmt.Fprintf(j.Out, "%v: %s\n", id, printable)
+				continue
+			}
+			// the filter again, so documents changed since they were read
+			// and no longer broken are left alone
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{"$and", bson.A{bson.D{{"_id", id}}, j.Filter}}}).
+				SetUpdate(update))
+		}
+	}
+	if len(models) > 0 {
+		if _, err = j.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return 0, fmt.Errorf("write batch to %s: %w", j.Collection.Name(), err)
+		}
+	}
+	if len(documents) > 0 {
+		if err = documents[len(documents)-1].Lookup("_id").Unmarshal(&checkpoint.LastID); err != nil {
+			return 0, err
+		}
+	}
+	return len(documents), nil
+}
+
+// repair returns the update turning raw into its repaired version, empty
+// when it needs none
+func (j *Job[T]) repair(ctx context.Context, raw bson.Raw) (bson.D, error) {
+	var original T
+	if err := bson.Unmarshal(raw, &original); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	// Transform gets its own copy, so changes to shared slices or maps
+	// cannot leak into the original the diff is taken against
+	var document T
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	repaired, err := j.Transform(ctx, document)
+	if err != nil {
+		return nil, fmt.Errorf("transform: %w", err)
+	}
+	if j.Verify != nil {
+		if err = j.Verify(repaired); err != nil {
+			return nil, fmt.Errorf("verify: %w", err)
+		}
+	}
+	update, err := diff.Update(original, repaired, j.DiffOptions)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	return update, nil
+}
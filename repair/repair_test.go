@@ -0,0 +1,234 @@
+package repair
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+type episode struct {
+	ID       int         `bson:"_id"`
+	Title    string      `bson:"title"`
+	Duration interface{} `bson:"duration"`
+	Tags     []string    `bson:"tags,omitempty"`
+}
+
+// parseDuration turns a string duration into an int32
+func parseDuration(_ context.Context, e episode) (episode, error) {
+	text, ok := e.Duration.(string)
+	if !ok {
+		return e, nil
+	}
+	minutes, err := strconv.Atoi(text)
+	if err != nil {
+		return e, err
+	}
+	e.Duration = int32(minutes)
+	return e, nil
+}
+
+func positive(e episode) error {
+	if minutes, ok := e.Duration.(int32); ok && minutes <= 0 {
+		return errors.New("duration is not positive")
+	}
+	return nil
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name      string
+		document  bson.D
+		transform func(context.Context, episode) (episode, error)
+		want      bson.D
+		wantErr   string
+	}{
+		{"repaired", bson.D{{"_id", 1}, {"title", "Go"}, {"duration", "25"}}, parseDuration,
+			bson.D{{"$set", bson.D{{"duration", int32(25)}}}}, ""},
+		{"already fine", bson.D{{"_id", 1}, {"title", "Go"}, {"duration", 25}}, parseDuration, bson.D{}, ""},
+		{"fields the type lacks are left alone", bson.D{{"_id", 1}, {"title", "Go"}, {"duration", "25"}, {"legacy", true}}, parseDuration,
+			bson.D{{"$set", bson.D{{"duration", int32(25)}}}}, ""},
+		{"transform error", bson.D{{"_id", 1}, {"duration", "soon"}}, parseDuration, nil, "transform"},
+		{"rejected by verify", bson.D{{"_id", 1}, {"duration", "0"}}, parseDuration, nil, "verify"},
+		{"undecodable", bson.D{{"_id", "one"}}, parseDuration, nil, "decode"},
+		{"shared slice changed in place", bson.D{{"_id", 1}, {"title", "Go"}, {"duration", 25}, {"tags", bson.A{"go"}}},
+			func(_ context.Context, e episode) (episode, error) {
+				e.Tags[0] = "golang"
+				return e, nil
+			},
+			bson.D{{"$set", bson.D{{"tags", bson.A{"golang"}}}}}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := bson.Marshal(test.document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			job := &Job[episode]{Transform: test.transform, Verify: positive}
+			update, err := job.repair(context.Background(), raw)
+			if test.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr+":") {
+					t.Fatalf("repair = %v, want a %s error", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			have, _ := bson.Marshal(bson.D{{"update", update}})
+			want, _ := bson.Marshal(bson.D{{"update", test.want}})
+			if !bytes.Equal(have, want) {
+				t.Errorf("repair = %s, want %s", bson.Raw(have), bson.Raw(want))
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	database := mongotest.Database(t)
+	episodes := database.Collection("episodes")
+	var documents []interface{}
+	for i := 1; i <= 10; i++ {
+		documents = append(documents, bson.D{{"_id", i}, {"title", "Go"}, {"duration", strconv.Itoa(i * 5)}})
+	}
+	documents = append(documents,
+		bson.D{{"_id", 11}, {"title", "Go"}, {"duration", "soon"}},
+		bson.D{{"_id", 12}, {"title", "Go"}, {"duration", 60}},
+	)
+	if _, err := episodes.InsertMany(ctx, documents); err != nil {
+		t.Fatal(err)
+	}
+	newJob := func(out *bytes.Buffer) *Job[episode] {
+		return &Job[episode]{
+			Name:        "duration-to-int",
+			Collection:  episodes,
+			Checkpoints: database.Collection("repair_jobs"),
+			Filter:      bson.D{{"duration", bson.D{{"$type", "string"}}}},
+			Transform:   parseDuration,
+			Verify:      positive,
+			BatchSize:   4,
+			Out:         out,
+		}
+	}
+
+	var out bytes.Buffer
+	dryRun := newJob(&out)
+	dryRun.DryRun = true
+	checkpoint, err := dryRun.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Scanned != 11 || checkpoint.Changed != 10 || checkpoint.Failed != 1 {
+		t.Errorf("dry run checkpoint = %+v, want 11 scanned, 10 changed and 1 failed", checkpoint)
+	}
+	if !strings.Contains(out.String(), `1: {"$set":{"duration":5}}`) {
+		t.Errorf("dry run printed\n%s\nwant the update of each document", out.String())
+	}
+	if n, _ := episodes.CountDocuments(ctx, bson.D{{"duration", bson.D{{"$type", "string"}}}}); n != 11 {
+		t.Errorf("dry run left %d string durations, want all 11", n)
+	}
+	if n, _ := database.Collection("repair_jobs").CountDocuments(ctx, bson.D{}); n != 0 {
+		t.Errorf("dry run saved %d checkpoints", n)
+	}
+
+	checkpoint, err = newJob(&out).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkpoint.Finished || checkpoint.Changed != 10 || checkpoint.Failed != 1 {
+		t.Errorf("checkpoint = %+v, want finished with 10 changed and 1 failed", checkpoint)
+	}
+	var repaired episode
+	if err = episodes.FindOne(ctx, bson.D{{"_id", 3}}).Decode(&repaired); err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Duration != int32(15) {
+		t.Errorf("duration of episode 3 = %#v, want 15", repaired.Duration)
+	}
+
+	// a finished job does nothing when run again
+	if _, err = episodes.UpdateOne(ctx, bson.D{{"_id", 1}}, bson.D{{"$set", bson.D{{"duration", "5"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := newJob(&out).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Scanned != checkpoint.Scanned {
+		t.Errorf("finished job scanned %d documents again", again.Scanned-checkpoint.Scanned)
+	}
+
+	changed := newJob(&out)
+	changed.Filter = bson.D{{"duration", bson.D{{"$type", "double"}}}}
+	if _, err = changed.Run(ctx); !errors.Is(err, ErrFilterChanged) {
+		t.Errorf("Run with another filter = %v, want ErrFilterChanged", err)
+	}
+}
+
+func TestRunResumes(t *testing.T) {
+	ctx := context.Background()
+	database := mongotest.Database(t)
+	episodes := database.Collection("episodes")
+	var documents []interface{}
+	for i := 1; i <= 6; i++ {
+		documents = append(documents, bson.D{{"_id", i}, {"title", "Go"}, {"duration", strconv.Itoa(i)}})
+	}
+	if _, err := episodes.InsertMany(ctx, documents); err != nil {
+		t.Fatal(err)
+	}
+	// a checkpoint left by a job interrupted after the batch ending at 4
+	_, err := database.Collection("repair_jobs").InsertOne(ctx, Checkpoint{
+		Job:        "resume",
+		Collection: "episodes",
+		Filter:     `{"duration":{"$type":"string"}}`,
+		LastID:     4,
+		Scanned:    4,
+		Changed:    4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &Job[episode]{
+		Name:        "resume",
+		Collection:  episodes,
+		Checkpoints: database.Collection("repair_jobs"),
+		Filter:      bson.D{{"duration", bson.D{{"$type", "string"}}}},
+		Transform:   parseDuration,
+		BatchSize:   4,
+		Out:         &bytes.Buffer{},
+	}
+	checkpoint, err := job.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Scanned != 6 || checkpoint.Changed != 6 {
+		t.Errorf("checkpoint = %+v, want 6 scanned and changed in total", checkpoint)
+	}
+	cursor, err := episodes.Find(ctx, bson.D{{"duration", bson.D{{"$type", "string"}}}}, options.Find().SetSort(bson.D{{"_id", 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []episode
+	if err = cursor.All(ctx, &left); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, e := range left {
+		ids = append(ids, e.ID)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("string durations left on %v, want only those before the checkpoint, %v", ids, want)
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Query is the read a Repository is about to make, as seen by AroundFind
+type Query struct {
+	// Operation is "Find", "FindByID" or "Aggregate"
+	Operation string
+	// Filter is the filter of Find and FindByID
+	Filter interface{}
+	// Pipeline is the pipeline of Aggregate
+	Pipeline interface{}
+}
+
+// Hooks plug cross-cutting concerns such as timestamps, auditing, metrics or
+// soft-delete filtering into every call of a Repository. Each hook is
+// optional. With several Hooks, the Before hooks run in the order they were
+// added, the After hooks once the write succeeded, and the first AroundFind
+// wraps all the others.
+type Hooks[T any] struct {
+	// BeforeInsert can change the document about to be inserted, or return
+	// an error to prevent the insert
+	BeforeInsert func(ctx context.Context, document *T) error
+	// BeforeUpdate returns the update to send instead of update, or an error
+	// to prevent the update. update may be an update document or a pipeline.
+	BeforeUpdate func(ctx context.Context, id interface{}, update interface{}) (interface{}, error)
+	// BeforeDelete can return an error to prevent the delete
+	BeforeDelete func(ctx context.Context, id interface{}) error
+
+	AfterInsert func(ctx context.Context, id interface{}, document T)
+	AfterUpdate func(ctx context.Context, id interface{}, update interface{})
+	AfterDelete func(ctx context.Context, id interface{})
+
+	// AroundFind wraps Find, FindByID and Aggregate. It may change the query
+	// it passes to next, time the call or look at its error, and must return
+	// what next returns unless it means to fail the read.
+	AroundFind func(ctx context.Context, query Query, next func(context.Context, Query) error) error
+}
+
+// Use adds hooks to r and returns r
+func (r *Repository[T]) Use(hooks Hooks[T]) *Repository[T] {
+	r.hooks = append(r.hooks, hooks)
+	return r
+}
+
+// read runs find inside every AroundFind hook
+func (r *Repository[T]) read(ctx context.Context, query Query, find func(context.Context, Query) error) error {
+	next := find
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		around := r.hooks[i].AroundFind
+		if around == nil {
+			continue
+		}
+		inner := next
+		next = func(ctx context.Context, query Query) error {
+			return around(ctx, query, inner)
+		}
+	}
+	return next(ctx, query)
+}
+
+// NotDeleted hides soft-deleted documents, those where field is set, from
+// every read. Soft deleting is an UpdateByID that sets field, usually to the
+// current time; DeleteByID still removes documents for good. The $match it
+// adds in front of pipelines rules out stages that must come first, such as
+// $geoNear or $search.
+func NotDeleted[T any](field string) Hooks[T] {
+	notDeleted := bson.D{{field, nil}}
+	return Hooks[T]{
+		AroundFind: func(ctx context.Context, query Query, next func(context.Context, Query) error) error {
+			if query.Filter != nil {
+				query.Filter = bson.D{{"$and", bson.A{query.Filter, notDeleted}}}
+			}
+			if query.Pipeline != nil {
+				pipeline, err := prepend(bson.D{{"$match", notDeleted}}, query.Pipeline)
+				if err != nil {
+					return err
+				}
+				query.Pipeline = pipeline
+			}
+			return next(ctx, query)
+		},
+	}
+}
+
+// prepend returns pipeline, which may be any type that marshals to an
+// array, with stage added at the start
+func prepend(stage bson.D, pipeline interface{}) (bson.A, error) {
+	t, data, err := bson.MarshalValue(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if t != bsontype.Array {
+		return nil, fmt.Errorf("pipeline is a %v, not an array", t)
+	}
+	stages, err := bson.RawValue{Type: t, Value: data}.Array().Values()
+	if err != nil {
+		return nil, err
+	}
+	prepended := bson.A{stage}
+	for _, existing := range stages {
+		prepended = append(prepended, existing)
+	}
+	return prepended, nil
+}
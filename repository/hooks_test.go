@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+type podcast struct {
+	ID    int    `bson:"_id"`
+	Title string `bson:"title"`
+}
+
+// recorder returns hooks appending name and the hook to calls
+func recorder(name string, calls *[]string) Hooks[podcast] {
+	return Hooks[podcast]{
+		BeforeInsert: func(ctx context.Context, document *podcast) error {
+			*calls = append(*calls, name+" BeforeInsert")
+			document.Title += " " + name
+			return nil
+		},
+		BeforeUpdate: func(ctx context.Context, id interface{}, update interface{}) (interface{}, error) {
+			*calls = append(*calls, name+" BeforeUpdate")
+			return update, nil
+		},
+		BeforeDelete: func(ctx context.Context, id interface{}) error {
+			*calls = append(*calls, name+" BeforeDelete")
+			return nil
+		},
+		AfterInsert: func(ctx context.Context, id interface{}, document podcast) {
+			*calls = append(*calls, name+" AfterInsert")
+		},
+		AfterUpdate: func(ctx context.Context, id interface{}, update interface{}) {
+			*calls = append(*calls, name+" AfterUpdate")
+		},
+		AfterDelete: func(ctx context.Context, id interface{}) {
+			*calls = append(*calls, name+" AfterDelete")
+		},
+		AroundFind: func(ctx context.Context, query Query, next func(context.Context, Query) error) error {
+			*calls = append(*calls, name+" before "+query.Operation)
+			err := next(ctx, query)
+			*calls = append(*calls, name+" after "+query.Operation)
+			return err
+		},
+	}
+}
+
+func TestAroundFindOrder(t *testing.T) {
+	var calls []string
+	r := New[podcast](nil).Use(recorder("first", &calls)).Use(Hooks[podcast]{}).Use(recorder("second", &calls))
+	err := r.read(context.Background(), Query{Operation: "Find"}, func(context.Context, Query) error {
+		calls = append(calls, "find")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first before Find", "second before Find", "find", "second after Find", "first after Find"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestAroundFindErrors(t *testing.T) {
+	failed := errors.New("find failed")
+	denied := errors.New("denied")
+	deny := Hooks[podcast]{AroundFind: func(ctx context.Context, query Query, next func(context.Context, Query) error) error {
+		return denied
+	}}
+	var calls []string
+	tests := []struct {
+		name  string
+		hooks []Hooks[podcast]
+		want  error
+		finds int
+	}{
+		{"error of the read comes back", []Hooks[podcast]{recorder("a", &calls), recorder("b", &calls)}, failed, 1},
+		{"hook failing the read", []Hooks[podcast]{recorder("a", &calls), deny, recorder("b", &calls)}, denied, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := New[podcast](nil)
+			for _, hooks := range test.hooks {
+				r.Use(hooks)
+			}
+			finds := 0
+			err := r.read(context.Background(), Query{Operation: "Find"}, func(context.Context, Query) error {
+				finds++
+				return failed
+			})
+			if !errors.Is(err, test.want) || finds != test.finds {
+				t.Errorf("read = %v after %d finds, want %v after %d", err, finds, test.want, test.finds)
+			}
+		})
+	}
+}
+
+func TestAroundFindChangesQuery(t *testing.T) {
+	r := New[podcast](nil).Use(NotDeleted[podcast]("deleted_at"))
+	var filter, pipeline interface{}
+	err := r.read(context.Background(), Query{Operation: "Find", Filter: bson.D{{"title", "Go"}}}, func(ctx context.Context, query Query) error {
+		filter = query.Filter
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{"$and", bson.A{bson.D{{"title", "Go"}}, bson.D{{"deleted_at", nil}}}}}); !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %v, want %v", filter, want)
+	}
+	err = r.read(context.Background(), Query{Operation: "Aggregate", Pipeline: bson.A{bson.D{{"$limit", 1}}}}, func(ctx context.Context, query Query) error {
+		pipeline = query.Pipeline
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := pipeline.(bson.A)
+	if len(stages) != 2 || !reflect.DeepEqual(stages[0], bson.D{{"$match", bson.D{{"deleted_at", nil}}}}) {
+		t.Errorf("pipeline = %v, want the $match first", pipeline)
+	}
+}
+
+func TestBeforeHookErrors(t *testing.T) {
+	ctx := context.Background()
+	stop := errors.New("stop")
+	var calls []string
+	failing := Hooks[podcast]{
+		BeforeInsert: func(context.Context, *podcast) error { return stop },
+		BeforeUpdate: func(context.Context, interface{}, interface{}) (interface{}, error) { return nil, stop },
+		BeforeDelete: func(context.Context, interface{}) error { return stop },
+	}
+	// the collection is nil, so reaching the driver would panic
+	r := New[podcast](nil).Use(recorder("first", &calls)).Use(failing).Use(recorder("last", &calls))
+	if _, err := r.Insert(ctx, podcast{}); !errors.Is(err, stop) {
+		t.Errorf("Insert = %v, want the hook's error", err)
+	}
+	if err := r.UpdateByID(ctx, 1, bson.A{}); !errors.Is(err, stop) {
+		t.Errorf("UpdateByID = %v, want the hook's error", err)
+	}
+	if err := r.DeleteByID(ctx, 1); !errors.Is(err, stop) {
+		t.Errorf("DeleteByID = %v, want the hook's error", err)
+	}
+	want := []string{"first BeforeInsert", "first BeforeUpdate", "first BeforeDelete"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	var updates []interface{}
+	r := New[podcast](mongotest.Database(t).Collection("podcasts")).
+		Use(recorder("first", &calls)).
+		Use(recorder("second", &calls)).
+		Use(Hooks[podcast]{
+			// gets the update as the hooks before it left it
+			BeforeUpdate: func(ctx context.Context, id interface{}, update interface{}) (interface{}, error) {
+				return append(update.(bson.A), bson.D{{"$set", bson.D{{"edited", true}}}}), nil
+			},
+			AfterUpdate: func(ctx context.Context, id interface{}, update interface{}) {
+				updates = append(updates, update)
+			},
+		})
+
+	if _, err := r.Insert(ctx, podcast{ID: 1, Title: "Go"}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := r.FindByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "Go first second" {
+		t.Errorf("stored title %q, want the changes of both hooks in order", stored.Title)
+	}
+	pipeline := bson.A{bson.D{{"$set", bson.D{{"title", "Rust"}}}}}
+	if err = r.UpdateByID(ctx, 1, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	var edited bson.M
+	if err = r.Collection.FindOne(ctx, bson.D{{"_id", 1}}).Decode(&edited); err != nil {
+		t.Fatal(err)
+	}
+	if edited["title"] != "Rust" || edited["edited"] != true {
+		t.Errorf("updated document %v, want the update with the hook's stage", edited)
+	}
+	if err = r.UpdateByID(ctx, 2, pipeline); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateByID of a missing document = %v, want ErrNotFound", err)
+	}
+	if err = r.DeleteByID(ctx, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteByID of a missing document = %v, want ErrNotFound", err)
+	}
+	if err = r.DeleteByID(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"first BeforeInsert", "second BeforeInsert", "first AfterInsert", "second AfterInsert",
+		"first before FindByID", "second before FindByID", "second after FindByID", "first after FindByID",
+		"first BeforeUpdate", "second BeforeUpdate", "first AfterUpdate", "second AfterUpdate",
+		"first BeforeUpdate", "second BeforeUpdate",
+		"first BeforeDelete", "second BeforeDelete",
+		"first BeforeDelete", "second BeforeDelete", "first AfterDelete", "second AfterDelete",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls =\n%v\nwant\n%v", calls, want)
+	}
+	// After hooks only run for the update that matched, and see what was sent
+	if len(updates) != 1 || len(updates[0].(bson.A)) != 2 {
+		t.Errorf("AfterUpdate got %v, want the sent update once", updates)
+	}
+}
@@ -0,0 +1,186 @@
+// Package repository wraps a collection in a typed repository, so callers
+// work with their own struct types instead of cursors and Decode calls:
+//
+//	podcasts := repository.New[Podcast](database.Collection("podcasts"))
+//	podcast, err := podcasts.FindByID(ctx, id)
+//
+// Code depending on the Store interface rather than *Repository can be unit
+// tested with an in-memory implementation. Hooks added with Use run around
+// every call, for concerns that should not be repeated at each call site:
+//
+//	podcasts.Use(repository.NotDeleted[Podcast]("deleted_at"))
+//
+// For typed results without leaving the driver's API, see typedcoll.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotFound is returned when no document has the requested _id
+var ErrNotFound = errors.New("document not found")
+
+// Store is the set of operations a Repository offers
+type Store[T any] interface {
+	Insert(ctx context.Context, document T) (interface{}, error)
+	FindByID(ctx context.Context, id interface{}) (T, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error)
+	UpdateByID(ctx context.Context, id interface{}, update interface{}) error
+	DeleteByID(ctx context.Context, id interface{}) error
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]T, error)
+}
+
+// Repository reads and writes documents of type T in one collection
+type Repository[T any] struct {
+	Collection *mongo.Collection
+
+	hooks []Hooks[T]
+}
+
+var _ Store[struct{}] = (*Repository[struct{}])(nil)
+
+// New returns a repository for documents of type T stored in collection
+func New[T any](collection *mongo.Collection) *Repository[T] {
+	return &Repository[T]{Collection: collection}
+}
+
+// Insert stores document and returns its _id, generated by the driver when
+// T leaves it empty with omitempty
+func (r *Repository[T]) Insert(ctx context.Context, document T) (interface{}, error) {
+	for _, hooks := range r.hooks {
+		if hooks.BeforeInsert == nil {
+			continue
+		}
+		if err := hooks.BeforeInsert(ctx, &document); err != nil {
+			return nil, err
+		}
+	}
+	result, err := r.Collection.InsertOne(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	for _, hooks := range r.hooks {
+		if hooks.AfterInsert != nil {
+			hooks.AfterInsert(ctx, result.InsertedID, document)
+		}
+	}
+	return result.InsertedID, nil
+}
+
+// FindByID returns the document with the given _id
+func (r *Repository[T]) FindByID(ctx context.Context, id interface{}) (T, error) {
+	var document T
+	err := r.read(ctx, Query{Operation: "FindByID", Filter: bson.D{{"_id", id}}}, func(ctx context.Context, query Query) error {
+		err := r.Collection.FindOne(ctx, query.Filter).Decode(&document)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		return err
+	})
+	return document, err
+}
+
+// Find returns every document matching filter, or an empty slice
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	documents := []T{}
+	err := r.read(ctx, Query{Operation: "Find", Filter: filter}, func(ctx context.Context, query Query) error {
+		cursor, err := r.Collection.Find(ctx, query.Filter, opts...)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &documents)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// UpdateByID applies update, an update document or pipeline, to the document
+// with the given _id
+func (r *Repository[T]) UpdateByID(ctx context.Context, id interface{}, update interface{}) error {
+	for _, hooks := range r.hooks {
+		if hooks.BeforeUpdate == nil {
+			continue
+		}
+		var err error
+		if update, err = hooks.BeforeUpdate(ctx, id, update); err != nil {
+			return err
+		}
+	}
+	result, err := r.Collection.UpdateByID(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	for _, hooks := range r.hooks {
+		if hooks.AfterUpdate != nil {
+			hooks.AfterUpdate(ctx, id, update)
+		}
+	}
+	return nil
+}
+
+// DeleteByID removes the document with the given _id
+func (r *Repository[T]) DeleteByID(ctx context.Context, id interface{}) error {
+	for _, hooks := range r.hooks {
+		if hooks.BeforeDelete == nil {
+			continue
+		}
+		if err := hooks.BeforeDelete(ctx, id); err != nil {
+			return err
+		}
+	}
+	result, err := r.Collection.DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	for _, hooks := range r.hooks {
+		if hooks.AfterDelete != nil {
+			hooks.AfterDelete(ctx, id)
+		}
+	}
+	return nil
+}
+
+// Aggregate runs a pipeline whose results have the shape of T, such as one
+// that filters, sorts or $lookup's into fields of T. Use AggregateAs for
+// pipelines producing another shape.
+func (r *Repository[T]) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]T, error) {
+	var results []T
+	err := r.read(ctx, Query{Operation: "Aggregate", Pipeline: pipeline}, func(ctx context.Context, query Query) error {
+		var err error
+		results, err = AggregateAs[T](ctx, r.Collection, query.Pipeline, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregateAs runs a pipeline on collection and decodes the results into R
+func AggregateAs[R any](ctx context.Context, collection *mongo.Collection, pipeline interface{}, opts ...*options.AggregateOptions) ([]R, error) {
+	cursor, err := collection.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	results := []R{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
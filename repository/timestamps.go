@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The fields Timestamped stores its times in
+const (
+	CreatedAtField = "created_at"
+	UpdatedAtField = "updated_at"
+)
+
+// Timestamped records when a document was created and last changed. Embed
+// it inline in a document type and add Timestamps to its repository:
+//
+//	type Podcast struct {
+//		ID    primitive.ObjectID `bson:"_id,omitempty"`
+//		Title string             `bson:"title"`
+//		repository.Timestamped `bson:",inline"`
+//	}
+//
+//	podcasts := repository.New[Podcast](collection).Use(repository.Timestamps[Podcast]())
+type Timestamped struct {
+	CreatedAt time.Time `bson:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty"`
+}
+
+func (t *Timestamped) stamp(now time.Time) {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+}
+
+// stamper is implemented by pointers to types embedding Timestamped
+type stamper interface {
+	stamp(now time.Time)
+}
+
+// Timestamps sets created_at and updated_at on inserts and updated_at on
+// updates. Inserts use the application's clock, since the server can only
+// fill in dates on updates; updates use the server's, with $currentDate or,
+// in update pipelines, $$NOW. It only compiles for types that embed
+// Timestamped.
+func Timestamps[T any, PT interface {
+	*T
+	stamper
+}]() Hooks[T] {
+	return Hooks[T]{
+		BeforeInsert: func(ctx context.Context, document *T) error {
+			// BSON dates keep milliseconds, so truncate to return what is stored
+			PT(document).stamp(time.Now().UTC().Truncate(time.Millisecond))
+			return nil
+		},
+		BeforeUpdate: func(ctx context.Context, id interface{}, update interface{}) (interface{}, error) {
+			return touch(update)
+		},
+	}
+}
+
+// touch adds setting updated_at to the current date to an update document
+// or pipeline. An update that already writes updated_at is left as it is,
+// since the server rejects two operators on one path and the caller's value
+// is the one meant.
+func touch(update interface{}) (interface{}, error) {
+	t, data, err := bson.MarshalValue(update)
+	if err != nil {
+		return nil, err
+	}
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Array:
+		var pipeline bson.A
+		if err = raw.Unmarshal(&pipeline); err != nil {
+			return nil, err
+		}
+		for _, stage := range pipeline {
+			if stage, ok := stage.(bson.D); ok && writesUpdatedAt(stage, "$set", "$addFields", "$unset", "$project") {
+				return pipeline, nil
+			}
+		}
+		return append(pipeline, bson.D{{"$set", bson.D{{UpdatedAtField, "$$NOW"}}}}), nil
+	case bsontype.EmbeddedDocument:
+		var document bson.D
+		if err = raw.Unmarshal(&document); err != nil {
+			return nil, err
+		}
+		if writesUpdatedAt(document) {
+			return document, nil
+		}
+		for i, element := range document {
+			if element.Key == "$currentDate" {
+				dates, ok := element.Value.(bson.D)
+				if !ok {
+					return nil, fmt.Errorf("$currentDate is a %T, not a document", element.Value)
+				}
+				document[i].Value = append(dates, bson.E{Key: UpdatedAtField, Value: true})
+				return document, nil
+			}
+		}
+		return append(document, bson.E{Key: "$currentDate", Value: bson.D{{UpdatedAtField, true}}}), nil
+	}
+	return nil, fmt.Errorf("update is a %v, not a document or pipeline", t)
+}
+
+// writesUpdatedAt reports whether one of the operators of an update, or of
+// a pipeline stage, writes updated_at or a field inside it, $rename targets
+// included. Without names, every operator is checked.
+func writesUpdatedAt(update bson.D, operators ...string) bool {
+	for _, operator := range update {
+		if len(operators) > 0 && !slices.Contains(operators, operator.Key) {
+			continue
+		}
+		if operator.Key == "$unset" {
+			// a pipeline $unset takes a field name or a list of them
+			switch fields := operator.Value.(type) {
+			case string:
+				if isUpdatedAt(fields) {
+					return true
+				}
+				continue
+			case bson.A:
+				for _, field := range fields {
+					if field, ok := field.(string); ok && isUpdatedAt(field) {
+						return true
+					}
+				}
+				continue
+			}
+		}
+		fields, ok := operator.Value.(bson.D)
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if isUpdatedAt(field.Key) {
+				return true
+			}
+			if target, ok := field.Value.(string); ok && operator.Key == "$rename" && isUpdatedAt(target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isUpdatedAt(path string) bool {
+	return path == UpdatedAtField || strings.HasPrefix(path, UpdatedAtField+".")
+}
+
+// ChangedSince matches documents created or updated after since
+func ChangedSince(since time.Time) bson.D {
+	return bson.D{{UpdatedAtField, bson.D{{"$gt", since}}}}
+}
+
+// CreatedSince matches documents created after since
+func CreatedSince(since time.Time) bson.D {
+	return bson.D{{CreatedAtField, bson.D{{"$gt", since}}}}
+}
+
+// FindChangedSince returns the documents changed after since, oldest change
+// first, so the UpdatedAt of the last one is where the next call can resume.
+// An index on updated_at keeps it from scanning the collection.
+func (r *Repository[T]) FindChangedSince(ctx context.Context, since time.Time, opts ...*options.FindOptions) ([]T, error) {
+	opts = append([]*options.FindOptions{options.Find().SetSort(bson.D{{UpdatedAtField, 1}})}, opts...)
+	return r.Find(ctx, ChangedSince(since), opts...)
+}
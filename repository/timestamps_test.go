@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTouch(t *testing.T) {
+	now := bson.D{{"$set", bson.D{{UpdatedAtField, "$$NOW"}}}}
+	tests := []struct {
+		name   string
+		update interface{}
+		want   interface{}
+	}{
+		{"adds $currentDate", bson.D{{"$set", bson.D{{"title", "Go"}}}},
+			bson.D{{"$set", bson.D{{"title", "Go"}}}, {"$currentDate", bson.D{{UpdatedAtField, true}}}}},
+		{"joins an existing $currentDate", bson.D{{"$currentDate", bson.D{{"seen_at", true}}}},
+			bson.D{{"$currentDate", bson.D{{"seen_at", true}, {UpdatedAtField, true}}}}},
+		{"bson.M", bson.M{"$inc": bson.M{"plays": 1}},
+			bson.D{{"$inc", bson.D{{"plays", int32(1)}}}, {"$currentDate", bson.D{{UpdatedAtField, true}}}}},
+		{"caller $sets updated_at", bson.D{{"$set", bson.D{{"title", "Go"}, {UpdatedAtField, "2020-02-05"}}}},
+			bson.D{{"$set", bson.D{{"title", "Go"}, {UpdatedAtField, "2020-02-05"}}}}},
+		{"caller sets it with $currentDate", bson.D{{"$currentDate", bson.D{{UpdatedAtField, bson.D{{"$type", "timestamp"}}}}}},
+			bson.D{{"$currentDate", bson.D{{UpdatedAtField, bson.D{{"$type", "timestamp"}}}}}}},
+		{"caller unsets it", bson.D{{"$unset", bson.D{{UpdatedAtField, ""}}}},
+			bson.D{{"$unset", bson.D{{UpdatedAtField, ""}}}}},
+		{"caller sets a field inside it", bson.D{{"$set", bson.D{{UpdatedAtField + ".by", "ada"}}}},
+			bson.D{{"$set", bson.D{{UpdatedAtField + ".by", "ada"}}}}},
+		{"caller renames onto it", bson.D{{"$rename", bson.D{{"modified", UpdatedAtField}}}},
+			bson.D{{"$rename", bson.D{{"modified", UpdatedAtField}}}}},
+		{"similar name", bson.D{{"$set", bson.D{{UpdatedAtField + "_by", "ada"}}}},
+			bson.D{{"$set", bson.D{{UpdatedAtField + "_by", "ada"}}}, {"$currentDate", bson.D{{UpdatedAtField, true}}}}},
+
+		{"pipeline", bson.A{bson.D{{"$set", bson.D{{"plays", bson.D{{"$add", bson.A{"$plays", 1}}}}}}}},
+			bson.A{bson.D{{"$set", bson.D{{"plays", bson.D{{"$add", bson.A{"$plays", int32(1)}}}}}}}, now}},
+		{"pipeline setting updated_at", bson.A{bson.D{{"$addFields", bson.D{{UpdatedAtField, "$imported_at"}}}}},
+			bson.A{bson.D{{"$addFields", bson.D{{UpdatedAtField, "$imported_at"}}}}}},
+		{"pipeline unsetting updated_at", bson.A{bson.D{{"$unset", bson.A{"draft", UpdatedAtField}}}},
+			bson.A{bson.D{{"$unset", bson.A{"draft", UpdatedAtField}}}}},
+		{"pipeline matching on updated_at", bson.A{bson.D{{"$set", bson.D{{"stale", bson.D{{"$lt", bson.A{"$" + UpdatedAtField, "$$NOW"}}}}}}}},
+			bson.A{bson.D{{"$set", bson.D{{"stale", bson.D{{"$lt", bson.A{"$" + UpdatedAtField, "$$NOW"}}}}}}}, now}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := touch(test.update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("touch =\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTouchErrors(t *testing.T) {
+	for _, update := range []interface{}{"title", bson.D{{"$currentDate", true}}} {
+		if _, err := touch(update); err == nil {
+			t.Errorf("touch(%v) succeeded", update)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"github.com/mongodb-developer/golang-quickstart/service"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// API exposes CRUD endpoints for podcasts and episodes. Every handler passes
+// r.Context() to the driver, so a client that disconnects or a request that
+// outlives the timeout cancels its database operations too.
+type API struct {
+	Service *service.Service
+}
+
+// Routes returns the handler serving every API route
+func (a *API) Routes(timeout time.Duration) http.Handler {
+	routes := openapi.New("Quickstart REST API", "1.0.0")
+	podcasts, episodes := []string{"podcasts"}, []string{"episodes"}
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/podcasts", Summary: "List podcasts", Tags: podcasts,
+		Response: []dto.Podcast{},
+	}, a.listPodcasts)
+	routes.Handle(openapi.Route{
+		Method: "POST", Path: "/podcasts", Summary: "Create a podcast", Tags: podcasts,
+		Request: dto.Podcast{}, Response: dto.Podcast{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge},
+	}, a.createPodcast)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/podcasts/{id}", Summary: "Get a podcast", Tags: podcasts,
+		Response: dto.Podcast{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.getPodcast)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/p/{slug}", Summary: "Get a podcast by its slug", Tags: podcasts,
+		Response: dto.Podcast{}, Errors: []int{http.StatusMovedPermanently, http.StatusNotFound},
+		Description: "A slug the podcast had before it was renamed redirects to its current slug.",
+	}, a.getPodcastBySlug)
+	routes.Handle(openapi.Route{
+		Method: "PUT", Path: "/podcasts/{id}", Summary: "Replace a podcast", Tags: podcasts,
+		Request: dto.Podcast{}, Response: dto.Podcast{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge},
+		Description: "The stored document is not replaced: only the fields that differ are written, with $set and $unset.",
+	}, a.updatePodcast)
+	routes.Handle(openapi.Route{
+		Method: "DELETE", Path: "/podcasts/{id}", Summary: "Delete a podcast and its episodes", Tags: podcasts,
+		Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.deletePodcast)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/podcasts/{id}/episodes", Summary: "List the episodes of a podcast", Tags: episodes,
+		Response: []dto.Episode{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.listEpisodes)
+	routes.Handle(openapi.Route{
+		Method: "POST", Path: "/podcasts/{id}/episodes", Summary: "Add an episode to a podcast", Tags: episodes,
+		Request: dto.Episode{}, Response: dto.Episode{}, Status: http.StatusCreated,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge},
+		Description: "The podcast field may be omitted; it defaults to the podcast in the path.",
+	}, a.createEpisode)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/episodes/{id}", Summary: "Get an episode", Tags: episodes,
+		Response: dto.Episode{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.getEpisode)
+	routes.Handle(openapi.Route{
+		Method: "PUT", Path: "/episodes/{id}", Summary: "Replace an episode", Tags: episodes,
+		Request: dto.Episode{}, Response: dto.Episode{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge},
+		Description: "The stored document is not replaced: only the fields that differ are written, with $set and $unset.",
+	}, a.updateEpisode)
+	routes.Handle(openapi.Route{
+		Method: "DELETE", Path: "/episodes/{id}", Summary: "Delete an episode", Tags: episodes,
+		Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.deleteEpisode)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/episodes/export", Summary: "Export every episode", Tags: episodes,
+		Query:    []openapi.Param{{Name: "format", Type: "string", Description: "json (default) for one array or ndjson for one episode per line"}},
+		Response: []dto.ExportedEpisode{}, Errors: []int{http.StatusBadRequest},
+		Description: "Streamed from the database cursor as it is read. A response cut short by an error ends without the closing bracket of the array.",
+	}, a.exportEpisodes(timeout))
+
+	// TimeoutHandler gives each request's context a deadline. The driver
+	// stops waiting at the deadline, and the service's reads send what is
+	// left of it as maxTimeMS so the server stops working too. It buffers
+	// responses, so the export is served around it and bounds its writes
+	// itself.
+	mux := http.NewServeMux()
+	mux.Handle("GET /episodes/export", routes)
+	mux.Handle("/", http.TimeoutHandler(routes, timeout, `{"error":"request timed out"}`))
+	return mux
+}
+
+func (a *API) listPodcasts(w http.ResponseWriter, r *http.Request) {
+	podcasts, err := a.Service.Podcasts(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, podcasts)
+}
+
+func (a *API) createPodcast(w http.ResponseWriter, r *http.Request) {
+	var podcast dto.Podcast
+	if err := dto.Decode(r.Body, &podcast); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if err := a.Service.CreatePodcast(r.Context(), &podcast); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/podcasts/"+podcast.ID.String())
+	writeJSON(w, http.StatusCreated, podcast)
+}
+
+func (a *API) getPodcast(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	podcast, err := a.Service.Podcast(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, podcast)
+}
+
+func (a *API) getPodcastBySlug(w http.ResponseWriter, r *http.Request) {
+	requested := r.PathValue("slug")
+	podcast, err := a.Service.PodcastBySlug(r.Context(), requested)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if podcast.Slug != requested {
+		http.Redirect(w, r, "/p/"+podcast.Slug, http.StatusMovedPermanently)
+		return
+	}
+	writeJSON(w, http.StatusOK, podcast)
+}
+
+func (a *API) updatePodcast(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	podcast := dto.Podcast{ID: id}
+	if err := dto.Decode(r.Body, &podcast); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if podcast.ID.IsZero() {
+		podcast.ID = id
+	}
+	if podcast.ID != id {
+		writeError(w, http.StatusBadRequest, "id in the body does not match the path")
+		return
+	}
+	if err := a.Service.UpdatePodcast(r.Context(), &podcast); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, podcast)
+}
+
+func (a *API) deletePodcast(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := a.Service.DeletePodcast(r.Context(), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) listEpisodes(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	// an unknown podcast is a 404, not an empty list
+	if _, err := a.Service.Podcast(r.Context(), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	episodes, err := a.Service.Episodes(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, episodes)
+}
+
+func (a *API) createEpisode(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	episode := dto.Episode{Podcast: id}
+	if err := dto.Decode(r.Body, &episode); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if episode.Podcast != id {
+		writeError(w, http.StatusBadRequest, "podcast in the body does not match the path")
+		return
+	}
+	if _, err := a.Service.Podcast(r.Context(), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if err := a.Service.CreateEpisode(r.Context(), &episode); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/episodes/"+episode.ID.String())
+	writeJSON(w, http.StatusCreated, episode)
+}
+
+func (a *API) getEpisode(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	episode, err := a.Service.Episode(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, episode)
+}
+
+func (a *API) updateEpisode(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	episode := dto.Episode{ID: id}
+	if err := dto.Decode(r.Body, &episode); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if episode.ID.IsZero() {
+		episode.ID = id
+	}
+	if episode.ID != id {
+		writeError(w, http.StatusBadRequest, "id in the body does not match the path")
+		return
+	}
+	if err := a.Service.UpdateEpisode(r.Context(), episode); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, episode)
+}
+
+func (a *API) deleteEpisode(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := a.Service.DeleteEpisode(r.Context(), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathID parses the {id} path parameter, writing a 400 response when it is
+// not an ObjectID
+func pathID(w http.ResponseWriter, r *http.Request) (dto.ID, bool) {
+	id, err := dto.ParseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be a 24-character hex ObjectID")
+		return dto.NilID, false
+	}
+	return id, true
+}
+
+// writeServiceError maps errors from decoding and the service to status codes
+func writeServiceError(w http.ResponseWriter, err error) {
+	var validation *dto.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		writeJSON(w, http.StatusBadRequest, validation)
+	case errors.Is(err, dto.ErrBodyTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, service.ErrNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	case mongo.IsDuplicateKeyError(err):
+		writeError(w, http.StatusConflict, "a document with this id already exists")
+	case errors.Is(err, context.DeadlineExceeded), mongo.IsTimeout(err):
+		writeError(w, http.StatusServiceUnavailable, "database timed out")
+	case errors.Is(err, context.Canceled):
+		// the client went away, nobody is left to read a response
+	default:
+		log.Printf("request failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+)
+
+// exportBatch is how many episodes are fetched from the server, written and
+// flushed to the client at a time
+const exportBatch = 500
+
+// exportEpisodes streams every episode from the aggregation cursor to the
+// client as a JSON array, or as newline-delimited JSON with ?format=ndjson,
+// so memory use does not grow with the collection. It must not run behind
+// http.TimeoutHandler, which buffers the whole response and cannot flush;
+// timeout limits each batch's write instead, so a client that stops reading
+// does not hold the cursor open forever.
+func (a *API) exportEpisodes(timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ndjson bool
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+		case "ndjson":
+			ndjson = true
+		default:
+			writeError(w, http.StatusBadRequest, "format must be json or ndjson")
+			return
+		}
+		// a disconnecting client cancels r.Context(), which makes the next
+		// cursor.Next fail instead of fetching batches nobody will read
+		ctx := r.Context()
+		cursor, err := a.Service.ExportEpisodes(ctx, exportBatch)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		defer cursor.Close(context.Background())
+
+		// from here on the status is sent: a failure can only cut the
+		// response short, which leaves a JSON array unterminated
+		controller := http.NewResponseController(w)
+		controller.SetWriteDeadline(time.Now().Add(timeout))
+		if ndjson {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, "[")
+		}
+		encoder := json.NewEncoder(w)
+		count := 0
+		for cursor.Next(ctx) {
+			var episode dto.ExportedEpisode
+			if err = cursor.Decode(&episode); err != nil {
+				log.Printf("export episodes: decode: %v", err)
+				return
+			}
+			if !ndjson && count > 0 {
+				io.WriteString(w, ",")
+			}
+			if err = encoder.Encode(episode); err != nil {
+				log.Printf("export episodes: write after %d: %v", count, err)
+				return
+			}
+			count++
+			// the next call to Next fetches another batch, so send this one
+			// to the client first
+			if cursor.RemainingBatchLength() == 0 {
+				if err = flush(controller, timeout); err != nil {
+					log.Printf("export episodes: flush after %d: %v", count, err)
+					return
+				}
+			}
+		}
+		if err = cursor.Err(); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Printf("export episodes: after %d: %v", count, err)
+			}
+			return
+		}
+		if !ndjson {
+			io.WriteString(w, "]\n")
+		}
+	}
+}
+
+// flush sends what has been written so far and gives the next batch timeout
+// to be written. Writers that cannot flush, such as httptest's recorder,
+// simply keep buffering.
+func flush(controller *http.ResponseController, timeout time.Duration) error {
+	if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	controller.SetWriteDeadline(time.Now().Add(timeout))
+	return nil
+}
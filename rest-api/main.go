@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/service"
+)
+
+var (
+	addr    = flag.String("addr", ":8080", "address to serve the API on")
+	timeout = flag.Duration("timeout", 5*time.Second, "time limit for each request, database operations included")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// one client serves every request: it is safe for concurrent use and
+	// keeps a pool of connections, so handlers must never create their own
+	database := client.Database("quickstart")
+	api := &API{Service: service.New(database, database)}
+	if err = api.Service.EnsureIndexes(connectCtx); err != nil {
+		return err
+	}
+
+	log.Printf("serving podcasts and episodes on %s, docs at /docs", *addr)
+	if err = shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: api.Routes(*timeout)}); err != nil {
+		return err
+	}
+	return nil
+}
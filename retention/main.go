@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// Rule is one retention policy: documents of Collection whose DateField is
+// older than MaxAge, and that match Filter, get Action applied
+type Rule struct {
+	Name       string                 `yaml:"name"`
+	Collection string                 `yaml:"collection"`
+	DateField  string                 `yaml:"date_field"`
+	MaxAge     string                 `yaml:"max_age"`
+	Filter     map[string]interface{} `yaml:"filter"`
+	// Action is delete, redact (unset Fields) or archive (move the documents
+	// to ArchiveTo, "<collection>_archive" by default)
+	Action    string   `yaml:"action"`
+	Fields    []string `yaml:"fields"`
+	ArchiveTo string   `yaml:"archive_to"`
+}
+
+// Report is the outcome of applying one rule
+type Report struct {
+	Rule     Rule
+	Cutoff   time.Time
+	Matched  int64
+	Affected int64
+}
+
+// validate checks a rule and returns its maximum age
+func (r Rule) validate() (time.Duration, error) {
+	if r.Name == "" || r.Collection == "" || r.DateField == "" {
+		return 0, errors.New("name, collection and date_field are required")
+	}
+	switch r.Action {
+	case "delete", "archive":
+	case "redact":
+		if len(r.Fields) == 0 {
+			return 0, errors.New("redact needs fields")
+		}
+	default:
+		return 0, fmt.Errorf("unknown action %q", r.Action)
+	}
+	return parseAge(r.MaxAge)
+}
+
+// parseAge accepts time.ParseDuration syntax plus whole days ("365d")
+func parseAge(text string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid max_age %q", text)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_age %q", text)
+	}
+	return age, nil
+}
+
+// filter selects the documents a rule applies to. Redaction skips documents
+// that no longer have any of the fields, so runs after the first are cheap.
+func (r Rule) filter(cutoff time.Time) bson.D {
+	conditions := bson.A{bson.D{{r.DateField, bson.D{{"$lt", cutoff}}}}}
+	if len(r.Filter) > 0 {
+		conditions = append(conditions, r.Filter)
+	}
+	if r.Action == "redact" {
+		present := bson.A{}
+		for _, field := range r.Fields {
+			present = append(present, bson.D{{field, bson.D{{"$exists", true}}}})
+		}
+		conditions = append(conditions, bson.D{{"$or", present}})
+	}
+	return bson.D{{"$and", conditions}}
+}
+
+// Engine applies retention rules to one database
+type Engine struct {
+	Database  *mongo.Database
+	Rules     []Rule
+	BatchSize int
+	DryRun    bool
+}
+
+// Run applies every rule and reports what happened, or in a dry run what
+// would happen. A failing rule does not stop the others.
+func (e *Engine) Run(ctx context.Context, now time.Time) ([]Report, error) {
+	var reports []Report
+	var errs []error
+	for _, rule := range e.Rules {
+		report, err := e.apply(ctx, rule, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Name, err))
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, errors.Join(errs...)
+}
+
+func (e *Engine) apply(ctx context.Context, rule Rule, now time.Time) (Report, error) {
+	maxAge, err := rule.validate()
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Rule: rule, Cutoff: now.Add(-maxAge)}
+	collection := e.Database.Collection(rule.Collection)
+	filter := rule.filter(report.Cutoff)
+
+	report.Matched, err = collection.CountDocuments(ctx, filter)
+	if err != nil || e.DryRun || report.Matched == 0 {
+		return report, err
+	}
+
+	switch rule.Action {
+	case "delete":
+		result, err := collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return report, err
+		}
+		report.Affected = result.DeletedCount
+	case "redact":
+		unset := bson.D{}
+		for _, field := range rule.Fields {
+			unset = append(unset, bson.E{field, ""})
+		}
+		result, err := collection.UpdateMany(ctx, filter, bson.D{
+			{"$unset", unset},
+			{"$set", bson.D{{"redacted_at", now}}},
+		})
+		if err != nil {
+			return report, err
+		}
+		report.Affected = result.ModifiedCount
+	case "archive":
+		report.Affected, err = e.archive(ctx, rule, collection, filter)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// archive moves matching documents in batches: each batch is copied into the
+// archive collection, then deleted from the source. A batch interrupted
+// between the two steps is copied again on the next run and the duplicate
+// key errors are ignored, so nothing is lost or archived twice.
+func (e *Engine) archive(ctx context.Context, rule Rule, collection *mongo.Collection, filter bson.D) (int64, error) {
+	target := rule.ArchiveTo
+	if target == "" {
+		target = rule.Collection + "_archive"
+	}
+	archive := e.Database.Collection(target)
+	var moved int64
+	for {
+		opts := options.Find().SetLimit(int64(e.BatchSize)).SetSort(bson.D{{"_id", 1}})
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return moved, err
+		}
+		var batch []bson.Raw
+		if err = cursor.All(ctx, &batch); err != nil {
+			return moved, err
+		}
+		if len(batch) == 0 {
+			return moved, nil
+		}
+		documents := make([]interface{}, len(batch))
+		ids := make(bson.A, len(batch))
+		for i, document := range batch {
+			documents[i] = document
+			ids[i] = document.Lookup("_id")
+		}
+		_, err = archive.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+		if err != nil && !onlyDuplicates(err) {
+			return moved, err
+		}
+		result, err := collection.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", ids}}}})
+		if err != nil {
+			return moved, err
+		}
+		moved += result.DeletedCount
+	}
+}
+
+// onlyDuplicates reports whether every write error is a duplicate key error
+func onlyDuplicates(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return false
+		}
+	}
+	return true
+}
+
+func printReports(reports []Report, dryRun bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	affected := "AFFECTED"
+	if dryRun {
+		affected = "AFFECTED (DRY RUN)"
+	}
+	fmt.Fprintf(w, "RULE\tCOLLECTION\tACTION\tOLDER THAN\tMATCHED\t%s\n", affected)
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n", report.Rule.Name, report.Rule.Collection, report.Rule.Action,
+			report.Cutoff.Format(time.RFC3339), report.Matched, report.Affected)
+	}
+	w.Flush()
+}
+
+var (
+	rulesPath = flag.String("rules", "", "YAML file of retention rules (default: the built-in rules)")
+	dryRun    = flag.Bool("dry-run", false, "only report how many documents each rule matches")
+	batchSize = flag.Int("batch-size", 500, "documents moved per batch by archive rules")
+	interval  = flag.Duration("interval", 24*time.Hour, "time between runs")
+	once      = flag.Bool("once", false, "run the rules a single time and exit")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	data := defaultRules
+	if *rulesPath != "" {
+		var err error
+		if data, err = os.ReadFile(*rulesPath); err != nil {
+			return err
+		}
+	}
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("rules: %v", err)
+	}
+	for _, rule := range rules {
+		if _, err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	engine := &Engine{Database: client.Database("quickstart"), Rules: rules, BatchSize: *batchSize, DryRun: *dryRun}
+	for {
+		reports, err := engine.Run(ctx, time.Now().UTC())
+		printReports(reports, *dryRun)
+		if err != nil {
+			log.Printf("retention: %v", err)
+		}
+		if *once || *dryRun {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
@@ -2,72 +2,35 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"flag"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/examples/retrieving"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/querylint"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func main() {
-	client, err := mongo.NewClient(options.Client().ApplyURI("<ATLAS_URI_HERE>"))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer client.Disconnect(ctx)
-
-	podcastsCollection := client.Database("quickstart").Collection("podcasts")
-	episodesCollection := client.Database("quickstart").Collection("episodes")
-
-	// Retrieve All Documents
-	cursor, err := episodesCollection.Find(ctx, bson.M{})
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer cursor.Close(ctx)
-	for cursor.Next(ctx) {
-		var episode bson.M
-		if err = cursor.Decode(&episode); err != nil {
-			log.Fatal(err)
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(func(ctx context.Context, down *shutdown.Shutdown) error {
+		clientOptions := options.Client()
+		// QUICKSTART_QUERYLINT=1 logs queries that scan a whole collection
+		linter := querylint.New(1000)
+		if querylint.Enabled() {
+			clientOptions.SetMonitor(linter.CommandMonitor())
 		}
-		fmt.Println(episode)
-	}
-
-	// Retrieve A Single Document
-	var podcast bson.M
-	if err = podcastsCollection.FindOne(ctx, bson.M{}).Decode(&podcast); err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println(podcast)
-
-	// Find Documents Matching A Filter
-	filterCursor, err := episodesCollection.Find(ctx, bson.M{"duration": 25})
-	if err != nil {
-		log.Fatal(err)
-	}
-	var episodesFiltered []bson.M
-	if err = filterCursor.All(ctx, &episodesFiltered); err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println(episodesFiltered)
-
-	// Find Documents Matching Filter And Sort
-	opts := options.Find()
-	opts.SetSort(bson.D{{"duration", -1}})
-	sortCursor, err := episodesCollection.Find(ctx, bson.D{{"duration", bson.D{{"$gt", 24}}}}, opts)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var episodesSorted []bson.M
-	if err = sortCursor.All(ctx, &episodesSorted); err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println(episodesSorted)
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		deps, err := examples.Connect(ctx, down, clientOptions)
+		if err != nil {
+			return err
+		}
+		linter.Attach(deps.Client)
+		defer linter.Wait()
+		return retrieving.Run(ctx, deps)
+	})
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/schemadrift"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Podcast is the schema documents in "podcasts" are expected to have
+type Podcast struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	Title  string             `bson:"title"`
+	Author string             `bson:"author"`
+	Tags   []string           `bson:"tags,omitempty"`
+	Slug   string             `bson:"slug,omitempty"`
+}
+
+// Episode is the schema documents in "episodes" are expected to have
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Podcast     primitive.ObjectID `bson:"podcast"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration"`
+}
+
+// scanners checks a collection against the struct of each -type
+var scanners = map[string]func(context.Context, *mongo.Collection, schemadrift.Options) (schemadrift.Report, error){
+	"podcast": schemadrift.Scan[Podcast],
+	"episode": schemadrift.Scan[Episode],
+}
+
+var (
+	databaseName   = flag.String("db", "quickstart", "database to scan")
+	collectionName = flag.String("collection", "", "collection to scan (default: the plural of -type)")
+	typeName       = flag.String("type", "episode", "expected schema: "+strings.Join(types(), " or "))
+	sampleSize     = flag.Int64("sample", 0, "scan this many random documents instead of all of them")
+	examples       = flag.Int("examples", 3, "example _ids to show per issue")
+)
+
+func types() []string {
+	names := make([]string, 0, len(scanners))
+	for name := range scanners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	scan, ok := scanners[*typeName]
+	if !ok {
+		return fmt.Errorf("%w: unknown -type %q", shutdown.ErrUsage, *typeName)
+	}
+	if *collectionName == "" {
+		*collectionName = *typeName + "s"
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	collection := client.Database(*databaseName).Collection(*collectionName)
+	report, err := scan(ctx, collection, schemadrift.Options{Sample: *sampleSize, Examples: *examples})
+	if err != nil {
+		return fmt.Errorf("scan %s: %w", *collectionName, err)
+	}
+	fmt.Printf("Scanned %d document(s) of %s against %s, %d drifted\n",
+		report.Scanned, *collectionName, *typeName, report.Drifted)
+	for _, issue := range report.Issues {
+		fmt.Println(" ", issue)
+	}
+	if len(report.Issues) > 0 {
+		// extra fields are harmless to decoding, the other issues are not
+		fmt.Println("Fix the wrong types and missing fields before adding a $jsonSchema validator, see ../schema-validation")
+	}
+	return nil
+}
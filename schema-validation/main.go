@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// podcastSchema requires a title and an author and constrains the optional
+// fields that are present
+var podcastSchema = bson.D{
+	{"bsonType", "object"},
+	{"required", bson.A{"title", "author"}},
+	{"properties", bson.D{
+		{"title", bson.D{{"bsonType", "string"}, {"minLength", 1}, {"description", "a non-empty string"}}},
+		{"author", bson.D{{"bsonType", "string"}}},
+		{"tags", bson.D{
+			{"bsonType", "array"},
+			{"uniqueItems", true},
+			{"items", bson.D{{"bsonType", "string"}}},
+		}},
+		{"plays", bson.D{{"bsonType", bson.A{"int", "long"}}, {"minimum", 0}}},
+	}},
+}
+
+// withLanguage is podcastSchema with a required language field, the change
+// applied with collMod
+func withLanguage(schema bson.D) bson.D {
+	changed := bson.D{}
+	for _, element := range schema {
+		switch element.Key {
+		case "required":
+			element.Value = append(bson.A{}, append(element.Value.(bson.A), "language")...)
+		case "properties":
+			element.Value = append(append(bson.D{}, element.Value.(bson.D)...),
+				bson.E{"language", bson.D{{"enum", bson.A{"en", "de", "es", "fr"}}}})
+		}
+		changed = append(changed, element)
+	}
+	return changed
+}
+
+// validationError returns the details the server attached to a write that
+// failed document validation, or false for any other error
+func validationError(err error) (bson.Raw, bool) {
+	var writeErr mongo.WriteException
+	if !errors.As(err, &writeErr) {
+		return nil, false
+	}
+	for _, failure := range writeErr.WriteErrors {
+		if failure.Code == 121 {
+			return failure.Details, true
+		}
+	}
+	return nil, false
+}
+
+// Rule is one unsatisfied rule from the details of a validation error
+type Rule struct {
+	Operator          string     `bson:"operatorName"`
+	MissingProperties []string   `bson:"missingProperties"`
+	Properties        []Property `bson:"propertiesNotSatisfied"`
+}
+
+// Property is a field that failed one or more of its rules
+type Property struct {
+	Name    string `bson:"propertyName"`
+	Details []struct {
+		Operator string        `bson:"operatorName"`
+		Reason   string        `bson:"reason"`
+		Value    bson.RawValue `bson:"consideredValue"`
+	} `bson:"details"`
+}
+
+// explain turns the details of a validation error into one line per failure
+func explain(details bson.Raw) []string {
+	var info struct {
+		Details struct {
+			Rules []Rule `bson:"schemaRulesNotSatisfied"`
+		} `bson:"details"`
+	}
+	if err := bson.Unmarshal(details, &info); err != nil {
+		return []string{fmt.Sprintf("unreadable details: %v", err)}
+	}
+	var lines []string
+	for _, rule := range info.Details.Rules {
+		if len(rule.MissingProperties) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: missing %s", rule.Operator, strings.Join(rule.MissingProperties, ", ")))
+		}
+		for _, property := range rule.Properties {
+			for _, detail := range property.Details {
+				line := fmt.Sprintf("%s: %s %s", property.Name, detail.Operator, detail.Reason)
+				if detail.Value.Type != 0 {
+					line += fmt.Sprintf(" (got %v)", detail.Value)
+				}
+				lines = append(lines, line)
+			}
+		}
+		if len(rule.MissingProperties) == 0 && len(rule.Properties) == 0 {
+			lines = append(lines, rule.Operator)
+		}
+	}
+	return lines
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// Create The Collection With A $jsonSchema Validator
+	database := client.Database("quickstart")
+	podcastsCollection := database.Collection("validated_podcasts")
+	if err = podcastsCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop validated_podcasts: %w", err)
+	}
+	err = database.CreateCollection(ctx, "validated_podcasts", options.CreateCollection().
+		SetValidator(bson.D{{"$jsonSchema", podcastSchema}}))
+	if err != nil {
+		return fmt.Errorf("create validated_podcasts: %w", err)
+	}
+
+	_, err = podcastsCollection.InsertOne(ctx, bson.D{
+		{"title", "The Polyglot Developer Podcast"},
+		{"author", "Nic Raboy"},
+		{"tags", bson.A{"development", "programming"}},
+		{"plays", 0},
+	})
+	if err != nil {
+		return fmt.Errorf("insert valid podcast: %w", err)
+	}
+	fmt.Println("Inserted a valid podcast")
+
+	// An Invalid Insert Fails With Code 121 And Says Why
+	_, err = podcastsCollection.InsertOne(ctx, bson.D{
+		{"title", ""},
+		{"tags", bson.A{"go", "go", 42}},
+		{"plays", -1},
+	})
+	details, ok := validationError(err)
+	if !ok {
+		return fmt.Errorf("insert invalid podcast: expected a validation error, got %v", err)
+	}
+	fmt.Println("Inserting an invalid podcast failed document validation:")
+	for _, line := range explain(details) {
+		fmt.Println("  " + line)
+	}
+
+	// Change The Validator With collMod
+	// moderate validation only checks inserts and updates of documents that
+	// already pass, so existing podcasts without a language stay editable
+	err = database.RunCommand(ctx, bson.D{
+		{"collMod", "validated_podcasts"},
+		{"validator", bson.D{{"$jsonSchema", withLanguage(podcastSchema)}}},
+		{"validationLevel", "moderate"},
+		{"validationAction", "error"},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("collMod validated_podcasts: %w", err)
+	}
+	specs, err := database.ListCollectionSpecifications(ctx, bson.D{{"name", "validated_podcasts"}})
+	if err != nil {
+		return fmt.Errorf("list validated_podcasts: %w", err)
+	}
+	required, _ := specs[0].Options.LookupErr("validator", "$jsonSchema", "required")
+	fmt.Println("Required fields are now", required)
+
+	_, err = podcastsCollection.InsertOne(ctx, bson.D{{"title", "Go Time"}, {"author", "Changelog"}})
+	if details, ok := validationError(err); ok {
+		fmt.Println("Inserting a podcast without a language now fails:", strings.Join(explain(details), "; "))
+	} else if err != nil {
+		return fmt.Errorf("insert podcast without language: %w", err)
+	}
+	_, err = podcastsCollection.InsertOne(ctx, bson.D{{"title", "Go Time"}, {"author", "Changelog"}, {"language", "en"}})
+	if err != nil {
+		return fmt.Errorf("insert podcast with language: %w", err)
+	}
+	fmt.Println("Inserted a podcast with a language")
+	return nil
+}
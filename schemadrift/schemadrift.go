@@ -0,0 +1,355 @@
+// Package schemadrift finds documents that no longer fit the Go struct a
+// collection is decoded into, the way Compass's schema tab shows variance,
+// so drifted data can be cleaned up before a $jsonSchema validator starts
+// rejecting writes:
+//
+//	report, err := schemadrift.Scan[dto.Episode](ctx, collection, schemadrift.Options{})
+//	for _, issue := range report.Issues {
+//		fmt.Println(issue) // duration: string in 3 document(s), want int32 (e.g. ObjectID("..."))
+//	}
+//
+// The schema is read from bson struct tags. A value has the wrong type when
+// the driver cannot decode it into its field, and a field is required unless
+// it is a pointer or tagged omitempty, since the struct always writes it.
+// Paths into arrays end in "[]", as in "episodes[].title".
+package schemadrift
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kind is the kind of variance an Issue reports
+type Kind string
+
+// The kinds of variance
+const (
+	// Extra fields are in documents but not in the struct. Decoding
+	// ignores them, so they are usually leftovers of a removed field or
+	// typos written by other code.
+	Extra Kind = "extra field"
+	// WrongType values cannot be decoded into their field, so reading the
+	// document fails
+	WrongType Kind = "wrong type"
+	// Missing fields are required by the struct but not in documents
+	Missing Kind = "missing field"
+)
+
+// Issue is one kind of variance at one path, with how many documents have it
+type Issue struct {
+	Kind Kind
+	Path string
+	// Found is the BSON type of WrongType values, Want the Go type of the
+	// field
+	Found string
+	Want  string
+	Count int64
+	// Examples are _ids of documents with the issue
+	Examples []interface{}
+}
+
+// String describes the issue on one line
+func (i Issue) String() string {
+	var b strings.Builder
+	switch i.Kind {
+	case WrongType:
+		fmt.Fprintf(&b, "%s: %s in %d document(s), want %s", i.Path, i.Found, i.Count, i.Want)
+	default:
+		fmt.Fprintf(&b, "%s: %s in %d document(s)", i.Path, i.Kind, i.Count)
+	}
+	if len(i.Examples) > 0 {
+		examples := make([]string, 0, len(i.Examples))
+		for _, id := range i.Examples {
+			examples = append(examples, fmt.Sprint(id))
+		}
+		fmt.Fprintf(&b, " (e.g. %s)", strings.Join(examples, ", "))
+	}
+	return b.String()
+}
+
+// Report is the result of a Scan
+type Report struct {
+	Scanned int64
+	// Drifted counts the documents with at least one issue
+	Drifted int64
+	// Issues are sorted by path, then kind
+	Issues []Issue
+}
+
+// Options tune a Scan
+type Options struct {
+	// Filter limits the scan to matching documents
+	Filter interface{}
+	// Sample scans that many random documents instead of the whole
+	// collection
+	Sample int64
+	// Examples is the number of _ids kept per issue, 3 by default
+	Examples int
+}
+
+// Scan checks the documents of collection against T
+func Scan[T any](ctx context.Context, collection *mongo.Collection, opts Options) (Report, error) {
+	if opts.Examples == 0 {
+		opts.Examples = 3
+	}
+	filter := opts.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	var cursor *mongo.Cursor
+	var err error
+	if opts.Sample > 0 {
+		cursor, err = collection.Aggregate(ctx, mongo.Pipeline{
+			{{"$match", filter}},
+			{{"$sample", bson.D{{"size", opts.Sample}}}},
+		})
+	} else {
+		// natural order reads the collection front to back without an index
+		cursor, err = collection.Find(ctx, filter, options.Find().SetHint(bson.D{{"$natural", 1}}))
+	}
+	if err != nil {
+		return Report{}, err
+	}
+	defer cursor.Close(ctx)
+
+	schema := reflect.TypeOf((*T)(nil)).Elem()
+	var report Report
+	found := map[issueKey]*Issue{}
+	for cursor.Next(ctx) {
+		report.Scanned++
+		issues := Check(schema, cursor.Current)
+		if len(issues) > 0 {
+			report.Drifted++
+		}
+		id := cursor.Current.Lookup("_id")
+		for _, issue := range issues {
+			total, ok := found[issue.key()]
+			if !ok {
+				total = &Issue{Kind: issue.Kind, Path: issue.Path, Found: issue.Found, Want: issue.Want}
+				found[issue.key()] = total
+			}
+			total.Count++
+			if len(total.Examples) < opts.Examples {
+				total.Examples = append(total.Examples, id)
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return report, err
+	}
+	for _, issue := range found {
+		report.Issues = append(report.Issues, *issue)
+	}
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Found < b.Found
+	})
+	return report, nil
+}
+
+// Check returns the issues of one document against the struct type schema,
+// each with a Count of 1 and no examples. An issue inside an array is
+// reported once per document, not once per element.
+func Check(schema reflect.Type, document bson.Raw) []Issue {
+	c := &checker{seen: map[issueKey]bool{}}
+	c.document("", indirect(schema), document)
+	return c.issues
+}
+
+// issueKey identifies an issue regardless of its count and examples
+type issueKey struct {
+	kind              Kind
+	path, found, want string
+}
+
+func (i Issue) key() issueKey {
+	return issueKey{i.Kind, i.Path, i.Found, i.Want}
+}
+
+type checker struct {
+	issues []Issue
+	seen   map[issueKey]bool
+}
+
+func (c *checker) add(issue Issue) {
+	if c.seen[issue.key()] {
+		return
+	}
+	c.seen[issue.key()] = true
+	issue.Count = 1
+	c.issues = append(c.issues, issue)
+}
+
+// field is a field of a schema struct as stored in BSON
+type field struct {
+	typ      reflect.Type
+	required bool
+}
+
+// fields returns the fields of struct t by BSON key, including those of
+// inline structs, and whether an inline map accepts any other key
+func fields(t reflect.Type) (map[string]field, bool) {
+	all := map[string]field{}
+	open := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, tagOptions, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		if key == "-" {
+			continue
+		}
+		if strings.Contains(tagOptions, "inline") {
+			inline := indirect(f.Type)
+			if inline.Kind() == reflect.Map {
+				open = true
+				continue
+			}
+			inner, innerOpen := fields(inline)
+			for k, v := range inner {
+				all[k] = v
+			}
+			open = open || innerOpen
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		all[key] = field{
+			typ:      f.Type,
+			required: f.Type.Kind() != reflect.Pointer && !strings.Contains(tagOptions, "omitempty"),
+		}
+	}
+	return all, open
+}
+
+func (c *checker) document(prefix string, t reflect.Type, document bson.Raw) {
+	expected, open := fields(t)
+	elements, err := document.Elements()
+	if err != nil {
+		c.add(Issue{Kind: WrongType, Path: prefix, Found: "invalid document", Want: t.String()})
+		return
+	}
+	present := map[string]bool{}
+	for _, element := range elements {
+		key := element.Key()
+		present[key] = true
+		f, ok := expected[key]
+		if !ok {
+			if !open {
+				c.add(Issue{Kind: Extra, Path: join(prefix, key)})
+			}
+			continue
+		}
+		c.value(join(prefix, key), f.typ, element.Value())
+	}
+	// in key order, so Check returns the same issues in the same order
+	// every time
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if f := expected[key]; f.required && !present[key] {
+			c.add(Issue{Kind: Missing, Path: join(prefix, key), Want: f.typ.String()})
+		}
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(bson.Raw{})
+	// types decoding themselves are checked by decoding, not field by field
+	unmarshalerType      = reflect.TypeOf((*bson.Unmarshaler)(nil)).Elem()
+	valueUnmarshalerType = reflect.TypeOf((*bson.ValueUnmarshaler)(nil)).Elem()
+)
+
+func (c *checker) value(path string, t reflect.Type, value bson.RawValue) {
+	if t.Kind() == reflect.Pointer && value.Type == bsontype.Null {
+		return
+	}
+	t = indirect(t)
+	switch {
+	case t.Kind() == reflect.Interface || t == rawType:
+		return
+	case isDocument(t):
+		if value.Type != bsontype.EmbeddedDocument {
+			c.wrongType(path, t, value)
+			return
+		}
+		c.document(path, t, value.Document())
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 && t.Elem() != reflect.TypeOf(bson.E{}):
+		if value.Type == bsontype.Null && t.Kind() == reflect.Slice {
+			return
+		}
+		if value.Type != bsontype.Array {
+			c.wrongType(path, t, value)
+			return
+		}
+		elements, err := value.Array().Values()
+		if err != nil {
+			c.wrongType(path, t, value)
+			return
+		}
+		for _, element := range elements {
+			c.value(path+"[]", t.Elem(), element)
+		}
+	default:
+		// everything else is checked by decoding it as the driver would
+		if err := bson.UnmarshalValue(value.Type, value.Value, reflect.New(t).Interface()); err != nil {
+			c.wrongType(path, t, value)
+		}
+	}
+}
+
+func (c *checker) wrongType(path string, t reflect.Type, value bson.RawValue) {
+	c.add(Issue{Kind: WrongType, Path: path, Found: value.Type.String(), Want: t.String()})
+}
+
+// isDocument reports whether t is a struct checked field by field
+func isDocument(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	pointer := reflect.PointerTo(t)
+	if pointer.Implements(unmarshalerType) || pointer.Implements(valueUnmarshalerType) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
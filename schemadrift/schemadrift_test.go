@@ -0,0 +1,108 @@
+package schemadrift
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type device struct {
+	OS      string `bson:"os"`
+	Version int32  `bson:"version"`
+}
+
+type account struct {
+	Name    string                 `bson:"name"`
+	Email   string                 `bson:"email,omitempty"`
+	Age     *int32                 `bson:"age"`
+	Created time.Time              `bson:"created"`
+	Devices []device               `bson:"devices"`
+	Profile *struct{ City string } `bson:"profile,omitempty"`
+	Tags    []string               `bson:"tags,omitempty"`
+	Secret  string                 `bson:"-"`
+}
+
+type extensible struct {
+	Name  string                 `bson:"name"`
+	Other map[string]interface{} `bson:",inline"`
+}
+
+func TestCheck(t *testing.T) {
+	created := time.Date(2020, 2, 5, 0, 0, 0, 0, time.UTC)
+	valid := func(extra ...bson.E) bson.D {
+		return append(bson.D{{"name", "Ada"}, {"created", created}, {"devices", bson.A{}}}, extra...)
+	}
+	tests := []struct {
+		name     string
+		schema   interface{}
+		document bson.D
+		want     []Issue
+	}{
+		{"fits", account{}, valid(bson.E{"email", "ada@example.com"}, bson.E{"age", 36}), nil},
+		{"optional fields missing", account{}, valid(), nil},
+		{"null pointer and slice", account{}, bson.D{{"name", "Ada"}, {"created", created}, {"devices", nil}, {"age", nil}}, nil},
+		{"added field", account{}, valid(bson.E{"nickname", "Countess"}),
+			[]Issue{{Kind: Extra, Path: "nickname", Count: 1}}},
+		{"removed fields", account{}, bson.D{{"devices", bson.A{}}},
+			[]Issue{
+				{Kind: Missing, Path: "created", Want: "time.Time", Count: 1},
+				{Kind: Missing, Path: "name", Want: "string", Count: 1},
+			}},
+		{"ignored field is extra", account{}, valid(bson.E{"secret", "x"}),
+			[]Issue{{Kind: Extra, Path: "secret", Count: 1}}},
+		{"type changed", account{}, bson.D{{"name", 42}, {"created", "2020-02-05"}, {"devices", bson.A{}}},
+			[]Issue{
+				{Kind: WrongType, Path: "name", Found: "32-bit integer", Want: "string", Count: 1},
+				{Kind: WrongType, Path: "created", Found: "string", Want: "time.Time", Count: 1},
+			}},
+		{"nested fields", account{}, valid(bson.E{"profile", bson.D{{"city", 5}, {"zip", "10115"}}}),
+			[]Issue{
+				{Kind: WrongType, Path: "profile.city", Found: "32-bit integer", Want: "string", Count: 1},
+				{Kind: Extra, Path: "profile.zip", Count: 1},
+			}},
+		{"embedded document replaced by a value", account{}, valid(bson.E{"profile", "Berlin"}),
+			[]Issue{{Kind: WrongType, Path: "profile", Found: "string", Want: "struct { City string }", Count: 1}}},
+		{"array elements reported once", account{}, bson.D{{"name", "Ada"}, {"created", created}, {"devices", bson.A{
+			bson.D{{"os", "ios"}, {"version", "17"}},
+			bson.D{{"os", "android"}, {"version", "14"}},
+			bson.D{{"version", 17}},
+		}}},
+			[]Issue{
+				{Kind: WrongType, Path: "devices[].version", Found: "string", Want: "int32", Count: 1},
+				{Kind: Missing, Path: "devices[].os", Want: "string", Count: 1},
+			}},
+		{"array replaced by a value", account{}, valid(bson.E{"tags", "go"}),
+			[]Issue{{Kind: WrongType, Path: "tags", Found: "string", Want: "[]string", Count: 1}}},
+		{"inline map takes other fields", extensible{}, bson.D{{"name", "Ada"}, {"anything", 1}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, err := bson.Marshal(test.document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Check(reflect.TypeOf(test.schema), document)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Check =\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  string
+	}{
+		{Issue{Kind: WrongType, Path: "duration", Found: "string", Want: "int32", Count: 3, Examples: []interface{}{1, 2}},
+			"duration: string in 3 document(s), want int32 (e.g. 1, 2)"},
+		{Issue{Kind: Extra, Path: "nickname", Count: 1}, "nickname: extra field in 1 document(s)"},
+	}
+	for _, test := range tests {
+		if got := test.issue.String(); got != test.want {
+			t.Errorf("String = %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Package scoped restricts a repository to the documents of one owner, the
+// way row-level security does in SQL databases. The owner comes from the
+// request context, and every read and write is rewritten to include it:
+//
+//	notes := scoped.New[Note](database.Collection("notes"))
+//	ctx = scoped.WithOwner(ctx, userID)
+//	note, err := notes.FindByID(ctx, id) // ErrNotFound for other users' notes
+//
+// A context without an owner fails every call with ErrNoOwner, so a missing
+// authentication step cannot silently expose every document.
+package scoped
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrNoOwner is returned for calls whose context carries no owner
+	ErrNoOwner = errors.New("scoped: no owner in context")
+	// ErrOwnerField is returned for writes that would set, change or remove
+	// the owner field, which would move a document out of its owner's scope
+	ErrOwnerField = errors.New("scoped: the owner field cannot be written")
+	// ErrPipelineUpdate is returned for update pipelines, since stages such
+	// as $replaceWith or $project can drop the owner field without naming it
+	ErrPipelineUpdate = errors.New("scoped: update pipelines are not supported")
+	// ErrStage is returned for aggregation stages that read or write other
+	// collections, or that must come before the owner $match
+	ErrStage = errors.New("scoped: aggregation stage not allowed")
+)
+
+// DefaultField is the field holding the owner of each document
+const DefaultField = "owner_id"
+
+// deniedStages would escape the owner $match that starts every pipeline
+var deniedStages = map[string]bool{
+	"$lookup": true, "$graphLookup": true, "$unionWith": true,
+	"$out": true, "$merge": true,
+	"$documents": true, "$changeStream": true, "$collStats": true, "$indexStats": true,
+	"$currentOp": true, "$listSessions": true, "$listLocalSessions": true,
+	"$geoNear": true, "$search": true, "$searchMeta": true, "$vectorSearch": true,
+}
+
+type ownerKey struct{}
+
+// WithOwner returns a context whose repository calls are scoped to owner
+func WithOwner(ctx context.Context, owner interface{}) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// Owner returns the owner stored by WithOwner
+func Owner(ctx context.Context) (interface{}, bool) {
+	owner := ctx.Value(ownerKey{})
+	return owner, owner != nil
+}
+
+// Middleware stores the owner that ownerOf reads from the request's claims,
+// such as a verified session or API key, and rejects requests without one
+func Middleware(ownerOf func(r *http.Request) (interface{}, bool), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := ownerOf(r)
+		if !ok || owner == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithOwner(r.Context(), owner)))
+	})
+}
+
+// Repository reads and writes the documents of type T in one collection
+// that belong to the owner in the context. The collection is unexported so
+// that no caller can reach around the scope to query it directly.
+type Repository[T any] struct {
+	collection *mongo.Collection
+	// Field holds the owner of each document and should be indexed
+	Field string
+}
+
+var _ repository.Store[struct{}] = (*Repository[struct{}])(nil)
+
+// New returns a repository scoped by DefaultField
+func New[T any](collection *mongo.Collection) *Repository[T] {
+	return &Repository[T]{collection: collection, Field: DefaultField}
+}
+
+// Name returns the name of the repository's collection
+func (r *Repository[T]) Name() string {
+	return r.collection.Name()
+}
+
+func (r *Repository[T]) owner(ctx context.Context) (bson.RawValue, error) {
+	owner, ok := Owner(ctx)
+	if !ok {
+		return bson.RawValue{}, ErrNoOwner
+	}
+	t, data, err := bson.MarshalValue(owner)
+	if err != nil {
+		return bson.RawValue{}, fmt.Errorf("scoped: owner: %w", err)
+	}
+	return bson.RawValue{Type: t, Value: data}, nil
+}
+
+// filter adds the owner condition to filter. $and keeps the condition
+// intact whatever filter contains, including its own owner field or $or.
+func (r *Repository[T]) filter(ctx context.Context, filter interface{}) (bson.D, error) {
+	owner, err := r.owner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := bson.D{{r.Field, owner}}
+	if filter == nil {
+		return scope, nil
+	}
+	return bson.D{{"$and", bson.A{filter, scope}}}, nil
+}
+
+// document converts document to bson.D with the owner field set, refusing
+// a document that already names another owner
+func (r *Repository[T]) document(ctx context.Context, document T) (bson.D, error) {
+	owner, err := r.owner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := bson.Raw(data).LookupErr(r.Field); err == nil && !existing.Equal(owner) {
+		return nil, ErrOwnerField
+	}
+	var d bson.D
+	if err = bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	for i := range d {
+		if d[i].Key == r.Field {
+			d[i].Value = owner
+			return d, nil
+		}
+	}
+	return append(d, bson.E{Key: r.Field, Value: owner}), nil
+}
+
+// names reports whether path is the owner field or inside it
+func (r *Repository[T]) names(path string) bool {
+	path = strings.TrimPrefix(path, "$")
+	return path == r.Field || strings.HasPrefix(path, r.Field+".")
+}
+
+// walk calls visit with every key and string value in value, at any depth
+func walk(value bson.RawValue, visit func(s string) error) error {
+	switch value.Type {
+	case bsontype.EmbeddedDocument, bsontype.Array:
+		elements, err := bson.Raw(value.Value).Elements()
+		if err != nil {
+			return err
+		}
+		for _, element := range elements {
+			if value.Type == bsontype.EmbeddedDocument {
+				if err = visit(element.Key()); err != nil {
+					return err
+				}
+			}
+			if err = walk(element.Value(), visit); err != nil {
+				return err
+			}
+		}
+	case bsontype.String:
+		return visit(value.StringValue())
+	}
+	return nil
+}
+
+// checkUpdate refuses update pipelines and updates naming the owner field
+// anywhere, as a target ($set, $unset) or a source ($rename's new name)
+func (r *Repository[T]) checkUpdate(update interface{}) error {
+	t, data, err := bson.MarshalValue(update)
+	if err != nil {
+		return err
+	}
+	if t == bsontype.Array {
+		return ErrPipelineUpdate
+	}
+	return walk(bson.RawValue{Type: t, Value: data}, func(s string) error {
+		if r.names(s) {
+			return ErrOwnerField
+		}
+		return nil
+	})
+}
+
+// pipeline returns pipeline behind a $match on the owner, refusing stages
+// that would read or write outside it, at any depth such as inside $facet
+func (r *Repository[T]) pipeline(ctx context.Context, pipeline interface{}) (bson.A, error) {
+	match, err := r.filter(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	t, data, err := bson.MarshalValue(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if t != bsontype.Array {
+		return nil, fmt.Errorf("scoped: pipeline must be an array, not %s", t)
+	}
+	value := bson.RawValue{Type: t, Value: data}
+	err = walk(value, func(s string) error {
+		if deniedStages[s] {
+			return fmt.Errorf("%w: %s", ErrStage, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stages, err := value.Array().Values()
+	if err != nil {
+		return nil, err
+	}
+	scoped := bson.A{bson.D{{"$match", match}}}
+	for _, stage := range stages {
+		scoped = append(scoped, stage)
+	}
+	return scoped, nil
+}
+
+// Insert stores document with the owner field set to the context's owner
+func (r *Repository[T]) Insert(ctx context.Context, document T) (interface{}, error) {
+	d, err := r.document(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.collection.InsertOne(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedID, nil
+}
+
+// FindByID returns the owner's document with the given _id. Other owners'
+// documents are reported as repository.ErrNotFound, not as forbidden, so
+// callers cannot probe which ids exist.
+func (r *Repository[T]) FindByID(ctx context.Context, id interface{}) (T, error) {
+	var document T
+	filter, err := r.filter(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return document, err
+	}
+	err = r.collection.FindOne(ctx, filter).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return document, repository.ErrNotFound
+	}
+	return document, err
+}
+
+// Find returns the owner's documents matching filter, or an empty slice
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	scoped, err := r.filter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := r.collection.Find(ctx, scoped, opts...)
+	if err != nil {
+		return nil, err
+	}
+	documents := []T{}
+	if err = cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// UpdateByID applies update, an update document that leaves the owner
+// field alone, to the owner's document with the given _id
+func (r *Repository[T]) UpdateByID(ctx context.Context, id interface{}, update interface{}) error {
+	filter, err := r.filter(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	if err = r.checkUpdate(update); err != nil {
+		return err
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByID removes the owner's document with the given _id
+func (r *Repository[T]) DeleteByID(ctx context.Context, id interface{}) error {
+	filter, err := r.filter(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Aggregate runs pipeline over the owner's documents only
+func (r *Repository[T]) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]T, error) {
+	scoped, err := r.pipeline(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return repository.AggregateAs[T](ctx, r.collection, scoped, opts...)
+}
@@ -0,0 +1,247 @@
+package scoped
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+// Note is a document type with an owner field
+type Note struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Owner string             `bson:"owner_id,omitempty"`
+	Text  string             `bson:"text"`
+}
+
+// offlineRepository returns a repository whose client never reaches a
+// server, so any call that gets past the scoping checks fails quickly with
+// a server selection error instead of one of the scoped errors
+func offlineRepository(t *testing.T) *Repository[Note] {
+	t.Helper()
+	opts := options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(100 * time.Millisecond)
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	return New[Note](client.Database("quickstart_scoped").Collection("notes"))
+}
+
+// TestEveryMethodRequiresOwner calls every method of repository.Store
+// through reflection, so a method added later is covered automatically
+func TestEveryMethodRequiresOwner(t *testing.T) {
+	notes := offlineRepository(t)
+	store := reflect.TypeOf((*repository.Store[Note])(nil)).Elem()
+	value := reflect.ValueOf(notes)
+	for i := 0; i < store.NumMethod(); i++ {
+		method := store.Method(i)
+		t.Run(method.Name, func(t *testing.T) {
+			call := value.MethodByName(method.Name)
+			args := []reflect.Value{reflect.ValueOf(context.Background())}
+			for j := 1; j < call.Type().NumIn(); j++ {
+				if call.Type().IsVariadic() && j == call.Type().NumIn()-1 {
+					continue
+				}
+				args = append(args, reflect.Zero(call.Type().In(j)))
+			}
+			results := call.Call(args)
+			err, _ := results[len(results)-1].Interface().(error)
+			if !errors.Is(err, ErrNoOwner) {
+				t.Errorf("%s without an owner returned %v, want ErrNoOwner", method.Name, err)
+			}
+		})
+	}
+}
+
+func TestFilterKeepsOwner(t *testing.T) {
+	notes := offlineRepository(t)
+	ctx := WithOwner(context.Background(), "alice")
+	filters := []interface{}{
+		nil,
+		bson.D{},
+		bson.D{{"owner_id", "bob"}},
+		bson.D{{"$or", bson.A{bson.D{{"owner_id", "bob"}}, bson.D{{"text", "x"}}}}},
+		bson.M{"owner_id": bson.M{"$exists": true}},
+	}
+	for _, filter := range filters {
+		scoped, err := notes.filter(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scope := bson.D{{"owner_id", mustRaw(t, "alice")}}
+		var want bson.D
+		if filter == nil {
+			want = scope
+		} else {
+			want = bson.D{{"$and", bson.A{filter, scope}}}
+		}
+		if !reflect.DeepEqual(scoped, want) {
+			t.Errorf("filter(%v) = %v, want %v", filter, scoped, want)
+		}
+	}
+}
+
+func TestDocumentSetsOwner(t *testing.T) {
+	notes := offlineRepository(t)
+	ctx := WithOwner(context.Background(), "alice")
+	tests := []struct {
+		note Note
+		err  error
+	}{
+		{Note{Text: "no owner"}, nil},
+		{Note{Owner: "alice", Text: "same owner"}, nil},
+		{Note{Owner: "bob", Text: "other owner"}, ErrOwnerField},
+	}
+	for _, test := range tests {
+		d, err := notes.document(ctx, test.note)
+		if !errors.Is(err, test.err) {
+			t.Errorf("document(%+v) error = %v, want %v", test.note, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		owner, ok := d.Map()["owner_id"].(bson.RawValue)
+		if !ok || owner.StringValue() != "alice" {
+			t.Errorf("document(%+v) = %v, want owner_id alice", test.note, d)
+		}
+	}
+}
+
+func TestUpdatesCannotTouchOwner(t *testing.T) {
+	notes := offlineRepository(t)
+	tests := []struct {
+		name   string
+		update interface{}
+		err    error
+	}{
+		{"set other field", bson.D{{"$set", bson.D{{"text", "x"}}}}, nil},
+		{"set owner", bson.D{{"$set", bson.D{{"owner_id", "bob"}}}}, ErrOwnerField},
+		{"set inside owner", bson.M{"$set": bson.M{"owner_id.name": "bob"}}, ErrOwnerField},
+		{"unset owner", bson.D{{"$unset", bson.D{{"owner_id", ""}}}}, ErrOwnerField},
+		{"rename owner away", bson.D{{"$rename", bson.D{{"owner_id", "previous_owner"}}}}, ErrOwnerField},
+		{"rename onto owner", bson.D{{"$rename", bson.D{{"text", "owner_id"}}}}, ErrOwnerField},
+		{"current date", bson.D{{"$currentDate", bson.D{{"owner_id", true}}}}, ErrOwnerField},
+		{"pipeline", mongo.Pipeline{{{"$replaceWith", bson.D{{"text", "x"}}}}}, ErrPipelineUpdate},
+	}
+	for _, test := range tests {
+		if err := notes.checkUpdate(test.update); !errors.Is(err, test.err) {
+			t.Errorf("%s: checkUpdate = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestPipelinesStayInScope(t *testing.T) {
+	notes := offlineRepository(t)
+	ctx := WithOwner(context.Background(), "alice")
+	denied := []mongo.Pipeline{
+		{{{"$lookup", bson.D{{"from", "notes"}, {"pipeline", bson.A{}}, {"as", "all"}}}}},
+		{{{"$unionWith", "notes"}}},
+		{{{"$out", "stolen"}}},
+		{{{"$merge", bson.D{{"into", "stolen"}}}}},
+		{{{"$facet", bson.D{{"all", bson.A{bson.D{{"$unionWith", "notes"}}}}}}}},
+	}
+	for _, pipeline := range denied {
+		if _, err := notes.pipeline(ctx, pipeline); !errors.Is(err, ErrStage) {
+			t.Errorf("pipeline(%v) error = %v, want ErrStage", pipeline, err)
+		}
+	}
+
+	scoped, err := notes.pipeline(ctx, mongo.Pipeline{{{"$match", bson.D{{"owner_id", "bob"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := bson.D{{"$match", bson.D{{"owner_id", mustRaw(t, "alice")}}}}
+	if len(scoped) != 2 || !reflect.DeepEqual(scoped[0], first) {
+		t.Errorf("pipeline = %v, want it to start with %v", scoped, first)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(func(r *http.Request) (interface{}, bool) {
+		user := r.Header.Get("X-User")
+		return user, user != ""
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner, _ := Owner(r.Context())
+		w.Write([]byte(owner.(string)))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("without claims: status %d, want 401", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("X-User", "alice")
+	handler.ServeHTTP(recorder, request)
+	if recorder.Body.String() != "alice" {
+		t.Errorf("with claims: body %q, want the owner alice", recorder.Body.String())
+	}
+}
+
+// TestIsolation checks against a real server that one owner can neither
+// read nor write another's documents
+func TestIsolation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	database := mongotest.Database(t)
+	notes := New[Note](database.Collection("notes"))
+	alice, bob := WithOwner(ctx, "alice"), WithOwner(ctx, "bob")
+
+	id, err := notes.Insert(alice, Note{Text: "alice's note"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = notes.Insert(bob, Note{Text: "bob's note"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = notes.FindByID(bob, id); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("bob FindByID alice's note: %v, want ErrNotFound", err)
+	}
+	if err = notes.UpdateByID(bob, id, bson.D{{"$set", bson.D{{"text", "changed"}}}}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("bob UpdateByID alice's note: %v, want ErrNotFound", err)
+	}
+	if err = notes.DeleteByID(bob, id); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("bob DeleteByID alice's note: %v, want ErrNotFound", err)
+	}
+	found, err := notes.Find(bob, bson.D{{"owner_id", "alice"}})
+	if err != nil || len(found) != 0 {
+		t.Errorf("bob Find alice's notes = %v, %v, want none", found, err)
+	}
+	aggregated, err := notes.Aggregate(bob, mongo.Pipeline{})
+	if err != nil || len(aggregated) != 1 || aggregated[0].Owner != "bob" {
+		t.Errorf("bob Aggregate = %v, %v, want only bob's note", aggregated, err)
+	}
+
+	note, err := notes.FindByID(alice, id)
+	if err != nil || note.Text != "alice's note" {
+		t.Errorf("alice FindByID = %+v, %v, want her note", note, err)
+	}
+}
+
+func mustRaw(t *testing.T, v interface{}) bson.RawValue {
+	t.Helper()
+	typ, data, err := bson.MarshalValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.RawValue{Type: typ, Value: data}
+}
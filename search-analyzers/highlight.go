@@ -0,0 +1,95 @@
+package main
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// Highlight is one entry of the searchHighlights metadata: a passage of
+// Path cut into texts that matched the query ("hit") or surround a match
+// ("text")
+type Highlight struct {
+	Path  string  `bson:"path"`
+	Score float64 `bson:"score"`
+	Texts []struct {
+		Value string `bson:"value"`
+		Type  string `bson:"type"`
+	} `bson:"texts"`
+}
+
+// Snippet is a highlighted passage as one string, with the byte offsets of
+// each matched word in it
+type Snippet struct {
+	Text    string
+	Matches []Match
+}
+
+// Match is the half-open byte range [Start, End) of a match in a snippet
+type Match struct {
+	Start int
+	End   int
+}
+
+// Snippet joins the texts of h, recording where the hits are. Hits next to
+// each other, such as the words of a phrase split into several texts, make
+// one match.
+func (h Highlight) Snippet() Snippet {
+	var b strings.Builder
+	var matches []Match
+	for _, text := range h.Texts {
+		start := b.Len()
+		b.WriteString(text.Value)
+		if text.Type == "hit" {
+			matches = append(matches, Match{Start: start, End: b.Len()})
+		}
+	}
+	return Snippet{Text: b.String(), Matches: merge(matches)}
+}
+
+// merge sorts matches and joins those that overlap or touch, dropping empty
+// ones, so no <mark> is nested in or directly follows another
+func merge(matches []Match) []Match {
+	sorted := make([]Match, 0, len(matches))
+	for _, match := range matches {
+		if match.Start < match.End {
+			sorted = append(sorted, match)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	var merged []Match
+	for _, match := range sorted {
+		if last := len(merged) - 1; last >= 0 && match.Start <= merged[last].End {
+			merged[last].End = max(merged[last].End, match.End)
+			continue
+		}
+		merged = append(merged, match)
+	}
+	return merged
+}
+
+// HTML returns the snippet escaped for HTML with every match wrapped in
+// <mark>, ready to be rendered in a search results page. Matches may be in
+// any order and overlap; those outside the text are cut to it.
+func (s Snippet) HTML() string {
+	var b strings.Builder
+	last := 0
+	for _, match := range merge(s.clamped()) {
+		b.WriteString(html.EscapeString(s.Text[last:match.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(s.Text[match.Start:match.End]))
+		b.WriteString("</mark>")
+		last = match.End
+	}
+	b.WriteString(html.EscapeString(s.Text[last:]))
+	return b.String()
+}
+
+// clamped returns the matches cut to the bounds of the text
+func (s Snippet) clamped() []Match {
+	clamped := make([]Match, 0, len(s.Matches))
+	for _, match := range s.Matches {
+		clamped = append(clamped, Match{Start: min(max(match.Start, 0), len(s.Text)), End: min(max(match.End, 0), len(s.Text))})
+	}
+	return clamped
+}
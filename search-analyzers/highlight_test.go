@@ -0,0 +1,110 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func highlight(texts ...string) Highlight {
+	var h Highlight
+	for i := 0; i+1 < len(texts); i += 2 {
+		h.Texts = append(h.Texts, struct {
+			Value string `bson:"value"`
+			Type  string `bson:"type"`
+		}{texts[i], texts[i+1]})
+	}
+	return h
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name      string
+		highlight Highlight
+		want      Snippet
+	}{
+		{"no hits", highlight("Nous parlons", "text"), Snippet{Text: "Nous parlons"}},
+		{"one hit", highlight("avec des ", "text", "développeurs", "hit", " du web", "text"),
+			Snippet{Text: "avec des développeurs du web", Matches: []Match{{9, 22}}}},
+		{"adjacent hits merge", highlight("bases", "hit", " ", "hit", "de", "hit", " datos", "text"),
+			Snippet{Text: "bases de datos", Matches: []Match{{0, 8}}}},
+		{"separate hits", highlight("Go", "hit", " y ", "text", "Go", "hit"),
+			Snippet{Text: "Go y Go", Matches: []Match{{0, 2}, {5, 7}}}},
+		{"empty hit", highlight("a", "text", "", "hit", "b", "text"), Snippet{Text: "ab"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.highlight.Snippet(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Snippet = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet Snippet
+		want    string
+	}{
+		{"no matches", Snippet{Text: "plain"}, "plain"},
+		{"escaped around and inside marks", Snippet{Text: `<b>Go</b> & "Rust"`, Matches: []Match{{3, 5}, {12, 18}}},
+			`&lt;b&gt;<mark>Go</mark>&lt;/b&gt; &amp; <mark>&#34;Rust&#34;</mark>`},
+		{"whole text", Snippet{Text: "Go", Matches: []Match{{0, 2}}}, "<mark>Go</mark>"},
+		{"unsorted", Snippet{Text: "a b c", Matches: []Match{{4, 5}, {0, 1}}}, "<mark>a</mark> b <mark>c</mark>"},
+		{"overlapping", Snippet{Text: "abcdef", Matches: []Match{{1, 4}, {2, 5}}}, "a<mark>bcde</mark>f"},
+		{"touching", Snippet{Text: "abcdef", Matches: []Match{{0, 2}, {2, 3}}}, "<mark>abc</mark>def"},
+		{"nested", Snippet{Text: "abcdef", Matches: []Match{{0, 6}, {2, 3}}}, "<mark>abcdef</mark>"},
+		{"outside the text", Snippet{Text: "abc", Matches: []Match{{2, 10}, {-1, 0}}}, "ab<mark>c</mark>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.snippet.HTML(); got != test.want {
+				t.Errorf("HTML = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	var b strings.Builder
+	err := pageTemplate.Execute(&b, pageView{
+		Query:     `<script>`,
+		Language:  "german",
+		Languages: []string{"french", "german"},
+		Analyzer:  "german",
+		Results: []resultView{{
+			Title:    "Eine Datenbank für alles",
+			Passages: []template.HTML{template.HTML(Snippet{Text: "Eine <Datenbank>", Matches: []Match{{5, 16}}}.HTML())},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`<p class="passage">Eine <mark>&lt;Datenbank&gt;</mark></p>`,
+		`value="&lt;script&gt;"`,
+		`<option selected>german</option>`,
+		`1 episode(s) with german`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page does not contain %s:\n%s", want, out)
+		}
+	}
+
+	// without a query the page is the form alone, and needs no database
+	recorder := httptest.NewRecorder()
+	searchPage(nil)(recorder, httptest.NewRequest("GET", "/", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "<option selected>french</option>") {
+		t.Errorf("empty search = %d\n%s", recorder.Code, recorder.Body)
+	}
+	recorder = httptest.NewRecorder()
+	searchPage(nil)(recorder, httptest.NewRequest("GET", "/?q=go&language=klingon", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown language = %d, want 400", recorder.Code)
+	}
+}
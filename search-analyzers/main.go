@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexName is the Atlas Search index over multilingual_episodes
+const indexName = "multilingual_search"
+
+// Episode represents the schema for the "multilingual_episodes" collection
+type Episode struct {
+	Title       string `bson:"title"`
+	Language    string `bson:"language"`
+	Description string `bson:"description"`
+}
+
+// Result is an episode found by search, with the passages of its
+// description that matched
+type Result struct {
+	Title      string      `bson:"title"`
+	Highlights []Highlight `bson:"highlights"`
+}
+
+var episodes = []interface{}{
+	Episode{"Les développeurs et le web", "french", "Nous parlons avec des développeurs des applications web progressives."},
+	Episode{"Un développeur à Paris", "french", "Le parcours d'un développeur qui a appris la programmation tout seul."},
+	Episode{"Die Entwickler von morgen", "german", "Wir sprechen über Datenbanken und die Zukunft der Entwicklung."},
+	Episode{"Eine Datenbank für alles", "german", "Warum eine Datenbank selten für alle Anwendungen reicht."},
+	Episode{"Los programadores y las bases de datos", "spanish", "Hablamos de bases de datos con programadores de Madrid."},
+	Episode{"Programando en Go", "spanish", "Una programadora nos cuenta cómo programa servicios en Go."},
+}
+
+// analyzers are the alternate analyzers indexed next to lucene.standard,
+// named the way a query path's "multi" refers to them
+var analyzers = []struct{ name, analyzer string }{
+	{"french", "lucene.french"},
+	{"german", "lucene.german"},
+	{"spanish", "lucene.spanish"},
+}
+
+// indexDefinition indexes description once per analyzer. lucene.standard
+// only splits words and lowercases them; the language analyzers also drop
+// the language's stop words and reduce words to their stem, so "développeurs"
+// and "développeur" become the same term.
+func indexDefinition() bson.D {
+	multi := bson.D{}
+	for _, a := range analyzers {
+		multi = append(multi, bson.E{Key: a.name, Value: bson.D{{"type", "string"}, {"analyzer", a.analyzer}}})
+	}
+	return bson.D{{"mappings", bson.D{
+		{"dynamic", false},
+		{"fields", bson.D{
+			{"description", bson.D{
+				{"type", "string"},
+				{"analyzer", "lucene.standard"},
+				{"multi", multi},
+			}},
+			{"language", bson.D{{"type", "token"}}},
+		}},
+	}}}
+}
+
+// ensureIndex creates the search index unless it exists, then waits until
+// Atlas reports it queryable
+func ensureIndex(ctx context.Context, collection *mongo.Collection) error {
+	view := collection.SearchIndexes()
+	cursor, err := view.List(ctx, options.SearchIndexes().SetName(indexName))
+	if err != nil {
+		return err
+	}
+	var existing []bson.M
+	if err = cursor.All(ctx, &existing); err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		_, err = view.CreateOne(ctx, mongo.SearchIndexModel{
+			Definition: indexDefinition(),
+			Options:    options.SearchIndexes().SetName(indexName),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created search index", indexName)
+	}
+	for {
+		cursor, err = view.List(ctx, options.SearchIndexes().SetName(indexName))
+		if err != nil {
+			return err
+		}
+		var indexes []struct {
+			Queryable bool `bson:"queryable"`
+		}
+		if err = cursor.All(ctx, &indexes); err != nil {
+			return err
+		}
+		if len(indexes) > 0 && indexes[0].Queryable {
+			return nil
+		}
+		fmt.Println("Waiting for the search index to become queryable...")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// search runs query against description analyzed by analyzer, which is
+// "standard" or the name of one of analyzers, limited to episodes in language.
+// Highlighting uses the same path, so it marks the words that analyzer
+// matched, stems included.
+func search(ctx context.Context, collection *mongo.Collection, query, language, analyzer string) ([]Result, error) {
+	var path interface{} = "description"
+	if analyzer != "standard" {
+		path = bson.D{{"value", "description"}, {"multi", analyzer}}
+	}
+	searchStage := bson.D{{"$search", bson.D{
+		{"index", indexName},
+		{"compound", bson.D{
+			{"must", bson.A{bson.D{{"text", bson.D{{"query", query}, {"path", path}}}}}},
+			{"filter", bson.A{bson.D{{"equals", bson.D{{"path", "language"}, {"value", language}}}}}},
+		}},
+		{"highlight", bson.D{{"path", path}}},
+	}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"title", 1},
+		{"highlights", bson.D{{"$meta", "searchHighlights"}}},
+	}}}
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{searchStage, projectStage})
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+var (
+	seed = flag.Bool("seed", false, "replace the sample episodes before searching")
+	addr = flag.String("addr", "", "serve a search page with highlighted matches on this address, such as :8080, instead of printing the comparison")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	// building the search index can take minutes; serving is not limited
+	setupCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	client, err := db.Connect(setupCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	collection := client.Database("quickstart").Collection("multilingual_episodes")
+
+	// Insert Descriptions In Several Languages
+	if *seed {
+		if _, err = collection.DeleteMany(setupCtx, bson.D{}); err != nil {
+			return err
+		}
+		if _, err = collection.InsertMany(setupCtx, episodes); err != nil {
+			return err
+		}
+	}
+
+	// Index Each Description With The Standard And A Language Analyzer
+	if err = ensureIndex(setupCtx, collection); err != nil {
+		return err
+	}
+
+	// Render The Highlights In A Search Page
+	if *addr != "" {
+		log.Printf("open http://localhost%s and search, for example, développeurs in french", *addr)
+		return shutdown.Serve(ctx, &http.Server{Addr: *addr, Handler: searchPage(collection)})
+	}
+
+	// Compare Matches Per Analyzer
+	// the plural or conjugated query words only match the other forms once
+	// stemmed, and the stop words only match under lucene.standard
+	queries := []struct {
+		language, query, shows string
+	}{
+		{"french", "développeurs", "stemming"},
+		{"french", "le", "stop words"},
+		{"german", "Datenbanken", "stemming"},
+		{"german", "und", "stop words"},
+		{"spanish", "programadores", "stemming"},
+		{"spanish", "de", "stop words"},
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LANGUAGE\tQUERY\tSHOWS\tANALYZER\tMATCHES")
+	var stemmed [][]Result
+	for _, q := range queries {
+		for _, analyzer := range []string{"standard", q.language} {
+			results, err := search(setupCtx, collection, q.query, q.language, analyzer)
+			if err != nil {
+				return err
+			}
+			titles := make([]string, len(results))
+			for i, result := range results {
+				titles[i] = result.Title
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d %s\n", q.language, q.query, q.shows, analyzer, len(titles), strings.Join(titles, "; "))
+			if q.shows == "stemming" && analyzer == q.language {
+				stemmed = append(stemmed, results)
+			}
+		}
+	}
+	w.Flush()
+
+	// Highlight The Matched Words
+	// each highlight is the passage around the matches, split into texts
+	// that are hits or not; the offsets turn them into <mark> spans
+	fmt.Println()
+	for _, results := range stemmed {
+		for _, result := range results {
+			for _, highlight := range result.Highlights {
+				fmt.Printf("%s: %s\n", result.Title, highlight.Snippet().HTML())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Episode search</title>
+  <style>
+    body { font-family: sans-serif; max-width: 48em; margin: 2em auto; }
+    mark { background: #ffe066; padding: 0 .1em; }
+    .passage { color: #444; }
+  </style>
+</head>
+<body>
+  <form method="get" action="/">
+    <input name="q" value="{{.Query}}" placeholder="développeurs" autofocus>
+    <select name="language">
+      {{range .Languages}}<option{{if eq . $.Language}} selected{{end}}>{{.}}</option>{{end}}
+    </select>
+    <label><input type="checkbox" name="stem" value="1"{{if .Stem}} checked{{end}}> language analyzer</label>
+    <button>Search</button>
+  </form>
+  {{if .Query}}<p>{{len .Results}} episode(s) with {{.Analyzer}}</p>{{end}}
+  {{range .Results}}
+  <h2>{{.Title}}</h2>
+  {{range .Passages}}<p class="passage">{{.}}</p>{{end}}
+  {{end}}
+</body>
+</html>
+`
+
+var pageTemplate = template.Must(template.New("page").Parse(page))
+
+// resultView is a Result with its highlights rendered as <mark> spans
+type resultView struct {
+	Title    string
+	Passages []template.HTML
+}
+
+type pageView struct {
+	Query     string
+	Language  string
+	Languages []string
+	Stem      bool
+	Analyzer  string
+	Results   []resultView
+}
+
+// searchPage serves a search form over collection and the matching episodes
+// with the words the analyzer matched highlighted
+func searchPage(collection *mongo.Collection) http.HandlerFunc {
+	languages := make([]string, len(analyzers))
+	for i, a := range analyzers {
+		languages[i] = a.name
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		view := pageView{
+			Query:     r.FormValue("q"),
+			Language:  r.FormValue("language"),
+			Languages: languages,
+			Stem:      r.FormValue("stem") != "",
+			Analyzer:  "standard",
+		}
+		if view.Language == "" {
+			view.Language = languages[0]
+		}
+		known := false
+		for _, language := range languages {
+			known = known || language == view.Language
+		}
+		if !known {
+			http.Error(w, "unknown language "+view.Language, http.StatusBadRequest)
+			return
+		}
+		if view.Stem {
+			view.Analyzer = view.Language
+		}
+		if view.Query != "" {
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			results, err := search(ctx, collection, view.Query, view.Language, view.Analyzer)
+			if err != nil {
+				log.Printf("search %q: %v", view.Query, err)
+				http.Error(w, "search failed", http.StatusInternalServerError)
+				return
+			}
+			for _, result := range results {
+				rendered := resultView{Title: result.Title}
+				for _, highlight := range result.Highlights {
+					// HTML escapes the passage itself, only the <mark>s are markup
+					rendered.Passages = append(rendered.Passages, template.HTML(highlight.Snippet().HTML()))
+				}
+				view.Results = append(view.Results, rendered)
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := pageTemplate.Execute(w, view); err != nil {
+			log.Printf("render search page: %v", err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	indexName   = "episodes_search"
+	mappingName = "podcast_synonyms"
+	// sourceName is the collection Atlas Search reads the synonyms from
+	sourceName = "search_synonyms"
+)
+
+// Synonym represents the schema for the "search_synonyms" collection in the
+// format Atlas Search expects. Equivalent mappings make every word match the
+// others; explicit mappings make the Input words also match Synonyms, but
+// not the other way around.
+type Synonym struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	MappingType string             `bson:"mappingType"`
+	Input       []string           `bson:"input,omitempty"`
+	Synonyms    []string           `bson:"synonyms"`
+}
+
+// Validate rejects documents that would put the whole synonym mapping, and
+// with it every query using it, into a failed state
+func (s Synonym) Validate() error {
+	switch s.MappingType {
+	case "equivalent":
+		if len(s.Synonyms) < 2 || len(s.Input) > 0 {
+			return errors.New("an equivalent mapping needs two or more synonyms and no input")
+		}
+	case "explicit":
+		if len(s.Input) == 0 || len(s.Synonyms) == 0 {
+			return errors.New("an explicit mapping needs input and synonyms")
+		}
+	default:
+		return fmt.Errorf("unknown mappingType %q", s.MappingType)
+	}
+	for _, word := range append(append([]string{}, s.Input...), s.Synonyms...) {
+		if strings.TrimSpace(word) == "" {
+			return errors.New("synonyms must not be empty")
+		}
+	}
+	return nil
+}
+
+// indexDefinition indexes titles and descriptions with the English analyzer
+// and attaches the synonym mapping, which must use the same analyzer
+func indexDefinition() bson.D {
+	return bson.D{
+		{"mappings", bson.D{
+			{"dynamic", false},
+			{"fields", bson.D{
+				{"title", bson.D{{"type", "string"}, {"analyzer", "lucene.english"}}},
+				{"description", bson.D{{"type", "string"}, {"analyzer", "lucene.english"}}},
+			}},
+		}},
+		{"synonyms", bson.A{bson.D{
+			{"name", mappingName},
+			{"analyzer", "lucene.english"},
+			{"source", bson.D{{"collection", sourceName}}},
+		}}},
+	}
+}
+
+// indexStatus is the part of $listSearchIndexes output describing readiness
+type indexStatus struct {
+	Status                     string                   `bson:"status"`
+	Queryable                  bool                     `bson:"queryable"`
+	SynonymMappingStatus       string                   `bson:"synonymMappingStatus"`
+	SynonymMappingStatusDetail []map[string]interface{} `bson:"synonymMappingStatusDetail"`
+}
+
+// ensureIndex creates the search index when it is missing
+func ensureIndex(ctx context.Context, episodes *mongo.Collection) error {
+	status, err := readStatus(ctx, episodes)
+	if err != nil || status != nil {
+		return err
+	}
+	_, err = episodes.SearchIndexes().CreateOne(ctx, mongo.SearchIndexModel{
+		Definition: indexDefinition(),
+		Options:    options.SearchIndexes().SetName(indexName),
+	})
+	if err == nil {
+		fmt.Println("Created search index", indexName)
+	}
+	return err
+}
+
+func readStatus(ctx context.Context, episodes *mongo.Collection) (*indexStatus, error) {
+	cursor, err := episodes.SearchIndexes().List(ctx, options.SearchIndexes().SetName(indexName))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []indexStatus
+	if err = cursor.All(ctx, &indexes); err != nil || len(indexes) == 0 {
+		return nil, err
+	}
+	return &indexes[0], nil
+}
+
+// waitReady polls until the index is queryable and the synonym mapping has
+// picked up the source collection. Atlas rebuilds the mapping by itself
+// when the source changes; a FAILED status means a document is invalid.
+func waitReady(ctx context.Context, episodes *mongo.Collection) error {
+	for {
+		status, err := readStatus(ctx, episodes)
+		if err != nil {
+			return err
+		}
+		if status == nil {
+			return fmt.Errorf("search index %s does not exist", indexName)
+		}
+		if status.SynonymMappingStatus == "FAILED" {
+			return fmt.Errorf("synonym mapping failed: %v", status.SynonymMappingStatusDetail)
+		}
+		if status.Queryable && status.SynonymMappingStatus == "READY" {
+			return nil
+		}
+		fmt.Printf("Index %s, synonyms %s, waiting...\n", status.Status, status.SynonymMappingStatus)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// search returns the titles matching query, expanded with the synonyms
+func search(ctx context.Context, episodes *mongo.Collection, query string) ([]string, error) {
+	searchStage := bson.D{{"$search", bson.D{
+		{"index", indexName},
+		{"text", bson.D{
+			{"query", query},
+			{"path", bson.A{"title", "description"}},
+			{"synonyms", mappingName},
+		}},
+	}}}
+	limitStage := bson.D{{"$limit", 10}}
+	projectStage := bson.D{{"$project", bson.D{{"_id", 0}, {"title", 1}}}}
+	cursor, err := episodes.Aggregate(ctx, mongo.Pipeline{searchStage, limitStage, projectStage})
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Title string `bson:"title"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	titles := make([]string, len(results))
+	for i, result := range results {
+		titles[i] = result.Title
+	}
+	return titles, nil
+}
+
+func splitWords(list string) []string {
+	var words []string
+	for _, word := range strings.Split(list, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+var (
+	words = flag.String("words", "", "comma separated synonyms")
+	input = flag.String("input", "", "comma separated input words, making the mapping explicit")
+	query = flag.String("query", "web apps", "query run by demo")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] add|remove|list|status|demo\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	episodesCollection := database.Collection("episodes")
+	synonymsCollection := database.Collection(sourceName)
+
+	synonym := Synonym{MappingType: "equivalent", Synonyms: splitWords(*words)}
+	if *input != "" {
+		synonym.MappingType, synonym.Input = "explicit", splitWords(*input)
+	}
+
+	switch flag.Arg(0) {
+	case "add":
+		// Add A Synonym Mapping
+		if err = synonym.Validate(); err != nil {
+			return err
+		}
+		if _, err = synonymsCollection.InsertOne(ctx, synonym); err != nil {
+			return err
+		}
+		fmt.Println("Added", synonym.MappingType, "mapping", synonym.Input, synonym.Synonyms)
+	case "remove":
+		// Remove Every Mapping Containing The Words
+		result, err := synonymsCollection.DeleteMany(ctx, bson.D{{"synonyms", bson.D{{"$all", synonym.Synonyms}}}})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d mapping(s)\n", result.DeletedCount)
+	case "list":
+		cursor, err := synonymsCollection.Find(ctx, bson.D{})
+		if err != nil {
+			return err
+		}
+		var synonyms []Synonym
+		if err = cursor.All(ctx, &synonyms); err != nil {
+			return err
+		}
+		for _, s := range synonyms {
+			fmt.Println(s.ID.Hex(), s.MappingType, s.Input, s.Synonyms)
+		}
+	case "status":
+		// Validate The Index And Synonym Mapping State
+		if err = ensureIndex(ctx, episodesCollection); err != nil {
+			return err
+		}
+		if err = waitReady(ctx, episodesCollection); err != nil {
+			return err
+		}
+		fmt.Println("Search index and synonyms are ready")
+	case "demo":
+		// Watch A Query's Results Change After Adding A Synonym
+		if err = ensureIndex(ctx, episodesCollection); err != nil {
+			return err
+		}
+		demo := Synonym{MappingType: "equivalent", Synonyms: []string{"web", "pwa", "progressive"}}
+		if _, err = synonymsCollection.DeleteMany(ctx, bson.D{{"synonyms", bson.D{{"$all", demo.Synonyms}}}}); err != nil {
+			return err
+		}
+		if err = waitReady(ctx, episodesCollection); err != nil {
+			return err
+		}
+		before, err := search(ctx, episodesCollection, *query)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%q without synonyms: %q\n", *query, before)
+
+		if _, err = synonymsCollection.InsertOne(ctx, demo); err != nil {
+			return err
+		}
+		// the mapping status only changes once Atlas notices the new document
+		time.Sleep(10 * time.Second)
+		if err = waitReady(ctx, episodesCollection); err != nil {
+			return err
+		}
+		after, err := search(ctx, episodesCollection, *query)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%q with %v as synonyms: %q\n", *query, demo.Synonyms, after)
+	default:
+		return shutdown.ErrUsage
+	}
+	return nil
+}
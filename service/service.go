@@ -0,0 +1,343 @@
+// Package service is a small service layer over the podcasts and episodes
+// collections that splits reads from writes. Writes always go to the write
+// client; reads go to the read client, which usually has a secondary read
+// preference and its own connection pool, unless the context asks for the
+// primary with ReadYourWrites.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mongodb-developer/golang-quickstart/cascade"
+	"github.com/mongodb-developer/golang-quickstart/diff"
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/deadline"
+	"github.com/mongodb-developer/golang-quickstart/slug"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ErrNotFound is returned when a podcast or episode does not exist
+var ErrNotFound = errors.New("not found")
+
+// Config describes the two client handles
+type Config struct {
+	Database string
+	WriteURI string
+	// ReadURI defaults to WriteURI, so both clients reach the same
+	// deployment through separate pools
+	ReadURI        string
+	ReadPreference string
+	ReadPoolSize   uint64
+	WritePoolSize  uint64
+}
+
+// ConfigFromEnv takes the write URI from db.URI, that is the -uri flag or
+// ATLAS_URI, and optionally reads ATLAS_URI_READ and
+// QUICKSTART_READ_PREFERENCE, defaulting to secondaryPreferred reads
+func ConfigFromEnv() Config {
+	config := Config{
+		Database:       "quickstart",
+		WriteURI:       db.URI(),
+		ReadURI:        os.Getenv("ATLAS_URI_READ"),
+		ReadPreference: os.Getenv("QUICKSTART_READ_PREFERENCE"),
+	}
+	if config.ReadURI == "" {
+		config.ReadURI = config.WriteURI
+	}
+	if config.ReadPreference == "" {
+		config.ReadPreference = readpref.SecondaryPreferredMode.String()
+	}
+	return config
+}
+
+// ClientOptions validates the URIs with db.Validate and returns the options
+// for the read and the write client. Callers may set more options on them,
+// such as a monitor, before connecting.
+func (c Config) ClientOptions() (read, write *options.ClientOptions, err error) {
+	mode, err := readpref.ModeFromString(c.ReadPreference)
+	if err != nil {
+		return nil, nil, err
+	}
+	preference, err := readpref.New(mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	readURI := c.ReadURI
+	if readURI == "" {
+		readURI = c.WriteURI
+	}
+	if err = db.Validate(c.WriteURI); err != nil {
+		return nil, nil, fmt.Errorf("write URI: %w", err)
+	}
+	if err = db.Validate(readURI); err != nil {
+		return nil, nil, fmt.Errorf("read URI: %w", err)
+	}
+	read = options.Client().ApplyURI(readURI).SetReadPreference(preference).SetAppName("quickstart-reads")
+	write = options.Client().ApplyURI(c.WriteURI).SetReadPreference(readpref.Primary()).SetAppName("quickstart-writes")
+	if c.ReadPoolSize > 0 {
+		read.SetMaxPoolSize(c.ReadPoolSize)
+	}
+	if c.WritePoolSize > 0 {
+		write.SetMaxPoolSize(c.WritePoolSize)
+	}
+	return read, write, nil
+}
+
+// Connect connects both clients and returns a Service using them
+func Connect(ctx context.Context, config Config) (*Service, error) {
+	readOptions, writeOptions, err := config.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return ConnectWith(ctx, config.Database, readOptions, writeOptions)
+}
+
+// ConnectWith connects a read and a write client from explicit options with
+// db.Connect, which validates their URIs, defaulting to db.URI, and keeps
+// credentials out of its errors
+func ConnectWith(ctx context.Context, database string, readOptions, writeOptions *options.ClientOptions) (*Service, error) {
+	write, err := db.Connect(ctx, writeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("write client: %w", err)
+	}
+	read, err := db.Connect(ctx, readOptions)
+	if err != nil {
+		write.Disconnect(context.Background())
+		return nil, fmt.Errorf("read client: %w", err)
+	}
+	return New(read.Database(database), write.Database(database)), nil
+}
+
+// Service routes each repository call to the read or the write database
+type Service struct {
+	reads   *mongo.Database
+	writes  *mongo.Database
+	deleter *cascade.Deleter
+	slugs   *slug.Slugs
+}
+
+// New returns a Service over two handles to the same database
+func New(reads, writes *mongo.Database) *Service {
+	return &Service{
+		reads:   reads,
+		writes:  writes,
+		deleter: cascade.New(writes),
+		slugs:   slug.New(writes.Collection("podcasts"), writes.Collection("podcast_slugs")),
+	}
+}
+
+// EnsureIndexes creates the indexes the service relies on for correctness
+func (s *Service) EnsureIndexes(ctx context.Context) error {
+	return s.slugs.EnsureIndexes(ctx)
+}
+
+// Disconnect closes both clients
+func (s *Service) Disconnect(ctx context.Context) error {
+	return errors.Join(s.reads.Client().Disconnect(ctx), s.writes.Client().Disconnect(ctx))
+}
+
+type primaryKey struct{}
+
+// ReadYourWrites marks ctx so reads made with it use the write client, for
+// callers that must see a write they just made despite replication lag
+func ReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func (s *Service) readDatabase(ctx context.Context) *mongo.Database {
+	if ctx.Value(primaryKey{}) != nil {
+		return s.writes
+	}
+	return s.reads
+}
+
+// Podcast reads one podcast
+func (s *Service) Podcast(ctx context.Context, id dto.ID) (dto.Podcast, error) {
+	var podcast dto.Podcast
+	err := s.readDatabase(ctx).Collection("podcasts").FindOne(ctx, bson.D{{"_id", id}}, deadline.FindOne(ctx)).Decode(&podcast)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return podcast, ErrNotFound
+	}
+	return podcast, err
+}
+
+// Podcasts reads every podcast ordered by title
+func (s *Service) Podcasts(ctx context.Context) ([]dto.Podcast, error) {
+	opts := options.Find().SetSort(bson.D{{"title", 1}})
+	cursor, err := s.readDatabase(ctx).Collection("podcasts").Find(ctx, bson.D{}, opts, deadline.Find(ctx))
+	if err != nil {
+		return nil, err
+	}
+	podcasts := []dto.Podcast{}
+	if err = cursor.All(ctx, &podcasts); err != nil {
+		return nil, err
+	}
+	return podcasts, nil
+}
+
+// Episodes reads the episodes of a podcast
+func (s *Service) Episodes(ctx context.Context, podcast dto.ID) ([]dto.Episode, error) {
+	cursor, err := s.readDatabase(ctx).Collection("episodes").Find(ctx, bson.D{{"podcast", podcast}}, deadline.Find(ctx))
+	if err != nil {
+		return nil, err
+	}
+	episodes := []dto.Episode{}
+	if err = cursor.All(ctx, &episodes); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// PodcastBySlug reads the podcast that has or had slug. Its Slug differs
+// from slug when the podcast was renamed since.
+func (s *Service) PodcastBySlug(ctx context.Context, slugOrOld string) (dto.Podcast, error) {
+	current, err := s.slugs.Resolve(ctx, slugOrOld)
+	if errors.Is(err, slug.ErrNotFound) {
+		return dto.Podcast{}, ErrNotFound
+	}
+	if err != nil {
+		return dto.Podcast{}, err
+	}
+	var podcast dto.Podcast
+	err = s.readDatabase(ctx).Collection("podcasts").FindOne(ctx, bson.D{{"slug", current}}, deadline.FindOne(ctx)).Decode(&podcast)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return podcast, ErrNotFound
+	}
+	return podcast, err
+}
+
+// CreatePodcast inserts a podcast, assigning its ID if unset and a slug made
+// from its title. The podcast is inserted with its slug, so a failure leaves
+// no podcast without one.
+func (s *Service) CreatePodcast(ctx context.Context, podcast *dto.Podcast) error {
+	if podcast.ID.IsZero() {
+		podcast.ID = dto.NewID()
+	}
+	assigned, err := s.slugs.Create(ctx, podcast.ID, podcast.Title, func(candidate string) error {
+		podcast.Slug = candidate
+		_, err := s.writes.Collection("podcasts").InsertOne(ctx, podcast)
+		return err
+	})
+	podcast.Slug = assigned
+	return err
+}
+
+// UpdatePodcast stores podcast, writing only the fields that differ from the
+// stored version. A new title gets the podcast a new slug; the slug in
+// podcast is ignored and replaced by the stored one.
+func (s *Service) UpdatePodcast(ctx context.Context, podcast *dto.Podcast) error {
+	if err := s.update(ctx, "podcasts", podcast.ID, &dto.Podcast{}, podcast, diff.Options{Ignore: []string{"slug"}}); err != nil {
+		return err
+	}
+	var err error
+	podcast.Slug, err = s.slugs.Assign(ctx, podcast.ID, podcast.Title)
+	return err
+}
+
+// CreateEpisode inserts an episode, assigning its ID if unset
+func (s *Service) CreateEpisode(ctx context.Context, episode *dto.Episode) error {
+	if episode.ID.IsZero() {
+		episode.ID = dto.NewID()
+	}
+	_, err := s.writes.Collection("episodes").InsertOne(ctx, episode)
+	return err
+}
+
+// DeletePodcast removes a podcast and everything referencing it, and frees
+// its old slugs
+func (s *Service) DeletePodcast(ctx context.Context, id dto.ID) error {
+	_, err := s.deleter.DeletePodcastCascade(ctx, id.ObjectID())
+	if errors.Is(err, cascade.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.slugs.Forget(ctx, id)
+}
+
+// Episode reads one episode
+func (s *Service) Episode(ctx context.Context, id dto.ID) (dto.Episode, error) {
+	var episode dto.Episode
+	err := s.readDatabase(ctx).Collection("episodes").FindOne(ctx, bson.D{{"_id", id}}, deadline.FindOne(ctx)).Decode(&episode)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return episode, ErrNotFound
+	}
+	return episode, err
+}
+
+// UpdateEpisode stores episode, writing only the fields that differ from the
+// stored version
+func (s *Service) UpdateEpisode(ctx context.Context, episode dto.Episode) error {
+	return s.update(ctx, "episodes", episode.ID, &dto.Episode{}, episode)
+}
+
+// update reads the stored document into original, which must be a pointer to
+// the type of edited, and sends the $set/$unset update between the two. Unlike
+// a ReplaceOne, this keeps concurrent edits of other fields and any stored
+// fields the Go type does not know about.
+func (s *Service) update(ctx context.Context, collection string, id dto.ID, original, edited interface{}, opts ...diff.Options) error {
+	documents := s.writes.Collection(collection)
+	err := documents.FindOne(ctx, bson.D{{"_id", id}}, deadline.FindOne(ctx)).Decode(original)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	update, err := diff.Update(original, edited, opts...)
+	if err != nil || len(update) == 0 {
+		return err
+	}
+	result, err := documents.UpdateOne(ctx, bson.D{{"_id", id}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteEpisode removes an episode
+func (s *Service) DeleteEpisode(ctx context.Context, id dto.ID) error {
+	result, err := s.writes.Collection("episodes").DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExportEpisodes returns a cursor over every episode as a
+// dto.ExportedEpisode, ordered by podcast. Unlike the other reads it does not
+// decode the results, so a caller can stream them one batch of batchSize at a
+// time; it must close the cursor.
+func (s *Service) ExportEpisodes(ctx context.Context, batchSize int32) (*mongo.Cursor, error) {
+	// sorting first orders the plain episodes, which an index on
+	// {podcast: 1, _id: 1} can provide, rather than the larger joined documents
+	sortStage := bson.D{{"$sort", bson.D{{"podcast", 1}, {"_id", 1}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{
+		{"from", "podcasts"},
+		{"localField", "podcast"},
+		{"foreignField", "_id"},
+		{"as", "podcastDocs"},
+	}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"podcast", 1},
+		{"podcastTitle", bson.D{{"$first", "$podcastDocs.title"}}},
+		{"title", 1},
+		{"duration", 1},
+	}}}
+	opts := options.Aggregate().SetBatchSize(batchSize)
+	return s.readDatabase(ctx).Collection("episodes").Aggregate(ctx, mongo.Pipeline{sortStage, lookupStage, projectStage}, opts, deadline.Aggregate(ctx))
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/dto"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("ATLAS_URI", "mongodb://primary.example.com")
+	t.Setenv("ATLAS_URI_READ", "")
+	t.Setenv("QUICKSTART_READ_PREFERENCE", "")
+	config := ConfigFromEnv()
+	if config.ReadURI != config.WriteURI {
+		t.Errorf("ReadURI = %q, want the write URI %q", config.ReadURI, config.WriteURI)
+	}
+	if config.ReadPreference != readpref.SecondaryPreferredMode.String() {
+		t.Errorf("ReadPreference = %q, want secondaryPreferred", config.ReadPreference)
+	}
+
+	t.Setenv("ATLAS_URI_READ", "mongodb://analytics.example.com")
+	t.Setenv("QUICKSTART_READ_PREFERENCE", "nearest")
+	config = ConfigFromEnv()
+	if config.ReadURI != "mongodb://analytics.example.com" || config.ReadPreference != "nearest" {
+		t.Errorf("config = %+v, want the read URI and preference from the environment", config)
+	}
+
+	// -uri takes precedence over ATLAS_URI
+	flags := flag.NewFlagSet("service", flag.ContinueOnError)
+	db.RegisterFlags(flags)
+	if err := flags.Parse([]string{"-uri", "mongodb://flag.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flags.Parse([]string{"-uri", ""}) })
+	if config = ConfigFromEnv(); config.WriteURI != "mongodb://flag.example.com" {
+		t.Errorf("WriteURI = %q, want the -uri flag", config.WriteURI)
+	}
+}
+
+func TestInvalidURI(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want string
+	}{
+		{"placeholder", "mongodb+srv://quickstart:<password>@cluster0.example.net", "placeholder <password>"},
+		{"malformed", "mongodb://quickstart:secret@", "invalid connection string"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Database: "quickstart", WriteURI: test.uri, ReadPreference: "primary"}
+			_, _, err := config.ClientOptions()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("ClientOptions = %v, want an error about the %s", err, test.want)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("ClientOptions = %v, which shows the password", err)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	config := Config{WriteURI: "mongodb://localhost", ReadURI: "mongodb://localhost", ReadPreference: "secondary", ReadPoolSize: 50}
+	read, write, err := config.ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.ReadPreference.Mode() != readpref.SecondaryMode {
+		t.Errorf("read client mode = %v, want secondary", read.ReadPreference.Mode())
+	}
+	if write.ReadPreference.Mode() != readpref.PrimaryMode {
+		t.Errorf("write client mode = %v, want primary", write.ReadPreference.Mode())
+	}
+	if read.MaxPoolSize == nil || *read.MaxPoolSize != 50 {
+		t.Errorf("read pool size = %v, want 50", read.MaxPoolSize)
+	}
+
+	config.ReadPreference = "sometimes"
+	if _, _, err = config.ClientOptions(); err == nil {
+		t.Error("expected an error for an unknown read preference")
+	}
+}
+
+// recorder remembers the collection commands a client sent
+type recorder struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (r *recorder) monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, started *event.CommandStartedEvent) {
+			// handshakes, heartbeats and session commands do not name a collection
+			collection, ok := started.Command.Lookup(started.CommandName).StringValueOK()
+			if !ok {
+				return
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.commands = append(r.commands, started.CommandName+" "+collection)
+		},
+	}
+}
+
+// take returns the recorded commands and forgets them
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	commands := r.commands
+	r.commands = nil
+	return commands
+}
+
+// TestRouting proves which client served each call by giving the read and
+// the write client their own monitor
+func TestRouting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	t.Setenv("ATLAS_URI", mongotest.URI(t))
+	config := ConfigFromEnv()
+	config.Database = mongotest.Database(t).Name()
+	readOptions, writeOptions, err := config.ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads, writes := &recorder{}, &recorder{}
+	readOptions.SetMonitor(reads.monitor())
+	writeOptions.SetMonitor(writes.monitor())
+	service, err := ConnectWith(ctx, config.Database, readOptions, writeOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.Disconnect(context.Background()) })
+
+	podcast := dto.Podcast{Title: "The Polyglot Developer", Author: "Nic Raboy"}
+	tests := []struct {
+		name   string
+		call   func(ctx context.Context) error
+		client string
+		want   string
+	}{
+		{"CreatePodcast", func(ctx context.Context) error { return service.CreatePodcast(ctx, &podcast) }, "write", "insert podcasts"},
+		{"CreateEpisode", func(ctx context.Context) error {
+			return service.CreateEpisode(ctx, &dto.Episode{Podcast: podcast.ID, Title: "GraphQL for Beginners", Duration: 25})
+		}, "write", "insert episodes"},
+		{"UpdatePodcast", func(ctx context.Context) error {
+			podcast.Tags = []string{"development"}
+			return service.UpdatePodcast(ctx, &podcast)
+		}, "write", "update podcasts"},
+		{"Podcast", func(ctx context.Context) error {
+			_, err := service.Podcast(ctx, podcast.ID)
+			// a lagging secondary may not have the podcast yet
+			if err == ErrNotFound {
+				return nil
+			}
+			return err
+		}, "read", "find podcasts"},
+		{"Podcasts", func(ctx context.Context) error { _, err := service.Podcasts(ctx); return err }, "read", "find podcasts"},
+		{"Episodes", func(ctx context.Context) error { _, err := service.Episodes(ctx, podcast.ID); return err }, "read", "find episodes"},
+		{"ReadYourWrites", func(ctx context.Context) error {
+			_, err := service.Podcast(ReadYourWrites(ctx), podcast.ID)
+			return err
+		}, "write", "find podcasts"},
+		{"DeletePodcast", func(ctx context.Context) error { return service.DeletePodcast(ctx, podcast.ID) }, "write", "delete podcasts"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reads.take()
+			writes.take()
+			if err := test.call(ctx); err != nil {
+				t.Fatal(err)
+			}
+			served, other := writes.take(), reads.take()
+			if test.client == "read" {
+				served, other = other, served
+			}
+			if !contains(served, test.want) {
+				t.Errorf("%s client sent %v, want %q", test.client, served, test.want)
+			}
+			if len(other) > 0 {
+				t.Errorf("the other client sent %v, want nothing", other)
+			}
+		})
+	}
+}
+
+func contains(commands []string, want string) bool {
+	for _, command := range commands {
+		if strings.EqualFold(command, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserSessions represents the schema for the "user_sessions" collection
+type UserSessions struct {
+	User            primitive.ObjectID `bson:"_id"`
+	Sessions        int64              `bson:"sessions"`
+	Listens         int64              `bson:"listens"`
+	AverageLength   float64            `bson:"average_length_seconds"`
+	AverageListens  float64            `bson:"average_listens"`
+	LongestLength   float64            `bson:"longest_length_seconds"`
+	FirstListenedAt time.Time          `bson:"first_listened_at"`
+	LastListenedAt  time.Time          `bson:"last_listened_at"`
+	ComputedAt      time.Time          `bson:"computed_at"`
+}
+
+// sessionsPipeline splits each user's listens into sessions: a listen more
+// than gap after the previous one starts a new session. $setWindowFields
+// (MongoDB 5.0+) looks at the previous listen with $shift, and a running
+// $sum of the "starts a session" flags numbers the sessions per user.
+func sessionsPipeline(since time.Time, gap time.Duration, now time.Time) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"listened_at", bson.D{{"$gte", since}}}}}}
+	previousStage := bson.D{{"$setWindowFields", bson.D{
+		{"partitionBy", "$user"},
+		{"sortBy", bson.D{{"listened_at", 1}}},
+		{"output", bson.D{
+			{"previous", bson.D{{"$shift", bson.D{{"output", "$listened_at"}, {"by", -1}}}}},
+		}},
+	}}}
+	startStage := bson.D{{"$set", bson.D{
+		{"starts_session", bson.D{{"$cond", bson.A{
+			bson.D{{"$or", bson.A{
+				bson.D{{"$eq", bson.A{"$previous", nil}}},
+				bson.D{{"$gt", bson.A{
+					bson.D{{"$subtract", bson.A{"$listened_at", "$previous"}}},
+					gap.Milliseconds(),
+				}}},
+			}}},
+			1,
+			0,
+		}}}},
+	}}}
+	numberStage := bson.D{{"$setWindowFields", bson.D{
+		{"partitionBy", "$user"},
+		{"sortBy", bson.D{{"listened_at", 1}}},
+		{"output", bson.D{
+			{"session", bson.D{
+				{"$sum", "$starts_session"},
+				{"window", bson.D{{"documents", bson.A{"unbounded", "current"}}}},
+			}},
+		}},
+	}}}
+	// a session lasts from its first to its last listen, so a single listen
+	// is a session of length zero
+	sessionStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{{"user", "$user"}, {"session", "$session"}}},
+		{"start", bson.D{{"$min", "$listened_at"}}},
+		{"end", bson.D{{"$max", "$listened_at"}}},
+		{"listens", bson.D{{"$sum", 1}}},
+	}}}
+	lengthStage := bson.D{{"$set", bson.D{
+		{"length", bson.D{{"$divide", bson.A{bson.D{{"$subtract", bson.A{"$end", "$start"}}}, 1000}}}},
+	}}}
+	userStage := bson.D{{"$group", bson.D{
+		{"_id", "$_id.user"},
+		{"sessions", bson.D{{"$sum", 1}}},
+		{"listens", bson.D{{"$sum", "$listens"}}},
+		{"average_length_seconds", bson.D{{"$avg", "$length"}}},
+		{"average_listens", bson.D{{"$avg", "$listens"}}},
+		{"longest_length_seconds", bson.D{{"$max", "$length"}}},
+		{"first_listened_at", bson.D{{"$min", "$start"}}},
+		{"last_listened_at", bson.D{{"$max", "$end"}}},
+	}}}
+	computedStage := bson.D{{"$set", bson.D{{"computed_at", now}}}}
+	mergeStage := bson.D{{"$merge", bson.D{
+		{"into", "user_sessions"},
+		{"on", "_id"},
+		{"whenMatched", "replace"},
+		{"whenNotMatched", "insert"},
+	}}}
+	return mongo.Pipeline{matchStage, previousStage, startStage, numberStage, sessionStage, lengthStage, userStage, computedStage, mergeStage}
+}
+
+var (
+	gap    = flag.Duration("gap", 30*time.Minute, "inactivity that ends a session")
+	window = flag.Duration("window", 30*24*time.Hour, "how far back to read listens")
+	top    = flag.Int64("top", 10, "number of users to print")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	listensCollection := database.Collection("listens")
+	summaryCollection := database.Collection("user_sessions")
+
+	// the window functions partition by user and sort by time
+	_, err = listensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{"user", 1}, {"listened_at", 1}}})
+	if err != nil {
+		return err
+	}
+
+	// Sessionize Listens And Merge The Summary Per User
+	// BSON dates keep milliseconds, truncate so computed_at compares equal below
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	cursor, err := listensCollection.Aggregate(ctx, sessionsPipeline(now.Add(-*window), *gap, now),
+		options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	// $merge returns no documents, closing the cursor finishes the run
+	if err = cursor.Close(ctx); err != nil {
+		return err
+	}
+
+	// Remove Summaries Of Users Without Listens In The Window
+	deleted, err := summaryCollection.DeleteMany(ctx, bson.D{{"computed_at", bson.D{{"$lt", now}}}})
+	if err != nil {
+		return err
+	}
+
+	// Read The Most Active Users
+	opts := options.Find().SetSort(bson.D{{"sessions", -1}}).SetLimit(*top)
+	cursor, err = summaryCollection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+	var summaries []UserSessions
+	if err = cursor.All(ctx, &summaries); err != nil {
+		return err
+	}
+	fmt.Printf("Sessions split at %v of inactivity, %d stale summaries removed\n", *gap, deleted.DeletedCount)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tSESSIONS\tLISTENS\tAVG LENGTH\tAVG LISTENS\tLONGEST")
+	for _, summary := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1f\t%s\n", summary.User.Hex(), summary.Sessions, summary.Listens,
+			time.Duration(summary.AverageLength*float64(time.Second)).Round(time.Second), summary.AverageListens,
+			time.Duration(summary.LongestLength*float64(time.Second)).Round(time.Second))
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,325 @@
+// Package slug gives documents readable, unique URL slugs derived from their
+// titles, such as "the-polyglot-developer-podcast", and remembers the slugs
+// they had before so old links keep working after a rename:
+//
+//	slugs := slug.New(database.Collection("podcasts"), database.Collection("podcast_slugs"))
+//	current, err := slugs.Assign(ctx, podcast.ID, podcast.Title)
+//	...
+//	current, err = slugs.Resolve(ctx, requested) // redirect when current != requested
+//
+// Uniqueness is enforced by a unique index rather than by checking first, so
+// two documents with the same title cannot race each other into one slug.
+package slug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotFound is returned for slugs that no document has or had
+var ErrNotFound = errors.New("slug not found")
+
+// MaxLength is the longest slug Make returns, not counting a "-2" suffix
+const MaxLength = 60
+
+// transliterations spell out the letters that lose more than an accent
+// when reduced to ASCII
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss", 'æ': "ae", 'ø': "o", 'å': "a", 'œ': "oe", 'ł': "l", 'đ': "d",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ý': "y", 'ÿ': "y",
+}
+
+// words spells out symbols that carry meaning in a title
+var words = strings.NewReplacer("&", " and ", "+", " plus ")
+
+// Make returns the slug for title: lower case ASCII letters and digits, with
+// every run of anything else turned into one hyphen. Titles with nothing to
+// keep get "untitled".
+func Make(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range words.Replace(strings.ToLower(title)) {
+		spelled, ok := transliterations[r]
+		if !ok && r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			spelled, ok = string(r), true
+		}
+		if !ok {
+			hyphen = true
+			continue
+		}
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(spelled)
+		hyphen = false
+	}
+	slug := b.String()
+	if len(slug) > MaxLength {
+		// cut at the last hyphen that keeps it short enough
+		slug = strings.TrimRight(slug[:MaxLength], "-")
+		if i := strings.LastIndexByte(slug, '-'); i > MaxLength/2 {
+			slug = slug[:i]
+		}
+	}
+	if slug == "" {
+		return "untitled"
+	}
+	return slug
+}
+
+// Slugs stores the current slug of each document in Field of Collection,
+// and the slugs they had before in History, keyed by the old slug
+type Slugs struct {
+	Collection *mongo.Collection
+	History    *mongo.Collection
+	Field      string
+	// MaxAttempts bounds the suffixes tried for a taken slug: "title",
+	// "title-2" up to "title-<MaxAttempts>"
+	MaxAttempts int
+}
+
+// New returns Slugs kept in the "slug" field of collection
+func New(collection, history *mongo.Collection) *Slugs {
+	return &Slugs{Collection: collection, History: history, Field: "slug", MaxAttempts: 100}
+}
+
+// EnsureIndexes creates the unique index Assign relies on. It is partial so
+// documents without a slug yet do not collide on null.
+func (s *Slugs) EnsureIndexes(ctx context.Context) error {
+	_, err := s.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{s.Field, 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.D{{s.Field, bson.D{{"$type", "string"}}}}),
+	})
+	if err != nil {
+		return fmt.Errorf("create unique index on %s.%s: %w", s.Collection.Name(), s.Field, err)
+	}
+	_, err = s.History.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{"target", 1}}})
+	if err != nil {
+		return fmt.Errorf("create index on %s.target: %w", s.History.Name(), err)
+	}
+	return nil
+}
+
+// historyEntry maps a slug a document no longer has to that document
+type historyEntry struct {
+	Slug       string      `bson:"_id"`
+	Target     interface{} `bson:"target"`
+	ReplacedAt time.Time   `bson:"replaced_at"`
+}
+
+// Assign gives the document with the given _id the slug made from title,
+// or the first of "<slug>-2", "<slug>-3" and so on that is free, and returns
+// it. A document whose slug already fits the title keeps it. A replaced slug
+// is kept in History so Resolve can redirect it.
+func (s *Slugs) Assign(ctx context.Context, id interface{}, title string) (string, error) {
+	var document bson.M
+	err := s.Collection.FindOne(ctx, bson.D{{"_id", id}}, options.FindOne().SetProjection(bson.D{{s.Field, 1}})).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	previous, _ := document[s.Field].(string)
+	base := Make(title)
+	if previous == base {
+		return previous, nil
+	}
+	if suffixed(previous, base) {
+		// "season-2" only fits "Season" while another document holds
+		// "season"; otherwise it was made from a title like "Season 2"
+		taken, err := s.heldByOther(ctx, id, base)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return previous, nil
+		}
+	}
+
+	current, err := s.claim(ctx, id, base, func(candidate string) error {
+		_, err := s.Collection.UpdateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$set", bson.D{{s.Field, candidate}}}})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return current, s.remember(ctx, id, previous, current)
+}
+
+// Create gives a new document with the given _id the slug made from title,
+// like Assign, by calling insert with each candidate until one is free. The
+// document is written together with its slug, so it never exists without
+// one.
+func (s *Slugs) Create(ctx context.Context, id interface{}, title string, insert func(slug string) error) (string, error) {
+	return s.claim(ctx, id, Make(title), insert)
+}
+
+// claim calls write with base, "<base>-2" and so on, skipping slugs other
+// documents had, until write does not fail on the unique slug index
+func (s *Slugs) claim(ctx context.Context, id interface{}, base string, write func(candidate string) error) (string, error) {
+	for attempt := 1; attempt <= s.MaxAttempts; attempt++ {
+		candidate := base
+		if attempt > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		// an old slug of another document stays reserved, so its links do not
+		// start leading somewhere else
+		var old historyEntry
+		err := s.History.FindOne(ctx, bson.D{{"_id", candidate}}).Decode(&old)
+		if err == nil && !sameID(old.Target, id) {
+			continue
+		}
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return "", err
+		}
+
+		err = write(candidate)
+		if s.taken(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("no free slug for %q after %d attempts", base, s.MaxAttempts)
+}
+
+// taken reports whether err is a duplicate key error on the slug index,
+// rather than on _id or another unique index
+func (s *Slugs) taken(err error) bool {
+	var writeErr mongo.WriteException
+	if !errors.As(err, &writeErr) {
+		return false
+	}
+	for _, e := range writeErr.WriteErrors {
+		if e.Code != 11000 {
+			continue
+		}
+		if _, lookupErr := e.Raw.LookupErr("keyPattern", s.Field); lookupErr == nil {
+			return true
+		}
+		// servers before 4.2 only name the index in the message
+		if strings.Contains(e.Message, " index: "+s.Field+"_1 ") {
+			return true
+		}
+	}
+	return false
+}
+
+// heldByOther reports whether a document other than id has slug, or had it
+// and keeps it reserved in History
+func (s *Slugs) heldByOther(ctx context.Context, id interface{}, slug string) (bool, error) {
+	filter := bson.D{{s.Field, slug}, {"_id", bson.D{{"$ne", id}}}}
+	err := s.Collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.D{{"_id", 1}})).Err()
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+	var old historyEntry
+	err = s.History.FindOne(ctx, bson.D{{"_id", slug}}).Decode(&old)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !sameID(old.Target, id), nil
+}
+
+// remember records that id moved from previous to current. A document that
+// gets a former slug back no longer needs that slug redirected.
+func (s *Slugs) remember(ctx context.Context, id interface{}, previous, current string) error {
+	if _, err := s.History.DeleteOne(ctx, bson.D{{"_id", current}}); err != nil {
+		return fmt.Errorf("delete %s from slug history: %w", current, err)
+	}
+	if previous == "" || previous == current {
+		return nil
+	}
+	entry := historyEntry{Slug: previous, Target: id, ReplacedAt: time.Now()}
+	_, err := s.History.ReplaceOne(ctx, bson.D{{"_id", previous}}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("add %s to slug history: %w", previous, err)
+	}
+	return nil
+}
+
+// Resolve returns the current slug of the document that has or had slug.
+// When it differs from slug, the caller should redirect to it permanently.
+func (s *Slugs) Resolve(ctx context.Context, slug string) (string, error) {
+	err := s.Collection.FindOne(ctx, bson.D{{s.Field, slug}}, options.FindOne().SetProjection(bson.D{{"_id", 1}})).Err()
+	if err == nil {
+		return slug, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+	var old historyEntry
+	err = s.History.FindOne(ctx, bson.D{{"_id", slug}}).Decode(&old)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	var document bson.M
+	err = s.Collection.FindOne(ctx, bson.D{{"_id", old.Target}}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	current, ok := document[s.Field].(string)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return current, nil
+}
+
+// Forget removes the history of a deleted document, freeing its old slugs
+func (s *Slugs) Forget(ctx context.Context, id interface{}) error {
+	_, err := s.History.DeleteMany(ctx, bson.D{{"target", id}})
+	return err
+}
+
+// sameID compares ids by their BSON encoding, since a decoded target is a
+// primitive.ObjectID while the caller's id may be a type wrapping one
+func sameID(a, b interface{}) bool {
+	aType, aData, err := bson.MarshalValue(a)
+	if err != nil {
+		return false
+	}
+	bType, bData, err := bson.MarshalValue(b)
+	return err == nil && aType == bType && bytes.Equal(aData, bData)
+}
+
+// suffixed reports whether slug is base with a numeric suffix, as claim
+// makes when base is taken
+func suffixed(slug, base string) bool {
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
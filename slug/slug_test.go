@@ -0,0 +1,169 @@
+package slug
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/mongotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	mongotest.Main(m)
+}
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Polyglot Developer Podcast", "the-polyglot-developer-podcast"},
+		{"  Go & MongoDB!  ", "go-and-mongodb"},
+		{"C++ Weekly", "c-plus-plus-weekly"},
+		{"Grüße aus Köln", "gruesse-aus-koeln"},
+		{"Café Société", "cafe-societe"},
+		{"Season 2", "season-2"},
+		{"日本語", "untitled"},
+		{"", "untitled"},
+		{strings.Repeat("abcdefg ", 10), strings.TrimSuffix(strings.Repeat("abcdefg-", 7), "-")},
+	}
+	for _, test := range tests {
+		if got := Make(test.title); got != test.want {
+			t.Errorf("Make(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestSuffixed(t *testing.T) {
+	tests := []struct {
+		slug, base string
+		want       bool
+	}{
+		{"season-2", "season", true},
+		{"season-12", "season", true},
+		{"season", "season", false},
+		{"season-", "season", false},
+		{"season-two", "season", false},
+		{"seasons-2", "season", false},
+	}
+	for _, test := range tests {
+		if got := suffixed(test.slug, test.base); got != test.want {
+			t.Errorf("suffixed(%q, %q) = %v, want %v", test.slug, test.base, got, test.want)
+		}
+	}
+}
+
+// newSlugs returns Slugs over fresh collections, with a podcast inserted for
+// each title
+func newSlugs(t *testing.T, titles ...string) (*Slugs, []primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	database := mongotest.Database(t)
+	slugs := New(database.Collection("podcasts"), database.Collection("podcast_slugs"))
+	if err := slugs.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var ids []primitive.ObjectID
+	for _, title := range titles {
+		id := primitive.NewObjectID()
+		if _, err := slugs.Collection.InsertOne(ctx, bson.D{{"_id", id}, {"title", title}}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return slugs, ids
+}
+
+func assign(t *testing.T, slugs *Slugs, id primitive.ObjectID, title, want string) {
+	t.Helper()
+	got, err := slugs.Assign(context.Background(), id, title)
+	if err != nil {
+		t.Fatalf("Assign(%q): %v", title, err)
+	}
+	if got != want {
+		t.Errorf("Assign(%q) = %q, want %q", title, got, want)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("same title gets a suffix", func(t *testing.T) {
+		slugs, ids := newSlugs(t, "Go Time", "Go Time")
+		assign(t, slugs, ids[0], "Go Time", "go-time")
+		assign(t, slugs, ids[1], "Go Time", "go-time-2")
+		// a suffixed slug fits while the other podcast still holds the base
+		assign(t, slugs, ids[1], "Go Time", "go-time-2")
+	})
+
+	t.Run("rename from a numbered title", func(t *testing.T) {
+		slugs, ids := newSlugs(t, "Season 2")
+		assign(t, slugs, ids[0], "Season 2", "season-2")
+		assign(t, slugs, ids[0], "Season", "season")
+		if current, err := slugs.Resolve(ctx, "season-2"); err != nil || current != "season" {
+			t.Errorf("Resolve(season-2) = %q, %v, want a redirect to season", current, err)
+		}
+	})
+
+	t.Run("old slugs stay reserved", func(t *testing.T) {
+		slugs, ids := newSlugs(t, "Gopher Talk", "Other")
+		assign(t, slugs, ids[0], "Gopher Talk", "gopher-talk")
+		assign(t, slugs, ids[0], "Gopher Talks", "gopher-talks")
+		assign(t, slugs, ids[1], "Gopher Talk", "gopher-talk-2")
+		// the podcast that had it may take it back
+		assign(t, slugs, ids[0], "Gopher Talk", "gopher-talk")
+		if current, err := slugs.Resolve(ctx, "gopher-talks"); err != nil || current != "gopher-talk" {
+			t.Errorf("Resolve(gopher-talks) = %q, %v, want gopher-talk", current, err)
+		}
+	})
+
+	t.Run("unknown document", func(t *testing.T) {
+		slugs, _ := newSlugs(t)
+		if _, err := slugs.Assign(ctx, primitive.NewObjectID(), "Missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Assign = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	slugs, ids := newSlugs(t, "Go Time")
+	assign(t, slugs, ids[0], "Go Time", "go-time")
+
+	insert := func(id primitive.ObjectID) func(string) error {
+		return func(slug string) error {
+			_, err := slugs.Collection.InsertOne(ctx, bson.D{{"_id", id}, {"title", "Go Time"}, {"slug", slug}})
+			return err
+		}
+	}
+	id := primitive.NewObjectID()
+	got, err := slugs.Create(ctx, id, "Go Time", insert(id))
+	if err != nil || got != "go-time-2" {
+		t.Fatalf("Create = %q, %v, want go-time-2", got, err)
+	}
+	// a duplicate _id is not mistaken for a taken slug
+	if _, err = slugs.Create(ctx, id, "Go Time", insert(id)); err == nil {
+		t.Error("Create with a duplicate _id succeeded")
+	}
+	count, err := slugs.Collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d podcasts, want 2", count)
+	}
+}
+
+func TestForget(t *testing.T) {
+	ctx := context.Background()
+	slugs, ids := newSlugs(t, "Old Name", "Other")
+	assign(t, slugs, ids[0], "Old Name", "old-name")
+	assign(t, slugs, ids[0], "New Name", "new-name")
+	if err := slugs.Forget(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	assign(t, slugs, ids[1], "Old Name", "old-name")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// scalarTypes maps BSON types to the Go types the driver decodes them into
+var scalarTypes = map[bsontype.Type]string{
+	bsontype.Double:     "float64",
+	bsontype.String:     "string",
+	bsontype.Binary:     "primitive.Binary",
+	bsontype.ObjectID:   "primitive.ObjectID",
+	bsontype.Boolean:    "bool",
+	bsontype.DateTime:   "time.Time",
+	bsontype.Regex:      "primitive.Regex",
+	bsontype.JavaScript: "primitive.JavaScript",
+	bsontype.Int32:      "int32",
+	bsontype.Timestamp:  "primitive.Timestamp",
+	bsontype.Int64:      "int64",
+	bsontype.Decimal128: "primitive.Decimal128",
+}
+
+// initialisms are written in capitals in Go names
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+type field struct {
+	name, goType, tag string
+}
+
+type declaration struct {
+	name, comment string
+	fields        []field
+}
+
+// generator turns shapes into struct declarations
+type generator struct {
+	declarations []declaration
+	typeNames    map[string]bool
+	imports      map[string]bool
+}
+
+func newGenerator() *generator {
+	return &generator{typeNames: map[string]bool{}, imports: map[string]bool{}}
+}
+
+// declare adds a struct for an embedded document shape and returns its name
+func (g *generator) declare(name, comment string, s *shape) string {
+	name = unique(name, g.typeNames)
+	// reserve the slot so nested types are printed after their parent
+	index := len(g.declarations)
+	g.declarations = append(g.declarations, declaration{name: name, comment: name + " " + comment})
+
+	fieldNames := map[string]bool{}
+	var fields []field
+	for _, property := range s.fields {
+		fieldName := unique(goName(property.key), fieldNames)
+		goType := g.goType(name+fieldName, property.key, property.shape)
+		omitempty := property.optional || property.key == "_id"
+		// omitempty never drops a struct value, only a nil pointer
+		if property.optional && !strings.HasPrefix(goType, "*") && g.isStruct(goType) {
+			goType = "*" + goType
+		}
+		tag := property.key
+		if omitempty {
+			tag += ",omitempty"
+		}
+		fields = append(fields, field{name: fieldName, goType: goType, tag: fmt.Sprintf("`bson:%s`", strconv.Quote(tag))})
+	}
+	g.declarations[index].fields = fields
+	return name
+}
+
+func (g *generator) isStruct(goType string) bool {
+	return g.typeNames[goType]
+}
+
+// goType picks the Go type for a shape, declaring nested structs as needed
+func (g *generator) goType(name, key string, s *shape) string {
+	t := g.baseType(name, key, s)
+	if s.nullable && t != "interface{}" && !strings.HasPrefix(t, "[]") {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *generator) baseType(name, key string, s *shape) string {
+	switch {
+	case len(s.types) == 0:
+		return "interface{}"
+	case isNumeric(s.types):
+		return g.numericType(s.types)
+	case len(s.types) > 1:
+		return "interface{}"
+	}
+	switch t := s.types[0]; t {
+	case bsontype.EmbeddedDocument:
+		if len(s.fields) == 0 {
+			g.imports["go.mongodb.org/mongo-driver/bson"] = true
+			return "bson.M"
+		}
+		return g.declare(name, fmt.Sprintf("represents the embedded %q documents", key), s)
+	case bsontype.Array:
+		if s.elem == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(singular(name), key, s.elem)
+	default:
+		goType, ok := scalarTypes[t]
+		if !ok {
+			return "interface{}"
+		}
+		switch {
+		case strings.HasPrefix(goType, "primitive."):
+			g.imports["go.mongodb.org/mongo-driver/bson/primitive"] = true
+		case strings.HasPrefix(goType, "time."):
+			g.imports["time"] = true
+		}
+		return goType
+	}
+}
+
+func isNumeric(types []bsontype.Type) bool {
+	for _, t := range types {
+		if t != bsontype.Int32 && t != bsontype.Int64 && t != bsontype.Double {
+			return false
+		}
+	}
+	return true
+}
+
+// numericType widens mixed numbers: int32 and int64 become int64, anything
+// with a double becomes float64
+func (g *generator) numericType(types []bsontype.Type) string {
+	widest := "int32"
+	for _, t := range types {
+		switch t {
+		case bsontype.Double:
+			return "float64"
+		case bsontype.Int64:
+			widest = "int64"
+		}
+	}
+	return widest
+}
+
+// source renders the declarations as a formatted Go file
+func (g *generator) source(packageName, header string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\npackage %s\n\n", header, packageName)
+	if len(g.imports) > 0 {
+		b.WriteString("import (\n")
+		if g.imports["time"] {
+			b.WriteString("\t\"time\"\n\n")
+		}
+		for _, path := range []string{"go.mongodb.org/mongo-driver/bson", "go.mongodb.org/mongo-driver/bson/primitive"} {
+			if g.imports[path] {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			}
+		}
+		b.WriteString(")\n\n")
+	}
+	for _, declaration := range g.declarations {
+		fmt.Fprintf(&b, "// %s\ntype %s struct {\n", declaration.comment, declaration.name)
+		for _, field := range declaration.fields {
+			fmt.Fprintf(&b, "\t%s %s %s\n", field.name, field.goType, field.tag)
+		}
+		b.WriteString("}\n\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// goName turns a field key such as "release_date" or "podcastId" into an
+// exported Go name such as "ReleaseDate" or "PodcastID"
+func goName(key string) string {
+	if key == "_id" {
+		return "ID"
+	}
+	parts := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		for _, word := range splitCamel(part) {
+			if upper := strings.ToUpper(word); initialisms[upper] {
+				b.WriteString(upper)
+				continue
+			}
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			b.WriteString(string(runes))
+		}
+	}
+	name := b.String()
+	if name == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// splitCamel splits "podcastId" into "podcast" and "Id"
+func splitCamel(s string) []string {
+	var words []string
+	start := 0
+	runes := []rune(s)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// singular makes an element type name from a plural field name
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name + "Item"
+	case strings.HasSuffix(name, "s") && len(name) > 3:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+// unique returns name, or name with a number appended if it is already taken
+func unique(name string, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	taken[candidate] = true
+	return candidate
+}
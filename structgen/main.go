@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	databaseName   = flag.String("db", "quickstart", "database to read from")
+	collectionName = flag.String("collection", "", "collection to generate a struct for")
+	sampleSize     = flag.Int("sample", 1000, "number of documents to sample")
+	useSchema      = flag.Bool("schema", false, "use the collection's $jsonSchema validator instead of sampling")
+	schemaFile     = flag.String("schema-file", "", "read a $jsonSchema from an Extended JSON file instead of the database")
+	typeName       = flag.String("type", "", "name of the generated struct (default: from the collection name)")
+	packageName    = flag.String("package", "main", "package clause of the generated file")
+	output         = flag.String("o", "", "file to write (default: standard output)")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *collectionName == "" {
+		return fmt.Errorf("%w: -collection is required", shutdown.ErrUsage)
+	}
+	if *typeName == "" {
+		*typeName = singular(goName(*collectionName))
+	}
+
+	var root *shape
+	var source string
+	var err error
+	if *schemaFile != "" {
+		root, err = schemaFromFile(*schemaFile)
+		source = *schemaFile
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		var client *mongo.Client
+		client, err = db.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		down.Client(client)
+		collection := client.Database(*databaseName).Collection(*collectionName)
+		source = *databaseName + "." + *collectionName
+		if *useSchema {
+			root, err = schemaFromValidator(ctx, collection)
+		} else {
+			root, err = sample(ctx, collection, *sampleSize)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	g := newGenerator()
+	g.declare(*typeName, fmt.Sprintf("represents the schema for the %q collection", *collectionName), root)
+	code, err := g.source(*packageName, "// Code generated by structgen from "+source+"; DO NOT EDIT.")
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(*output, code, 0644)
+}
+
+// sample learns the shape of a collection from a random sample of documents
+func sample(ctx context.Context, collection *mongo.Collection, size int) (*shape, error) {
+	sampleStage := bson.D{{"$sample", bson.D{{"size", size}}}}
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{sampleStage})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	root := newSampler()
+	for cursor.Next(ctx) {
+		root.observeDocument(cursor.Current)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+	if root.seen == 0 {
+		return nil, fmt.Errorf("%s is empty, nothing to sample", collection.Name())
+	}
+	root.finish()
+	return root.shape, nil
+}
+
+// schemaFromValidator reads the $jsonSchema validator of a collection
+func schemaFromValidator(ctx context.Context, collection *mongo.Collection) (*shape, error) {
+	specs, err := collection.Database().ListCollectionSpecifications(ctx, bson.D{{"name", collection.Name()}})
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("collection %s does not exist", collection.Name())
+	}
+	schema, err := specs[0].Options.LookupErr("validator", "$jsonSchema")
+	if err != nil {
+		return nil, fmt.Errorf("collection %s has no $jsonSchema validator", collection.Name())
+	}
+	return fromSchema(schema.Document())
+}
+
+// schemaFromFile reads a $jsonSchema, either bare or wrapped in a validator
+// document as exported by Compass
+func schemaFromFile(path string) (*shape, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.Raw
+	if err = bson.UnmarshalExtJSON(data, false, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if wrapped, ok := document.Lookup("$jsonSchema").DocumentOK(); ok {
+		document = wrapped
+	}
+	return fromSchema(document)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// shape describes the values found in one place of the documents, whether
+// learned from samples or from a $jsonSchema
+type shape struct {
+	types    []bsontype.Type // non-null BSON types, in the order first seen
+	nullable bool
+	fields   []*property // for embedded documents
+	elem     *shape      // for arrays
+}
+
+// property is a field of an embedded document
+type property struct {
+	key      string
+	optional bool
+	shape    *shape
+}
+
+func (s *shape) addType(t bsontype.Type) {
+	for _, known := range s.types {
+		if known == t {
+			return
+		}
+	}
+	s.types = append(s.types, t)
+}
+
+// sampler accumulates the shape of sampled values
+type sampler struct {
+	shape   *shape
+	present int // how often the field held a value
+	seen    int // how many documents were observed, for embedded documents
+	fields  map[string]*sampler
+	elem    *sampler
+}
+
+func newSampler() *sampler {
+	return &sampler{shape: &shape{}, fields: map[string]*sampler{}}
+}
+
+func (s *sampler) observe(value bson.RawValue) {
+	s.present++
+	if value.Type == bsontype.Null || value.Type == bsontype.Undefined {
+		s.shape.nullable = true
+		return
+	}
+	s.shape.addType(value.Type)
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		s.observeDocument(value.Document())
+	case bsontype.Array:
+		values, _ := value.Array().Values()
+		for _, item := range values {
+			if s.elem == nil {
+				s.elem = newSampler()
+				s.shape.elem = s.elem.shape
+			}
+			s.elem.observe(item)
+		}
+	}
+}
+
+func (s *sampler) observeDocument(document bson.Raw) {
+	s.seen++
+	elements, _ := document.Elements()
+	for _, element := range elements {
+		field, ok := s.fields[element.Key()]
+		if !ok {
+			field = newSampler()
+			s.fields[element.Key()] = field
+			s.shape.fields = append(s.shape.fields, &property{key: element.Key(), shape: field.shape})
+		}
+		field.observe(element.Value())
+	}
+}
+
+// finish marks fields that were missing from some documents as optional
+func (s *sampler) finish() {
+	for _, property := range s.shape.fields {
+		field := s.fields[property.key]
+		property.optional = field.present < s.seen
+		field.finish()
+	}
+	if s.elem != nil {
+		s.elem.finish()
+	}
+}
+
+// schemaTypes maps $jsonSchema bsonType and type names to BSON types
+var schemaTypes = map[string]bsontype.Type{
+	"double":     bsontype.Double,
+	"number":     bsontype.Double,
+	"string":     bsontype.String,
+	"object":     bsontype.EmbeddedDocument,
+	"array":      bsontype.Array,
+	"binData":    bsontype.Binary,
+	"objectId":   bsontype.ObjectID,
+	"bool":       bsontype.Boolean,
+	"boolean":    bsontype.Boolean,
+	"date":       bsontype.DateTime,
+	"regex":      bsontype.Regex,
+	"javascript": bsontype.JavaScript,
+	"int":        bsontype.Int32,
+	"integer":    bsontype.Int64,
+	"timestamp":  bsontype.Timestamp,
+	"long":       bsontype.Int64,
+	"decimal":    bsontype.Decimal128,
+}
+
+// fromSchema converts a $jsonSchema document into a shape
+func fromSchema(schema bson.Raw) (*shape, error) {
+	s := &shape{}
+	var names []string
+	for _, key := range []string{"bsonType", "type"} {
+		value, err := schema.LookupErr(key)
+		if err != nil {
+			continue
+		}
+		if name, ok := value.StringValueOK(); ok {
+			names = append(names, name)
+		} else if array, ok := value.ArrayOK(); ok {
+			values, _ := array.Values()
+			for _, item := range values {
+				if name, ok := item.StringValueOK(); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	properties, hasProperties := schema.Lookup("properties").DocumentOK()
+	if len(names) == 0 && hasProperties {
+		names = []string{"object"}
+	}
+	for _, name := range names {
+		if name == "null" {
+			s.nullable = true
+			continue
+		}
+		t, ok := schemaTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %q", name)
+		}
+		s.addType(t)
+	}
+
+	if hasProperties {
+		required := map[string]bool{}
+		if array, ok := schema.Lookup("required").ArrayOK(); ok {
+			values, _ := array.Values()
+			for _, item := range values {
+				if name, ok := item.StringValueOK(); ok {
+					required[name] = true
+				}
+			}
+		}
+		elements, _ := properties.Elements()
+		for _, element := range elements {
+			document, ok := element.Value().DocumentOK()
+			if !ok {
+				return nil, fmt.Errorf("property %q: schema is not a document", element.Key())
+			}
+			field, err := fromSchema(document)
+			if err != nil {
+				return nil, fmt.Errorf("property %q: %w", element.Key(), err)
+			}
+			s.fields = append(s.fields, &property{key: element.Key(), optional: !required[element.Key()], shape: field})
+		}
+	}
+	if items, ok := schema.Lookup("items").DocumentOK(); ok {
+		elem, err := fromSchema(items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		s.elem = elem
+	}
+	return s, nil
+}
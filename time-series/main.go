@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Source is the metaField of the time series collection. The server buckets
+// measurements sharing a source together, so it should only hold values that
+// rarely change for a series.
+type Source struct {
+	Episode string `bson:"episode"`
+	Country string `bson:"country"`
+}
+
+// Plays is one measurement: how often an episode was played from a country
+// in the five minutes ending at Timestamp
+type Plays struct {
+	Timestamp time.Time `bson:"ts"`
+	Source    Source    `bson:"source"`
+	Count     int       `bson:"count"`
+	// Completed counts the plays that reached the end of the episode
+	Completed int `bson:"completed"`
+}
+
+// measurements fakes a day of play counts for each episode and country, busier
+// in the evening, ending at end
+func measurements(end time.Time) []interface{} {
+	random := rand.New(rand.NewSource(1))
+	var plays []interface{}
+	for _, episode := range []string{"GraphQL for Everyone", "Progressive Web Application Development"} {
+		for _, country := range []string{"US", "DE"} {
+			for t := end.Add(-24 * time.Hour); t.Before(end); t = t.Add(5 * time.Minute) {
+				count := 5 + random.Intn(10)
+				if hour := t.Hour(); hour >= 18 && hour < 23 {
+					count *= 3
+				}
+				plays = append(plays, Plays{
+					Timestamp: t,
+					Source:    Source{Episode: episode, Country: country},
+					Count:     count,
+					Completed: count * (40 + random.Intn(40)) / 100,
+				})
+			}
+		}
+	}
+	return plays
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	// Create The Time Series Collection
+	// timeField and metaField cannot change after creation; granularity can
+	// only be made coarser, so start with the interval measurements arrive at
+	database := client.Database("quickstart")
+	playsCollection := database.Collection("timeseries_plays")
+	if err = playsCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop timeseries_plays: %w", err)
+	}
+	timeSeries := options.TimeSeries().
+		SetTimeField("ts").
+		SetMetaField("source").
+		SetGranularity("minutes")
+	err = database.CreateCollection(ctx, "timeseries_plays", options.CreateCollection().
+		SetTimeSeriesOptions(timeSeries).
+		SetExpireAfterSeconds(90*24*60*60))
+	if err != nil {
+		return fmt.Errorf("create timeseries_plays (needs MongoDB 5.0): %w", err)
+	}
+	specs, err := database.ListCollectionSpecifications(ctx, bson.D{{"name", "timeseries_plays"}})
+	if err != nil {
+		return fmt.Errorf("list timeseries_plays: %w", err)
+	}
+	fmt.Printf("Created %s collection with %v\n", specs[0].Type, specs[0].Options.Lookup("timeseries"))
+
+	// Insert Measurements
+	end := time.Now().UTC().Truncate(time.Hour)
+	result, err := playsCollection.InsertMany(ctx, measurements(end))
+	if err != nil {
+		return fmt.Errorf("insert into timeseries_plays: %w", err)
+	}
+	fmt.Printf("Inserted %v measurements\n", len(result.InsertedIDs))
+
+	// Sum Plays Per Episode And Hour
+	// filtering on the metaField and timeField lets the server skip whole
+	// buckets before unpacking them
+	matchStage := bson.D{{"$match", bson.D{
+		{"source.episode", "GraphQL for Everyone"},
+		{"ts", bson.D{{"$gte", end.Add(-6 * time.Hour)}}},
+	}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", bson.D{{"$dateTrunc", bson.D{{"date", "$ts"}, {"unit", "hour"}}}}},
+		{"plays", bson.D{{"$sum", "$count"}}},
+		{"completed", bson.D{{"$sum", "$completed"}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"_id", 1}}}}
+	cursor, err := playsCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage, sortStage})
+	if err != nil {
+		return fmt.Errorf("aggregate hourly plays: %w", err)
+	}
+	var hours []struct {
+		Hour      time.Time `bson:"_id"`
+		Plays     int       `bson:"plays"`
+		Completed int       `bson:"completed"`
+	}
+	if err = cursor.All(ctx, &hours); err != nil {
+		return fmt.Errorf("decode hourly plays: %w", err)
+	}
+	fmt.Println("Plays of GraphQL for Everyone per hour:")
+	for _, hour := range hours {
+		fmt.Printf("  %s  %4d plays, %3.0f%% completed\n",
+			hour.Hour.Format("15:04"), hour.Plays, 100*float64(hour.Completed)/float64(hour.Plays))
+	}
+
+	// Moving Average Over A Time Window
+	// $setWindowFields with a range window averages the measurements of the
+	// last hour per series, however many there are
+	matchStage = bson.D{{"$match", bson.D{
+		{"source.country", "US"},
+		{"ts", bson.D{{"$gte", end.Add(-3 * time.Hour)}}},
+	}}}
+	windowStage := bson.D{{"$setWindowFields", bson.D{
+		{"partitionBy", "$source.episode"},
+		{"sortBy", bson.D{{"ts", 1}}},
+		{"output", bson.D{
+			{"hourlyAverage", bson.D{
+				{"$avg", "$count"},
+				{"window", bson.D{{"range", bson.A{-1, "current"}}, {"unit", "hour"}}},
+			}},
+		}},
+	}}}
+	// keep one row per series and half hour to keep the output short
+	sampleStage := bson.D{{"$match", bson.D{{"$expr", bson.D{
+		{"$eq", bson.A{bson.D{{"$mod", bson.A{bson.D{{"$minute", "$ts"}}, 30}}}, 0}},
+	}}}}}
+	sortStage = bson.D{{"$sort", bson.D{{"source.episode", 1}, {"ts", 1}}}}
+	cursor, err = playsCollection.Aggregate(ctx, mongo.Pipeline{matchStage, windowStage, sampleStage, sortStage})
+	if err != nil {
+		return fmt.Errorf("aggregate moving average: %w", err)
+	}
+	var windows []struct {
+		Plays   `bson:",inline"`
+		Average float64 `bson:"hourlyAverage"`
+	}
+	if err = cursor.All(ctx, &windows); err != nil {
+		return fmt.Errorf("decode moving average: %w", err)
+	}
+	fmt.Println("Plays per five minutes in the US, averaged over the last hour:")
+	for _, window := range windows {
+		fmt.Printf("  %s  %-40s %3d now, %5.1f average\n",
+			window.Timestamp.Format("15:04"), window.Source.Episode, window.Count, window.Average)
+	}
+	return nil
+}
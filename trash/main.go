@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Retention is how long deleted documents stay restorable
+const Retention = 30 * 24 * time.Hour
+
+// Item represents the schema for the "trash" collection
+type Item struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Collection string             `bson:"collection"`
+	DocumentID interface{}        `bson:"document_id"`
+	Document   bson.Raw           `bson:"document"`
+	DeletedAt  time.Time          `bson:"deleted_at"`
+}
+
+// Trash moves documents between their collection and the "trash" collection
+type Trash struct {
+	Database *mongo.Database
+}
+
+func (t *Trash) items() *mongo.Collection {
+	return t.Database.Collection("trash")
+}
+
+// EnsureIndexes creates the TTL index that purges items after Retention
+func (t *Trash) EnsureIndexes(ctx context.Context) error {
+	_, err := t.items().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"deleted_at", 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(Retention.Seconds())),
+		},
+		{Keys: bson.D{{"collection", 1}, {"document_id", 1}}},
+	})
+	return err
+}
+
+func (t *Trash) transaction(ctx context.Context, fn func(sessionContext mongo.SessionContext) error) error {
+	session, err := t.Database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext)
+	})
+	return err
+}
+
+// Delete moves every document of the collection matching filter into the
+// trash. The copy and the delete commit together, so a document is never
+// lost or in both places.
+func (t *Trash) Delete(ctx context.Context, collection string, filter interface{}) (int, error) {
+	var moved int
+	err := t.transaction(ctx, func(sessionContext mongo.SessionContext) error {
+		moved = 0
+		source := t.Database.Collection(collection)
+		cursor, err := source.Find(sessionContext, filter)
+		if err != nil {
+			return err
+		}
+		var documents []bson.Raw
+		if err = cursor.All(sessionContext, &documents); err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+		now := time.Now().UTC()
+		items := make([]interface{}, len(documents))
+		ids := make(bson.A, len(documents))
+		for i, document := range documents {
+			ids[i] = document.Lookup("_id")
+			items[i] = Item{Collection: collection, DocumentID: ids[i], Document: document, DeletedAt: now}
+		}
+		if _, err = t.items().InsertMany(sessionContext, items); err != nil {
+			return err
+		}
+		result, err := source.DeleteMany(sessionContext, bson.D{{"_id", bson.D{{"$in", ids}}}})
+		if err != nil {
+			return err
+		}
+		moved = int(result.DeletedCount)
+		return nil
+	})
+	return moved, err
+}
+
+// Restore moves a trashed document back into its original collection. It
+// fails if a document with the same _id has been created there since.
+func (t *Trash) Restore(ctx context.Context, id primitive.ObjectID) (Item, error) {
+	var item Item
+	err := t.transaction(ctx, func(sessionContext mongo.SessionContext) error {
+		if err := t.items().FindOneAndDelete(sessionContext, bson.D{{"_id", id}}).Decode(&item); err != nil {
+			return err
+		}
+		_, err := t.Database.Collection(item.Collection).InsertOne(sessionContext, item.Document)
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%s already contains a document with _id %v", item.Collection, item.DocumentID)
+		}
+		return err
+	})
+	return item, err
+}
+
+// List returns the most recently deleted items, optionally for one collection
+func (t *Trash) List(ctx context.Context, collection string) ([]Item, error) {
+	filter := bson.D{}
+	if collection != "" {
+		filter = append(filter, bson.E{"collection", collection})
+	}
+	opts := options.Find().SetSort(bson.D{{"deleted_at", -1}}).SetLimit(100)
+	cursor, err := t.items().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+var (
+	collection = flag.String("collection", "podcasts", "collection to delete from or list")
+	filterJSON = flag.String("filter", "", "Extended JSON filter of documents to delete")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] delete|list|restore <trash id>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	trash := &Trash{Database: client.Database("quickstart")}
+	if err = trash.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	switch flag.Arg(0) {
+	case "delete":
+		if *filterJSON == "" {
+			return errors.New("delete requires -filter, use '{}' to trash the whole collection")
+		}
+		var filter bson.D
+		if err = bson.UnmarshalExtJSON([]byte(*filterJSON), false, &filter); err != nil {
+			return fmt.Errorf("invalid -filter: %v", err)
+		}
+		moved, err := trash.Delete(ctx, *collection, filter)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Moved %v document(s) from %s to the trash\n", moved, *collection)
+	case "restore":
+		id, err := primitive.ObjectIDFromHex(flag.Arg(1))
+		if err != nil {
+			return errors.New("restore requires the id of a trash item")
+		}
+		item, err := trash.Restore(ctx, id)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("trash item %s not found, it may have expired", id.Hex())
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %v into %s\n", item.DocumentID, item.Collection)
+	case "list":
+		items, err := trash.List(ctx, *collection)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TRASH ID\tCOLLECTION\tDOCUMENT\tDELETED\tEXPIRES")
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\n", item.ID.Hex(), item.Collection, item.DocumentID,
+				item.DeletedAt.Format(time.RFC3339), item.DeletedAt.Add(Retention).Format(time.RFC3339))
+		}
+		w.Flush()
+	default:
+		return shutdown.ErrUsage
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/deadline"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/querycache"
+	"github.com/mongodb-developer/golang-quickstart/refdata"
+	"github.com/mongodb-developer/golang-quickstart/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrendingPodcast represents an aggregation result-set of a trending score and its podcast
+type TrendingPodcast struct {
+	ID        primitive.ObjectID `bson:"_id" json:"podcast"`
+	Title     string             `bson:"title" json:"title"`
+	Author    string             `bson:"author" json:"author"`
+	Category  string             `bson:"category,omitempty" json:"category,omitempty"`
+	Language  string             `bson:"language,omitempty" json:"language,omitempty"`
+	Score     float64            `bson:"score" json:"score"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	CategoryName string `bson:"-" json:"category_name,omitempty"`
+	LanguageName string `bson:"-" json:"language_name,omitempty"`
+}
+
+// state represents the single document in "trending_state" remembering
+// how far the listens collection has been folded into the scores
+type state struct {
+	ProcessedUntil time.Time `bson:"processed_until"`
+}
+
+// Scorer maintains exponentially decayed play counts in the "trending" collection
+type Scorer struct {
+	Database *mongo.Database
+	HalfLife time.Duration
+	Lag      time.Duration
+}
+
+// lambda is the decay rate per second for the configured half-life
+func (s *Scorer) lambda() float64 {
+	return math.Ln2 / s.HalfLife.Seconds()
+}
+
+// decayPipeline ages every stored score to now:
+// score * e^(-lambda * seconds since updated_at)
+func (s *Scorer) decayPipeline(now time.Time) mongo.Pipeline {
+	elapsed := bson.D{{"$divide", bson.A{bson.D{{"$subtract", bson.A{now, "$updated_at"}}}, 1000}}}
+	return mongo.Pipeline{
+		{{"$set", bson.D{
+			{"score", bson.D{{"$multiply", bson.A{
+				"$score",
+				bson.D{{"$exp", bson.D{{"$multiply", bson.A{-s.lambda(), elapsed}}}}},
+			}}}},
+			{"updated_at", now},
+		}}},
+	}
+}
+
+// playsPipeline weighs each new play by its own age and adds the result
+// to the already decayed score of its podcast. Each score remembers in
+// plays_until how far its plays were added, and only later plays are added
+// to it, so running the pipeline again for the same plays adds nothing.
+func (s *Scorer) playsPipeline(from, now time.Time) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"listened_at", bson.D{{"$gt", from}, {"$lte", now}}}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{
+		{"from", "trending"},
+		{"localField", "podcast"},
+		{"foreignField", "_id"},
+		{"as", "scored"},
+	}}}
+	newerStage := bson.D{{"$match", bson.D{{"$expr", bson.D{{"$gt", bson.A{
+		"$listened_at",
+		bson.D{{"$ifNull", bson.A{bson.D{{"$arrayElemAt", bson.A{"$scored.plays_until", 0}}}, from}}},
+	}}}}}}}
+	age := bson.D{{"$divide", bson.A{bson.D{{"$subtract", bson.A{now, "$listened_at"}}}, 1000}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$podcast"},
+		{"score", bson.D{{"$sum", bson.D{{"$exp", bson.D{{"$multiply", bson.A{-s.lambda(), age}}}}}}}},
+	}}}
+	setStage := bson.D{{"$set", bson.D{{"updated_at", now}, {"plays_until", now}}}}
+	mergeStage := bson.D{{"$merge", bson.D{
+		{"into", "trending"},
+		{"on", "_id"},
+		{"whenMatched", bson.A{
+			bson.D{{"$set", bson.D{
+				{"score", bson.D{{"$add", bson.A{"$score", "$$new.score"}}}},
+				{"updated_at", "$$new.updated_at"},
+				{"plays_until", "$$new.plays_until"},
+			}}},
+		}},
+		{"whenNotMatched", "insert"},
+	}}}
+	return mongo.Pipeline{matchStage, lookupStage, newerStage, groupStage, setStage, mergeStage}
+}
+
+// Update runs one scheduled pass: decay, fold in new plays, prune negligible
+// scores and advance the watermark. $merge cannot run in a transaction, so
+// the steps are separate writes, each safe to repeat: decaying to the same
+// now changes nothing, plays already in a score are skipped by its
+// plays_until, and a pass that fails before moving the watermark is simply
+// run again from the old one.
+func (s *Scorer) Update(ctx context.Context) error {
+	trending := s.Database.Collection("trending")
+	states := s.Database.Collection("trending_state")
+	now := time.Now().UTC().Add(-s.Lag).Truncate(time.Millisecond)
+
+	var last state
+	err := states.FindOne(ctx, bson.M{"_id": "listens"}).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if last.ProcessedUntil.IsZero() {
+		last.ProcessedUntil = now.Add(-4 * s.HalfLife)
+	}
+
+	if _, err = trending.UpdateMany(ctx, bson.M{}, s.decayPipeline(now)); err != nil {
+		return err
+	}
+	cursor, err := s.Database.Collection("listens").Aggregate(ctx, s.playsPipeline(last.ProcessedUntil, now))
+	if err != nil {
+		return err
+	}
+	if err = cursor.Close(ctx); err != nil {
+		return err
+	}
+	// a score still holding plays past the watermark must stay, or its
+	// plays_until would be lost and a rerun would add them again
+	prune := bson.M{"score": bson.M{"$lt": 0.01}, "plays_until": bson.M{"$not": bson.M{"$gt": last.ProcessedUntil}}}
+	if _, err = trending.DeleteMany(ctx, prune); err != nil {
+		return err
+	}
+	_, err = states.UpdateOne(ctx,
+		bson.M{"_id": "listens"},
+		bson.M{"$set": bson.M{"processed_until": now}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+type server struct {
+	// chart caches the ranked chart for a few seconds, since every visitor
+	// asks for it and the scores only change every interval
+	chart   *querycache.Store[TrendingPodcast]
+	refdata *refdata.Store
+}
+
+// top serves the ranked chart. The {score: -1} index lets the $sort and
+// $limit run without scanning the whole collection.
+func (s *server) top(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	sortStage := bson.D{{"$sort", bson.D{{"score", -1}}}}
+	limitStage := bson.D{{"$limit", limit}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "podcasts"}, {"localField", "_id"}, {"foreignField", "_id"}, {"as", "podcast"}}}}
+	unwindStage := bson.D{{"$unwind", "$podcast"}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"title", "$podcast.title"},
+		{"author", "$podcast.author"},
+		{"category", "$podcast.category"},
+		{"language", "$podcast.language"},
+		{"score", 1},
+		{"updated_at", 1},
+	}}}
+	chart, err := s.chart.Aggregate(r.Context(), mongo.Pipeline{sortStage, limitStage, lookupStage, unwindStage, projectStage}, deadline.Aggregate(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range chart {
+		if category, ok := s.refdata.Category(chart[i].Category); ok {
+			chart[i].CategoryName = category.Name
+		}
+		if language, ok := s.refdata.Language(chart[i].Language); ok {
+			chart[i].LanguageName = language.Name
+		}
+	}
+	writeJSON(w, chart)
+}
+
+func (s *server) categories(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.refdata.Categories())
+}
+
+func (s *server) languages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.refdata.Languages())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+var (
+	halfLife = flag.Duration("half-life", 24*time.Hour, "time for a play's weight to halve")
+	interval = flag.Duration("interval", 5*time.Minute, "time between score updates")
+	addr     = flag.String("addr", ":8080", "HTTP listen address")
+	cacheTTL = flag.Duration("cache", 10*time.Second, "time a chart is served from memory before it is read again")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	if _, err = database.Collection("trending").Indexes().CreateOne(connectCtx, mongo.IndexModel{Keys: bson.D{{"score", -1}}}); err != nil {
+		return err
+	}
+	if _, err = database.Collection("listens").Indexes().CreateOne(connectCtx, mongo.IndexModel{Keys: bson.D{{"listened_at", 1}}}); err != nil {
+		return err
+	}
+
+	scorer := &Scorer{Database: database, HalfLife: *halfLife, Lag: 5 * time.Second}
+	go func() {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for ; ctx.Err() == nil; <-ticker.C {
+			updateCtx, cancel := context.WithTimeout(ctx, *interval)
+			if err := scorer.Update(updateCtx); err != nil {
+				log.Printf("update trending scores: %v", err)
+			}
+			cancel()
+		}
+	}()
+
+	reference := refdata.New(database)
+	if err = reference.Load(connectCtx); err != nil {
+		return err
+	}
+	go reference.Run(ctx, 10*time.Minute)
+
+	chart := querycache.New[TrendingPodcast](repository.New[TrendingPodcast](database.Collection("trending")), *cacheTTL)
+	expvar.Publish("trending_cache", expvar.Func(func() interface{} { return chart.Stats() }))
+	s := &server{chart: chart, refdata: reference}
+	routes := openapi.New("Quickstart trending", "1.0.0")
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/trending", Summary: "Podcasts ranked by decayed play count",
+		Query:    []openapi.Param{{Name: "limit", Type: "integer", Description: "1-100, defaults to 20"}},
+		Response: []TrendingPodcast{},
+	}, s.top)
+	routes.Handle(openapi.Route{Method: "GET", Path: "/categories", Summary: "List categories", Response: []refdata.Category{}}, s.categories)
+	routes.Handle(openapi.Route{Method: "GET", Path: "/languages", Summary: "List languages", Response: []refdata.Language{}}, s.languages)
+	routes.Handle(openapi.Route{Method: "GET", Path: "/debug/vars", Summary: "Chart cache hits and misses, among other expvar variables"}, expvar.Handler().ServeHTTP)
+	log.Printf("serving trending chart on %s", *addr)
+	server := &http.Server{Addr: *addr, Handler: http.TimeoutHandler(routes, 5*time.Second, "request timed out")}
+	if err = shutdown.Serve(ctx, server); err != nil {
+		return err
+	}
+	return nil
+}
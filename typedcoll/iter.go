@@ -0,0 +1,68 @@
+package typedcoll
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrConsumed is yielded when the sequence of Iter is ranged over again
+var ErrConsumed = errors.New("typedcoll: cursor already consumed")
+
+// Iter ranges over the documents of cursor decoded into T, one batch at a
+// time rather than all at once like Collection.Find:
+//
+//	for episode, err := range typedcoll.Iter[Episode](ctx, cursor) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The cursor is closed when the loop ends, including by break or return. An
+// error is yielded once, with the zero T, and ends the loop. A cursor cannot
+// be rewound, so ranging over the sequence a second time yields only
+// ErrConsumed; use Find for a sequence that can be ranged over again.
+func Iter[T any](ctx context.Context, cursor *mongo.Cursor) iter.Seq2[T, error] {
+	ranged := false
+	return func(yield func(T, error) bool) {
+		if ranged {
+			var zero T
+			yield(zero, ErrConsumed)
+			return
+		}
+		ranged = true
+		defer cursor.Close(context.Background())
+		for cursor.Next(ctx) {
+			var document T
+			if err := cursor.Decode(&document); err != nil {
+				yield(document, err)
+				return
+			}
+			if !yield(document, nil) {
+				return
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// Find runs a find on collection each time the sequence is ranged over and
+// yields the results like Iter
+func Find[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor, err := collection.Find(ctx, orEmpty(filter), opts...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		Iter[T](ctx, cursor)(yield)
+	}
+}
@@ -0,0 +1,96 @@
+package typedcoll
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type episode struct {
+	Title string `bson:"title"`
+}
+
+func cursor(t *testing.T, titles ...string) *mongo.Cursor {
+	t.Helper()
+	var documents []interface{}
+	for _, title := range titles {
+		documents = append(documents, bson.D{{"title", title}})
+	}
+	cursor, err := mongo.NewCursorFromDocuments(documents, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cursor
+}
+
+func TestIter(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		titles []string
+		stop   int
+		want   []string
+	}{
+		{"empty", nil, -1, nil},
+		{"all", []string{"a", "b", "c"}, -1, []string{"a", "b", "c"}},
+		{"break", []string{"a", "b", "c"}, 2, []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for episode, err := range Iter[episode](ctx, cursor(t, test.titles...)) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, episode.Title)
+				if len(got) == test.stop {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ranged over %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIterTwice(t *testing.T) {
+	ctx := context.Background()
+	episodes := Iter[episode](ctx, cursor(t, "a", "b"))
+	for _, err := range episodes {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var errs []error
+	for document, err := range episodes {
+		if document != (episode{}) {
+			t.Errorf("second range yielded %+v", document)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrConsumed) {
+		t.Errorf("second range yielded %v, want ErrConsumed once", errs)
+	}
+}
+
+func TestIterDecodeError(t *testing.T) {
+	c, err := mongo.NewCursorFromDocuments([]interface{}{bson.D{{"title", 5}}, bson.D{{"title", "b"}}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs int
+	for _, err := range Iter[episode](context.Background(), c) {
+		if err == nil {
+			t.Error("yielded a document that does not decode")
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("yielded %d errors, want the decode error once", errs)
+	}
+}
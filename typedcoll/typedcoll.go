@@ -0,0 +1,89 @@
+// Package typedcoll puts a document type on a collection. Collection[T] keeps
+// the driver's methods, filters, options and errors, but Find and FindOne
+// return T instead of a cursor or SingleResult to decode, so a result can
+// no longer be decoded into the wrong type or into interface{}:
+//
+//	episodes := typedcoll.New[Episode](database.Collection("episodes"))
+//	long, err := episodes.Find(ctx, bson.D{{"duration", bson.D{{"$gt", 30}}}})
+//
+// Aggregation results rarely have the collection's shape, so Aggregate takes
+// the result type separately. The Find and Iter functions stream results
+// into a range loop instead, which needs Go 1.23. For domain code that
+// should depend on an interface with its own not-found error, use the
+// repository package.
+package typedcoll
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection holds documents of type T. The embedded *mongo.Collection
+// still offers every untyped method, such as Indexes or DeleteMany.
+type Collection[T any] struct {
+	*mongo.Collection
+}
+
+// New returns collection typed as holding T
+func New[T any](collection *mongo.Collection) Collection[T] {
+	return Collection[T]{collection}
+}
+
+// FindOne returns the first document matching filter. Like the driver it
+// returns mongo.ErrNoDocuments when there is none.
+func (c Collection[T]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (T, error) {
+	var document T
+	err := c.Collection.FindOne(ctx, orEmpty(filter), opts...).Decode(&document)
+	return document, err
+}
+
+// Find returns every document matching filter, or an empty slice
+func (c Collection[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := c.Collection.Find(ctx, orEmpty(filter), opts...)
+	if err != nil {
+		return nil, err
+	}
+	documents := []T{}
+	if err = cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// InsertOne stores document, which only compiles when it is a T
+func (c Collection[T]) InsertOne(ctx context.Context, document T, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return c.Collection.InsertOne(ctx, document, opts...)
+}
+
+// UpdateByID applies update to the document with the given _id and returns
+// that document as it is after the update, or mongo.ErrNoDocuments
+func (c Collection[T]) UpdateByID(ctx context.Context, id interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) (T, error) {
+	opts = append([]*options.FindOneAndUpdateOptions{options.FindOneAndUpdate().SetReturnDocument(options.After)}, opts...)
+	var document T
+	err := c.Collection.FindOneAndUpdate(ctx, bson.D{{"_id", id}}, update, opts...).Decode(&document)
+	return document, err
+}
+
+// Aggregate runs pipeline on c and decodes every result into R. It is a
+// function because Go methods cannot have type parameters of their own.
+func Aggregate[R, T any](ctx context.Context, c Collection[T], pipeline interface{}, opts ...*options.AggregateOptions) ([]R, error) {
+	cursor, err := c.Collection.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	results := []R{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func orEmpty(filter interface{}) interface{} {
+	if filter == nil {
+		return bson.D{}
+	}
+	return filter
+}
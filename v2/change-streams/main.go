@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples/changestreams"
+)
+
+var (
+	name       = flag.String("stream", "long-episodes", "name the resume token is saved under")
+	crashAfter = flag.Int("crash-after", 0, "exit abruptly after handling this many events, to demonstrate recovery")
+	reset      = flag.Bool("reset", false, "forget the saved resume token and only watch new events")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	// Ctrl+C cancels ctx, which ends the watch
+	shutdown.Main(func(ctx context.Context, down *shutdown.Shutdown) error {
+		deps, err := examples.Connect(ctx, down)
+		if err != nil {
+			return err
+		}
+		return changestreams.Watch(ctx, deps, changestreams.Options{Stream: *name, CrashAfter: *crashAfter, Reset: *reset})
+	})
+}
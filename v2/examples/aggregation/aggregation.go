@@ -0,0 +1,116 @@
+// Package aggregation runs $match, $group, $lookup and $unwind pipelines
+// on the episodes collection
+package aggregation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     bson.ObjectID `bson:"_id,omitempty"`
+	Title  string        `bson:"title,omitempty"`
+	Author string        `bson:"author,omitempty"`
+	Tags   []string      `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          bson.ObjectID `bson:"_id,omitempty"`
+	Podcast     bson.ObjectID `bson:"podcast,omitempty"`
+	Title       string        `bson:"title,omitempty"`
+	Description string        `bson:"description,omitempty"`
+	Duration    int32         `bson:"duration,omitempty"`
+}
+
+// PodcastEpisode represents an aggregation result-set for two collections
+type PodcastEpisode struct {
+	ID          bson.ObjectID `bson:"_id,omitempty"`
+	Podcast     Podcast       `bson:"podcast,omitempty"`
+	Title       string        `bson:"title,omitempty"`
+	Description string        `bson:"description,omitempty"`
+	Duration    int32         `bson:"duration,omitempty"`
+}
+
+// totalDurationPipeline sums the duration of every episode of one podcast
+func totalDurationPipeline(podcast bson.ObjectID) mongo.Pipeline {
+	matchStage := bson.D{{"$match", bson.D{{"podcast", podcast}}}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", "$podcast"}, {"total", bson.D{{"$sum", "$duration"}}}}}}
+	return mongo.Pipeline{matchStage, groupStage}
+}
+
+// episodesWithPodcastPipeline embeds each episode's podcast document from
+// the podcasts collection
+func episodesWithPodcastPipeline(podcasts string) mongo.Pipeline {
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", podcasts}, {"localField", "podcast"}, {"foreignField", "_id"}, {"as", "podcast"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$podcast"}, {"preserveNullAndEmptyArrays", false}}}}
+	return mongo.Pipeline{lookupStage, unwindStage}
+}
+
+// PodcastTotal is a result of totalDurationPipeline
+type PodcastTotal struct {
+	Podcast bson.ObjectID `bson:"_id"`
+	Total   int64         `bson:"total"`
+}
+
+// TotalDuration returns the total duration of the episodes of podcast, as
+// one PodcastTotal or none when it has no episodes
+func TotalDuration(ctx context.Context, episodes *mongo.Collection, podcast bson.ObjectID) ([]PodcastTotal, error) {
+	return aggregate[PodcastTotal](ctx, episodes, totalDurationPipeline(podcast))
+}
+
+// EpisodesWithPodcast returns every episode that has a podcast, with the
+// podcast embedded. $lookup joins within one database, so podcasts must be
+// in the database of episodes.
+func EpisodesWithPodcast(ctx context.Context, episodes, podcasts *mongo.Collection) ([]PodcastEpisode, error) {
+	return aggregate[PodcastEpisode](ctx, episodes, episodesWithPodcastPipeline(podcasts.Name()))
+}
+
+// aggregate runs pipeline on collection and decodes every result into an R.
+// It stands in for typedcoll.Aggregate, which is written against the v1
+// driver.
+func aggregate[R any](ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) ([]R, error) {
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []R{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Run prints the total duration of one podcast and every episode with its
+// podcast embedded, decoded into maps and into structs
+func Run(ctx context.Context, deps examples.Deps) error {
+	episodes := deps.Collection("episodes")
+	podcasts := deps.Collection("podcasts")
+
+	id, _ := bson.ObjectIDFromHex("5e3b37e51c9d4400004117e6")
+
+	// The Result Type Is Named At The Call, Not Hidden In A Pointer
+	showsWithInfo, err := TotalDuration(ctx, episodes, id)
+	if err != nil {
+		return fmt.Errorf("total duration of podcast %s: %w", id.Hex(), err)
+	}
+	deps.Println(showsWithInfo)
+
+	showsLoaded, err := aggregate[bson.M](ctx, episodes, episodesWithPodcastPipeline(podcasts.Name()))
+	if err != nil {
+		return fmt.Errorf("episodes with their podcast: %w", err)
+	}
+	deps.Println(showsLoaded)
+
+	showsLoadedStruct, err := EpisodesWithPodcast(ctx, episodes, podcasts)
+	if err != nil {
+		return fmt.Errorf("episodes with their podcast as structs: %w", err)
+	}
+	deps.Println(showsLoadedStruct)
+	return nil
+}
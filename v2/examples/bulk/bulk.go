@@ -0,0 +1,98 @@
+// Package bulk mixes inserts, updates, replaces and deletes in BulkWrite
+// calls and handles partial failures
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func printResult(deps examples.Deps, label string, result *mongo.BulkWriteResult) {
+	deps.Printf("%s: inserted %d, matched %d, modified %d, upserted %d, deleted %d\n",
+		label, result.InsertedCount, result.MatchedCount, result.ModifiedCount, result.UpsertedCount, result.DeletedCount)
+}
+
+// Run executes an ordered bulk write, then the same duplicate insert ordered
+// and unordered to show how each reports a failure
+func Run(ctx context.Context, deps examples.Deps) error {
+	episodesCollection := deps.Collection("bulk_episodes")
+	if err := episodesCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop bulk_episodes: %w", err)
+	}
+	podcast := bson.NewObjectID()
+	first, second := bson.NewObjectID(), bson.NewObjectID()
+
+	// Mix Inserts, Updates, Replaces And Deletes In One Round Trip
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.D{
+			{"_id", first},
+			{"podcast", podcast},
+			{"title", "GraphQL for API Development"},
+			{"duration", 25},
+		}),
+		mongo.NewInsertOneModel().SetDocument(bson.D{
+			{"_id", second},
+			{"podcast", podcast},
+			{"title", "Progressive Web Application Development"},
+			{"duration", 32},
+		}),
+		mongo.NewUpdateManyModel().
+			SetFilter(bson.D{{"podcast", podcast}}).
+			SetUpdate(bson.D{{"$set", bson.D{{"published", true}}}}),
+		mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{"_id", first}}).
+			SetReplacement(bson.D{
+				{"podcast", podcast},
+				{"title", "GraphQL for API Development (Remastered)"},
+				{"duration", 27},
+			}),
+		mongo.NewDeleteOneModel().SetFilter(bson.D{{"_id", second}}),
+	}
+	// Ordered execution (the default) runs the models one after another, so
+	// the update and replace see the documents inserted before them
+	result, err := episodesCollection.BulkWrite(ctx, models)
+	if err != nil {
+		return fmt.Errorf("ordered bulk write to bulk_episodes: %w", err)
+	}
+	printResult(deps, "Ordered bulk write", result)
+
+	// Unordered Execution Keeps Going After A Failure
+	duplicate := bson.NewObjectID()
+	models = []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.D{{"_id", duplicate}, {"title", "First"}}),
+		mongo.NewInsertOneModel().SetDocument(bson.D{{"_id", duplicate}, {"title", "Duplicate"}}),
+		mongo.NewInsertOneModel().SetDocument(bson.D{{"title", "Third"}}),
+	}
+	for _, ordered := range []bool{true, false} {
+		if _, err = episodesCollection.DeleteOne(ctx, bson.D{{"_id", duplicate}}); err != nil {
+			return fmt.Errorf("delete duplicate from bulk_episodes: %w", err)
+		}
+		result, err := episodesCollection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+		label := fmt.Sprintf("Bulk write with ordered=%v", ordered)
+
+		// Inspect Partial Failures
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) {
+			// the result still counts the writes that succeeded
+			printResult(deps, label, result)
+			for _, writeErr := range bulkErr.WriteErrors {
+				deps.Printf("  model %d failed with code %d: %s\n", writeErr.Index, writeErr.Code, writeErr.Message)
+			}
+			if bulkErr.WriteConcernError != nil {
+				deps.Printf("  write concern error: %s\n", bulkErr.WriteConcernError.Message)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("bulk write to bulk_episodes with ordered=%v: %w", ordered, err)
+		}
+		printResult(deps, label, result)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package changestreams reacts to inserts of long episodes with a change
+// stream that resumes after the last handled event
+package changestreams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ResumeToken represents the schema for the "resume_tokens" collection, one
+// document per named stream holding the token of the last handled event
+type ResumeToken struct {
+	Stream    string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func loadResumeToken(ctx context.Context, tokens *mongo.Collection, stream string) (bson.Raw, error) {
+	var saved ResumeToken
+	err := tokens.FindOne(ctx, bson.D{{"_id", stream}}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return saved.Token, err
+}
+
+func saveResumeToken(ctx context.Context, tokens *mongo.Collection, stream string, token bson.Raw) error {
+	_, err := tokens.ReplaceOne(ctx, bson.D{{"_id", stream}},
+		ResumeToken{Stream: stream, Token: token, UpdatedAt: time.Now().UTC()},
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// iterateChangeStream prints each event and then persists its resume token,
+// so after a restart the stream picks up right after the last printed event.
+// With crashAfter > 0 the process exits abruptly after that many events.
+// It returns nil once routineCtx is canceled; Watch closes the stream.
+func iterateChangeStream(routineCtx context.Context, deps examples.Deps, stream *mongo.ChangeStream, tokens *mongo.Collection, name string, crashAfter int) error {
+	handled := 0
+	for stream.Next(routineCtx) {
+		var data bson.M
+		if err := stream.Decode(&data); err != nil {
+			return fmt.Errorf("decode change event: %w", err)
+		}
+		deps.Printf("%v\n", data)
+		if err := saveResumeToken(routineCtx, tokens, name, stream.ResumeToken()); err != nil {
+			if routineCtx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("save resume token of %s in %s: %w", name, tokens.Name(), err)
+		}
+		handled++
+		if crashAfter > 0 && handled == crashAfter {
+			deps.Printf("Simulating a crash after %d events, run again to resume\n", handled)
+			os.Exit(1)
+		}
+	}
+	if err := stream.Err(); err != nil && routineCtx.Err() == nil {
+		return fmt.Errorf("change stream: %w", err)
+	}
+	return nil
+}
+
+// Options configures Watch
+type Options struct {
+	// Stream is the name the resume token is saved under and defaults to
+	// "long-episodes"
+	Stream string
+	// CrashAfter > 0 exits the process abruptly after that many events, to
+	// demonstrate recovery
+	CrashAfter int
+	// Reset forgets the saved resume token and only watches new events
+	Reset bool
+}
+
+// Run watches with the default Options until ctx is canceled
+func Run(ctx context.Context, deps examples.Deps) error {
+	return Watch(ctx, deps, Options{})
+}
+
+// Watch prints every inserted episode longer than 30 minutes until ctx is
+// canceled, which ends the blocked Next call in iterateChangeStream
+func Watch(ctx context.Context, deps examples.Deps, opts Options) error {
+	if opts.Stream == "" {
+		opts.Stream = "long-episodes"
+	}
+
+	episodesCollection := deps.Collection("episodes")
+	tokensCollection := deps.Collection("resume_tokens")
+
+	var waitGroup sync.WaitGroup
+
+	matchPipeline := bson.D{
+		{
+			"$match", bson.D{
+				{"operationType", "insert"},
+				{"fullDocument.duration", bson.D{
+					{"$gt", 30},
+				}},
+			},
+		},
+	}
+
+	// Resume After The Last Handled Event
+	// events that happened while the process was down are delivered first,
+	// as long as they are still in the oplog
+	if opts.Reset {
+		if _, err := tokensCollection.DeleteOne(ctx, bson.D{{"_id", opts.Stream}}); err != nil {
+			return fmt.Errorf("delete resume token of %s: %w", opts.Stream, err)
+		}
+	}
+	token, err := loadResumeToken(ctx, tokensCollection, opts.Stream)
+	if err != nil {
+		return fmt.Errorf("load resume token of %s from %s: %w", opts.Stream, tokensCollection.Name(), err)
+	}
+	streamOptions := options.ChangeStream()
+	if token != nil {
+		deps.Printf("Resuming %s after %v\n", opts.Stream, token)
+		streamOptions.SetResumeAfter(token)
+	}
+
+	episodesStream, err := episodesCollection.Watch(ctx, mongo.Pipeline{matchPipeline}, streamOptions)
+	var serverErr mongo.ServerError
+	if token != nil && errors.As(err, &serverErr) && serverErr.HasErrorCode(286) {
+		// ChangeStreamHistoryLost: the oplog no longer reaches back to the
+		// token, so the missed events are gone and the stream starts over
+		deps.Println("Resume token is older than the oplog, watching from now")
+		episodesStream, err = episodesCollection.Watch(ctx, mongo.Pipeline{matchPipeline})
+	}
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", episodesCollection.Name(), err)
+	}
+	defer episodesStream.Close(context.Background())
+
+	var streamErr error
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		streamErr = iterateChangeStream(ctx, deps, episodesStream, tokensCollection, opts.Stream, opts.CrashAfter)
+	}()
+
+	waitGroup.Wait()
+	return streamErr
+}
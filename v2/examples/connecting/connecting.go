@@ -0,0 +1,25 @@
+// Package connecting checks the connection to a cluster
+package connecting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// Run pings the primary and prints the names of the databases
+func Run(ctx context.Context, deps examples.Deps) error {
+	err := deps.Client.Ping(ctx, readpref.Primary())
+	if err != nil {
+		return fmt.Errorf("ping primary: %w", err)
+	}
+	databases, err := deps.Client.ListDatabaseNames(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("list databases: %w", err)
+	}
+	deps.Println(databases)
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package deleting removes documents and drops collections
+package deleting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Cascaded counts what DeletePodcastCascade removed
+type Cascaded struct {
+	Podcasts, Episodes, Reviews int64
+}
+
+// Deleter is the transactional path of the v1-only cascade package: the
+// podcast, its episodes and its reviews go in one transaction. GridFS files
+// and the saga fallback for standalone servers are left out.
+type Deleter struct {
+	Database *mongo.Database
+	// Podcasts, Episodes and Reviews name the collections
+	Podcasts string
+	Episodes string
+	Reviews  string
+}
+
+// newDeleter returns a Deleter using the "podcasts", "episodes" and
+// "reviews" collections, like cascade.New
+func newDeleter(database *mongo.Database) *Deleter {
+	return &Deleter{Database: database, Podcasts: "podcasts", Episodes: "episodes", Reviews: "reviews"}
+}
+
+// DeletePodcastCascade deletes the podcast with the given _id together with
+// its episodes and reviews
+func (d *Deleter) DeletePodcastCascade(ctx context.Context, id bson.ObjectID) (Cascaded, error) {
+	session, err := d.Database.Client().StartSession()
+	if err != nil {
+		return Cascaded{}, err
+	}
+	defer session.EndSession(context.Background())
+	var cascaded Cascaded
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		// WithTransaction may retry the callback, so start from zero each time
+		cascaded = Cascaded{}
+		deleted, err := d.Database.Collection(d.Reviews).DeleteMany(ctx, bson.D{{"podcast", id}})
+		if err != nil {
+			return nil, err
+		}
+		cascaded.Reviews = deleted.DeletedCount
+		if deleted, err = d.Database.Collection(d.Episodes).DeleteMany(ctx, bson.D{{"podcast", id}}); err != nil {
+			return nil, err
+		}
+		cascaded.Episodes = deleted.DeletedCount
+		if deleted, err = d.Database.Collection(d.Podcasts).DeleteOne(ctx, bson.D{{"_id", id}}); err != nil {
+			return nil, err
+		}
+		cascaded.Podcasts = deleted.DeletedCount
+		return nil, nil
+	})
+	return cascaded, err
+}
+
+// Run deletes a podcast with everything referring to it, then every
+// 25 minute episode, then drops both collections
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	deps.Pause("DeletePodcastCascade deletes a podcast and everything referring to it")
+	title := deps.String("title", "The Polyglot Developer Podcast")
+	var podcast struct {
+		ID bson.ObjectID `bson:"_id"`
+	}
+	if err := podcastsCollection.FindOne(ctx, bson.M{"title": title}).Decode(&podcast); err != nil {
+		return fmt.Errorf("find podcast to delete: %w", err)
+	}
+	deleter := newDeleter(deps.DB())
+	deleter.Podcasts = podcastsCollection.Name()
+	deleter.Episodes = episodesCollection.Name()
+	deleter.Reviews = deps.Collection("reviews").Name()
+	cascaded, err := deleter.DeletePodcastCascade(ctx, podcast.ID)
+	if err != nil {
+		return fmt.Errorf("delete podcast %s with its episodes: %w", podcast.ID.Hex(), err)
+	}
+	deps.Printf("DeletePodcastCascade removed %v podcast(s), %v episode(s) and %v review(s)\n",
+		cascaded.Podcasts, cascaded.Episodes, cascaded.Reviews)
+
+	deps.Show(ctx, "Episodes left", episodesCollection, bson.M{"podcast": podcast.ID})
+
+	deps.Pause("DeleteMany deletes every episode of one duration")
+	duration := deps.Int("duration", 25)
+	deps.Show(ctx, "Before", episodesCollection, bson.M{"duration": duration})
+	deleted, err := DeleteEpisodesByDuration(ctx, episodesCollection, duration)
+	if err != nil {
+		return fmt.Errorf("delete many in episodes: %w", err)
+	}
+	deps.Printf("DeleteMany removed %v document(s)\n", deleted)
+
+	deps.Show(ctx, "After", episodesCollection, bson.M{"duration": duration})
+
+	deps.Pause("Drop removes both collections")
+	if err = podcastsCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop podcasts: %w", err)
+	}
+
+	if err = episodesCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop episodes: %w", err)
+	}
+	return nil
+}
+
+// DeleteEpisodesByDuration deletes every episode lasting minutes and returns
+// how many it deleted
+func DeleteEpisodesByDuration(ctx context.Context, episodes *mongo.Collection, minutes int) (int64, error) {
+	result, err := episodes.DeleteMany(ctx, bson.M{"duration": minutes})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
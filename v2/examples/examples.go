@@ -0,0 +1,179 @@
+// Package examples is the mongo-driver v2 counterpart of the examples
+// package. Each package below it is a port of the package of the same name
+// under examples, kept line for line where the driver allows, so
+//
+//	diff examples/creating/creating.go v2/examples/creating/creating.go
+//
+// shows what migrating that code takes. The exceptions are the helper
+// packages written against the v1 driver: where a v1 example uses cascade,
+// compass, dotpath, filter, repository or typedcoll, the port calls the
+// driver directly or carries a small stand-in.
+//
+// The directories under v2 hold the thin mains, run with go run ./v2/creating.
+// They need module mode with go.mongodb.org/mongo-driver/v2 in go.mod; dep
+// cannot resolve the /v2 import path, so unlike the v1 directories they have
+// no Gopkg.toml.
+//
+// The registry and the umbrella runner stay with the v1 examples.
+package examples
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/mongodb-developer/golang-quickstart/examples"
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/connstring"
+)
+
+// DefaultDatabase is the database the examples use unless Deps names another
+const DefaultDatabase = v1.DefaultDatabase
+
+// Deps is what an example runs against, as in the v1 package
+type Deps struct {
+	Client *mongo.Client
+	// Database defaults to DefaultDatabase
+	Database string
+	// Collections renames the collections an example uses, keyed by the
+	// name the example gives them, such as "episodes"
+	Collections map[string]string
+	// Out receives everything the example prints and defaults to standard
+	// output
+	Out io.Writer
+	// Workshop is shared with the v1 examples; nil runs straight through
+	Workshop *v1.Workshop
+}
+
+// DB returns the database the example works in
+func (d Deps) DB() *mongo.Database {
+	if d.Database == "" {
+		return d.Client.Database(DefaultDatabase)
+	}
+	return d.Client.Database(d.Database)
+}
+
+// Collection returns the collection the example calls name, renamed by
+// Collections
+func (d Deps) Collection(name string) *mongo.Collection {
+	if renamed, ok := d.Collections[name]; ok {
+		return d.DB().Collection(renamed)
+	}
+	return d.DB().Collection(name)
+}
+
+// Printf formats to Out
+func (d Deps) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(d.out(), format, args...)
+}
+
+// Println prints its arguments to Out like fmt.Println
+func (d Deps) Println(args ...interface{}) {
+	fmt.Fprintln(d.out(), args...)
+}
+
+func (d Deps) out() io.Writer {
+	if d.Out == nil {
+		return os.Stdout
+	}
+	return d.Out
+}
+
+// Pause calls Workshop.Pause
+func (d Deps) Pause(operation string) { d.Workshop.Pause(operation) }
+
+// String calls Workshop.String, returning value without a workshop
+func (d Deps) String(name, value string) string { return d.Workshop.String(name, value) }
+
+// Int calls Workshop.Int, returning value without a workshop
+func (d Deps) Int(name string, value int) int { return d.Workshop.Int(name, value) }
+
+// Show prints the documents in collection matching filter, like the v1 Show
+func (d Deps) Show(ctx context.Context, label string, collection *mongo.Collection, filter interface{}) {
+	if d.Workshop == nil {
+		return
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(10))
+	if err != nil {
+		d.Printf("   %s: %v\n", label, err)
+		return
+	}
+	var documents []bson.Raw
+	if err = cursor.All(ctx, &documents); err != nil {
+		d.Printf("   %s: %v\n", label, err)
+		return
+	}
+	d.Printf("   %s:\n", label)
+	for _, document := range documents {
+		extJSON, err := bson.MarshalExtJSON(document, false, false)
+		if err != nil {
+			d.Printf("     (%v)\n", err)
+			continue
+		}
+		d.Printf("     %s\n", extJSON)
+	}
+	if len(documents) == 0 {
+		d.Printf("     (no documents)\n")
+	}
+}
+
+// Func is the entry point every example package exposes as Run
+type Func func(ctx context.Context, deps Deps) error
+
+// Connect is db.Connect for the v2 driver: the same connection string,
+// validation, timeouts and ping. v2's Connect takes no context; it only
+// validates the options and starts monitoring in the background.
+func Connect(ctx context.Context, down *shutdown.Shutdown, opts ...*options.ClientOptions) (Deps, error) {
+	uri := db.URI()
+	if err := db.Validate(uri); err != nil {
+		return Deps{}, err
+	}
+	base := options.Client().
+		ApplyURI(uri).
+		SetConnectTimeout(db.ConnectTimeout).
+		SetServerSelectionTimeout(db.ServerSelectionTimeout)
+	client, err := mongo.Connect(append([]*options.ClientOptions{base}, opts...)...)
+	if err != nil {
+		return Deps{}, fmt.Errorf("connecting to %s: %w", hosts(uri), err)
+	}
+	down.Close("client", client.Disconnect)
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		return Deps{}, fmt.Errorf("connecting to %s: %w", hosts(uri), err)
+	}
+	return Deps{Client: client, Database: DefaultDatabase, Out: os.Stdout}, nil
+}
+
+func hosts(uri string) string {
+	cs, err := connstring.Parse(uri)
+	if err != nil {
+		return "cluster"
+	}
+	return strings.Join(cs.Hosts, ",")
+}
+
+// Main is the v2 counterpart of the v1 Main
+func Main(run Func, timeout time.Duration, opts ...*options.ClientOptions) {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(func(ctx context.Context, down *shutdown.Shutdown) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		deps, err := Connect(ctx, down, opts...)
+		if err != nil {
+			return err
+		}
+		return run(ctx, deps)
+	})
+}
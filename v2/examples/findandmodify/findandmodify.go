@@ -0,0 +1,119 @@
+// Package findandmodify reads and changes a document in one atomic step
+package findandmodify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     bson.ObjectID `bson:"_id,omitempty"`
+	Title  string        `bson:"title,omitempty"`
+	Author string        `bson:"author,omitempty"`
+	Tags   []string      `bson:"tags,omitempty"`
+	Plays  int64         `bson:"plays"`
+}
+
+// Counter represents the schema for the "counters" collection
+type Counter struct {
+	Name  string `bson:"_id"`
+	Value int64  `bson:"value"`
+}
+
+// nextSequence atomically increments the named counter and returns its new
+// value. Two callers can never get the same number, which a FindOne followed
+// by an UpdateOne could not guarantee.
+func nextSequence(ctx context.Context, counters *mongo.Collection, name string) (int64, error) {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+	var counter Counter
+	err := counters.FindOneAndUpdate(ctx,
+		bson.D{{"_id", name}},
+		bson.D{{"$inc", bson.D{{"value", 1}}}},
+		opts,
+	).Decode(&counter)
+	return counter.Value, err
+}
+
+// Run updates, replaces and deletes one podcast with the FindOneAnd
+// methods and hands out sequence numbers from a counter
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	countersCollection := deps.Collection("counters")
+
+	result, err := podcastsCollection.InsertOne(ctx, Podcast{Title: "Find And Modify FM", Author: "Nic Raboy"})
+	if err != nil {
+		return fmt.Errorf("insert into podcasts: %w", err)
+	}
+	id := result.InsertedID.(bson.ObjectID)
+
+	// Update A Document And Return It After The Update
+	var podcast Podcast
+	err = podcastsCollection.FindOneAndUpdate(ctx,
+		bson.D{{"_id", id}},
+		bson.D{{"$inc", bson.D{{"plays", 1}}}, {"$addToSet", bson.D{{"tags", "atomic"}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&podcast)
+	if err != nil {
+		return fmt.Errorf("find one and update podcast %s: %w", id.Hex(), err)
+	}
+	deps.Printf("After the update: %+v\n", podcast)
+
+	// Update A Document And Return It Before The Update
+	var before Podcast
+	err = podcastsCollection.FindOneAndUpdate(ctx,
+		bson.D{{"_id", id}},
+		bson.D{{"$inc", bson.D{{"plays", 1}}}},
+	).Decode(&before)
+	if err != nil {
+		return fmt.Errorf("find one and update podcast %s: %w", id.Hex(), err)
+	}
+	deps.Printf("Before the second update, plays was %d\n", before.Plays)
+
+	// Hand Out Unique Sequence Numbers
+	for i := 0; i < 3; i++ {
+		number, err := nextSequence(ctx, countersCollection, "episode_number")
+		if err != nil {
+			return fmt.Errorf("next episode_number: %w", err)
+		}
+		deps.Println("Next episode number:", number)
+	}
+
+	// Replace A Document And Return The New Version
+	var replaced Podcast
+	err = podcastsCollection.FindOneAndReplace(ctx,
+		bson.D{{"_id", id}},
+		Podcast{Title: "Find And Modify FM", Author: "Nicolas Raboy", Tags: []string{"replaced"}},
+		options.FindOneAndReplace().SetReturnDocument(options.After),
+	).Decode(&replaced)
+	if err != nil {
+		return fmt.Errorf("find one and replace podcast %s: %w", id.Hex(), err)
+	}
+	deps.Printf("After the replacement: %+v\n", replaced)
+
+	// Delete A Document And Return What Was Deleted
+	var deleted Podcast
+	err = podcastsCollection.FindOneAndDelete(ctx, bson.D{{"_id", id}}).Decode(&deleted)
+	if err != nil {
+		return fmt.Errorf("find one and delete podcast %s: %w", id.Hex(), err)
+	}
+	deps.Printf("Deleted: %+v\n", deleted)
+
+	// Handle A Filter That Matches Nothing
+	// the result carries mongo.ErrNoDocuments instead of a document
+	err = podcastsCollection.FindOneAndDelete(ctx, bson.D{{"_id", id}}).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		deps.Println("Nothing left to delete for", id.Hex())
+	} else if err != nil {
+		return fmt.Errorf("find one and delete podcast %s: %w", id.Hex(), err)
+	}
+	return nil
+}
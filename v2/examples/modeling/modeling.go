@@ -0,0 +1,74 @@
+// Package modeling maps documents to native Go structs
+package modeling
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     bson.ObjectID `bson:"_id,omitempty"`
+	Title  string        `bson:"title,omitempty"`
+	Author string        `bson:"author,omitempty"`
+	Tags   []string      `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          bson.ObjectID `bson:"_id,omitempty"`
+	Podcast     bson.ObjectID `bson:"podcast,omitempty"`
+	Title       string        `bson:"title,omitempty"`
+	Description string        `bson:"description,omitempty"`
+	Duration    int32         `bson:"duration,omitempty"`
+}
+
+// Run finds episodes into structs, inserts a podcast struct and reads both
+// back
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	var episodes []Episode
+	cursor, err := episodesCollection.Find(ctx, bson.M{"duration": bson.D{{"$gt", 25}}})
+	if err != nil {
+		return fmt.Errorf("find in episodes: %w", err)
+	}
+	if err = cursor.All(ctx, &episodes); err != nil {
+		return fmt.Errorf("decode episodes: %w", err)
+	}
+	deps.Println(episodes)
+
+	podcast := Podcast{
+		Title:  "The Polyglot Developer",
+		Author: "Nic Raboy",
+		Tags:   []string{"development", "programming", "coding"},
+	}
+	insertResult, err := podcastsCollection.InsertOne(ctx, podcast)
+	if err != nil {
+		return fmt.Errorf("insert into podcasts: %w", err)
+	}
+	deps.Println(insertResult.InsertedID)
+
+	// Read The Same Structs Back
+	// the repository package is written against the v1 driver, so this port
+	// makes the two calls it wraps directly
+	var longEpisodes []Episode
+	cursor, err = episodesCollection.Find(ctx, bson.M{"duration": bson.D{{"$gt", 25}}})
+	if err != nil {
+		return fmt.Errorf("find long episodes: %w", err)
+	}
+	if err = cursor.All(ctx, &longEpisodes); err != nil {
+		return fmt.Errorf("find long episodes: %w", err)
+	}
+	deps.Println(longEpisodes)
+	var inserted Podcast
+	if err = podcastsCollection.FindOne(ctx, bson.M{"_id": insertResult.InsertedID}).Decode(&inserted); err != nil {
+		return fmt.Errorf("find podcast %v: %w", insertResult.InsertedID, err)
+	}
+	deps.Println(inserted)
+	return nil
+}
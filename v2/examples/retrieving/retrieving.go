@@ -0,0 +1,274 @@
+// Package retrieving reads documents with Find and FindOne, including
+// searches for several terms across several fields and nested paths
+package retrieving
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Podcast represents the schema for the "Podcasts" collection
+type Podcast struct {
+	ID     bson.ObjectID `bson:"_id,omitempty"`
+	Title  string        `bson:"title,omitempty"`
+	Author string        `bson:"author,omitempty"`
+	Tags   []string      `bson:"tags,omitempty"`
+}
+
+// Episode represents the schema for the "Episodes" collection
+type Episode struct {
+	ID          bson.ObjectID `bson:"_id,omitempty"`
+	Podcast     bson.ObjectID `bson:"podcast,omitempty"`
+	Title       string        `bson:"title,omitempty"`
+	Description string        `bson:"description,omitempty"`
+	Duration    int32         `bson:"duration,omitempty"`
+}
+
+// Listener represents the schema for the "retrieving_listeners" collection,
+// which holds the nested documents the nested path searches need
+type Listener struct {
+	ID      bson.ObjectID `bson:"_id,omitempty"`
+	Name    string        `bson:"name"`
+	Profile struct {
+		Location struct {
+			Country string `bson:"country"`
+			City    string `bson:"city"`
+		} `bson:"location"`
+	} `bson:"profile"`
+	Devices []struct {
+		OS string `bson:"os"`
+	} `bson:"devices"`
+}
+
+// listeners returns the documents for "retrieving_listeners"
+func listeners() []interface{} {
+	listener := func(name, country, city string, devices ...string) bson.D {
+		deviceDocuments := bson.A{}
+		for _, device := range devices {
+			deviceDocuments = append(deviceDocuments, bson.D{{"os", device}})
+		}
+		return bson.D{
+			{"name", name},
+			{"profile", bson.D{{"location", bson.D{{"country", country}, {"city", city}}}}},
+			{"devices", deviceDocuments},
+		}
+	}
+	return []interface{}{
+		listener("Ada", "DE", "Berlin", "ios", "android"),
+		listener("Grace", "FR", "Paris", "android"),
+		listener("Linus", "US", "Boston", "ios"),
+	}
+}
+
+// titles returns the title of each episode
+func titles(episodes []Episode) []string {
+	titles := []string{}
+	for _, episode := range episodes {
+		titles = append(titles, episode.Title)
+	}
+	return titles
+}
+
+// names returns the name of each listener
+func names(listeners []Listener) []string {
+	names := []string{}
+	for _, listener := range listeners {
+		names = append(names, listener.Name)
+	}
+	return names
+}
+
+// find decodes every document of collection matching filter into a T. It
+// stands in for typedcoll, which is written against the v1 driver.
+func find[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...options.Lister[options.FindOptions]) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	documents := []T{}
+	if err = cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// EpisodesLongerThan returns the episodes longer than minutes, longest first
+func EpisodesLongerThan(ctx context.Context, episodes *mongo.Collection, minutes int) ([]Episode, error) {
+	opts := options.Find()
+	opts.SetSort(bson.D{{"duration", -1}})
+	return find[Episode](ctx, episodes, bson.D{{"duration", bson.D{{"$gt", minutes}}}}, opts)
+}
+
+// SearchEpisodes returns the episodes whose title or description contains
+// any of terms, ignoring case. Terms are regular expressions.
+func SearchEpisodes(ctx context.Context, episodes *mongo.Collection, terms ...string) ([]Episode, error) {
+	patterns := make(bson.A, 0, len(terms))
+	for _, term := range terms {
+		patterns = append(patterns, bson.Regex{Pattern: term, Options: "i"})
+	}
+	// filter.InAny builds v1 documents, so the $or of one $in per field is
+	// spelled out
+	return find[Episode](ctx, episodes, bson.D{{"$or", bson.A{
+		bson.D{{"title", bson.D{{"$in", patterns}}}},
+		bson.D{{"description", bson.D{{"$in", patterns}}}},
+	}}})
+}
+
+// Run finds all episodes, one podcast, filtered and sorted episodes and
+// episodes matching a query copied from Compass, then searches for several
+// terms at once. Every collection is typed, so every result is decoded into
+// Podcast, Episode or Listener.
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+	episodesCollection := deps.Collection("episodes")
+
+	// Retrieve All Documents
+	deps.Pause("Find with an empty filter returns every episode")
+	cursor, err := episodesCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("find in episodes: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var episode Episode
+		if err = cursor.Decode(&episode); err != nil {
+			return fmt.Errorf("decode episode: %w", err)
+		}
+		deps.Println(episode)
+	}
+
+	// Retrieve A Single Document
+	deps.Pause("FindOne returns the first podcast")
+	var podcast Podcast
+	if err = podcastsCollection.FindOne(ctx, bson.M{}).Decode(&podcast); err != nil {
+		return fmt.Errorf("find one in podcasts: %w", err)
+	}
+	deps.Println(podcast)
+
+	// Find Documents Matching A Filter
+	deps.Pause("Find with a filter returns episodes of one duration")
+	duration := deps.Int("duration", 25)
+	episodesFiltered, err := find[Episode](ctx, episodesCollection, bson.M{"duration": duration})
+	if err != nil {
+		return fmt.Errorf("find episodes with duration %d: %w", duration, err)
+	}
+	deps.Println(episodesFiltered)
+
+	// Find Documents Matching Filter And Sort
+	deps.Pause("Find with $gt and a sort returns longer episodes, longest first")
+	longer := deps.Int("longer than", 24)
+	episodesSorted, err := EpisodesLongerThan(ctx, episodesCollection, longer)
+	if err != nil {
+		return fmt.Errorf("find sorted episodes: %w", err)
+	}
+	deps.Println(episodesSorted)
+
+	// Find Documents With A Query Copied From Compass
+	deps.Pause("Find with a query copied from Compass")
+	// the compass package builds v1 options, so this port decodes the query
+	// itself with v2's Extended JSON parser and options builder
+	var compassQuery struct {
+		Filter bson.D `bson:"filter"`
+		Sort   bson.D `bson:"sort"`
+		Limit  int64  `bson:"limit"`
+	}
+	err = bson.UnmarshalExtJSON([]byte(`{
+		"filter": {"podcast": {"$oid": "5dd890a61c9d4400003f3a31"}},
+		"sort": {"duration": -1},
+		"limit": 2
+	}`), false, &compassQuery)
+	if err != nil {
+		return fmt.Errorf("parse Compass query: %w", err)
+	}
+	compassOptions := options.Find().SetSort(compassQuery.Sort).SetLimit(compassQuery.Limit)
+	episodesCompass, err := find[Episode](ctx, episodesCollection, compassQuery.Filter, compassOptions)
+	if err != nil {
+		return fmt.Errorf("find episodes with Compass query: %w", err)
+	}
+	deps.Println(episodesCompass)
+
+	// Find Documents Matching Any Of Several Values With $in
+	deps.Pause("$in matches a field against a list of values")
+	episodesIn, err := find[Episode](ctx, episodesCollection, bson.D{{"title", bson.D{{"$in", bson.A{
+		"GraphQL for API Development", "MongoDB Transactions",
+	}}}}})
+	if err != nil {
+		return fmt.Errorf("find episodes with $in: %w", err)
+	}
+	deps.Printf("%q\n", titles(episodesIn))
+
+	// $in takes a single field, so searching several fields for several
+	// terms is an $or with one $in per field. Regular expressions in $in
+	// match parts of a string, here case insensitively.
+	deps.Pause("$or of $in searches several fields for several terms")
+	episodesSearched, err := SearchEpisodes(ctx, episodesCollection, "graphql", "tara")
+	if err != nil {
+		return fmt.Errorf("find episodes with $or of $in: %w", err)
+	}
+	deps.Printf("%q\n", titles(episodesSearched))
+
+	// Match Arrays Containing Every Value With $all
+	// $in on an array field matches when any element is listed, $all only
+	// when every listed value is an element
+	deps.Pause("$all and $in on the tags array")
+	for _, tagFilter := range []bson.D{
+		{{"tags", bson.D{{"$all", bson.A{"development", "coding"}}}}},
+		{{"tags", bson.D{{"$all", bson.A{"development", "go"}}}}},
+		{{"tags", bson.D{{"$in", bson.A{"development", "go"}}}}},
+	} {
+		count, err := podcastsCollection.CountDocuments(ctx, tagFilter)
+		if err != nil {
+			return fmt.Errorf("count podcasts by tags: %w", err)
+		}
+		deps.Printf("%v matches %d podcast(s)\n", tagFilter, count)
+	}
+
+	// Search Nested Paths With Dot Notation
+	listenersCollection := deps.Collection("retrieving_listeners")
+	if err = listenersCollection.Drop(ctx); err != nil {
+		return fmt.Errorf("drop retrieving_listeners: %w", err)
+	}
+	if _, err = listenersCollection.InsertMany(ctx, listeners()); err != nil {
+		return fmt.Errorf("insert into retrieving_listeners: %w", err)
+	}
+	// a path like nested0.nested1.val1 reaches into embedded documents,
+	// and into every element of an array of them
+	deps.Pause("$in on a nested path with dot notation")
+	inCountries, err := find[Listener](ctx, listenersCollection, bson.D{{"profile.location.country", bson.D{{"$in", bson.A{"DE", "FR"}}}}})
+	if err != nil {
+		return fmt.Errorf("find listeners by country: %w", err)
+	}
+	deps.Printf("%q\n", names(inCountries))
+
+	// an embedded document as the value only matches documents whose
+	// location is exactly that document, city and field order included
+	deps.Pause("The same search with an embedded document instead of a path")
+	exactLocation, err := find[Listener](ctx, listenersCollection, bson.D{{"profile.location", bson.D{{"country", "DE"}}}})
+	if err != nil {
+		return fmt.Errorf("find listeners by location: %w", err)
+	}
+	deps.Printf("%q\n", names(exactLocation))
+
+	// one path per field of the embedded document is what the search meant;
+	// v1 builds it with dotpath, which is written against the v1 driver
+	deps.Pause("The embedded document flattened into paths")
+	byPaths := bson.D{{"profile.location.country", "DE"}}
+	inLocation, err := find[Listener](ctx, listenersCollection, byPaths)
+	if err != nil {
+		return fmt.Errorf("find listeners by location paths: %w", err)
+	}
+	deps.Printf("%q\n", names(inLocation))
+
+	deps.Pause("$all on a path through an array of documents")
+	bothDevices, err := find[Listener](ctx, listenersCollection, bson.D{{"devices.os", bson.D{{"$all", bson.A{"ios", "android"}}}}})
+	if err != nil {
+		return fmt.Errorf("find listeners by devices: %w", err)
+	}
+	deps.Printf("%q\n", names(bothDevices))
+	return nil
+}
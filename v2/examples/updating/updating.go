@@ -0,0 +1,128 @@
+// Package updating changes documents with UpdateOne, UpdateMany,
+// ReplaceOne and upserts
+package updating
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Run updates and replaces podcasts and upserts one twice, so the first
+// call inserts it
+func Run(ctx context.Context, deps examples.Deps) error {
+	podcastsCollection := deps.Collection("podcasts")
+
+	// Update a single document based on a document id hash
+	deps.Pause("UpdateOne sets the author of the podcast with a given _id")
+	id, err := bson.ObjectIDFromHex(deps.String("_id", "5dd890a61c9d4400003f3a31"))
+	if err != nil {
+		return fmt.Errorf("podcast _id: %w", err)
+	}
+	author := deps.String("author", "Nic Raboy")
+	deps.Show(ctx, "Before", podcastsCollection, bson.M{"_id": id})
+	result, err := SetAuthor(ctx, podcastsCollection, id, author)
+	if err != nil {
+		return fmt.Errorf("update one in podcasts: %w", err)
+	}
+	deps.Show(ctx, "After", podcastsCollection, bson.M{"_id": id})
+	deps.Printf("Updated %v Documents!\n", result.MatchedCount)
+
+	// Update zero or more documents based on a filter criteria
+	deps.Pause("UpdateMany sets the author of every podcast with a given title")
+	title := deps.String("title", "The Polyglot Developer Podcast")
+	author = deps.String("author", "Nicolas Raboy")
+	deps.Show(ctx, "Before", podcastsCollection, bson.M{"title": title})
+	result, err = podcastsCollection.UpdateMany(
+		ctx,
+		bson.M{"title": title},
+		bson.D{
+			{"$set", bson.D{{"author", author}}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("update many in podcasts: %w", err)
+	}
+	deps.Show(ctx, "After", podcastsCollection, bson.M{"title": title})
+	deps.Printf("Updated %v Documents!\n", result.ModifiedCount)
+
+	// Update zero or more documents and add a field that may not exist to the document
+	deps.Pause("UpdateMany sets the author back and adds a website field")
+	result, err = podcastsCollection.UpdateMany(
+		ctx,
+		bson.M{"title": "The Polyglot Developer Podcast"},
+		bson.D{
+			{"$set", bson.D{{"author", "Nic Raboy"}, {"website", "thepolyglotdeveloper.com"}}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("update many in podcasts: %w", err)
+	}
+	deps.Show(ctx, "After", podcastsCollection, bson.M{"title": "The Polyglot Developer Podcast"})
+	deps.Printf("Updated %v Documents!\n", result.ModifiedCount)
+
+	// Repace an entire single document based on a filter criteria
+	deps.Pause("ReplaceOne replaces a whole podcast, keeping only its _id")
+	result, err = podcastsCollection.ReplaceOne(
+		ctx,
+		bson.M{"author": "Nic Raboy"},
+		bson.M{
+			"title":  "The Nic Raboy Show",
+			"author": "Nicolas Raboy",
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("replace one in podcasts: %w", err)
+	}
+	deps.Show(ctx, "After", podcastsCollection, bson.M{"author": "Nicolas Raboy"})
+	deps.Printf("Replaced %v Documents!\n", result.ModifiedCount)
+
+	// Update a document or insert it when no document matches the filter, running it
+	// twice so the first call creates the document and the second one updates it
+	for i := 0; i < 2; i++ {
+		deps.Pause("UpdateOne with upsert, which inserts when nothing matches")
+		result, err = UpsertPodcast(ctx, podcastsCollection, "The Upsert Podcast", "Nic Raboy")
+		if err != nil {
+			return fmt.Errorf("upsert into podcasts: %w", err)
+		}
+		deps.Show(ctx, "After", podcastsCollection, bson.M{"title": "The Upsert Podcast"})
+		if result.UpsertedID != nil {
+			deps.Printf("Inserted a new document with _id %v!\n", result.UpsertedID)
+		} else {
+			deps.Printf("Updated %v existing Documents!\n", result.ModifiedCount)
+		}
+	}
+	return nil
+}
+
+// SetAuthor sets the author of the podcast with the given _id
+func SetAuthor(ctx context.Context, podcasts *mongo.Collection, id bson.ObjectID, author string) (*mongo.UpdateResult, error) {
+	return podcasts.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.D{
+			{"$set", bson.D{{"author", author}}},
+		},
+	)
+}
+
+// UpsertPodcast sets the author of the podcast with the given title, or
+// inserts the podcast tagged "upsert" when there is none. UpsertedID is set
+// only when it inserted.
+func UpsertPodcast(ctx context.Context, podcasts *mongo.Collection, title, author string) (*mongo.UpdateResult, error) {
+	return podcasts.UpdateOne(
+		ctx,
+		bson.M{"title": title},
+		bson.D{
+			{"$set", bson.D{{"author", author}, {"updated_at", time.Now()}}},
+			// $setOnInsert fields are only written when the upsert creates the document
+			{"$setOnInsert", bson.D{{"created_at", time.Now()}, {"tags", bson.A{"upsert"}}}},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+}
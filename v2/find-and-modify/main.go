@@ -0,0 +1,12 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples/findandmodify"
+)
+
+func main() {
+	examples.Main(findandmodify.Run, 10*time.Second)
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples/indexes"
+)
+
+func main() {
+	examples.Main(indexes.Run, 30*time.Second)
+}
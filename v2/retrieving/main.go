@@ -0,0 +1,12 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples/retrieving"
+)
+
+func main() {
+	examples.Main(retrieving.Run, 10*time.Second)
+}
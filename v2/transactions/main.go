@@ -0,0 +1,14 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/v2/examples"
+	"github.com/mongodb-developer/golang-quickstart/v2/examples/transactions"
+)
+
+func main() {
+	// the deadline bounds every retry, like WithTransaction's own 120 second
+	// limit
+	examples.Main(transactions.Run, 2*time.Minute)
+}
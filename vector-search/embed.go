@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns text into a vector. Any embedding model can be plugged in,
+// as long as documents and queries use the same one and the index is created
+// with its number of dimensions.
+type Embedder func(ctx context.Context, text string) ([]float32, error)
+
+// HashEmbedder returns a toy embedder that needs no model: each word is
+// hashed into one of the dimensions and the vector is normalized. Texts
+// sharing words end up close, which is enough to try $vectorSearch, but it
+// knows nothing about meaning.
+func HashEmbedder(dimensions int) Embedder {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		vector := make([]float32, dimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%uint32(dimensions)]++
+		}
+		var norm float64
+		for _, v := range vector {
+			norm += float64(v * v)
+		}
+		if norm > 0 {
+			scale := float32(1 / math.Sqrt(norm))
+			for i := range vector {
+				vector[i] *= scale
+			}
+		}
+		return vector, nil
+	}
+}
+
+// OpenAIEmbedder calls an OpenAI compatible /v1/embeddings endpoint
+func OpenAIEmbedder(baseURL, apiKey, model string) Embedder {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		body, err := json.Marshal(map[string]string{"model": model, "input": text})
+		if err != nil {
+			return nil, err
+		}
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/v1/embeddings", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+apiKey)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("embeddings request failed: %s", response.Status)
+		}
+		var result struct {
+			Data []struct {
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+			return nil, err
+		}
+		if len(result.Data) == 0 {
+			return nil, fmt.Errorf("embeddings response has no data")
+		}
+		return result.Data[0].Embedding, nil
+	}
+}
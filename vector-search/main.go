@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexName is the Atlas Vector Search index over episodes.embedding
+const indexName = "episode_embeddings"
+
+// Episode represents the schema for the "Episodes" collection with its embedding
+type Episode struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Podcast     primitive.ObjectID `bson:"podcast,omitempty"`
+	Title       string             `bson:"title,omitempty"`
+	Description string             `bson:"description,omitempty"`
+	Duration    int32              `bson:"duration,omitempty"`
+	Embedding   []float32          `bson:"embedding,omitempty"`
+	// EmbeddingModel names the model Embedding was made with, so a switch
+	// to another model or size is noticed and the episode embedded again
+	EmbeddingModel string `bson:"embedding_model,omitempty"`
+}
+
+// Match represents an aggregation result-set of a $vectorSearch hit
+type Match struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Title    string             `bson:"title"`
+	Duration int32              `bson:"duration"`
+	Score    float64            `bson:"score"`
+}
+
+// embedEpisodes stores an embedding on every episode that has none yet, or
+// one made with another model than model
+func embedEpisodes(ctx context.Context, episodes *mongo.Collection, embed Embedder, model string) (int, error) {
+	cursor, err := episodes.Find(ctx, bson.D{{"embedding_model", bson.D{{"$ne", model}}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	embedded := 0
+	for cursor.Next(ctx) {
+		var episode Episode
+		if err = cursor.Decode(&episode); err != nil {
+			return embedded, err
+		}
+		vector, err := embed(ctx, episode.Title+"\n"+episode.Description)
+		if err != nil {
+			return embedded, fmt.Errorf("embedding %v: %w", episode.ID, err)
+		}
+		if _, err = episodes.UpdateByID(ctx, episode.ID, bson.D{{"$set", bson.D{{"embedding", vector}, {"embedding_model", model}}}}); err != nil {
+			return embedded, err
+		}
+		embedded++
+	}
+	return embedded, cursor.Err()
+}
+
+// searchIndex is the part of a $listSearchIndexes result ensureIndex reads
+type searchIndex struct {
+	Status           string `bson:"status"`
+	Queryable        bool   `bson:"queryable"`
+	LatestDefinition struct {
+		Fields []struct {
+			Type          string `bson:"type"`
+			NumDimensions int    `bson:"numDimensions"`
+		} `bson:"fields"`
+	} `bson:"latestDefinition"`
+}
+
+// dimensions returns the numDimensions of the index's vector field
+func (i searchIndex) dimensions() int {
+	for _, field := range i.LatestDefinition.Fields {
+		if field.Type == "vector" {
+			return field.NumDimensions
+		}
+	}
+	return 0
+}
+
+func listIndex(ctx context.Context, view mongo.SearchIndexView) (*searchIndex, error) {
+	cursor, err := view.List(ctx, options.SearchIndexes().SetName(indexName))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []searchIndex
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	return &indexes[0], nil
+}
+
+// ensureIndex creates the vector index unless it exists, rebuilds it when it
+// was made for vectors of another size, then waits until Atlas reports it
+// ready. podcast and duration are indexed as filter fields so $vectorSearch
+// can pre-filter on them.
+func ensureIndex(ctx context.Context, episodes *mongo.Collection, dimensions int) error {
+	view := episodes.SearchIndexes()
+	definition := bson.D{{"fields", bson.A{
+		bson.D{
+			{"type", "vector"},
+			{"path", "embedding"},
+			{"numDimensions", dimensions},
+			{"similarity", "cosine"},
+		},
+		bson.D{{"type", "filter"}, {"path", "podcast"}},
+		bson.D{{"type", "filter"}, {"path", "duration"}},
+	}}}
+	existing, err := listIndex(ctx, view)
+	if err != nil {
+		return err
+	}
+	switch {
+	case existing == nil:
+		_, err = view.CreateOne(ctx, mongo.SearchIndexModel{
+			Definition: definition,
+			Options:    options.SearchIndexes().SetName(indexName).SetType("vectorSearch"),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created vector search index", indexName)
+	case existing.dimensions() != dimensions:
+		if err = view.UpdateOne(ctx, indexName, definition); err != nil {
+			return err
+		}
+		fmt.Printf("Rebuilding vector search index %s for %d instead of %d dimensions\n", indexName, dimensions, existing.dimensions())
+	}
+
+	for {
+		index, err := listIndex(ctx, view)
+		if err != nil {
+			return err
+		}
+		if index != nil && index.Status == "FAILED" {
+			return fmt.Errorf("vector search index %s failed to build", indexName)
+		}
+		// during a rebuild the old definition stays queryable, so wait for
+		// the new one to be ready
+		if index != nil && index.Queryable && index.Status == "READY" && index.dimensions() == dimensions {
+			return nil
+		}
+		fmt.Println("Waiting for the index to become queryable...")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// searchPipeline finds the episodes nearest to vector. numCandidates is how
+// many neighbors the approximate search considers before keeping limit.
+func searchPipeline(vector []float32, filter bson.D, limit int) mongo.Pipeline {
+	search := bson.D{
+		{"index", indexName},
+		{"path", "embedding"},
+		{"queryVector", vector},
+		{"numCandidates", limit * 20},
+		{"limit", limit},
+	}
+	if len(filter) > 0 {
+		search = append(search, bson.E{"filter", filter})
+	}
+	vectorSearchStage := bson.D{{"$vectorSearch", search}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"title", 1},
+		{"duration", 1},
+		{"score", bson.D{{"$meta", "vectorSearchScore"}}},
+	}}}
+	return mongo.Pipeline{vectorSearchStage, projectStage}
+}
+
+var (
+	query       = flag.String("query", "building APIs with GraphQL", "text to find similar episodes for")
+	maxDuration = flag.Int("max-duration", 0, "only return episodes up to this many minutes long")
+	podcastHex  = flag.String("podcast", "", "only return episodes of this podcast")
+	limit       = flag.Int("limit", 5, "number of episodes to return")
+	dimensions  = flag.Int("dimensions", 256, "vector size of the toy embedder")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	if *dimensions <= 0 {
+		return fmt.Errorf("%w: -dimensions must be positive", shutdown.ErrUsage)
+	}
+	filter := bson.D{}
+	if *maxDuration > 0 {
+		filter = append(filter, bson.E{"duration", bson.D{{"$lte", *maxDuration}}})
+	}
+	if *podcastHex != "" {
+		podcast, err := primitive.ObjectIDFromHex(*podcastHex)
+		if err != nil {
+			return fmt.Errorf("%w: -podcast must be an ObjectID", shutdown.ErrUsage)
+		}
+		filter = append(filter, bson.E{"podcast", podcast})
+	}
+
+	// Plug In An Embedding Function
+	embed, model, dims := HashEmbedder(*dimensions), fmt.Sprintf("hash-%d", *dimensions), *dimensions
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		// text-embedding-3-small returns 1536 dimensions
+		model, dims = "text-embedding-3-small", 1536
+		embed = OpenAIEmbedder("https://api.openai.com", key, model)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	client, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	episodesCollection := client.Database("quickstart").Collection("episodes")
+
+	// Store Embeddings On The Episode Documents
+	embedded, err := embedEpisodes(ctx, episodesCollection, embed, model)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Embedded %d episode(s)\n", embedded)
+
+	// Create The Vector Index With The SearchIndexes API
+	if err = ensureIndex(ctx, episodesCollection, dims); err != nil {
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) {
+			return fmt.Errorf("creating the vector index failed, Atlas Vector Search needs an Atlas cluster: %v", err)
+		}
+		return err
+	}
+
+	// Run A Filtered kNN Query With $vectorSearch
+	vector, err := embed(ctx, *query)
+	if err != nil {
+		return err
+	}
+	cursor, err := episodesCollection.Aggregate(ctx, searchPipeline(vector, filter, *limit))
+	if err != nil {
+		return err
+	}
+	var matches []Match
+	if err = cursor.All(ctx, &matches); err != nil {
+		return err
+	}
+	fmt.Printf("Episodes closest to %q:\n", *query)
+	for _, match := range matches {
+		fmt.Printf("  %.4f  %s (%d min)\n", match.Score, match.Title, match.Duration)
+	}
+	return nil
+}
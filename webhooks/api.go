@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/deadline"
+	"github.com/mongodb-developer/golang-quickstart/internal/openapi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// API exposes endpoint registration, delivery logs and redelivery over HTTP
+type API struct {
+	Endpoints  *mongo.Collection
+	Deliveries *mongo.Collection
+}
+
+// Routes returns the handler serving every API route
+func (a *API) Routes() http.Handler {
+	routes := openapi.New("Quickstart webhooks", "1.0.0")
+	routes.Handle(openapi.Route{
+		Method: "POST", Path: "/endpoints", Summary: "Register an endpoint", Tags: []string{"endpoints"},
+		Request: Endpoint{}, Response: Endpoint{}, Status: http.StatusCreated, Errors: []int{http.StatusBadRequest},
+		Description: "A signing secret is generated when none is provided. Events default to \"*\".",
+	}, a.createEndpoint)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/endpoints", Summary: "List endpoints", Tags: []string{"endpoints"},
+		Response: []Endpoint{},
+	}, a.listEndpoints)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/deliveries", Summary: "List the 50 most recent deliveries", Tags: []string{"deliveries"},
+		Query: []openapi.Param{
+			{Name: "status", Description: "pending, in_flight, succeeded or failed"},
+			{Name: "endpoint", Description: "endpoint id"},
+		},
+		Response: []Delivery{}, Errors: []int{http.StatusBadRequest},
+	}, a.listDeliveries)
+	routes.Handle(openapi.Route{
+		Method: "GET", Path: "/deliveries/{id}", Summary: "Get a delivery with its attempt log", Tags: []string{"deliveries"},
+		Response: Delivery{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+	}, a.getDelivery)
+	routes.Handle(openapi.Route{
+		Method: "POST", Path: "/deliveries/{id}/redeliver", Summary: "Queue a delivery again", Tags: []string{"deliveries"},
+		Status: http.StatusAccepted, Errors: []int{http.StatusBadRequest, http.StatusConflict},
+	}, a.redeliver)
+	return http.TimeoutHandler(routes, 5*time.Second, `{"error":"request timed out"}`)
+}
+
+func (a *API) createEndpoint(w http.ResponseWriter, r *http.Request) {
+	var endpoint Endpoint
+	if err := json.NewDecoder(r.Body).Decode(&endpoint); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if target, err := url.Parse(endpoint.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http(s) URL")
+		return
+	}
+	if len(endpoint.Events) == 0 {
+		endpoint.Events = []string{"*"}
+	}
+	if endpoint.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		endpoint.Secret = hex.EncodeToString(secret)
+	}
+	endpoint.ID = primitive.NilObjectID
+	endpoint.Active = true
+	endpoint.CreatedAt = time.Now().UTC()
+	result, err := a.Endpoints.InsertOne(r.Context(), endpoint)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	endpoint.ID = result.InsertedID.(primitive.ObjectID)
+	writeJSON(w, http.StatusCreated, endpoint)
+}
+
+func (a *API) listEndpoints(w http.ResponseWriter, r *http.Request) {
+	opts := options.Find().SetProjection(bson.D{{"secret", 0}})
+	cursor, err := a.Endpoints.Find(r.Context(), bson.M{}, opts, deadline.Find(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	endpoints := []Endpoint{}
+	if err = cursor.All(r.Context(), &endpoints); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+func (a *API) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	filter := bson.D{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter = append(filter, bson.E{"status", status})
+	}
+	if endpoint := r.URL.Query().Get("endpoint"); endpoint != "" {
+		id, err := primitive.ObjectIDFromHex(endpoint)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "endpoint must be an ObjectID")
+			return
+		}
+		filter = append(filter, bson.E{"endpoint", id})
+	}
+	opts := options.Find().
+		SetSort(bson.D{{"created_at", -1}}).
+		SetLimit(50).
+		SetProjection(bson.D{{"payload", 0}})
+	cursor, err := a.Deliveries.Find(r.Context(), filter, opts, deadline.Find(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	deliveries := []Delivery{}
+	if err = cursor.All(r.Context(), &deliveries); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (a *API) getDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be an ObjectID")
+		return
+	}
+	var delivery Delivery
+	err = a.Deliveries.FindOne(r.Context(), bson.M{"_id": id}, deadline.FindOne(r.Context())).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, http.StatusNotFound, "delivery not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+// redeliver puts a delivery back in the queue with a fresh retry budget,
+// keeping its previous attempts in the log
+func (a *API) redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be an ObjectID")
+		return
+	}
+	result, err := a.Deliveries.UpdateOne(r.Context(),
+		bson.D{{"_id", id}, {"status", bson.D{{"$ne", StatusInFlight}}}},
+		bson.D{{"$set", bson.D{
+			{"status", StatusPending},
+			{"attempt_count", 0},
+			{"next_attempt_at", time.Now().UTC()},
+		}}},
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result.MatchedCount == 0 {
+		writeError(w, http.StatusConflict, "delivery not found or currently in flight")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
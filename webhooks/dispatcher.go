@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body
+const SignatureHeader = "X-Quickstart-Signature"
+
+// DefaultLease is how long a claimed delivery stays locked when Client has
+// no timeout to derive the lease from
+const DefaultLease = time.Minute
+
+// Dispatcher claims pending deliveries and POSTs them to their endpoints
+type Dispatcher struct {
+	Endpoints   *mongo.Collection
+	Deliveries  *mongo.Collection
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Sign returns the signature receivers compare against SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the given attempt number (1-based),
+// doubling from BaseDelay and capped at MaxDelay
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.MaxDelay {
+			return d.MaxDelay
+		}
+	}
+	return delay
+}
+
+// Enqueue stores one pending delivery per endpoint subscribed to the event
+func (d *Dispatcher) Enqueue(ctx context.Context, name string, body []byte) (int, error) {
+	endpoints, err := subscribedEndpoints(ctx, d.Endpoints, name)
+	if err != nil || len(endpoints) == 0 {
+		return 0, err
+	}
+	deliveries := make([]interface{}, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, newDelivery(endpoint.ID, name, body))
+	}
+	result, err := d.Deliveries.InsertMany(ctx, deliveries)
+	if err != nil {
+		return 0, err
+	}
+	return len(result.InsertedIDs), nil
+}
+
+// Run polls for due deliveries until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			delivery, err := d.claim(ctx)
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) && ctx.Err() == nil {
+					log.Printf("claim delivery: %v", err)
+				}
+				break
+			}
+			if err = d.attempt(ctx, delivery); err != nil {
+				log.Printf("delivery %s: %v", delivery.ID.Hex(), err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim atomically moves one due delivery to in_flight. Deliveries left
+// in_flight by a crashed process become claimable again once their lock expires.
+func (d *Dispatcher) claim(ctx context.Context) (Delivery, error) {
+	now := time.Now().UTC()
+	filter := bson.D{{"$or", bson.A{
+		bson.D{{"status", StatusPending}, {"next_attempt_at", bson.D{{"$lte", now}}}},
+		bson.D{{"status", StatusInFlight}, {"locked_until", bson.D{{"$lt", now}}}},
+	}}}
+	update := bson.D{{"$set", bson.D{
+		{"status", StatusInFlight},
+		{"locked_until", now.Add(d.lease())},
+	}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{"next_attempt_at", 1}}).
+		SetReturnDocument(options.After)
+	var delivery Delivery
+	err := d.Deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	return delivery, err
+}
+
+// lease returns how long a claim lasts: twice the HTTP timeout, so a slow
+// but successful POST is not claimed again by another process meanwhile
+func (d *Dispatcher) lease() time.Duration {
+	if d.Client.Timeout <= 0 {
+		return DefaultLease
+	}
+	return 2 * d.Client.Timeout
+}
+
+// attempt performs one HTTP POST and records the outcome, scheduling a
+// retry with exponential backoff or marking the delivery as failed
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) error {
+	var endpoint Endpoint
+	err := d.Endpoints.FindOne(ctx, bson.M{"_id": delivery.Endpoint}).Decode(&endpoint)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !endpoint.Active) {
+		return d.finish(ctx, delivery, StatusFailed, Attempt{At: time.Now().UTC(), Error: "endpoint removed or inactive"})
+	}
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	result := Attempt{At: started.UTC()}
+	statusCode, err := d.post(ctx, endpoint, delivery)
+	result.Duration = time.Since(started)
+	result.StatusCode = statusCode
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		return d.finish(ctx, delivery, StatusSucceeded, result)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Error = fmt.Sprintf("unexpected status %d", statusCode)
+	}
+
+	attempts := delivery.AttemptCount + 1
+	if attempts >= d.MaxAttempts {
+		return d.finish(ctx, delivery, StatusFailed, result)
+	}
+	_, err = d.Deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.D{
+		{"$set", bson.D{
+			{"status", StatusPending},
+			{"next_attempt_at", time.Now().UTC().Add(d.Backoff(attempts))},
+		}},
+		{"$inc", bson.D{{"attempt_count", 1}}},
+		{"$push", bson.D{{"attempts", result}}},
+		{"$unset", bson.D{{"locked_until", ""}}},
+	})
+	return err
+}
+
+func (d *Dispatcher) post(ctx context.Context, endpoint Endpoint, delivery Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Quickstart-Event", delivery.Event)
+	request.Header.Set("X-Quickstart-Delivery", delivery.ID.Hex())
+	request.Header.Set("X-Quickstart-Attempt", strconv.Itoa(delivery.AttemptCount+1))
+	request.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+	response, err := d.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return response.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(ctx context.Context, delivery Delivery, status string, result Attempt) error {
+	_, err := d.Deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.D{
+		{"$set", bson.D{{"status", status}}},
+		{"$inc", bson.D{{"attempt_count", 1}}},
+		{"$push", bson.D{{"attempts", result}}},
+		{"$unset", bson.D{{"locked_until", ""}}},
+	})
+	return err
+}
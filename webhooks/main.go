@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Endpoint represents the schema for the "webhook_endpoints" collection
+type Endpoint struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"secret,omitempty"`
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Attempt records the outcome of a single HTTP delivery attempt
+type Attempt struct {
+	At         time.Time     `bson:"at" json:"at"`
+	StatusCode int           `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+}
+
+// Delivery represents the schema for the "webhook_deliveries" collection
+type Delivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Endpoint      primitive.ObjectID `bson:"endpoint" json:"endpoint"`
+	Event         string             `bson:"event" json:"event"`
+	Payload       string             `bson:"payload" json:"payload"`
+	Status        string             `bson:"status" json:"status"`
+	AttemptCount  int                `bson:"attempt_count" json:"attempt_count"`
+	Attempts      []Attempt          `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   time.Time          `bson:"locked_until,omitempty" json:"-"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Delivery states stored in the "status" field
+const (
+	StatusPending   = "pending"
+	StatusInFlight  = "in_flight"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+func ensureIndexes(ctx context.Context, deliveries *mongo.Collection) error {
+	_, err := deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"status", 1}, {"next_attempt_at", 1}}},
+		{Keys: bson.D{{"endpoint", 1}, {"created_at", -1}}},
+	})
+	return err
+}
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	database := client.Database("quickstart")
+	endpointsCollection := database.Collection("webhook_endpoints")
+	deliveriesCollection := database.Collection("webhook_deliveries")
+
+	if err = ensureIndexes(connectCtx, deliveriesCollection); err != nil {
+		return err
+	}
+
+	dispatcher := &Dispatcher{
+		Endpoints:   endpointsCollection,
+		Deliveries:  deliveriesCollection,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 6,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    10 * time.Minute,
+	}
+
+	// A failed watch stops the server, since no more deliveries would be queued
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	watchErr := make(chan error, 1)
+	go func() {
+		err := watchChanges(ctx, database, dispatcher)
+		if ctx.Err() != nil {
+			err = nil
+		}
+		watchErr <- err
+		stop()
+	}()
+	go dispatcher.Run(ctx, time.Second)
+
+	addr := os.Getenv("WEBHOOKS_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	api := &API{Endpoints: endpointsCollection, Deliveries: deliveriesCollection}
+	log.Printf("webhooks API listening on %s", addr)
+	if err = shutdown.Serve(ctx, &http.Server{Addr: addr, Handler: api.Routes()}); err != nil {
+		return err
+	}
+	return <-watchErr
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeEvent holds the parts of a change stream event that end up in a payload
+type changeEvent struct {
+	OperationType string    `bson:"operationType"`
+	WallTime      time.Time `bson:"wallTime"`
+	Namespace     struct {
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey  bson.Raw `bson:"documentKey"`
+	FullDocument bson.Raw `bson:"fullDocument,omitempty"`
+}
+
+// payload is the JSON body POSTed to every subscribed endpoint
+type payload struct {
+	Event       string          `json:"event"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	DocumentKey json.RawMessage `json:"document_key"`
+	Document    json.RawMessage `json:"document,omitempty"`
+}
+
+// streamName is the _id the watcher's resume token is saved under
+const streamName = "webhooks"
+
+// resumeToken represents the schema for the "resume_tokens" collection, one
+// document per named stream holding the token of the last handled event
+type resumeToken struct {
+	Stream    string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// watchChanges opens a change stream on the quickstart database and enqueues
+// one delivery per subscribed endpoint for each podcast or episode change.
+// The resume token is saved after each enqueued event and the stream resumes
+// after it, so an event is never skipped: a failed enqueue stops the watch
+// and the restarted process enqueues the event again. A crash between
+// enqueueing and saving the token enqueues an event twice, so receivers see
+// each change at least once.
+func watchChanges(ctx context.Context, database *mongo.Database, dispatcher *Dispatcher) error {
+	tokens := database.Collection("resume_tokens")
+	matchStage := bson.D{{"$match", bson.D{
+		{"ns.coll", bson.D{{"$in", bson.A{"podcasts", "episodes"}}}},
+		{"operationType", bson.D{{"$in", bson.A{"insert", "update", "replace", "delete"}}}},
+	}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	var saved resumeToken
+	err := tokens.FindOne(ctx, bson.D{{"_id", streamName}}).Decode(&saved)
+	switch {
+	case err == nil:
+		opts.SetResumeAfter(saved.Token)
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("load resume token: %w", err)
+	}
+	stream, err := database.Watch(ctx, mongo.Pipeline{matchStage}, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		name := event.Namespace.Collection + "." + event.OperationType
+		body, err := buildPayload(name, event)
+		if err != nil {
+			return err
+		}
+		count, err := dispatcher.Enqueue(ctx, name, body)
+		if err != nil {
+			return fmt.Errorf("enqueue %s: %w", name, err)
+		}
+		_, err = tokens.ReplaceOne(ctx, bson.D{{"_id", streamName}},
+			resumeToken{Stream: streamName, Token: stream.ResumeToken(), UpdatedAt: time.Now().UTC()},
+			options.Replace().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("save resume token: %w", err)
+		}
+		log.Printf("enqueued %s for %d endpoint(s)", name, count)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return stream.Err()
+}
+
+func buildPayload(name string, event changeEvent) ([]byte, error) {
+	documentKey, err := bson.MarshalExtJSON(event.DocumentKey, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("encoding document key: %w", err)
+	}
+	p := payload{
+		Event:       name,
+		OccurredAt:  event.WallTime,
+		DocumentKey: documentKey,
+	}
+	if len(event.FullDocument) > 0 {
+		if p.Document, err = bson.MarshalExtJSON(event.FullDocument, false, false); err != nil {
+			return nil, fmt.Errorf("encoding full document: %w", err)
+		}
+	}
+	return json.Marshal(p)
+}
+
+// subscribedEndpoints returns the active endpoints listening for an event,
+// either by its exact name or through the "*" wildcard
+func subscribedEndpoints(ctx context.Context, endpoints *mongo.Collection, name string) ([]Endpoint, error) {
+	cursor, err := endpoints.Find(ctx, bson.D{
+		{"active", true},
+		{"events", bson.D{{"$in", bson.A{name, "*"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []Endpoint
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func newDelivery(endpoint primitive.ObjectID, name string, body []byte) Delivery {
+	now := time.Now().UTC()
+	return Delivery{
+		Endpoint:      endpoint,
+		Event:         name,
+		Payload:       string(body),
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// publishEpisode is an example workflow: each step simulates slow work that
+// can fail, and records its result in the workflow data so later steps (and
+// restarted workers) can pick up where the last one left off
+func publishEpisode(failRate float64) Definition {
+	work := func(ctx context.Context, duration time.Duration) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(duration):
+		}
+		if rand.Float64() < failRate {
+			return errors.New("simulated failure")
+		}
+		return nil
+	}
+	return Definition{
+		Start:      "transcode",
+		MaxRetries: 3,
+		Steps: map[string]Step{
+			"transcode": func(ctx context.Context, workflow *Workflow) (string, error) {
+				if err := work(ctx, 2*time.Second); err != nil {
+					return "", err
+				}
+				workflow.Data["audio_url"] = fmt.Sprintf("https://cdn.example.com/%s.mp3", workflow.ID.Hex())
+				return "transcribe", nil
+			},
+			"transcribe": func(ctx context.Context, workflow *Workflow) (string, error) {
+				if err := work(ctx, 3*time.Second); err != nil {
+					return "", err
+				}
+				workflow.Data["transcript_words"] = rand.Intn(10000)
+				return "notify", nil
+			},
+			"notify": func(ctx context.Context, workflow *Workflow) (string, error) {
+				if err := work(ctx, time.Second); err != nil {
+					return "", err
+				}
+				workflow.Data["notified_at"] = time.Now().UTC()
+				return "", nil
+			},
+		},
+	}
+}
+
+var (
+	start    = flag.Int("start", 0, "number of new workflows to start before working")
+	workers  = flag.Int("workers", 2, "concurrent workers in this process")
+	failRate = flag.Float64("fail-rate", 0.2, "probability that a simulated step fails")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	workflowsCollection := client.Database("quickstart").Collection("workflows")
+	_, err = workflowsCollection.Indexes().CreateOne(connectCtx, mongo.IndexModel{
+		Keys: bson.D{{"status", 1}, {"next_run_at", 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	engine := &Engine{
+		Workflows:   workflowsCollection,
+		Definitions: map[string]Definition{"publish-episode": publishEpisode(*failRate)},
+		Lease:       30 * time.Second,
+		BaseDelay:   time.Second,
+	}
+	for i := 0; i < *start; i++ {
+		id, err := engine.Start(connectCtx, "publish-episode", bson.M{"requested_by": "quickstart"})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Started workflow %s\n", id.Hex())
+	}
+
+	// Stop the process mid-step and start it again: workflows that were
+	// running are resumed by the next worker once their lease expires
+	hostname, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			engine.Work(ctx, worker, time.Second)
+		}(fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i))
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Workflow statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Transition is appended to a workflow's history every time a step finishes
+type Transition struct {
+	Step     string        `bson:"step"`
+	At       time.Time     `bson:"at"`
+	Worker   string        `bson:"worker"`
+	Duration time.Duration `bson:"duration"`
+	Error    string        `bson:"error,omitempty"`
+}
+
+// Workflow represents the schema for the "workflows" collection. Data holds
+// whatever the steps need to resume after a restart.
+type Workflow struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Type        string             `bson:"type"`
+	Status      string             `bson:"status"`
+	Step        string             `bson:"step"`
+	Retries     int                `bson:"retries"`
+	Data        bson.M             `bson:"data"`
+	NextRunAt   time.Time          `bson:"next_run_at"`
+	LockedBy    string             `bson:"locked_by,omitempty"`
+	LockedUntil time.Time          `bson:"locked_until,omitempty"`
+	History     []Transition       `bson:"history"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+// Step performs one unit of work and returns the name of the next step, or
+// "" when the workflow is complete. Steps may run more than once if a worker
+// dies before recording the result, so they must be idempotent.
+type Step func(ctx context.Context, workflow *Workflow) (string, error)
+
+// Definition is the set of steps of one workflow type
+type Definition struct {
+	Start      string
+	Steps      map[string]Step
+	MaxRetries int
+}
+
+// Engine starts workflows and runs workers that advance them
+type Engine struct {
+	Workflows   *mongo.Collection
+	Definitions map[string]Definition
+	Lease       time.Duration
+	BaseDelay   time.Duration
+}
+
+// Start inserts a new workflow at the first step of its definition
+func (e *Engine) Start(ctx context.Context, workflowType string, data bson.M) (primitive.ObjectID, error) {
+	definition, ok := e.Definitions[workflowType]
+	if !ok {
+		return primitive.NilObjectID, fmt.Errorf("unknown workflow type %q", workflowType)
+	}
+	now := time.Now().UTC()
+	result, err := e.Workflows.InsertOne(ctx, Workflow{
+		Type:      workflowType,
+		Status:    StatusPending,
+		Step:      definition.Start,
+		Data:      data,
+		NextRunAt: now,
+		History:   []Transition{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Work claims and advances due workflows until the context is cancelled
+func (e *Engine) Work(ctx context.Context, worker string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			workflow, err := e.claim(ctx, worker)
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) && ctx.Err() == nil {
+					log.Printf("%s: claim workflow: %v", worker, err)
+				}
+				break
+			}
+			if err = e.advance(ctx, worker, workflow); err != nil {
+				log.Printf("%s: workflow %s: %v", worker, workflow.ID.Hex(), err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim takes a lease on one due workflow. A workflow whose worker died keeps
+// status running but becomes claimable again once its lease expires, which is
+// how work survives restarts.
+func (e *Engine) claim(ctx context.Context, worker string) (Workflow, error) {
+	now := time.Now().UTC()
+	filter := bson.D{
+		{"status", bson.D{{"$in", bson.A{StatusPending, StatusRunning}}}},
+		{"next_run_at", bson.D{{"$lte", now}}},
+		{"$or", bson.A{
+			bson.D{{"locked_until", bson.D{{"$exists", false}}}},
+			bson.D{{"locked_until", bson.D{{"$lt", now}}}},
+		}},
+	}
+	update := bson.D{{"$set", bson.D{
+		{"status", StatusRunning},
+		{"locked_by", worker},
+		{"locked_until", now.Add(e.Lease)},
+		{"updated_at", now},
+	}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{"next_run_at", 1}}).
+		SetReturnDocument(options.After)
+	var workflow Workflow
+	err := e.Workflows.FindOneAndUpdate(ctx, filter, update, opts).Decode(&workflow)
+	return workflow, err
+}
+
+// heartbeat extends the lease while a step is running so long steps are not
+// picked up by another worker
+func (e *Engine) heartbeat(ctx context.Context, worker string, id primitive.ObjectID) {
+	ticker := time.NewTicker(e.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := e.Workflows.UpdateOne(ctx,
+				bson.D{{"_id", id}, {"locked_by", worker}},
+				bson.D{{"$set", bson.D{{"locked_until", time.Now().UTC().Add(e.Lease)}}}},
+			)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("%s: extend lease on %s: %v", worker, id.Hex(), err)
+			}
+		}
+	}
+}
+
+// advance runs the current step and records its outcome. Every update is
+// conditional on still holding the lease, so a worker that lost its lease
+// cannot overwrite progress made by another one.
+func (e *Engine) advance(ctx context.Context, worker string, workflow Workflow) error {
+	definition, ok := e.Definitions[workflow.Type]
+	if !ok {
+		return fmt.Errorf("unknown workflow type %q", workflow.Type)
+	}
+	step, ok := definition.Steps[workflow.Step]
+	if !ok {
+		return e.record(ctx, worker, workflow, bson.D{{"status", StatusFailed}},
+			Transition{Step: workflow.Step, Error: "unknown step"})
+	}
+
+	if workflow.Data == nil {
+		workflow.Data = bson.M{}
+	}
+	stepCtx, cancel := context.WithCancel(ctx)
+	go e.heartbeat(stepCtx, worker, workflow.ID)
+	started := time.Now()
+	next, err := step(stepCtx, &workflow)
+	cancel()
+	transition := Transition{Step: workflow.Step, At: started.UTC(), Duration: time.Since(started)}
+
+	if err != nil {
+		transition.Error = err.Error()
+		retries := workflow.Retries + 1
+		if retries > definition.MaxRetries {
+			return e.record(ctx, worker, workflow, bson.D{{"status", StatusFailed}, {"retries", retries}}, transition)
+		}
+		delay := e.BaseDelay << (retries - 1)
+		return e.record(ctx, worker, workflow, bson.D{
+			{"status", StatusPending},
+			{"retries", retries},
+			{"next_run_at", time.Now().UTC().Add(delay)},
+		}, transition)
+	}
+
+	set := bson.D{
+		{"step", next},
+		{"retries", 0},
+		{"data", workflow.Data},
+		{"next_run_at", time.Now().UTC()},
+	}
+	if next == "" {
+		set = append(set, bson.E{"status", StatusCompleted})
+	} else {
+		set = append(set, bson.E{"status", StatusPending})
+	}
+	return e.record(ctx, worker, workflow, set, transition)
+}
+
+func (e *Engine) record(ctx context.Context, worker string, workflow Workflow, set bson.D, transition Transition) error {
+	transition.Worker = worker
+	set = append(set, bson.E{"updated_at", time.Now().UTC()})
+	result, err := e.Workflows.UpdateOne(ctx,
+		bson.D{{"_id", workflow.ID}, {"locked_by", worker}, {"step", workflow.Step}},
+		bson.D{
+			{"$set", set},
+			{"$push", bson.D{{"history", transition}}},
+			{"$unset", bson.D{{"locked_by", ""}, {"locked_until", ""}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("lease on step %q was lost before its result was recorded", workflow.Step)
+	}
+	return nil
+}
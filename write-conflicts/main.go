@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mongodb-developer/golang-quickstart/internal/db"
+	"github.com/mongodb-developer/golang-quickstart/internal/shutdown"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Metrics are published with expvar and served on /debug/vars
+var (
+	attempts        = expvar.NewInt("transaction_attempts")
+	commits         = expvar.NewInt("transaction_commits")
+	aborts          = expvar.NewInt("transaction_aborts")
+	writeConflicts  = expvar.NewInt("transaction_write_conflicts")
+	transientErrors = expvar.NewInt("transaction_transient_retries")
+	commitRetries   = expvar.NewInt("transaction_commit_retries")
+	failures        = expvar.NewInt("transaction_failures")
+	commitLatencyMS = expvar.NewFloat("transaction_commit_latency_ms_total")
+)
+
+// WriteConflict is the server error code returned when two transactions
+// modify the same document
+const WriteConflict = 112
+
+// Error labels telling the client what can safely be retried
+const (
+	transientLabel     = "TransientTransactionError"
+	unknownCommitLabel = "UnknownTransactionCommitResult"
+)
+
+func hasErrorLabel(err error, label string) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorLabel(label)
+}
+
+func isWriteConflict(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(WriteConflict)
+}
+
+// transfer moves one unit from the "hot" counter to the worker's own counter.
+// Every worker updates the hot document, so concurrent transactions conflict.
+// The sleep widens the window between the read and the write.
+func transfer(sessionContext mongo.SessionContext, counters *mongo.Collection, worker string, hold time.Duration) error {
+	var hot struct {
+		Value int64 `bson:"value"`
+	}
+	if err := counters.FindOne(sessionContext, bson.D{{"_id", "hot"}}).Decode(&hot); err != nil {
+		return err
+	}
+	time.Sleep(hold)
+	if _, err := counters.UpdateOne(sessionContext, bson.D{{"_id", "hot"}}, bson.D{{"$set", bson.D{{"value", hot.Value - 1}}}}); err != nil {
+		return err
+	}
+	_, err := counters.UpdateOne(sessionContext, bson.D{{"_id", worker}}, bson.D{{"$inc", bson.D{{"value", 1}}}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// runTransaction starts, runs and commits one transaction, retrying the whole
+// transaction on TransientTransactionError and only the commit on
+// UnknownTransactionCommitResult. This is what WithTransaction does
+// internally; spelling it out lets every abort and retry be counted.
+func runTransaction(ctx context.Context, session mongo.Session, fn func(mongo.SessionContext) error) error {
+	txnOptions := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+	return mongo.WithSession(ctx, session, func(sessionContext mongo.SessionContext) error {
+		for {
+			attempts.Add(1)
+			if err := session.StartTransaction(txnOptions); err != nil {
+				return err
+			}
+			err := fn(sessionContext)
+			if err != nil {
+				session.AbortTransaction(context.Background())
+				aborts.Add(1)
+				if isWriteConflict(err) {
+					writeConflicts.Add(1)
+				}
+				if hasErrorLabel(err, transientLabel) && ctx.Err() == nil {
+					transientErrors.Add(1)
+					continue
+				}
+				return err
+			}
+			for {
+				started := time.Now()
+				err = session.CommitTransaction(sessionContext)
+				commitLatencyMS.Add(float64(time.Since(started).Microseconds()) / 1000)
+				if err == nil {
+					commits.Add(1)
+					return nil
+				}
+				if hasErrorLabel(err, unknownCommitLabel) && ctx.Err() == nil {
+					commitRetries.Add(1)
+					continue
+				}
+				break
+			}
+			aborts.Add(1)
+			if hasErrorLabel(err, transientLabel) && ctx.Err() == nil {
+				transientErrors.Add(1)
+				continue
+			}
+			return err
+		}
+	})
+}
+
+var (
+	workers  = flag.Int("workers", 4, "concurrent transactions")
+	duration = flag.Duration("duration", 30*time.Second, "how long to generate conflicts")
+	hold     = flag.Duration("hold", 20*time.Millisecond, "time between a transaction's read and write")
+	addr     = flag.String("addr", "localhost:8080", "address serving metrics on /debug/vars")
+)
+
+func main() {
+	db.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	shutdown.Main(run)
+}
+
+func run(ctx context.Context, down *shutdown.Shutdown) error {
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := db.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	down.Client(client)
+
+	counters := client.Database("quickstart").Collection("conflict_counters")
+	if _, err = counters.DeleteMany(connectCtx, bson.D{}); err != nil {
+		return err
+	}
+	const initial = 1_000_000
+	if _, err = counters.InsertOne(connectCtx, bson.D{{"_id", "hot"}, {"value", initial}}); err != nil {
+		return err
+	}
+
+	go func() {
+		log.Printf("metrics on http://%s/debug/vars", *addr)
+		if err := http.ListenAndServe(*addr, nil); err != nil {
+			log.Printf("metrics server: %v", err)
+		}
+	}()
+
+	runCtx, stop := context.WithTimeout(ctx, *duration)
+	defer stop()
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			session, err := client.StartSession()
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			defer session.EndSession(context.Background())
+			for runCtx.Err() == nil {
+				err := runTransaction(runCtx, session, func(sessionContext mongo.SessionContext) error {
+					return transfer(sessionContext, counters, worker, *hold)
+				})
+				if err != nil && runCtx.Err() == nil {
+					failures.Add(1)
+					log.Printf("%s: %v", worker, err)
+				}
+			}
+		}(fmt.Sprintf("worker-%d", i))
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for waiting := true; waiting; {
+		select {
+		case <-done:
+			waiting = false
+		case <-ticker.C:
+			fmt.Printf("attempts=%v commits=%v aborts=%v write_conflicts=%v\n", attempts, commits, aborts, writeConflicts)
+		}
+	}
+
+	fmt.Printf("\nattempts:        %v\n", attempts)
+	fmt.Printf("commits:         %v\n", commits)
+	fmt.Printf("aborts:          %v\n", aborts)
+	fmt.Printf("write conflicts: %v\n", writeConflicts)
+	fmt.Printf("txn retries:     %v\n", transientErrors)
+	fmt.Printf("commit retries:  %v\n", commitRetries)
+	fmt.Printf("failures:        %v\n", failures)
+	if n := commits.Value(); n > 0 {
+		fmt.Printf("avg commit:      %.2fms\n", commitLatencyMS.Value()/float64(n))
+	}
+
+	// Every commit moved exactly one unit, so the totals must still add up
+	var hot struct {
+		Value int64 `bson:"value"`
+	}
+	checkCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = counters.FindOne(checkCtx, bson.D{{"_id", "hot"}}).Decode(&hot); err != nil {
+		return err
+	}
+	fmt.Printf("hot counter:     %v (expected %v)\n", hot.Value, initial-commits.Value())
+	return nil
+}